@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"simplebill/internal/config"
+	"simplebill/internal/invoice"
+	"simplebill/internal/money"
+)
+
+func printCreditHelp() {
+	fmt.Println("Usage: simplebill credit <invoice-number> [product:qty]... [-y]")
+	fmt.Println()
+	fmt.Println("Issue a credit note against an invoice. Without products, everything")
+	fmt.Println("not yet credited is credited in full.")
+	fmt.Println()
+	fmt.Println("Arguments:")
+	fmt.Println("  invoice-number   Invoice to credit (e.g., INV-2025-0001)")
+	fmt.Println("  product:qty      Product key and quantity to credit (e.g., widget:2)")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  -y, --yes    Skip preview and save immediately")
+	fmt.Println("  -h, --help   Show this help message")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  simplebill credit INV-2025-0001")
+	fmt.Println("  simplebill credit INV-2025-0001 widget:2 -y")
+}
+
+func RunCredit(args []string) error {
+	skipPreview := false
+	var filteredArgs []string
+	for _, arg := range args {
+		if arg == "-y" || arg == "--yes" {
+			skipPreview = true
+		} else if arg == "-h" || arg == "--help" {
+			printCreditHelp()
+			return nil
+		} else {
+			filteredArgs = append(filteredArgs, arg)
+		}
+	}
+	args = filteredArgs
+
+	if len(args) < 1 {
+		printCreditHelp()
+		return nil
+	}
+
+	var lines []invoice.CreditLine
+	for _, arg := range args[1:] {
+		parts := strings.Split(arg, ":")
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid format '%s', expected product:qty", arg)
+		}
+		qty, err := money.ParseDecimal(parts[1])
+		if err != nil || qty <= 0 {
+			return fmt.Errorf("invalid quantity '%s' for product '%s'", parts[1], parts[0])
+		}
+		lines = append(lines, invoice.CreditLine{Product: parts[0], Quantity: qty})
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	customers, err := config.LoadCustomers()
+	if err != nil {
+		return err
+	}
+
+	products, err := config.LoadProducts()
+	if err != nil {
+		return err
+	}
+
+	existing, err := invoice.LoadAll()
+	if err != nil {
+		return err
+	}
+
+	original, err := invoice.Load(args[0], existing)
+	if err != nil {
+		return err
+	}
+
+	customer, err := customerFor(original, customers)
+	if err != nil {
+		return err
+	}
+
+	rounding, err := cfg.Rounding()
+	if err != nil {
+		return err
+	}
+
+	number, err := invoice.NextCreditNoteNumber(cfg)
+	if err != nil {
+		return fmt.Errorf("generating credit note number: %w", err)
+	}
+
+	cn, err := invoice.NewCreditNote(original, number, lines, existing, rounding, time.Now())
+	if err != nil {
+		return err
+	}
+	cn.CompanyInfo = &cfg.Company
+
+	saved, err := previewAndSave(cn, cfg, customer, products, skipPreview)
+	if err != nil || !saved {
+		return err
+	}
+
+	config.AutoCommit(fmt.Sprintf("simplebill: created credit note %s for %s", number, original.InvoiceNumber))
+	return nil
+}
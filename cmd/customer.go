@@ -0,0 +1,581 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"simplebill/internal/config"
+	"simplebill/internal/invoice"
+)
+
+func printCustomerHelp() {
+	fmt.Println("Usage: simplebill customer <command>")
+	fmt.Println()
+	fmt.Println("Manage customers.yml without editing it by hand. Comments and the order")
+	fmt.Println("of entries in the file are kept.")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  add <key> [options]           Add a customer, asking for each detail without options")
+	fmt.Println("  edit <key> [options]          Change the given details, or ask for each one")
+	fmt.Println("  show <key>                    Show all details of a customer")
+	fmt.Println("  remove <key> [-y] [--force]   Remove a customer")
+	fmt.Println("  rename <key> <new-key>        Rename a customer, also in invoices and schedules")
+	fmt.Println()
+	fmt.Println("Options for add and edit (\"-\" clears a value):")
+	for _, f := range customerFields {
+		fmt.Printf("  --%-26s %s\n", f.flag+" "+f.arg, f.help)
+	}
+	fmt.Println("  -h, --help                   Show this help message")
+	fmt.Println()
+	fmt.Println("late_fees are set in customers.yml by hand and kept by edit.")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  simplebill customer add acme")
+	fmt.Println("  simplebill customer add acme --name \"Acme Corp\" --address \"456 Oak Ave\\nDenver, CO 80202\" --email billing@acme.com")
+	fmt.Println("  simplebill customer edit acme --currency EUR --cc accounts@acme.com")
+	fmt.Println("  simplebill customer rename acme acme-corp")
+}
+
+// customerField is a customer detail that can be given as an option or
+// asked for
+type customerField struct {
+	flag, arg, help string
+	prompt          string
+	get             func(c *config.Customer) string
+	set             func(c *config.Customer, value string)
+	multiline       bool
+}
+
+// stringField describes a plain text field of the customer
+func stringField(flag, arg, prompt, help string, field func(c *config.Customer) *string) customerField {
+	return customerField{
+		flag: flag, arg: arg, prompt: prompt, help: help,
+		get: func(c *config.Customer) string { return *field(c) },
+		set: func(c *config.Customer, value string) { *field(c) = value },
+	}
+}
+
+// listField describes a comma-separated list of email addresses
+func listField(flag, prompt, help string, field func(c *config.Customer) *[]string) customerField {
+	return customerField{
+		flag: flag, arg: "ADDRS", prompt: prompt, help: help,
+		get: func(c *config.Customer) string { return strings.Join(*field(c), ", ") },
+		set: func(c *config.Customer, value string) {
+			var list []string
+			for _, s := range strings.Split(value, ",") {
+				if s = strings.TrimSpace(s); s != "" {
+					list = append(list, s)
+				}
+			}
+			*field(c) = list
+		},
+	}
+}
+
+var customerFields = []customerField{
+	stringField("name", "NAME", "Name", "Name on invoices (required)", func(c *config.Customer) *string { return &c.Name }),
+	func() customerField {
+		f := stringField("address", "ADDR", "Address", "Postal address, lines split by \\n (required)", func(c *config.Customer) *string { return &c.Address })
+		f.multiline = true
+		return f
+	}(),
+	stringField("email", "EMAIL", "Email", "Address invoices are sent to", func(c *config.Customer) *string { return &c.Email }),
+	stringField("phone", "PHONE", "Phone", "Phone number", func(c *config.Customer) *string { return &c.Phone }),
+	stringField("id", "ID", "VAT or customer ID", "VAT or customer ID", func(c *config.Customer) *string { return &c.ID }),
+	stringField("country", "CODE", "Country code", "ISO 3166 country code, e.g. DE", func(c *config.Customer) *string { return &c.Country }),
+	stringField("currency", "CODE", "Currency", "Currency to bill in, e.g. EUR", func(c *config.Customer) *string { return &c.Currency }),
+	stringField("tax", "TAX", "Tax (exempt, reverse-charge or a tax_rates key)", "exempt, reverse-charge or a tax_rates key", func(c *config.Customer) *string { return &c.Tax }),
+	stringField("einvoice", "FORMAT", "E-invoice format (facturx, ubl or xrechnung)", "facturx, ubl or xrechnung", func(c *config.Customer) *string { return &c.EInvoice }),
+	stringField("einvoice-profile", "PROFILE", "Factur-X profile", "Factur-X profile", func(c *config.Customer) *string { return &c.EInvoiceProfile }),
+	stringField("peppol-id", "ID", "Peppol ID", "Peppol ID as scheme:id", func(c *config.Customer) *string { return &c.PeppolID }),
+	stringField("buyer-reference", "REF", "Buyer reference", "The customer's reference, e.g. a PO", func(c *config.Customer) *string { return &c.BuyerReference }),
+	stringField("leitweg-id", "ID", "Leitweg-ID", "Leitweg-ID for XRechnung", func(c *config.Customer) *string { return &c.LeitwegID }),
+	stringField("payment-qr", "QR", "Payment QR code (qr-bill, girocode or none)", "qr-bill, girocode or none", func(c *config.Customer) *string { return &c.PaymentQR }),
+	listField("cc", "Cc (comma-separated)", "Copied on emailed invoices", func(c *config.Customer) *[]string { return &c.Cc }),
+	listField("bcc", "Bcc (comma-separated)", "Blind-copied on emailed invoices", func(c *config.Customer) *[]string { return &c.Bcc }),
+}
+
+func RunCustomer(args []string) error {
+	if len(args) == 0 {
+		printCustomerHelp()
+		return nil
+	}
+
+	switch args[0] {
+	case "-h", "--help":
+		printCustomerHelp()
+		return nil
+	case "add":
+		return saveCustomer(args[1:], true)
+	case "edit":
+		return saveCustomer(args[1:], false)
+	case "show":
+		return showCustomer(args[1:])
+	case "remove":
+		return removeCustomer(args[1:])
+	case "rename":
+		return renameCustomer(args[1:])
+	default:
+		return fmt.Errorf("unknown customer command '%s'. Use: add, edit, show, remove, rename", args[0])
+	}
+}
+
+// saveCustomer adds a customer or edits an existing one
+func saveCustomer(args []string, add bool) error {
+	var key string
+	values := map[string]string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "-h" || arg == "--help" {
+			printCustomerHelp()
+			return nil
+		}
+		if strings.HasPrefix(arg, "--") {
+			name, value, hasValue := strings.Cut(arg[2:], "=")
+			if findCustomerField(name) == nil {
+				return fmt.Errorf("unknown option '%s'", arg)
+			}
+			if !hasValue {
+				if i+1 >= len(args) {
+					return fmt.Errorf("%s requires a value", arg)
+				}
+				i++
+				value = args[i]
+			}
+			values[name] = value
+			continue
+		}
+		if key != "" {
+			return fmt.Errorf("unexpected argument '%s'", arg)
+		}
+		key = arg
+	}
+	if key == "" {
+		printCustomerHelp()
+		return nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	customers, err := config.LoadCustomers()
+	if err != nil {
+		return err
+	}
+	path, err := config.CustomersPath()
+	if err != nil {
+		return err
+	}
+	file, err := config.OpenYAML(path)
+	if err != nil {
+		return err
+	}
+
+	customer, exists := customers[key]
+	if add {
+		if err := config.ValidateKey(key); err != nil {
+			return err
+		}
+		if exists || file.Has(key) {
+			return fmt.Errorf("customer '%s' already exists, use 'simplebill customer edit %s'", key, key)
+		}
+	} else if !exists {
+		return fmt.Errorf("customer '%s' not found in customers.yml", key)
+	}
+
+	if len(values) == 0 {
+		if err := promptCustomer(&customer); err != nil {
+			return err
+		}
+	}
+	for name, value := range values {
+		if value == "-" {
+			value = ""
+		}
+		f := findCustomerField(name)
+		if f.multiline {
+			value = strings.ReplaceAll(value, `\n`, "\n")
+		}
+		f.set(&customer, strings.TrimSpace(value))
+	}
+	if customer.Address != "" && !strings.HasSuffix(customer.Address, "\n") {
+		customer.Address += "\n"
+	}
+
+	if err := checkCustomer(cfg, customer); err != nil {
+		return fmt.Errorf("customer '%s': %w", key, err)
+	}
+	if err := file.Set(key, customer); err != nil {
+		return err
+	}
+	if err := file.Save(); err != nil {
+		return err
+	}
+
+	if add {
+		fmt.Printf("Added customer %s\n", key)
+		config.AutoCommit(fmt.Sprintf("simplebill: added customer %s", key))
+	} else {
+		fmt.Printf("Updated customer %s\n", key)
+		config.AutoCommit(fmt.Sprintf("simplebill: updated customer %s", key))
+	}
+	return nil
+}
+
+func findCustomerField(flag string) *customerField {
+	for i := range customerFields {
+		if customerFields[i].flag == flag {
+			return &customerFields[i]
+		}
+	}
+	return nil
+}
+
+// promptCustomer asks for every field, showing the current value, which
+// Enter keeps and "-" clears
+func promptCustomer(customer *config.Customer) error {
+	reader := bufio.NewReader(os.Stdin)
+	for _, f := range customerFields {
+		current := f.get(customer)
+		if !f.multiline {
+			value, err := promptValue(reader, f.prompt, current)
+			if err != nil {
+				return err
+			}
+			f.set(customer, value)
+			continue
+		}
+
+		fmt.Printf("%s, end with an empty line", f.prompt)
+		if current != "" {
+			fmt.Printf(" [%s]", strings.ReplaceAll(strings.TrimSpace(current), "\n", ", "))
+		}
+		fmt.Print(":\n")
+		var lines []string
+		for {
+			line, err := readInput(reader)
+			if err != nil {
+				return err
+			}
+			if line == "" {
+				break
+			}
+			lines = append(lines, line)
+		}
+		if len(lines) == 1 && lines[0] == "-" {
+			f.set(customer, "")
+		} else if len(lines) > 0 {
+			f.set(customer, strings.Join(lines, "\n"))
+		}
+	}
+	return nil
+}
+
+// promptValue asks for a value, showing the current one, which Enter keeps
+// and "-" clears
+func promptValue(reader *bufio.Reader, label, current string) (string, error) {
+	if current != "" {
+		fmt.Printf("%s [%s]: ", label, current)
+	} else {
+		fmt.Printf("%s: ", label)
+	}
+	line, err := readInput(reader)
+	if err != nil {
+		return "", err
+	}
+	switch line {
+	case "":
+		return current, nil
+	case "-":
+		return "", nil
+	}
+	return line, nil
+}
+
+// readInput reads a line typed at a prompt
+func readInput(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("no input, give the details as options instead")
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// checkCustomer validates a customer, including the settings that refer to
+// config.yml
+func checkCustomer(cfg *config.Config, customer config.Customer) error {
+	if err := customer.Validate(); err != nil {
+		return err
+	}
+	if customer.Tax != "" && customer.Tax != config.TaxExempt && customer.Tax != config.TaxReverseCharge {
+		if _, ok := cfg.TaxRates[customer.Tax]; !ok {
+			return fmt.Errorf("tax rate '%s' not found in tax_rates in config.yml", customer.Tax)
+		}
+	}
+	if _, err := einvoiceFormat(&customer); err != nil {
+		return err
+	}
+	if customer.EInvoiceProfile != "" {
+		if _, err := facturXProfile(cfg, &customer); err != nil {
+			return err
+		}
+	}
+	if _, err := paymentQR(cfg, &customer); err != nil {
+		return err
+	}
+	_, err := cfg.LateFeesFor(customer)
+	return err
+}
+
+func showCustomer(args []string) error {
+	if len(args) != 1 || args[0] == "-h" || args[0] == "--help" {
+		printCustomerHelp()
+		return nil
+	}
+	key := args[0]
+
+	customers, err := config.LoadCustomers()
+	if err != nil {
+		return err
+	}
+	customer, ok := customers[key]
+	if !ok {
+		return fmt.Errorf("customer '%s' not found in customers.yml", key)
+	}
+
+	fmt.Printf("%-18s %s\n", "key:", key)
+	for _, f := range customerFields {
+		value := f.get(&customer)
+		if value == "" {
+			continue
+		}
+		value = strings.ReplaceAll(strings.TrimSpace(value), "\n", "\n"+strings.Repeat(" ", 19))
+		fmt.Printf("%-18s %s\n", f.flag+":", value)
+	}
+	if customer.LateFees != nil {
+		fmt.Printf("%-18s %s\n", "late-fees:", customer.LateFees.Describe())
+	}
+
+	used, err := customerUse(key)
+	if err != nil {
+		return err
+	}
+	if len(used) > 0 {
+		fmt.Printf("%-18s %s\n", "used by:", strings.Join(used, ", "))
+	}
+	return nil
+}
+
+// customerUse describes the invoices, quotes and schedules that refer to
+// the customer key
+func customerUse(key string) ([]string, error) {
+	invoices, err := invoice.LoadAll()
+	if err != nil {
+		return nil, err
+	}
+	quotes, err := invoice.LoadQuotes()
+	if err != nil {
+		return nil, err
+	}
+	schedules, err := invoice.LoadSchedules()
+	if err != nil {
+		return nil, err
+	}
+	expenses, err := invoice.LoadExpenses()
+	if err != nil {
+		return nil, err
+	}
+
+	var used []string
+	count := func(docs []invoice.Invoice, what string) {
+		n := 0
+		for _, d := range docs {
+			if d.Customer == key {
+				n++
+			}
+		}
+		if n > 0 {
+			used = append(used, fmt.Sprintf("%d %s", n, what))
+		}
+	}
+	count(invoices, "invoices")
+	count(quotes, "quotes")
+	for _, s := range schedules {
+		if s.Customer == key {
+			used = append(used, "schedule "+s.Name)
+		}
+	}
+	n := 0
+	for _, e := range expenses {
+		if e.Customer == key {
+			n++
+		}
+	}
+	if n > 0 {
+		used = append(used, fmt.Sprintf("%d expenses", n))
+	}
+	return used, nil
+}
+
+func removeCustomer(args []string) error {
+	var key string
+	var confirmed, force bool
+	for _, arg := range args {
+		if arg == "--confirm" || arg == "-y" {
+			confirmed = true
+		} else if arg == "--force" {
+			force = true
+		} else if arg == "-h" || arg == "--help" {
+			printCustomerHelp()
+			return nil
+		} else {
+			key = arg
+		}
+	}
+	if key == "" {
+		printCustomerHelp()
+		return nil
+	}
+
+	path, err := config.CustomersPath()
+	if err != nil {
+		return err
+	}
+	file, err := config.OpenYAML(path)
+	if err != nil {
+		return err
+	}
+	if !file.Has(key) {
+		return fmt.Errorf("customer '%s' not found in customers.yml", key)
+	}
+
+	// Invoices keep a copy of the details, but schedules and older invoices
+	// look the customer up
+	used, err := customerUse(key)
+	if err != nil {
+		return err
+	}
+	if len(used) > 0 && !force {
+		return fmt.Errorf("customer '%s' is used by %s; use --force to remove it anyway", key, strings.Join(used, ", "))
+	}
+
+	if !confirmed {
+		fmt.Printf("Remove customer %s? [y/N] ", key)
+		reader := bufio.NewReader(os.Stdin)
+		response, _ := reader.ReadString('\n')
+		response = strings.TrimSpace(strings.ToLower(response))
+		if response != "y" && response != "yes" {
+			fmt.Println("Cancelled.")
+			return nil
+		}
+	}
+
+	file.Remove(key)
+	if err := file.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Removed customer %s\n", key)
+	config.AutoCommit(fmt.Sprintf("simplebill: removed customer %s", key))
+	return nil
+}
+
+func renameCustomer(args []string) error {
+	if len(args) != 2 {
+		printCustomerHelp()
+		return nil
+	}
+	oldKey, newKey := args[0], args[1]
+	if err := config.ValidateKey(newKey); err != nil {
+		return err
+	}
+
+	path, err := config.CustomersPath()
+	if err != nil {
+		return err
+	}
+	file, err := config.OpenYAML(path)
+	if err != nil {
+		return err
+	}
+	if !file.Has(oldKey) {
+		return fmt.Errorf("customer '%s' not found in customers.yml", oldKey)
+	}
+	if file.Has(newKey) {
+		return fmt.Errorf("customer '%s' already exists", newKey)
+	}
+
+	// Read every file that refers to customers before anything is changed,
+	// so a bad one stops the rename instead of being left behind
+	schedules, err := invoice.LoadSchedules()
+	if err != nil {
+		return err
+	}
+	docs, err := invoice.LoadDocuments()
+	if err != nil {
+		return err
+	}
+	expenses, err := invoice.LoadExpenses()
+	if err != nil {
+		return err
+	}
+
+	updated := 0
+	for i := range docs {
+		if docs[i].Customer != oldKey {
+			continue
+		}
+		docs[i].Customer = newKey
+		if err := docs[i].Save(); err != nil {
+			return err
+		}
+		updated++
+	}
+
+	// Schedules are written by hand, so keep their comments
+	dir, err := invoice.SchedulesDir()
+	if err != nil {
+		return err
+	}
+	for _, s := range schedules {
+		if s.Customer != oldKey {
+			continue
+		}
+		schedule, err := config.OpenYAML(filepath.Join(dir, s.Name+".yml"))
+		if err != nil {
+			return err
+		}
+		if err := schedule.Set("customer", newKey); err != nil {
+			return err
+		}
+		if err := schedule.Save(); err != nil {
+			return err
+		}
+		updated++
+	}
+	for _, e := range expenses {
+		if e.Customer != oldKey {
+			continue
+		}
+		e.Customer = newKey
+		if err := e.Save(); err != nil {
+			return err
+		}
+		updated++
+	}
+
+	// customers.yml goes last, so after a failure above the customer still
+	// has its old key and running the rename again finishes the job
+	file.Rename(oldKey, newKey)
+	if err := file.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Renamed customer %s to %s (%d files updated)\n", oldKey, newKey, updated)
+	config.AutoCommit(fmt.Sprintf("simplebill: renamed customer %s to %s", oldKey, newKey))
+	return nil
+}
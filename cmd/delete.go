@@ -8,23 +8,28 @@ import (
 	"strings"
 
 	"simplebill/internal/config"
+	"simplebill/internal/invoice"
 )
 
 func printDeleteHelp() {
-	fmt.Println("Usage: simplebill delete <invoice-number> [--confirm]")
+	fmt.Println("Usage: simplebill delete <invoice-number> [--confirm] [--force]")
 	fmt.Println()
-	fmt.Println("Delete an invoice (both .yml and .pdf files).")
+	fmt.Println("Delete a draft invoice (both .yml and .pdf files). Invoices that have")
+	fmt.Println("been sent must be corrected with a credit note instead.")
 	fmt.Println()
 	fmt.Println("Arguments:")
 	fmt.Println("  invoice-number   Invoice number to delete (e.g., INV-2025-0001)")
 	fmt.Println()
 	fmt.Println("Options:")
 	fmt.Println("  -y, --confirm    Skip confirmation prompt")
+	fmt.Println("  --force          Delete even if the invoice is no longer a draft, or has")
+	fmt.Println("                   payments or credit notes")
 	fmt.Println("  -h, --help       Show this help message")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  simplebill delete INV-2025-0001")
 	fmt.Println("  simplebill delete INV-2025-0001 --confirm")
+	fmt.Println("  simplebill delete INV-2025-0001 --force")
 }
 
 func RunDelete(args []string) error {
@@ -35,10 +40,13 @@ func RunDelete(args []string) error {
 
 	var invoiceNumber string
 	var confirmed bool
+	var force bool
 
 	for _, arg := range args {
 		if arg == "--confirm" || arg == "-y" {
 			confirmed = true
+		} else if arg == "--force" {
+			force = true
 		} else if arg == "-h" || arg == "--help" {
 			printDeleteHelp()
 			return nil
@@ -60,10 +68,30 @@ func RunDelete(args []string) error {
 	invoicesDir := filepath.Join(dir, "invoices")
 	ymlPath := filepath.Join(invoicesDir, invoiceNumber+".yml")
 	pdfPath := filepath.Join(invoicesDir, invoiceNumber+".pdf")
+	xmlPath := filepath.Join(invoicesDir, invoiceNumber+".xml")
+
+	invoices, err := invoice.LoadAll()
+	if err != nil {
+		return err
+	}
 
 	// Check if invoice exists
-	if _, err := os.Stat(ymlPath); os.IsNotExist(err) {
-		return fmt.Errorf("invoice %s not found", invoiceNumber)
+	inv, err := invoice.Load(invoiceNumber, invoices)
+	if err != nil {
+		return err
+	}
+
+	// Issued invoices must stay on record
+	if status := inv.CurrentStatus(); status != invoice.StatusDraft && !force {
+		return fmt.Errorf("invoice %s is %s; issue a credit note with 'simplebill credit %s' or use --force",
+			invoiceNumber, status, invoiceNumber)
+	}
+	// Payments and credit notes would be left pointing at nothing
+	if len(inv.Payments) > 0 && !force {
+		return fmt.Errorf("invoice %s has payments recorded; use --force to delete it anyway", invoiceNumber)
+	}
+	if inv.Credited != 0 && !force {
+		return fmt.Errorf("invoice %s has been credited; use --force to delete it anyway", invoiceNumber)
 	}
 
 	// Prompt for confirmation if not already confirmed
@@ -90,6 +118,31 @@ func RunDelete(args []string) error {
 		}
 	}
 
+	// Delete e-invoice XML file if it exists
+	if _, err := os.Stat(xmlPath); err == nil {
+		if err := os.Remove(xmlPath); err != nil {
+			return fmt.Errorf("deleting XML: %w", err)
+		}
+	}
+
+	// Delete payment reminders issued for it
+	reminders, _ := filepath.Glob(filepath.Join(invoicesDir, invoiceNumber+"-reminder-*.pdf"))
+	for _, path := range reminders {
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("deleting reminder: %w", err)
+		}
+	}
+
+	// The quote can be accepted again once its invoice is gone
+	if inv.Quote != "" {
+		if quote, err := invoice.LoadQuote(inv.Quote); err == nil && quote.AcceptedAs == invoiceNumber {
+			quote.AcceptedAs = ""
+			if err := quote.Save(); err != nil {
+				return fmt.Errorf("updating quote %s: %w", quote.InvoiceNumber, err)
+			}
+		}
+	}
+
 	fmt.Printf("Deleted %s\n", invoiceNumber)
 	config.AutoCommit(fmt.Sprintf("simplebill: deleted invoice %s", invoiceNumber))
 
@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"simplebill/internal/config"
+	"simplebill/internal/invoice"
+)
+
+func printEditHelp() {
+	fmt.Println("Usage: simplebill edit <invoice-number> [--force]")
+	fmt.Println()
+	fmt.Println("Open a saved invoice in $EDITOR, then validate it, recompute totals")
+	fmt.Println("and re-render the PDF.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --force      Edit even if the invoice is no longer a draft")
+	fmt.Println("  -h, --help   Show this help message")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  simplebill edit INV-2025-0001")
+	fmt.Println("  EDITOR=nano simplebill edit INV-2025-0001")
+}
+
+func RunEdit(args []string) error {
+	var number string
+	var force bool
+	for _, arg := range args {
+		if arg == "--force" {
+			force = true
+		} else if arg == "-h" || arg == "--help" {
+			printEditHelp()
+			return nil
+		} else {
+			number = arg
+		}
+	}
+
+	if number == "" {
+		printEditHelp()
+		return nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	invoices, err := invoice.LoadAll()
+	if err != nil {
+		return err
+	}
+	inv, err := invoice.Load(number, invoices)
+	if err != nil {
+		return err
+	}
+	if status := inv.CurrentStatus(); status != invoice.StatusDraft && !force {
+		return fmt.Errorf("invoice %s is %s; issue a credit note instead or use --force", number, status)
+	}
+
+	path, err := invoice.Path(number)
+	if err != nil {
+		return err
+	}
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading invoice: %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp("", "simplebill-"+number+"-*.yml")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.Write(original); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("writing temp file: %w", err)
+	}
+	tmpFile.Close()
+
+	var edited invoice.Invoice
+	for {
+		if err := runEditor(tmpFile.Name()); err != nil {
+			return err
+		}
+
+		data, err := os.ReadFile(tmpFile.Name())
+		if err != nil {
+			return fmt.Errorf("reading temp file: %w", err)
+		}
+		if bytes.Equal(data, original) {
+			fmt.Println("No changes.")
+			return nil
+		}
+
+		edited = invoice.Invoice{}
+		err = yaml.Unmarshal(data, &edited)
+		if err == nil {
+			err = edited.Validate()
+		}
+		if err == nil && edited.InvoiceNumber != number {
+			err = fmt.Errorf("invoice_number can't be changed")
+		}
+		edited.Credited = inv.Credited
+		if err == nil {
+			break
+		}
+
+		fmt.Printf("Invalid invoice: %s\n", err)
+		fmt.Print("Edit again? [Y/n]: ")
+		reader := bufio.NewReader(os.Stdin)
+		response, _ := reader.ReadString('\n')
+		response = strings.TrimSpace(strings.ToLower(response))
+		if response == "n" || response == "no" {
+			fmt.Println("Edit cancelled.")
+			return nil
+		}
+	}
+
+	rounding, err := cfg.Rounding()
+	if err != nil {
+		return err
+	}
+	if err := edited.Recalculate(rounding.In(edited.CurrencyInfo())); err != nil {
+		return err
+	}
+
+	customers, err := config.LoadCustomers()
+	if err != nil {
+		return err
+	}
+	products, err := config.LoadProducts()
+	if err != nil {
+		return err
+	}
+	customer, err := customerFor(&edited, customers)
+	if err != nil {
+		return err
+	}
+	if err := checkEInvoice(&edited, cfg, customer); err != nil {
+		return err
+	}
+	if err := checkPaymentQR(&edited, cfg, customer); err != nil {
+		return err
+	}
+
+	if err := edited.Save(); err != nil {
+		return err
+	}
+	if err := RenderPDF(&edited, cfg, customer, products, ""); err != nil {
+		return err
+	}
+	if _, err := saveEInvoiceXML(&edited, cfg, customer); err != nil {
+		return err
+	}
+
+	fmt.Printf("Updated %s (total %s)\n", number, edited.CurrencyInfo().Format(edited.Total))
+	config.AutoCommit(fmt.Sprintf("simplebill: edited invoice %s", number))
+	return nil
+}
+
+// runEditor opens path in $VISUAL or $EDITOR and waits for it to exit
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+
+	fields := strings.Fields(editor)
+	cmd := exec.Command(fields[0], append(fields[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running editor %s: %w", fields[0], err)
+	}
+	return nil
+}
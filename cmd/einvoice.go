@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"simplebill/internal/config"
+	"simplebill/internal/einvoice"
+	"simplebill/internal/invoice"
+	"simplebill/internal/pdf"
+)
+
+// einvoiceFormat returns the customer's einvoice setting, accepting the
+// other names Factur-X and UBL go by
+func einvoiceFormat(customer *config.Customer) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(customer.EInvoice)) {
+	case "":
+		return "", nil
+	case "facturx", "factur-x", "zugferd":
+		return einvoice.FacturX, nil
+	case "ubl", "peppol":
+		return einvoice.UBL, nil
+	case "xrechnung":
+		return einvoice.XRechnung, nil
+	}
+	return "", fmt.Errorf("unknown einvoice '%s' for customer '%s' in customers.yml, expected facturx, ubl or xrechnung", customer.EInvoice, customer.Name)
+}
+
+// facturXProfile returns the customer's Factur-X profile, falling back to
+// the default in config.yml
+func facturXProfile(cfg *config.Config, customer *config.Customer) (einvoice.Profile, error) {
+	profile := customer.EInvoiceProfile
+	if profile == "" {
+		profile = cfg.EInvoice.Profile
+	}
+	return einvoice.ParseProfile(profile)
+}
+
+// checkEInvoice validates inv for customers that get e-invoices
+func checkEInvoice(inv *invoice.Invoice, cfg *config.Config, customer *config.Customer) error {
+	format, err := einvoiceFormat(customer)
+	if err != nil || format == "" || inv.IsQuote() {
+		return err
+	}
+	doc := einvoice.FromInvoice(inv, cfg, *customer)
+	switch format {
+	case einvoice.UBL:
+		return doc.ValidatePeppol()
+	case einvoice.XRechnung:
+		return doc.ValidateXRechnung()
+	}
+	if err := checkFacturXReceipts(inv); err != nil {
+		return err
+	}
+	profile, err := facturXProfile(cfg, customer)
+	if err != nil {
+		return err
+	}
+	// Writing the XML validates it against the bundled schema too
+	_, err = einvoice.CII(doc, profile)
+	return err
+}
+
+// checkFacturXReceipts refuses PDF receipts on a Factur-X invoice. Their
+// pages are copied as they are, fonts, colour spaces and all, so the result
+// may no longer be valid PDF/A-3. Images are drawn by simplebill and are
+// fine.
+func checkFacturXReceipts(inv *invoice.Invoice) error {
+	var pdfs []string
+	for _, name := range inv.Receipts {
+		if strings.EqualFold(filepath.Ext(name), ".pdf") {
+			pdfs = append(pdfs, name)
+		}
+	}
+	if len(pdfs) > 0 {
+		return fmt.Errorf("PDF receipts (%s) can't be appended to Factur-X invoice %s without breaking PDF/A-3\n\nBill the expenses with --no-receipts, or add the receipts as JPEG or PNG", strings.Join(pdfs, ", "), inv.InvoiceNumber)
+	}
+	return nil
+}
+
+// warnedNotValidated is set once the warning below has been printed
+var warnedNotValidated bool
+
+// warnNotValidated reminds the user, once per run, that UBL and XRechnung
+// files only go through simplebill's own checks. Factur-X XML is validated
+// against the bundled schema and schematron instead.
+func warnNotValidated() {
+	if warnedNotValidated {
+		return
+	}
+	warnedNotValidated = true
+	fmt.Println("Warning: UBL and XRechnung files are checked against the common EN 16931 rules only, not the official schema and schematron. Have them validated before relying on them.")
+}
+
+// xmlPath is where the e-invoice XML for an invoice is kept, next to its PDF
+func xmlPath(number string) (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "invoices", number+".xml"), nil
+}
+
+// saveEInvoiceXML writes the UBL or XRechnung file next to the PDF for
+// customers that get one. It returns the path written, or "" for other
+// customers.
+func saveEInvoiceXML(inv *invoice.Invoice, cfg *config.Config, customer *config.Customer) (string, error) {
+	format, err := einvoiceFormat(customer)
+	if err != nil || inv.IsQuote() {
+		return "", err
+	}
+	var write func(*einvoice.Document) ([]byte, error)
+	switch format {
+	case einvoice.UBL:
+		write = einvoice.WriteUBL
+	case einvoice.XRechnung:
+		write = einvoice.WriteXRechnung
+	default:
+		return "", nil
+	}
+	data, err := write(einvoice.FromInvoice(inv, cfg, *customer))
+	if err != nil {
+		return "", err
+	}
+	path, err := xmlPath(inv.InvoiceNumber)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("writing %s: %w", path, err)
+	}
+	warnNotValidated()
+	return path, nil
+}
+
+// renderFacturX renders job as PDF/A-3 with the invoice XML embedded
+func renderFacturX(backend pdfBackend, job renderJob, cfg *config.Config, customer *config.Customer, outputPath string) error {
+	profile, err := facturXProfile(cfg, customer)
+	if err != nil {
+		return err
+	}
+
+	// Check the data and the XML before spending time on rendering
+	doc := einvoice.FromInvoice(job.inv, cfg, *customer)
+	if _, err := einvoice.CII(doc, profile); err != nil {
+		return err
+	}
+	if err := checkFacturXReceipts(job.inv); err != nil {
+		return err
+	}
+
+	// PDF/A needs embedded fonts, which the external renderers already do
+	if _, ok := backend.(builtinBackend); ok {
+		regular, bold, err := loadEmbedFonts(cfg)
+		if err != nil {
+			return err
+		}
+		job.fonts = [2]*pdf.Font{regular, bold}
+	}
+
+	tmpPDF, err := os.CreateTemp("", "simplebill-*.pdf")
+	if err != nil {
+		return fmt.Errorf("creating temp PDF: %w", err)
+	}
+	tmpPDF.Close()
+	defer os.Remove(tmpPDF.Name())
+
+	if err := backend.Render(job, tmpPDF.Name()); err != nil {
+		return err
+	}
+	rendered, err := os.ReadFile(tmpPDF.Name())
+	if err != nil {
+		return fmt.Errorf("reading rendered PDF: %w", err)
+	}
+	// Image receipts go before the conversion, which covers the whole file
+	if rendered, err = appendReceipts(job.inv, rendered); err != nil {
+		return err
+	}
+
+	out, err := einvoice.EmbedFacturX(rendered, doc, profile)
+	if err != nil {
+		return fmt.Errorf("creating Factur-X PDF: %w", err)
+	}
+	if err := os.WriteFile(outputPath, out, 0644); err != nil {
+		return fmt.Errorf("writing PDF: %w", err)
+	}
+	return nil
+}
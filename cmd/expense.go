@@ -0,0 +1,469 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"simplebill/internal/config"
+	"simplebill/internal/invoice"
+	"simplebill/internal/money"
+	"simplebill/internal/pdf"
+)
+
+func printExpenseHelp() {
+	fmt.Println("Usage: simplebill expense <command>")
+	fmt.Println()
+	fmt.Println("Record costs incurred on a customer's behalf, such as travel or")
+	fmt.Println("materials. The next invoice for the customer bills them as separate")
+	fmt.Println("lines. Expenses are kept in ~/.simplebill/expenses/.")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  add <customer> <amount> <description>   Record an expense")
+	fmt.Println("  list [customer]                         List unbilled expenses")
+	fmt.Println("  remove <id>                             Remove an unbilled expense")
+	fmt.Println()
+	fmt.Println("Options for add:")
+	fmt.Println("  --receipt FILE     PDF, JPEG or PNG receipt, copied into expenses/")
+	fmt.Println("  --markup PCT       Percentage added to the amount when billed")
+	fmt.Println("  --date YYYY-MM-DD  Date of the expense (default: today)")
+	fmt.Println("  --tax KEY          Tax rate from tax_rates in config.yml")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --all              List billed expenses too")
+	fmt.Println("  -y, --confirm      Remove without asking")
+	fmt.Println("  -h, --help         Show this help message")
+	fmt.Println()
+	fmt.Println("Expenses are in the customer's currency. Receipts are appended to the")
+	fmt.Println("invoice PDF with 'simplebill invoice --receipts', or always when")
+	fmt.Println("invoice.attach_receipts is set in config.yml.")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  simplebill expense add acme 412.50 \"Flight to Berlin\" --receipt ticket.pdf")
+	fmt.Println("  simplebill expense add acme 89.90 \"Cables\" --markup 10 --receipt scan.jpg")
+	fmt.Println("  simplebill expense list acme")
+	fmt.Println("  simplebill expense remove EXP-2025-0003")
+}
+
+func RunExpense(args []string) error {
+	if len(args) == 0 {
+		printExpenseHelp()
+		return nil
+	}
+
+	switch args[0] {
+	case "-h", "--help":
+		printExpenseHelp()
+		return nil
+	case "add":
+		return addExpense(args[1:])
+	case "list":
+		return listExpenses(args[1:])
+	case "remove":
+		return removeExpense(args[1:])
+	default:
+		return fmt.Errorf("unknown expense command '%s'. Use: add, list, remove", args[0])
+	}
+}
+
+func addExpense(args []string) error {
+	var receipt, markup, date, tax string
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-h", "--help":
+			printExpenseHelp()
+			return nil
+		case "--receipt", "--markup", "--date", "--tax":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s requires a value", arg)
+			}
+			i++
+			switch arg {
+			case "--receipt":
+				receipt = args[i]
+			case "--markup":
+				markup = args[i]
+			case "--date":
+				date = args[i]
+			case "--tax":
+				tax = args[i]
+			}
+		default:
+			positional = append(positional, arg)
+		}
+	}
+	if len(positional) < 3 {
+		printExpenseHelp()
+		return nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	customers, err := config.LoadCustomers()
+	if err != nil {
+		return err
+	}
+	customerKey := positional[0]
+	customer, ok := customers[customerKey]
+	if !ok {
+		return fmt.Errorf("customer '%s' not found in customers.yml", customerKey)
+	}
+	currency, err := cfg.CurrencyFor(customer)
+	if err != nil {
+		return fmt.Errorf("customer '%s': %w", customerKey, err)
+	}
+
+	amount, err := money.Parse(positional[1])
+	if err != nil || amount <= 0 {
+		return fmt.Errorf("invalid amount '%s', expected a positive amount such as 49.90", positional[1])
+	}
+	if currency.Decimals == 0 && amount%100 != 0 {
+		return fmt.Errorf("invalid amount '%s', %s has no minor units", positional[1], currency.Code)
+	}
+
+	e := invoice.Expense{
+		Customer:    customerKey,
+		Date:        time.Now().Format("2006-01-02"),
+		Description: strings.Join(positional[2:], " "),
+		Amount:      amount,
+		Currency:    currency.Code,
+		Tax:         tax,
+		CreatedAt:   time.Now(),
+	}
+	if date != "" {
+		day, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return fmt.Errorf("invalid date '%s', expected YYYY-MM-DD", date)
+		}
+		e.Date = day.Format("2006-01-02")
+	}
+	if markup != "" {
+		if e.Markup, err = money.ParseDecimal(strings.TrimSuffix(markup, "%")); err != nil || e.Markup < 0 {
+			return fmt.Errorf("invalid markup '%s', expected a percentage such as 10", markup)
+		}
+	}
+	if tax != "" {
+		if _, _, err := cfg.TaxRateFor(config.Customer{}, config.Product{Tax: tax}); err != nil {
+			return err
+		}
+	}
+
+	// Check the receipt before anything is written
+	var receiptData []byte
+	var receiptExt string
+	if receipt != "" {
+		if receiptData, err = os.ReadFile(receipt); err != nil {
+			return fmt.Errorf("reading receipt: %w", err)
+		}
+		if receiptExt = receiptType(receiptData); receiptExt == "" {
+			return fmt.Errorf("receipt %s is not a PDF, JPEG or PNG file", receipt)
+		}
+	}
+
+	if e.ID, err = invoice.NextExpenseID(); err != nil {
+		return fmt.Errorf("generating expense id: %w", err)
+	}
+	if receiptData != nil {
+		dir, err := invoice.ExpensesDir()
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("creating %s: %w", dir, err)
+		}
+		e.Receipt = e.ID + receiptExt
+		if err := os.WriteFile(filepath.Join(dir, e.Receipt), receiptData, 0644); err != nil {
+			return fmt.Errorf("writing receipt: %w", err)
+		}
+	}
+	if err := e.Save(); err != nil {
+		return err
+	}
+
+	price := currency.Format(e.Amount)
+	if e.Markup > 0 {
+		rounding, err := cfg.Rounding()
+		if err != nil {
+			return err
+		}
+		billed, err := rounding.In(currency).Round(e.Price())
+		if err != nil {
+			return err
+		}
+		price += fmt.Sprintf(" (%s with %s%% markup)", currency.Format(billed), e.Markup)
+	}
+	fmt.Printf("Added expense %s for %s: %s, %s\n", e.ID, customerKey, e.Description, price)
+	config.AutoCommit(fmt.Sprintf("simplebill: added expense %s", e.ID))
+	return nil
+}
+
+// receiptType returns the file extension for a receipt, or "" when the file
+// is not a kind that can be appended to an invoice
+func receiptType(data []byte) string {
+	if bytes.HasPrefix(data, []byte("%PDF")) {
+		return ".pdf"
+	}
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return ""
+	}
+	switch format {
+	case "jpeg":
+		return ".jpg"
+	case "png":
+		return ".png"
+	}
+	return ""
+}
+
+func listExpenses(args []string) error {
+	var customerKey string
+	var all bool
+	for _, arg := range args {
+		if arg == "--all" {
+			all = true
+		} else if arg == "-h" || arg == "--help" {
+			printExpenseHelp()
+			return nil
+		} else if customerKey == "" {
+			customerKey = arg
+		} else {
+			return fmt.Errorf("unexpected argument '%s'", arg)
+		}
+	}
+
+	expenses, err := invoice.LoadExpenses()
+	if err != nil {
+		return err
+	}
+	invoices, err := invoice.LoadAll()
+	if err != nil {
+		return err
+	}
+	billed := invoice.BilledExpenses(invoices)
+
+	var shown []invoice.Expense
+	for _, e := range expenses {
+		if customerKey != "" && e.Customer != customerKey {
+			continue
+		}
+		if _, ok := billed[e.ID]; ok && !all {
+			continue
+		}
+		shown = append(shown, e)
+	}
+	if len(shown) == 0 {
+		if all {
+			fmt.Println("No expenses.")
+		} else {
+			fmt.Println("No unbilled expenses.")
+		}
+		return nil
+	}
+
+	fmt.Printf("%-14s  %-10s  %-16s  %14s  %6s  %-13s  %s\n", "ID", "DATE", "CUSTOMER", "AMOUNT", "MARKUP", "INVOICE", "DESCRIPTION")
+	for _, e := range shown {
+		currency, err := money.LookupCurrency(e.Currency)
+		if err != nil {
+			return err
+		}
+		markup := "-"
+		if e.Markup > 0 {
+			markup = e.Markup.String() + "%"
+		}
+		number := billed[e.ID]
+		if number == "" {
+			number = "-"
+		}
+		description := e.Description
+		if e.Receipt != "" {
+			description += " [receipt]"
+		}
+		fmt.Printf("%-14s  %-10s  %-16s  %14s  %6s  %-13s  %s\n", e.ID, e.Date, e.Customer, currency.Format(e.Amount), markup, number, description)
+	}
+	return nil
+}
+
+func removeExpense(args []string) error {
+	var id string
+	var confirmed bool
+	for _, arg := range args {
+		if arg == "--confirm" || arg == "-y" {
+			confirmed = true
+		} else if arg == "-h" || arg == "--help" {
+			printExpenseHelp()
+			return nil
+		} else {
+			id = arg
+		}
+	}
+	if id == "" {
+		printExpenseHelp()
+		return nil
+	}
+
+	expenses, err := invoice.LoadExpenses()
+	if err != nil {
+		return err
+	}
+	var expense *invoice.Expense
+	for i := range expenses {
+		if expenses[i].ID == id {
+			expense = &expenses[i]
+		}
+	}
+	if expense == nil {
+		return fmt.Errorf("expense %s not found", id)
+	}
+
+	invoices, err := invoice.LoadAll()
+	if err != nil {
+		return err
+	}
+	if number, ok := invoice.BilledExpenses(invoices)[id]; ok {
+		return fmt.Errorf("expense %s is billed on %s; void the invoice first", id, number)
+	}
+
+	if !confirmed {
+		fmt.Printf("Remove expense %s (%s)? [y/N] ", id, expense.Description)
+		reader := bufio.NewReader(os.Stdin)
+		response, _ := reader.ReadString('\n')
+		response = strings.TrimSpace(strings.ToLower(response))
+		if response != "y" && response != "yes" {
+			fmt.Println("Cancelled.")
+			return nil
+		}
+	}
+
+	dir, err := invoice.ExpensesDir()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(dir, id+".yml")); err != nil {
+		return err
+	}
+	// A void invoice may still list the receipt, so it is only a warning
+	if path, _ := expense.ReceiptPath(); path != "" {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Warning: could not remove receipt: %v\n", err)
+		}
+	}
+
+	fmt.Printf("Removed expense %s\n", id)
+	config.AutoCommit(fmt.Sprintf("simplebill: removed expense %s", id))
+	return nil
+}
+
+// unbilledExpenses returns the customer's expenses that are on no invoice
+// yet, or on void ones only
+func unbilledExpenses(customerKey string) ([]invoice.Expense, error) {
+	expenses, err := invoice.LoadExpenses()
+	if err != nil {
+		return nil, err
+	}
+	if len(expenses) == 0 {
+		return nil, nil
+	}
+	invoices, err := invoice.LoadAll()
+	if err != nil {
+		return nil, err
+	}
+	billed := invoice.BilledExpenses(invoices)
+
+	var unbilled []invoice.Expense
+	for _, e := range expenses {
+		if _, ok := billed[e.ID]; ok || e.Customer != customerKey {
+			continue
+		}
+		unbilled = append(unbilled, e)
+	}
+	return unbilled, nil
+}
+
+// billExpenses adds a line per expense to a draft invoice and records them
+// as billed. With attach set, the receipts are recorded to be appended to
+// the PDF.
+func billExpenses(inv *invoice.Invoice, cfg *config.Config, customer config.Customer, expenses []invoice.Expense, attach bool) error {
+	currency, rounding, err := invoiceRules(cfg, inv.Customer, customer)
+	if err != nil {
+		return err
+	}
+
+	var receipts int
+	for _, e := range expenses {
+		if e.Currency != currency.Code {
+			return fmt.Errorf("expense %s is in %s, but customer '%s' is billed in %s", e.ID, e.Currency, inv.Customer, currency.Code)
+		}
+		price, err := rounding.Round(e.Price())
+		if err != nil {
+			return fmt.Errorf("expense %s: %w", e.ID, err)
+		}
+		item := invoice.NewItem(e.ID, money.NewDecimal(1), price, 0, rounding)
+		item.Name = e.Description
+		item.Description = e.Date
+
+		taxKey, taxRate, err := cfg.TaxRateFor(customer, config.Product{Tax: e.Tax})
+		if err != nil {
+			return fmt.Errorf("expense %s: %w", e.ID, err)
+		}
+		if taxKey != "" {
+			item.Tax = &invoice.Tax{Key: taxKey, Name: taxRate.Name, Rate: taxRate.Rate}
+		}
+
+		inv.Items = append(inv.Items, item)
+		inv.Expenses = append(inv.Expenses, e.ID)
+		if e.Receipt != "" {
+			receipts++
+			if attach {
+				inv.Receipts = append(inv.Receipts, e.Receipt)
+			}
+		}
+	}
+	if err := inv.Recalculate(rounding); err != nil {
+		return err
+	}
+
+	if attach && receipts > 0 {
+		fmt.Printf("Billing %d expenses and attaching their receipts.\n", len(expenses))
+	} else {
+		fmt.Printf("Billing %d expenses.\n", len(expenses))
+	}
+	return nil
+}
+
+// appendReceipts adds the invoice's receipts to its rendered PDF as extra
+// pages
+func appendReceipts(inv *invoice.Invoice, data []byte) ([]byte, error) {
+	if len(inv.Receipts) == 0 {
+		return data, nil
+	}
+	dir, err := invoice.ExpensesDir()
+	if err != nil {
+		return nil, err
+	}
+
+	var files [][]byte
+	for _, name := range inv.Receipts {
+		file, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("reading receipt: %w", err)
+		}
+		files = append(files, file)
+	}
+	out, err := pdf.AppendPages(data, files)
+	if err != nil {
+		return nil, fmt.Errorf("appending receipts to %s: %w", inv.InvoiceNumber, err)
+	}
+	return out, nil
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"simplebill/internal/config"
+	"simplebill/internal/einvoice"
+	"simplebill/internal/invoice"
+)
+
+func printExportHelp() {
+	fmt.Println("Usage: simplebill export <format> <invoice-number> [-o file]")
+	fmt.Println()
+	fmt.Println("Export an invoice or credit note as e-invoice XML. The XML is written")
+	fmt.Println("to stdout unless -o is given.")
+	fmt.Println()
+	fmt.Println("Formats:")
+	fmt.Println("  ubl         UBL 2.1 following Peppol BIS Billing 3.0")
+	fmt.Println("  xrechnung   XRechnung 3.0 (UBL) for German public authorities")
+	fmt.Println()
+	fmt.Println("Customers with \"einvoice: ubl\" or \"einvoice: xrechnung\" in")
+	fmt.Println("customers.yml get this file saved next to the PDF automatically.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  -o, --output FILE   Write the XML to FILE")
+	fmt.Println("  -h, --help          Show this help message")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  simplebill export ubl INV-2025-0001")
+	fmt.Println("  simplebill export ubl INV-2025-0001 -o INV-2025-0001.xml")
+	fmt.Println("  simplebill export xrechnung INV-2025-0001 -o INV-2025-0001.xml")
+}
+
+func RunExport(args []string) error {
+	if len(args) == 0 {
+		printExportHelp()
+		return nil
+	}
+
+	switch args[0] {
+	case "-h", "--help":
+		printExportHelp()
+		return nil
+	case "ubl":
+		return exportXML(args[1:], einvoice.WriteUBL)
+	case "xrechnung":
+		return exportXML(args[1:], einvoice.WriteXRechnung)
+	default:
+		return fmt.Errorf("unknown export format '%s'. Use: ubl, xrechnung", args[0])
+	}
+}
+
+// exportXML writes an invoice with the given e-invoice writer
+func exportXML(args []string, write func(*einvoice.Document) ([]byte, error)) error {
+	var number, output string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "-h", "--help":
+			printExportHelp()
+			return nil
+		case "-o", "--output":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s requires a value", arg)
+			}
+			i++
+			output = args[i]
+		default:
+			if number != "" {
+				return fmt.Errorf("unexpected argument '%s'", arg)
+			}
+			number = arg
+		}
+	}
+
+	if number == "" {
+		printExportHelp()
+		return nil
+	}
+
+	invoices, err := invoice.LoadAll()
+	if err != nil {
+		return err
+	}
+	inv, err := invoice.Load(number, invoices)
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	customers, err := config.LoadCustomers()
+	if err != nil {
+		return err
+	}
+	customer, err := customerFor(inv, customers)
+	if err != nil {
+		return err
+	}
+
+	data, err := write(einvoice.FromInvoice(inv, cfg, *customer))
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(output, data, 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", output, err)
+	}
+	fmt.Println(output)
+	return nil
+}
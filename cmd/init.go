@@ -19,14 +19,116 @@ var defaultConfig = `company:
     City, ST 12345
   email: "billing@example.com"
   phone: ""
-  id: ""
+  contact: ""      # person to contact about invoices
+  iban: ""         # bank account for payments, for e-invoices and payment QR codes
+  bic: ""
+  id: ""           # VAT ID, e.g. DE123456789, required for e-invoices
+  country: ""      # ISO 3166 code, e.g. DE, required for e-invoices
+  # peppol_id: "0088:5790000436057"  # Peppol ID as scheme:id, if not your VAT ID
+  # qr_iban: ""        # Swiss QR-IBAN, for QR-bills with QR references
+  # reference_type: "" # QR-bill reference: qrr, scor or none
+  currency: "USD"  # default ISO 4217 currency code
 
 invoice:
   prefix: "INV"
+  credit_note_prefix: "CN"
+  quote_prefix: "Q"
+  quote_valid_days: 30
   starting_number: "0000"  # set to last invoice number (next will be +1)
   payment_terms: "Net 14"
   due_days: 14
   notes: "Thank you for your business!"
+  rounding: half-up  # half-up or half-even
+  round_per: line    # line: rows add up to the total; invoice: round the total once
+  default_tax: ""    # tax_rates key applied to products without their own tax
+  payment_qr: none   # QR code to pay with: qr-bill (Swiss QR-bill), girocode (SEPA) or none
+  attach_receipts: false  # append expense receipts to invoice PDFs
+
+# Tax rates in percent. Products pick one with "tax: <key>"; customers can
+# override with "tax: <key>", "tax: exempt" or "tax: reverse-charge".
+# tax_rates:
+#   standard:
+#     name: "VAT 20%"
+#     rate: 20
+#   reduced:
+#     name: "VAT 5%"
+#     rate: 5
+
+# PDF renderer: "builtin" (no dependencies, styled by layout.yml), or
+# "wkhtmltopdf", "chromium", "weasyprint" or "command" (styled by
+# template.html). Empty uses wkhtmltopdf if installed.
+render:
+  backend: ""
+  page_size: Letter        # Letter, Legal, A4 or A5
+  orientation: portrait    # portrait or landscape
+  margins: 10mm            # CSS shorthand, e.g. "15mm 10mm"
+  dpi: 0                   # wkhtmltopdf and weasyprint only, 0 = default
+  # command: "prince {html} -o {pdf}"   # for backend: command
+
+# Factur-X / ZUGFeRD e-invoices, for customers with "einvoice: facturx"
+einvoice:
+  profile: en16931   # minimum, basic or en16931
+  # font: /path/to/Regular.ttf      # embedded by the builtin renderer,
+  # font_bold: /path/to/Bold.ttf    # default is a system font
+
+# Outgoing mail for "simplebill send"
+# smtp:
+#   host: smtp.example.com
+#   port: 587           # default 587, or 465 with tls: tls
+#   username: billing@example.com
+#   password: ""        # or set $SIMPLEBILL_SMTP_PASSWORD
+#   tls: starttls       # starttls, tls or none (local test servers only)
+# email:
+#   from: "Acme Billing <billing@example.com>"  # default: company name and email
+#   subject: "Invoice {{.InvoiceNumber}} from {{.Company.Name}}"
+#   body: |
+#     Hello,
+#
+#     please find attached invoice {{.InvoiceNumber}} for {{money .BalanceDue}}, due {{.DueDate}}.
+#   bcc: ["books@example.com"]
+
+# Payment reminders for "simplebill remind", each sent once an invoice is
+# "days" past due. Without this list: 7, 21 and 45 days, without fees.
+# reminders:
+#   - days: 7
+#     name: Payment reminder
+#     text: "Invoice {{.InvoiceNumber}} of {{.Date}} was due on {{.DueDate}}. Please transfer {{money .Reminder.AmountDue}}."
+#   - days: 21
+#     name: Second notice
+#     text: "Invoice {{.InvoiceNumber}} is {{.Reminder.DaysOverdue}} days overdue. Please transfer {{money .Reminder.AmountDue}} within 10 days."
+#   - days: 45
+#     name: Final notice
+#     text: "Please transfer {{money .Reminder.AmountDue}}, fees included, within 7 days."
+#     fee: 20.00        # optional, in the company currency
+#     fees: {EUR: 15}   # for customers billed in other currencies
+#     late_fees: true   # add late fees and interest to this notice
+
+# Charges for late payment, see "simplebill late-fees". interest is monthly
+# (rate % a month) or statutory (base_rates + rate % a year).
+# late_fees:
+#   flat: 40.00
+#   interest: statutory
+#   rate: 9
+#   grace_days: 5
+# base_rates:
+#   - from: 2025-01-01
+#     rate: 2.27
+
+# Time tracker exports for "simplebill invoice --from-timesheet". Without
+# --source the columns date, duration, project, task, rate, product,
+# customer and id are read, or those of a source named default.
+# timesheets:
+#   toggl:
+#     product: consulting        # billed for entries without a product column
+#     duration_unit: seconds     # for plain numbers: hours (default), minutes or seconds
+#     date_format: YYYY-MM-DD    # e.g. DD.MM.YYYY
+#     columns:
+#       date: start
+#       duration: dur
+#       project: project.name    # nested JSON fields with dots
+#       task: description
+#       customer: client
+#       id: id                   # tells entries apart when billing them once
 
 # If true and ~/.simplebill is a git repo, auto-commit after changes
 auto_commit: false
@@ -46,6 +148,17 @@ var defaultCustomers = `# Add customers here. The key (e.g., "acme") is used on
 #     456 Oak Ave
 #     Denver, CO 80202
 #   id: "LIC-12345"
+#   tax: exempt  # optional: exempt, reverse-charge or a tax_rates key
+#   currency: EUR  # optional: bill in a currency other than the company default
+#   country: US    # ISO 3166 code, required for e-invoices
+#   einvoice: facturx          # optional: facturx (Factur-X / ZUGFeRD PDF), ubl (Peppol XML) or xrechnung
+#   einvoice_profile: basic    # optional: overrides einvoice.profile in config.yml
+#   peppol_id: "0088:5790000436057"  # optional: Peppol ID, if not the VAT ID in id
+#   buyer_reference: "PO-4711"       # optional: the customer's reference for invoices
+#   leitweg_id: "991-12345-73"       # German public authorities, for einvoice: xrechnung
+#   payment_qr: qr-bill              # optional: overrides invoice.payment_qr in config.yml
+#   cc: ["accounts@acme.com"]        # optional: copied when invoices are sent
+#   late_fees: {interest: monthly, rate: 1}  # optional: overrides late_fees in config.yml
 `
 
 var defaultProducts = `# Add products here. The key (e.g., "widget") is used on the command line.
@@ -54,7 +167,18 @@ var defaultProducts = `# Add products here. The key (e.g., "widget") is used on
 # widget:
 #   name: "Standard Widget"
 #   sku: "WDG-001"
-#   price: 19.99
+#   description: "Optional text shown under the name"
+#   price: 19.99   # in the company currency
+#   prices:        # optional: prices in other currencies
+#     EUR: 18.50
+#     GBP: 15.99
+#   tax: standard  # optional: tax_rates key from config.yml
+#
+# consulting:
+#   name: "Consulting"
+#   price: 120.00
+#   unit: h                # optional: shown next to the quantity, e.g. "7.5 h"
+#   quantity_decimals: 2   # optional: allow quantities such as 7.25
 `
 
 func RunInit() error {
@@ -94,6 +218,38 @@ func RunInit() error {
 		return fmt.Errorf("could not write template.html: %w", err)
 	}
 
+	creditNoteContent, err := templates.ReadFile("templates/credit_note.html")
+	if err != nil {
+		return fmt.Errorf("could not read embedded template: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "credit_note.html"), creditNoteContent, 0644); err != nil {
+		return fmt.Errorf("could not write credit_note.html: %w", err)
+	}
+
+	reminderContent, err := templates.ReadFile("templates/reminder.html")
+	if err != nil {
+		return fmt.Errorf("could not read embedded template: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "reminder.html"), reminderContent, 0644); err != nil {
+		return fmt.Errorf("could not write reminder.html: %w", err)
+	}
+
+	quoteContent, err := templates.ReadFile("templates/quote.html")
+	if err != nil {
+		return fmt.Errorf("could not read embedded template: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "quote.html"), quoteContent, 0644); err != nil {
+		return fmt.Errorf("could not write quote.html: %w", err)
+	}
+
+	layoutContent, err := templates.ReadFile("templates/layout.yml")
+	if err != nil {
+		return fmt.Errorf("could not read embedded layout: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "layout.yml"), layoutContent, 0644); err != nil {
+		return fmt.Errorf("could not write layout.yml: %w", err)
+	}
+
 	fmt.Printf("Created %s\n", dir)
 	fmt.Println("Edit your config files there, then run: simplebill invoice <customer> <product:qty>")
 
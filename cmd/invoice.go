@@ -10,46 +10,90 @@ import (
 
 	"simplebill/internal/config"
 	"simplebill/internal/invoice"
+	"simplebill/internal/money"
 )
 
 func printInvoiceHelp() {
 	fmt.Println("Usage: simplebill invoice <customer> <product:qty[:discount[:@price]]>... [-y]")
+	fmt.Println("       simplebill invoice --from-timesheet <file> <customer> [product:qty...] [options]")
+	fmt.Println("       simplebill invoice <customer> [-y]  (bills unbilled expenses only)")
 	fmt.Println()
 	fmt.Println("Generate a PDF invoice for a customer.")
 	fmt.Println()
 	fmt.Println("Arguments:")
 	fmt.Println("  customer                     Customer key from customers.yml")
-	fmt.Println("  product:qty                  Product key and quantity (e.g., widget:10)")
+	fmt.Println("  product:qty                  Product key and quantity (e.g., widget:10, consulting:7.5)")
 	fmt.Println("  product:qty:discount         Percentage discount (e.g., widget:1:25 for 25% off)")
 	fmt.Println("  product:qty:discount:@price  Custom price with optional discount (e.g., widget:1:0:@15.00)")
 	fmt.Println()
 	fmt.Println("Options:")
-	fmt.Println("  -y, --yes    Skip preview and save immediately")
-	fmt.Println("  -h, --help   Show this help message")
+	fmt.Println("  -y, --yes                   Skip preview and save immediately")
+	fmt.Println("  --from-timesheet FILE       Bill the unbilled entries of a CSV or JSON time export")
+	fmt.Println("  --source NAME               Column mapping from timesheets: in config.yml")
+	fmt.Println("  --by product|task|entry     One line per product, per task (default) or per entry")
+	fmt.Println("  --product KEY               Product for entries that don't name one")
+	fmt.Println("  --no-expenses               Leave the customer's unbilled expenses for later")
+	fmt.Println("  --receipts                  Append expense receipts to the PDF")
+	fmt.Println("  --no-receipts               Don't, even if invoice.attach_receipts is set")
+	fmt.Println("  -h, --help                  Show this help message")
+	fmt.Println()
+	fmt.Println("Timesheet entries are billed once: the invoice records them, and")
+	fmt.Println("entries of a void invoice can be billed again. The same goes for")
+	fmt.Println("expenses recorded with 'simplebill expense add', which are added as")
+	fmt.Println("separate lines.")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  simplebill invoice acme widget:10")
 	fmt.Println("  simplebill invoice acme widget:5 widget:1:25 gadget:3 -y")
 	fmt.Println("  simplebill invoice acme widget:10:0:@15.00")
+	fmt.Println("  simplebill invoice --from-timesheet october.csv acme --product consulting")
+	fmt.Println("  simplebill invoice --from-timesheet toggl.json acme --source toggl --by entry")
 }
 
 func RunInvoice(args []string) error {
 	// Check for flags
 	skipPreview := false
+	noExpenses := false
+	var receipts *bool
+	var ts timesheetOptions
 	var filteredArgs []string
-	for _, arg := range args {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
 		if arg == "-y" || arg == "--yes" {
 			skipPreview = true
 		} else if arg == "-h" || arg == "--help" {
 			printInvoiceHelp()
 			return nil
+		} else if arg == "--no-expenses" {
+			noExpenses = true
+		} else if arg == "--receipts" || arg == "--no-receipts" {
+			attach := arg == "--receipts"
+			receipts = &attach
+		} else if arg == "--from-timesheet" || arg == "--source" || arg == "--by" || arg == "--product" {
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s requires a value", arg)
+			}
+			i++
+			switch arg {
+			case "--from-timesheet":
+				ts.path = args[i]
+			case "--source":
+				ts.source = args[i]
+			case "--by":
+				ts.by = args[i]
+			case "--product":
+				ts.product = args[i]
+			}
 		} else {
 			filteredArgs = append(filteredArgs, arg)
 		}
 	}
 	args = filteredArgs
 
-	if len(args) < 2 {
+	if ts.path == "" && (ts.source != "" || ts.by != "" || ts.product != "") {
+		return fmt.Errorf("--source, --by and --product are options for --from-timesheet")
+	}
+	if len(args) < 1 {
 		printInvoiceHelp()
 		return nil
 	}
@@ -76,140 +120,250 @@ func RunInvoice(args []string) error {
 		return fmt.Errorf("customer '%s' not found in customers.yml", customerKey)
 	}
 
-	// Parse product:qty pairs
-	var items []invoice.Item
-	var total float64
+	var expenses []invoice.Expense
+	if !noExpenses {
+		if expenses, err = unbilledExpenses(customerKey); err != nil {
+			return err
+		}
+	}
+	if len(args) < 2 && ts.path == "" && len(expenses) == 0 {
+		if noExpenses {
+			printInvoiceHelp()
+			return nil
+		}
+		return fmt.Errorf("no items given and customer '%s' has no unbilled expenses", customerKey)
+	}
 
-	for _, arg := range args[1:] {
-		parts := strings.Split(arg, ":")
-		if len(parts) < 2 || len(parts) > 4 {
-			return fmt.Errorf("invalid format '%s', expected product:qty or product:qty:discount or product:qty:discount:@price", arg)
+	invNumber, err := invoice.NextNumber(cfg)
+	if err != nil {
+		return fmt.Errorf("generating invoice number: %w", err)
+	}
+	var inv *invoice.Invoice
+	if ts.path != "" {
+		inv, err = timesheetInvoice(cfg, invNumber, customerKey, customer, products, ts, args[1:], time.Now())
+	} else {
+		inv, err = newInvoice(cfg, invNumber, customerKey, customer, products, args[1:], time.Now())
+	}
+	if err != nil {
+		return err
+	}
+	if len(expenses) > 0 {
+		attach := cfg.Invoice.AttachReceipts
+		if receipts != nil {
+			attach = *receipts
+		}
+		if err := billExpenses(inv, cfg, customer, expenses, attach); err != nil {
+			return err
 		}
+	}
 
-		productKey := parts[0]
-		qty, err := strconv.Atoi(parts[1])
+	saved, err := previewAndSave(inv, cfg, &customer, products, skipPreview)
+	if err != nil || !saved {
+		return err
+	}
+
+	// Auto-commit if enabled
+	config.AutoCommit(fmt.Sprintf("simplebill: created invoice %s", inv.InvoiceNumber))
+
+	return nil
+}
+
+// previewAndSave opens a preview PDF and asks for confirmation unless
+// skipPreview is set, then saves the YAML and renders the final PDF.
+// It reports whether the document was saved.
+func previewAndSave(inv *invoice.Invoice, cfg *config.Config, customer *config.Customer, products map[string]config.Product, skipPreview bool) (bool, error) {
+	dir, _ := inv.Dir()
+	name := documentName(inv)
+
+	// Catch missing e-invoice and QR-bill details before anything is saved
+	if err := checkEInvoice(inv, cfg, customer); err != nil {
+		return false, err
+	}
+	if err := checkPaymentQR(inv, cfg, customer); err != nil {
+		return false, err
+	}
+
+	if !skipPreview {
+		// Preview flow: render to temp, open, prompt
+		tempPDF, err := RenderPDFToTemp(inv, cfg, customer, products)
 		if err != nil {
-			return fmt.Errorf("invalid quantity '%s' for product '%s'", parts[1], productKey)
+			return false, err
 		}
+		defer os.Remove(tempPDF)
 
-		var discount int
-		if len(parts) >= 3 {
-			discount, err = strconv.Atoi(parts[2])
-			if err != nil || discount < 0 || discount > 100 {
-				return fmt.Errorf("invalid discount '%s' for product '%s', expected 0-100", parts[2], productKey)
-			}
+		// Open in default viewer
+		if err := openFile(tempPDF); err != nil {
+			return false, fmt.Errorf("opening preview: %w", err)
 		}
 
-		var customPrice float64
-		if len(parts) == 4 {
-			priceStr := parts[3]
-			if !strings.HasPrefix(priceStr, "@") {
-				return fmt.Errorf("invalid price '%s' for product '%s', expected @price (e.g., @15.00)", priceStr, productKey)
-			}
-			customPrice, err = strconv.ParseFloat(priceStr[1:], 64)
-			if err != nil || customPrice < 0 {
-				return fmt.Errorf("invalid price '%s' for product '%s'", priceStr, productKey)
-			}
-		}
+		// Prompt user
+		fmt.Printf("Save %s? [y/n]: ", name)
+		reader := bufio.NewReader(os.Stdin)
+		response, _ := reader.ReadString('\n')
+		response = strings.TrimSpace(strings.ToLower(response))
 
-		product, ok := products[productKey]
-		if !ok {
-			return fmt.Errorf("product '%s' not found in products.yml", productKey)
+		if response != "y" && response != "yes" {
+			fmt.Printf("%s%s cancelled.\n", strings.ToUpper(name[:1]), name[1:])
+			return false, nil
 		}
+	}
 
-		basePrice := product.Price
-		if len(parts) == 4 && strings.HasPrefix(parts[3], "@") {
-			basePrice = customPrice
-		}
-		unitPrice := basePrice
-		if discount > 0 {
-			unitPrice = basePrice * (1 - float64(discount)/100)
-		}
-		itemTotal := unitPrice * float64(qty)
-		items = append(items, invoice.Item{
-			Product:   productKey,
-			Quantity:  qty,
-			UnitPrice: unitPrice,
-			Total:     itemTotal,
-			Discount:  discount,
-		})
-		total += itemTotal
-	}
-
-	// Generate invoice number
-	invNumber, err := invoice.NextNumber(cfg)
+	// Save YAML
+	if err := inv.Save(); err != nil {
+		return false, err
+	}
+
+	// Save final PDF
+	if err := RenderPDF(inv, cfg, customer, products, ""); err != nil {
+		return false, err
+	}
+	xml, err := saveEInvoiceXML(inv, cfg, customer)
 	if err != nil {
-		return fmt.Errorf("generating invoice number: %w", err)
+		return false, err
 	}
 
-	// Create invoice
+	fmt.Printf("Created %s\n", inv.InvoiceNumber)
+	fmt.Printf("%s/%s.pdf\n", dir, inv.InvoiceNumber)
+	if xml != "" {
+		fmt.Println(xml)
+	}
+	return true, nil
+}
+
+// newInvoice builds a draft invoice dated date for the customer from
+// product:qty[:discount[:@price]] specs
+func newInvoice(cfg *config.Config, invNumber, customerKey string, customer config.Customer, products map[string]config.Product, specs []string, date time.Time) (*invoice.Invoice, error) {
+	currency, rounding, err := invoiceRules(cfg, customerKey, customer)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := parseItems(specs, cfg, customer, products, currency, rounding)
+	if err != nil {
+		return nil, err
+	}
+
+	return draftInvoice(cfg, invNumber, customerKey, customer, currency, rounding, items, date)
+}
+
+// invoiceRules returns the currency the customer is billed in and the
+// rounding rule for it
+func invoiceRules(cfg *config.Config, customerKey string, customer config.Customer) (money.Currency, money.Rounding, error) {
+	currency, err := cfg.CurrencyFor(customer)
+	if err != nil {
+		return currency, money.Rounding{}, fmt.Errorf("customer '%s': %w", customerKey, err)
+	}
+
+	rounding, err := cfg.Rounding()
+	if err != nil {
+		return currency, rounding, err
+	}
+	return currency, rounding.In(currency), nil
+}
+
+// draftInvoice builds a draft invoice dated date with the given items
+func draftInvoice(cfg *config.Config, invNumber, customerKey string, customer config.Customer, currency money.Currency, rounding money.Rounding, items []invoice.Item, date time.Time) (*invoice.Invoice, error) {
 	now := time.Now()
-	dueDate := now.AddDate(0, 0, cfg.Invoice.DueDays)
+	dueDate := date.AddDate(0, 0, cfg.Invoice.DueDays)
 
 	inv := &invoice.Invoice{
 		InvoiceNumber: invNumber,
-		Date:          now.Format("2006-01-02"),
+		Date:          date.Format("2006-01-02"),
 		DueDate:       dueDate.Format("2006-01-02"),
 		Customer:      customerKey,
+		CustomerInfo:  &customer,
+		CompanyInfo:   &cfg.Company,
+		Currency:      currency.Code,
 		Items:         items,
-		Total:         total,
+		TaxNote:       cfg.TaxNote(customer),
+		Status:        invoice.StatusDraft,
+		History:       []invoice.StatusChange{{Status: invoice.StatusDraft, At: now}},
 		CreatedAt:     now,
 	}
+	if err := inv.Recalculate(rounding); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
 
-	dir, _ := config.Dir()
+// parseItems prices product:qty[:discount[:@price]] specs for the customer
+func parseItems(specs []string, cfg *config.Config, customer config.Customer, products map[string]config.Product, currency money.Currency, rounding money.Rounding) ([]invoice.Item, error) {
+	var items []invoice.Item
 
-	if skipPreview {
-		// Save directly without preview
-		if err := inv.Save(); err != nil {
-			return err
+	for _, arg := range specs {
+		parts := strings.Split(arg, ":")
+		if len(parts) < 2 || len(parts) > 4 {
+			return nil, fmt.Errorf("invalid format '%s', expected product:qty or product:qty:discount or product:qty:discount:@price", arg)
 		}
-		if err := RenderPDF(inv, cfg, &customer, products, ""); err != nil {
-			return err
+
+		productKey := parts[0]
+		product, ok := products[productKey]
+		if !ok {
+			return nil, fmt.Errorf("product '%s' not found in products.yml", productKey)
 		}
-		fmt.Printf("Created %s\n", invNumber)
-		fmt.Printf("%s/invoices/%s.pdf\n", dir, invNumber)
-		config.AutoCommit(fmt.Sprintf("simplebill: created invoice %s", invNumber))
-		return nil
-	}
 
-	// Preview flow: render to temp, open, prompt
-	tempPDF, err := RenderPDFToTemp(inv, cfg, &customer, products)
-	if err != nil {
-		return err
-	}
-	defer os.Remove(tempPDF)
+		qty, err := product.ParseQuantity(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("product '%s': %w", productKey, err)
+		}
 
-	// Open in default viewer
-	if err := openFile(tempPDF); err != nil {
-		return fmt.Errorf("opening preview: %w", err)
-	}
+		var discount int
+		if len(parts) >= 3 {
+			discount, err = strconv.Atoi(parts[2])
+			if err != nil || discount < 0 || discount > 100 {
+				return nil, fmt.Errorf("invalid discount '%s' for product '%s', expected 0-100", parts[2], productKey)
+			}
+		}
 
-	// Prompt user
-	fmt.Print("Save invoice? [y/n]: ")
-	reader := bufio.NewReader(os.Stdin)
-	response, _ := reader.ReadString('\n')
-	response = strings.TrimSpace(strings.ToLower(response))
+		var customPrice *money.Amount
+		if len(parts) == 4 {
+			priceStr := parts[3]
+			if !strings.HasPrefix(priceStr, "@") {
+				return nil, fmt.Errorf("invalid price '%s' for product '%s', expected @price (e.g., @15.00)", priceStr, productKey)
+			}
+			price, err := money.Parse(priceStr[1:])
+			if err != nil || price < 0 {
+				return nil, fmt.Errorf("invalid price '%s' for product '%s'", priceStr, productKey)
+			}
+			customPrice = &price
+		}
 
-	if response != "y" && response != "yes" {
-		fmt.Println("Invoice cancelled.")
-		return nil
+		item, err := priceItem(cfg, customer, productKey, product, qty, discount, customPrice, currency, rounding)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
 	}
 
-	// Save invoice YAML
-	if err := inv.Save(); err != nil {
-		return err
-	}
+	return items, nil
+}
 
-	// Save final PDF
-	if err := RenderPDF(inv, cfg, &customer, products, ""); err != nil {
-		return err
+// priceItem builds the item for qty of a product at its price in currency,
+// or at price when one is given, taxed as the customer's tax setting says
+func priceItem(cfg *config.Config, customer config.Customer, productKey string, product config.Product, qty money.Decimal, discount int, price *money.Amount, currency money.Currency, rounding money.Rounding) (invoice.Item, error) {
+	var basePrice money.Amount
+	if price != nil {
+		basePrice = *price
+	} else {
+		var err error
+		basePrice, err = product.PriceIn(currency.Code, cfg.DefaultCurrency())
+		if err != nil {
+			return invoice.Item{}, fmt.Errorf("product '%s': %w", productKey, err)
+		}
 	}
+	// Prices without a discount are billed as they are, fractions included
+	if currency.Decimals == 0 && basePrice%100 != 0 {
+		return invoice.Item{}, fmt.Errorf("invalid price %s for product '%s', %s has no minor units", basePrice, productKey, currency.Code)
+	}
+	item := invoice.NewItem(productKey, qty, basePrice, discount, rounding)
+	item.Describe(product)
 
-	fmt.Printf("Created %s\n", invNumber)
-	fmt.Printf("%s/invoices/%s.pdf\n", dir, invNumber)
-
-	// Auto-commit if enabled
-	config.AutoCommit(fmt.Sprintf("simplebill: created invoice %s", invNumber))
-
-	return nil
+	taxKey, taxRate, err := cfg.TaxRateFor(customer, product)
+	if err != nil {
+		return invoice.Item{}, fmt.Errorf("product '%s': %w", productKey, err)
+	}
+	if taxKey != "" {
+		item.Tax = &invoice.Tax{Key: taxKey, Name: taxRate.Name, Rate: taxRate.Rate}
+	}
+	return item, nil
 }
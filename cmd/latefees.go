@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"simplebill/internal/config"
+	"simplebill/internal/invoice"
+)
+
+func printLateFeesHelp() {
+	fmt.Println("Usage: simplebill late-fees <invoice-number> [--as-of YYYY-MM-DD] [--invoice [-y]]")
+	fmt.Println()
+	fmt.Println("Show the late fee and interest an invoice has accrued under late_fees:")
+	fmt.Println("in config.yml or customers.yml, beyond what was already charged.")
+	fmt.Println("With --invoice, bill them on a new invoice linked to the original.")
+	fmt.Println("Fees billed on an invoice that was later voided or deleted are owed again.")
+	fmt.Println()
+	fmt.Println("Reminder levels with \"late_fees: true\" add them to the notice instead.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --as-of       Charge interest up to this date (default: today)")
+	fmt.Println("  --invoice     Create a fee invoice for the amount")
+	fmt.Println("  -y, --yes     Skip preview and save the fee invoice immediately")
+	fmt.Println("  -h, --help    Show this help message")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  simplebill late-fees INV-2025-0001")
+	fmt.Println("  simplebill late-fees INV-2025-0001 --as-of 2025-06-30 --invoice")
+}
+
+func RunLateFees(args []string) error {
+	var number string
+	asOf := time.Now()
+	billIt, skipPreview := false, false
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "-h", "--help":
+			printLateFeesHelp()
+			return nil
+		case "--invoice":
+			billIt = true
+		case "-y", "--yes":
+			skipPreview = true
+		case "--as-of":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--as-of requires a value")
+			}
+			i++
+			date, err := parseDate(args[i])
+			if err != nil {
+				return err
+			}
+			asOf = date
+		default:
+			if number != "" {
+				return fmt.Errorf("unexpected argument '%s'", arg)
+			}
+			number = arg
+		}
+	}
+	if number == "" {
+		printLateFeesHelp()
+		return nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	customers, err := config.LoadCustomers()
+	if err != nil {
+		return err
+	}
+	products, err := config.LoadProducts()
+	if err != nil {
+		return err
+	}
+	invoices, err := invoice.LoadAll()
+	if err != nil {
+		return err
+	}
+	original, err := invoice.Load(number, invoices)
+	if err != nil {
+		return err
+	}
+	customer, err := customerFor(original, customers)
+	if err != nil {
+		return err
+	}
+
+	rule, charge, err := lateCharge(original, cfg, currentCustomer(original, customer, customers), asOf)
+	if err != nil {
+		return err
+	}
+	if rule == nil {
+		return fmt.Errorf("no late_fees in config.yml or for customer '%s'", original.Customer)
+	}
+
+	currency := original.CurrencyInfo()
+	fmt.Printf("Late fees for %s as of %s (%s)\n", number, charge.Through, rule.Describe())
+	if charge.Flat != 0 {
+		fmt.Printf("  %-58s  %s\n", "Flat fee", padLeft(currency.Format(charge.Flat), 14))
+	}
+	for _, p := range charge.Periods {
+		line := fmt.Sprintf("%s to %s, %d days on %s at %s%% a year", p.From, p.Through, p.Days, currency.Format(p.Balance), p.Rate)
+		fmt.Printf("  %-58s\n", line)
+	}
+	if charge.Interest != 0 {
+		fmt.Printf("  %-58s  %s\n", "Interest", padLeft(currency.Format(charge.Interest), 14))
+	}
+	fmt.Printf("  %-58s  %s\n", "Total", padLeft(currency.Format(charge.Total()), 14))
+	for _, f := range original.LateFees {
+		if f.Void {
+			continue
+		}
+		where := "with reminder " + fmt.Sprint(f.Reminder)
+		if f.Invoice != "" {
+			where = "on " + f.Invoice
+		}
+		fmt.Printf("  Already charged through %s: %s %s\n", f.Through, currency.Format(f.Flat+f.Interest), where)
+	}
+
+	if !billIt {
+		if charge.Total() > 0 {
+			fmt.Printf("\nRun 'simplebill late-fees %s --invoice' to bill it.\n", number)
+		}
+		return nil
+	}
+
+	rounding, err := cfg.Rounding()
+	if err != nil {
+		return err
+	}
+	feeNumber, err := invoice.NextNumber(cfg)
+	if err != nil {
+		return fmt.Errorf("generating invoice number: %w", err)
+	}
+	inv, err := invoice.NewLateFeeInvoice(original, feeNumber, charge, cfg.Invoice.DueDays, rounding, time.Now())
+	if err != nil {
+		return err
+	}
+	inv.CompanyInfo = &cfg.Company
+
+	saved, err := previewAndSave(inv, cfg, customer, products, skipPreview)
+	if err != nil || !saved {
+		return err
+	}
+
+	original.RecordLateFee(charge, feeNumber, 0)
+	if err := original.Save(); err != nil {
+		return err
+	}
+
+	config.AutoCommit(fmt.Sprintf("simplebill: created late fee invoice %s for %s", feeNumber, number))
+	return nil
+}
+
+// lateCharge works out the late fees inv owes as of day under the
+// customer's rule. The rule is nil when late payment costs nothing.
+func lateCharge(inv *invoice.Invoice, cfg *config.Config, customer config.Customer, day time.Time) (*config.LateFees, invoice.LateCharge, error) {
+	rule, err := cfg.LateFeesFor(customer)
+	if err != nil || rule == nil {
+		return nil, invoice.LateCharge{}, err
+	}
+	flat, err := rule.FlatIn(inv.CurrencyInfo().Code, cfg.DefaultCurrency())
+	if err != nil {
+		return nil, invoice.LateCharge{}, err
+	}
+	rounding, err := cfg.Rounding()
+	if err != nil {
+		return nil, invoice.LateCharge{}, err
+	}
+	charge, err := inv.AccrueLateFees(*rule, flat, cfg.BaseRates, day, rounding)
+	return rule, charge, err
+}
@@ -2,14 +2,14 @@ package cmd
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
 	"sort"
 	"strings"
+	"time"
+	"unicode/utf8"
 
-	"gopkg.in/yaml.v3"
 	"simplebill/internal/config"
 	"simplebill/internal/invoice"
+	"simplebill/internal/money"
 )
 
 func printListHelp() {
@@ -19,6 +19,7 @@ func printListHelp() {
 	fmt.Println()
 	fmt.Println("Types:")
 	fmt.Println("  invoices    List all invoices (default)")
+	fmt.Println("  quotes      List all quotes")
 	fmt.Println("  customers   List all customers")
 	fmt.Println("  products    List all products")
 	fmt.Println("  config      Show current configuration")
@@ -42,6 +43,8 @@ func RunList(args []string) error {
 		return nil
 	case "invoices":
 		return listInvoices()
+	case "quotes":
+		return listQuotes()
 	case "customers":
 		return listCustomers()
 	case "products":
@@ -49,67 +52,83 @@ func RunList(args []string) error {
 	case "config":
 		return listConfig()
 	default:
-		return fmt.Errorf("unknown list type '%s'. Use: invoices, customers, products, config", args[0])
+		return fmt.Errorf("unknown list type '%s'. Use: invoices, quotes, customers, products, config", args[0])
 	}
 }
 
 func listInvoices() error {
-	dir, err := config.Dir()
+	invoices, err := invoice.LoadAll()
 	if err != nil {
 		return err
 	}
 
-	invoicesDir := filepath.Join(dir, "invoices")
-	entries, err := os.ReadDir(invoicesDir)
-	if err != nil {
-		if os.IsNotExist(err) {
-			fmt.Println("No invoices yet.")
-			return nil
-		}
-		return err
-	}
-
 	customers, err := config.LoadCustomers()
 	if err != nil {
 		return err
 	}
 
-	var invoices []invoice.Invoice
-	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".yml") {
-			continue
-		}
-
-		path := filepath.Join(invoicesDir, entry.Name())
-		data, err := os.ReadFile(path)
-		if err != nil {
-			continue
-		}
-
-		var inv invoice.Invoice
-		if err := yaml.Unmarshal(data, &inv); err != nil {
-			continue
-		}
-		invoices = append(invoices, inv)
-	}
-
 	if len(invoices) == 0 {
 		fmt.Println("No invoices yet.")
 		return nil
 	}
 
+	fmt.Printf("%-15s  %-10s  %-30s  %14s  %14s  %s\n", "NUMBER", "DATE", "CUSTOMER", "NET", "GROSS", "STATUS")
+
 	// Sort by date descending (newest first)
 	sort.Slice(invoices, func(i, j int) bool {
 		return invoices[i].Date > invoices[j].Date
 	})
 
+	today := time.Now()
 	for _, inv := range invoices {
-		customerName := inv.Customer
-		if c, ok := customers[inv.Customer]; ok {
-			customerName = c.Name
+		customerName := inv.CustomerName(customers)
+		currency := inv.CurrencyInfo()
+		net, gross := inv.Net(), inv.Total
+		status := string(inv.StatusOn(today))
+		if inv.IsCreditNote() {
+			net, gross = -net, -gross
+			status += " (credit for " + inv.CreditFor + ")"
 		}
-		fmt.Printf("%-15s  %s  %-30s  $%7.2f\n",
-			inv.InvoiceNumber, inv.Date, customerName, inv.Total)
+		fmt.Printf("%-15s  %s  %-30s  %s  %s  %s\n",
+			inv.InvoiceNumber, inv.Date, customerName,
+			padLeft(currency.Format(net), 14), padLeft(currency.Format(gross), 14),
+			status)
+	}
+
+	return nil
+}
+
+func listQuotes() error {
+	quotes, err := invoice.LoadQuotes()
+	if err != nil {
+		return err
+	}
+
+	customers, err := config.LoadCustomers()
+	if err != nil {
+		return err
+	}
+
+	if len(quotes) == 0 {
+		fmt.Println("No quotes yet.")
+		return nil
+	}
+
+	fmt.Printf("%-15s  %-10s  %-30s  %14s  %-11s  %s\n", "NUMBER", "DATE", "CUSTOMER", "GROSS", "VALID UNTIL", "STATUS")
+
+	sort.Slice(quotes, func(i, j int) bool {
+		return quotes[i].Date > quotes[j].Date
+	})
+
+	today := time.Now()
+	for _, q := range quotes {
+		status := q.QuoteStatus(today)
+		if q.AcceptedAs != "" {
+			status += " (invoice " + q.AcceptedAs + ")"
+		}
+		fmt.Printf("%-15s  %s  %-30s  %s  %-11s  %s\n",
+			q.InvoiceNumber, q.Date, q.CustomerName(customers),
+			padLeft(q.CurrencyInfo().Format(q.Total), 14), q.ValidUntil, status)
 	}
 
 	return nil
@@ -135,13 +154,18 @@ func listCustomers() error {
 
 	for _, k := range keys {
 		c := customers[k]
-		fmt.Printf("%-15s  %s\n", k, c.Name)
+		fmt.Printf("%-15s  %-30s  %-30s  %s\n", k, c.Name, c.Email, c.Currency)
 	}
 
 	return nil
 }
 
 func listProducts() error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
 	products, err := config.LoadProducts()
 	if err != nil {
 		return err
@@ -161,12 +185,47 @@ func listProducts() error {
 
 	for _, k := range keys {
 		p := products[k]
-		fmt.Printf("%-15s  %-40s  $%.2f\n", k, p.Name, p.Price)
+		prices := []string{formatPrice(p.Price, cfg.DefaultCurrency())}
+		var codes []string
+		for code := range p.Prices {
+			if code != cfg.DefaultCurrency() {
+				codes = append(codes, code)
+			}
+		}
+		sort.Strings(codes)
+		for _, code := range codes {
+			prices = append(prices, formatPrice(p.Prices[code], code))
+		}
+		line := fmt.Sprintf("%-15s  %-40s  %s", k, p.Name, strings.Join(prices, " / "))
+		if p.Unit != "" {
+			line += " per " + p.Unit
+		}
+		if p.Tax != "" {
+			line += fmt.Sprintf("  (%s)", p.Tax)
+		}
+		fmt.Println(line)
 	}
 
 	return nil
 }
 
+// padLeft right-aligns s in a column of width characters. Currency symbols
+// are often multi-byte, which %14s would miscount.
+func padLeft(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n < width {
+		return strings.Repeat(" ", width-n) + s
+	}
+	return s
+}
+
+func formatPrice(a money.Amount, code string) string {
+	currency, err := money.LookupCurrency(code)
+	if err != nil {
+		return fmt.Sprintf("%s %s", a, code)
+	}
+	return currency.Format(a)
+}
+
 func listConfig() error {
 	cfg, err := config.Load()
 	if err != nil {
@@ -183,6 +242,7 @@ func listConfig() error {
 	if cfg.Company.ID != "" {
 		fmt.Printf("  ID:      %s\n", cfg.Company.ID)
 	}
+	fmt.Printf("  Currency: %s\n", cfg.DefaultCurrency())
 
 	fmt.Println()
 	fmt.Println("Invoice Settings:")
@@ -191,6 +251,30 @@ func listConfig() error {
 	fmt.Printf("  Payment Terms: %s\n", cfg.Invoice.PaymentTerms)
 	fmt.Printf("  Due Days:      %d\n", cfg.Invoice.DueDays)
 	fmt.Printf("  Notes:         %s\n", cfg.Invoice.Notes)
+	if rounding, err := cfg.Rounding(); err == nil {
+		per := "line"
+		if rounding.PerInvoice {
+			per = "invoice"
+		}
+		fmt.Printf("  Rounding:      %s per %s\n", rounding.Mode, per)
+	}
+
+	if len(cfg.TaxRates) > 0 {
+		fmt.Println()
+		fmt.Println("Tax Rates:")
+		var keys []string
+		for k := range cfg.TaxRates {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			rate := cfg.TaxRates[k]
+			fmt.Printf("  %-13s  %s%%  %s\n", k, rate.Rate, rate.Name)
+		}
+		if cfg.Invoice.DefaultTax != "" {
+			fmt.Printf("  Default:       %s\n", cfg.Invoice.DefaultTax)
+		}
+	}
 
 	fmt.Println()
 	fmt.Printf("Auto-commit: %v\n", cfg.AutoCommit)
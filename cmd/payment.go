@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"simplebill/internal/config"
+	"simplebill/internal/invoice"
+	"simplebill/internal/money"
+)
+
+func printPaymentHelp() {
+	fmt.Println("Usage: simplebill payment <command>")
+	fmt.Println()
+	fmt.Println("Record and review payments received.")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  add <invoice-number>... <amount>  Record a payment, split across invoices in order")
+	fmt.Println("  list [invoice-number]             List payments and balances")
+	fmt.Println()
+	fmt.Println("Options for add:")
+	fmt.Println("  --date YYYY-MM-DD   Date received (default: today)")
+	fmt.Println("  --method METHOD     Payment method (e.g., bank transfer, card)")
+	fmt.Println("  --reference REF     Bank or transaction reference")
+	fmt.Println("  -h, --help          Show this help message")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  simplebill payment add INV-2025-0001 100.00")
+	fmt.Println("  simplebill payment add INV-2025-0001 INV-2025-0002 750.00 --method transfer --reference TX123")
+	fmt.Println("  simplebill payment list")
+}
+
+func RunPayment(args []string) error {
+	if len(args) == 0 {
+		printPaymentHelp()
+		return nil
+	}
+
+	switch args[0] {
+	case "-h", "--help":
+		printPaymentHelp()
+		return nil
+	case "add":
+		return addPayment(args[1:])
+	case "list":
+		return listPayments(args[1:])
+	default:
+		return fmt.Errorf("unknown payment command '%s'. Use: add, list", args[0])
+	}
+}
+
+func addPayment(args []string) error {
+	date := time.Now()
+	var method, reference string
+	var positional []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-h", "--help":
+			printPaymentHelp()
+			return nil
+		case "--date", "--method", "--reference":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s requires a value", arg)
+			}
+			i++
+			switch arg {
+			case "--date":
+				d, err := parseDate(args[i])
+				if err != nil {
+					return err
+				}
+				date = d
+			case "--method":
+				method = args[i]
+			case "--reference":
+				reference = args[i]
+			}
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	if len(positional) < 2 {
+		printPaymentHelp()
+		return nil
+	}
+
+	amount, err := money.Parse(positional[len(positional)-1])
+	if err != nil {
+		return err
+	}
+	if amount <= 0 {
+		return fmt.Errorf("payment amount must be positive")
+	}
+
+	all, err := invoice.LoadAll()
+	if err != nil {
+		return err
+	}
+	var invoices []*invoice.Invoice
+	seen := map[string]bool{}
+	for _, number := range positional[:len(positional)-1] {
+		// Two copies of one invoice would each take a share, and saving the
+		// second would drop the first
+		if seen[number] {
+			return fmt.Errorf("invoice %s is listed more than once", number)
+		}
+		seen[number] = true
+		inv, err := invoice.Load(number, all)
+		if err != nil {
+			return err
+		}
+		if inv.IsCreditNote() {
+			return fmt.Errorf("%s is a credit note and cannot take payments", number)
+		}
+		if inv.CurrentStatus() == invoice.StatusVoid {
+			return fmt.Errorf("invoice %s is void and cannot take payments", number)
+		}
+		if len(invoices) > 0 && inv.CurrencyInfo().Code != invoices[0].CurrencyInfo().Code {
+			return fmt.Errorf("invoices %s and %s are in different currencies", invoices[0].InvoiceNumber, number)
+		}
+		invoices = append(invoices, inv)
+	}
+
+	// Allocate the payment to each invoice's balance in the order given.
+	// A single invoice may take the whole amount, even if it overpays.
+	remaining := amount
+	allocations := make([]money.Amount, len(invoices))
+	for i, inv := range invoices {
+		if remaining == 0 {
+			break
+		}
+		share := inv.BalanceDue()
+		if len(invoices) == 1 || share > remaining {
+			share = remaining
+		}
+		if share <= 0 {
+			continue
+		}
+		allocations[i] = share
+		remaining -= share
+	}
+
+	currency := invoices[0].CurrencyInfo()
+	if remaining > 0 {
+		return fmt.Errorf("payment of %s exceeds the balance due on the listed invoices by %s",
+			currency.Format(amount), currency.Format(remaining))
+	}
+
+	var numbers []string
+	for i, inv := range invoices {
+		if allocations[i] == 0 {
+			fmt.Printf("Skipped %s: nothing due\n", inv.InvoiceNumber)
+			continue
+		}
+		payment := invoice.Payment{
+			Date:      date.Format("2006-01-02"),
+			Amount:    allocations[i],
+			Method:    method,
+			Reference: reference,
+		}
+		if err := inv.AddPayment(payment, date); err != nil {
+			return err
+		}
+		numbers = append(numbers, inv.InvoiceNumber)
+	}
+
+	// Only save once every allocation succeeded, so a failure leaves no
+	// invoice half-updated
+	for i, inv := range invoices {
+		if allocations[i] == 0 {
+			continue
+		}
+		if err := inv.Save(); err != nil {
+			return err
+		}
+		fmt.Printf("%s  paid %s, balance due %s (%s)\n", inv.InvoiceNumber,
+			currency.Format(allocations[i]), currency.Format(inv.BalanceDue()), inv.CurrentStatus())
+	}
+
+	config.AutoCommit(fmt.Sprintf("simplebill: recorded payment for %s", strings.Join(numbers, ", ")))
+	return nil
+}
+
+func listPayments(args []string) error {
+	all, err := invoice.LoadAll()
+	if err != nil {
+		return err
+	}
+	invoices := all
+	if len(args) > 0 {
+		inv, err := invoice.Load(args[0], all)
+		if err != nil {
+			return err
+		}
+		invoices = []invoice.Invoice{*inv}
+	}
+
+	found := false
+	for _, inv := range invoices {
+		if len(inv.Payments) == 0 {
+			continue
+		}
+		found = true
+		currency := inv.CurrencyInfo()
+		fmt.Printf("%s  total %s, paid %s, balance due %s\n", inv.InvoiceNumber,
+			currency.Format(inv.Total), currency.Format(inv.AmountPaid()), currency.Format(inv.BalanceDue()))
+		for _, p := range inv.Payments {
+			line := fmt.Sprintf("  %s  %s", p.Date, padLeft(currency.Format(p.Amount), 14))
+			if p.Method != "" {
+				line += "  " + p.Method
+			}
+			if p.Reference != "" {
+				line += "  " + p.Reference
+			}
+			fmt.Println(line)
+		}
+	}
+
+	if !found {
+		fmt.Println("No payments recorded.")
+	}
+	return nil
+}
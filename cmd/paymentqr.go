@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"image"
+	"strings"
+
+	"simplebill/internal/config"
+	"simplebill/internal/girocode"
+	"simplebill/internal/iban"
+	"simplebill/internal/invoice"
+	"simplebill/internal/qr"
+	"simplebill/internal/qrbill"
+)
+
+// payment_qr settings
+const (
+	paymentQRBill   = "qr-bill"
+	paymentGiroCode = "girocode"
+)
+
+// paymentQR returns the customer's payment_qr setting, falling back to the
+// default in config.yml
+func paymentQR(cfg *config.Config, customer *config.Customer) (string, error) {
+	setting := customer.PaymentQR
+	if setting == "" {
+		setting = cfg.Invoice.PaymentQR
+	}
+	switch strings.ToLower(strings.TrimSpace(setting)) {
+	case "", "none":
+		return "", nil
+	case "qr-bill", "qrbill", "swiss":
+		return paymentQRBill, nil
+	case "girocode", "epc", "sepa":
+		return paymentGiroCode, nil
+	}
+	return "", fmt.Errorf("unknown payment_qr '%s', expected qr-bill, girocode or none", setting)
+}
+
+// checkPaymentQR catches missing payment QR details before anything is saved
+func checkPaymentQR(inv *invoice.Invoice, cfg *config.Config, customer *config.Customer) error {
+	if _, err := swissQRBill(inv, cfg, customer); err != nil {
+		return err
+	}
+	_, err := giroCodeTransfer(inv, cfg, customer)
+	return err
+}
+
+// pngDataURI embeds an image in the template, so it works with any renderer
+func pngDataURI(img image.Image) (template.URL, error) {
+	png, err := qr.PNG(img)
+	if err != nil {
+		return "", err
+	}
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png)), nil
+}
+
+// TemplateGiroCode is the EPC QR code for a SEPA credit transfer
+type TemplateGiroCode struct {
+	Image      template.URL // PNG data URI of the QR code
+	Name       string       // beneficiary
+	IBAN       string       // in blocks of four
+	BIC        string
+	Amount     string // e.g. "1.234,50 €"
+	Remittance string
+
+	code *qr.Code
+}
+
+// giroCodeTransfer builds the credit transfer for inv, or returns nil when
+// the customer doesn't get a GiroCode or nothing is left to pay
+func giroCodeTransfer(inv *invoice.Invoice, cfg *config.Config, customer *config.Customer) (*girocode.Transfer, error) {
+	setting, err := paymentQR(cfg, customer)
+	if err != nil || setting != paymentGiroCode {
+		return nil, err
+	}
+	if inv.IsCreditNote() || inv.IsQuote() || inv.BalanceDue() <= 0 {
+		return nil, nil
+	}
+
+	company := cfg.Company
+	if inv.CompanyInfo != nil {
+		company = *inv.CompanyInfo
+	}
+
+	var problems []string
+	t := &girocode.Transfer{
+		BIC:        strings.ToUpper(strings.TrimSpace(company.BIC)),
+		Name:       strings.TrimSpace(company.Name),
+		Amount:     inv.BalanceDue(),
+		Remittance: inv.InvoiceNumber,
+	}
+	if company.IBAN == "" {
+		problems = append(problems, "company.iban is missing in config.yml")
+	} else if t.IBAN, err = iban.Parse(company.IBAN); err != nil {
+		problems = append(problems, fmt.Sprintf("company.iban in config.yml is not valid: %s", err))
+	}
+	if code := inv.CurrencyInfo().Code; code != "EUR" {
+		problems = append(problems, fmt.Sprintf("GiroCodes can only be in EUR, not %s", code))
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("cannot create the GiroCode:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// templateGiroCode builds the GiroCode for the template, or nil when the
+// invoice doesn't get one
+func templateGiroCode(inv *invoice.Invoice, cfg *config.Config, customer *config.Customer) (*TemplateGiroCode, error) {
+	t, err := giroCodeTransfer(inv, cfg, customer)
+	if err != nil || t == nil {
+		return nil, err
+	}
+	code, err := t.Code()
+	if err != nil {
+		return nil, err
+	}
+	image, err := pngDataURI(code.Image(8, 4))
+	if err != nil {
+		return nil, err
+	}
+	return &TemplateGiroCode{
+		Image:      image,
+		Name:       t.Name,
+		IBAN:       qrbill.FormatAccount(t.IBAN),
+		BIC:        t.BIC,
+		Amount:     inv.CurrencyInfo().Format(t.Amount),
+		Remittance: t.Remittance,
+		code:       code,
+	}, nil
+}
@@ -8,76 +8,192 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
 
 	"simplebill/internal/config"
+	"simplebill/internal/einvoice"
 	"simplebill/internal/invoice"
+	"simplebill/internal/money"
 )
 
 // TemplateData holds all data passed to the HTML template
 type TemplateData struct {
+	Title         string
 	InvoiceNumber string
 	Date          string
 	DueDate       string
+	ValidUntil    string // quotes only
 	Company       config.Company
 	Customer      config.Customer
 	PaymentTerms  string
 	Notes         string
 	Items         []TemplateItem
-	Total         float64
+	Subtotal      money.Amount
+	Taxes         []TemplateTax
+	TaxNote       string
+	Total         money.Amount
+	AmountPaid    money.Amount
+	BalanceDue    money.Amount
+	Currency      money.Currency
+	CreditFor     string
+	Period        string            // billing period of a recurring invoice
+	Quote         string            // the quote an invoice was accepted from
+	QRBill        *TemplateQRBill   // Swiss QR-bill payment part, when the customer gets one
+	GiroCode      *TemplateGiroCode // EPC QR code for SEPA transfers, when the customer gets one
+	Reminder      *TemplateReminder // set when rendering a payment reminder for the invoice
+}
+
+// TemplateReminder is a payment reminder for an overdue invoice, rendered
+// with reminder.html
+type TemplateReminder struct {
+	Level       int
+	Name        string
+	Text        string
+	Date        string
+	DaysOverdue int
+	Paid        money.Amount // paid or credited on the invoice
+	Fee         money.Amount // charged with this reminder
+	Fees        money.Amount // charged with all reminders so far
+	LateFees    money.Amount // late fees and interest added to reminders so far
+	AmountDue   money.Amount // balance due plus fees
 }
 
 // TemplateItem holds item data for the template
 type TemplateItem struct {
-	Name     string
-	SKU      string
-	Quantity int
-	Price    float64
-	Total    float64
+	Name        string
+	SKU         string
+	Description string
+	Quantity    money.Decimal
+	Unit        string
+	Price       money.Amount
+	Total       money.Amount
+}
+
+// TemplateTax holds one row of the tax summary for the template
+type TemplateTax struct {
+	Name   string
+	Rate   money.Decimal
+	Net    money.Amount
+	Amount money.Amount
 }
 
 func buildTemplateData(inv *invoice.Invoice, cfg *config.Config, customer *config.Customer, products map[string]config.Product) TemplateData {
 	var items []TemplateItem
 	for _, item := range inv.Items {
-		prod := products[item.Product]
-		name := prod.Name
+		// Invoices created before item details were saved look them up
+		if item.Name == "" {
+			item.Describe(products[item.Product])
+		}
+		name := item.Name
 		if item.Discount > 0 {
-			name = fmt.Sprintf("%s (%d%% off)", prod.Name, item.Discount)
+			name = fmt.Sprintf("%s (%d%% off)", item.Name, item.Discount)
 		}
 		items = append(items, TemplateItem{
-			Name:     name,
-			SKU:      prod.SKU,
-			Quantity: item.Quantity,
-			Price:    item.UnitPrice,
-			Total:    item.Total,
+			Name:        name,
+			SKU:         item.SKU,
+			Description: item.Description,
+			Quantity:    item.Quantity,
+			Unit:        item.Unit,
+			Price:       item.UnitPrice,
+			Total:       item.Total,
 		})
 	}
 
+	var taxes []TemplateTax
+	for _, t := range inv.Taxes {
+		taxes = append(taxes, TemplateTax{
+			Name:   t.Name,
+			Rate:   t.Rate,
+			Net:    t.Net,
+			Amount: t.Amount,
+		})
+	}
+
+	company := cfg.Company
+	if inv.CompanyInfo != nil {
+		company = *inv.CompanyInfo
+	}
+
+	var period string
+	if inv.Recurring != nil {
+		period = periodText(inv.Recurring)
+	}
+
 	return TemplateData{
+		Title:         strings.ToUpper(documentName(inv)),
 		InvoiceNumber: inv.InvoiceNumber,
 		Date:          inv.Date,
 		DueDate:       inv.DueDate,
-		Company:       cfg.Company,
+		ValidUntil:    inv.ValidUntil,
+		Company:       company,
 		Customer:      *customer,
 		PaymentTerms:  cfg.Invoice.PaymentTerms,
 		Notes:         cfg.Invoice.Notes,
 		Items:         items,
+		Subtotal:      inv.Net(),
+		Taxes:         taxes,
+		TaxNote:       inv.TaxNote,
 		Total:         inv.Total,
+		AmountPaid:    inv.AmountPaid(),
+		BalanceDue:    inv.BalanceDue(),
+		Currency:      inv.CurrencyInfo(),
+		CreditFor:     inv.CreditFor,
+		Period:        period,
+		Quote:         inv.Quote,
+	}
+}
+
+// templateFuncs returns helpers available to template.html. money formats an
+// amount in the invoice currency, e.g. {{money .Total}} gives "1.234,50 €".
+func templateFuncs(currency money.Currency) template.FuncMap {
+	return template.FuncMap{
+		"money": currency.Format,
+	}
+}
+
+// documentName returns what the document is called in prompts and titles
+func documentName(inv *invoice.Invoice) string {
+	if inv.IsCreditNote() {
+		return "credit note"
+	}
+	if inv.IsQuote() {
+		return "quote"
 	}
+	return "invoice"
 }
 
-func renderHTML(data TemplateData) ([]byte, error) {
+// templateFile returns the template in ~/.simplebill used to render inv and
+// the embedded default used when an older setup doesn't have that file yet
+func templateFile(inv *invoice.Invoice) (string, string) {
+	if inv.IsCreditNote() {
+		return "credit_note.html", "templates/credit_note.html"
+	}
+	if inv.IsQuote() {
+		return "quote.html", "templates/quote.html"
+	}
+	return "template.html", ""
+}
+
+func renderHTML(inv *invoice.Invoice, data TemplateData) ([]byte, error) {
 	dir, err := config.Dir()
 	if err != nil {
 		return nil, err
 	}
 
-	tmplPath := filepath.Join(dir, "template.html")
+	name, fallback := templateFile(inv)
+	if data.Reminder != nil {
+		name, fallback = "reminder.html", "templates/reminder.html"
+	}
+	tmplPath := filepath.Join(dir, name)
 	tmplContent, err := os.ReadFile(tmplPath)
+	if os.IsNotExist(err) && fallback != "" {
+		tmplContent, err = templates.ReadFile(fallback)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("reading template: %w", err)
 	}
 
-	tmpl, err := template.New("invoice").Parse(string(tmplContent))
+	tmpl, err := template.New("invoice").Funcs(templateFuncs(data.Currency)).Parse(string(tmplContent))
 	if err != nil {
 		return nil, fmt.Errorf("parsing template: %w", err)
 	}
@@ -90,95 +206,67 @@ func renderHTML(data TemplateData) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func runWkhtmltopdf(htmlPath, pdfPath string) error {
-	if _, err := exec.LookPath("wkhtmltopdf"); err != nil {
-		return fmt.Errorf("wkhtmltopdf not installed\n\nInstall it with:\n  macOS: brew install wkhtmltopdf\n  Ubuntu/Debian: sudo apt install wkhtmltopdf\n  Fedora: sudo dnf install wkhtmltopdf")
+// RenderPDF renders invoice to final PDF location. If outputPath is empty, uses default location.
+func RenderPDF(inv *invoice.Invoice, cfg *config.Config, customer *config.Customer, products map[string]config.Product, outputPath string) error {
+	// Determine output path
+	if outputPath == "" {
+		dir, err := inv.Dir()
+		if err != nil {
+			return err
+		}
+		outputPath = filepath.Join(dir, inv.InvoiceNumber+".pdf")
 	}
 
-	args := []string{
-		"--page-size", "Letter",
-		"--margin-top", "10mm",
-		"--margin-bottom", "10mm",
-		"--margin-left", "10mm",
-		"--margin-right", "10mm",
+	backend, err := newPDFBackend(cfg)
+	if err != nil {
+		return err
 	}
 
-	if runtime.GOOS != "windows" {
-		args = append(args, "--quiet")
+	format, err := einvoiceFormat(customer)
+	if err != nil {
+		return err
 	}
 
-	args = append(args, htmlPath, pdfPath)
-
-	cmd := exec.Command("wkhtmltopdf", args...)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("wkhtmltopdf failed: %s\n%s", err, output)
+	job := renderJob{inv: inv, data: buildTemplateData(inv, cfg, customer, products)}
+	if job.data.QRBill, err = templateQRBill(inv, cfg, customer); err != nil {
+		return err
 	}
-
-	return nil
-}
-
-// RenderPDF renders invoice to final PDF location. If outputPath is empty, uses default location.
-func RenderPDF(inv *invoice.Invoice, cfg *config.Config, customer *config.Customer, products map[string]config.Product, outputPath string) error {
-	data := buildTemplateData(inv, cfg, customer, products)
-	html, err := renderHTML(data)
-	if err != nil {
+	if job.data.GiroCode, err = templateGiroCode(inv, cfg, customer); err != nil {
 		return err
 	}
-
-	// Write HTML to temp file
-	tmpFile, err := os.CreateTemp("", "simplebill-*.html")
-	if err != nil {
-		return fmt.Errorf("creating temp file: %w", err)
+	if format == einvoice.FacturX && !inv.IsQuote() {
+		return renderFacturX(backend, job, cfg, customer, outputPath)
 	}
-	defer os.Remove(tmpFile.Name())
-
-	if _, err := tmpFile.Write(html); err != nil {
-		tmpFile.Close()
-		return fmt.Errorf("writing temp file: %w", err)
+	if err := backend.Render(job, outputPath); err != nil {
+		return err
 	}
-	tmpFile.Close()
-
-	// Determine output path
-	if outputPath == "" {
-		dir, err := config.Dir()
-		if err != nil {
-			return err
-		}
-		outputPath = filepath.Join(dir, "invoices", inv.InvoiceNumber+".pdf")
+	if len(inv.Receipts) == 0 {
+		return nil
 	}
 
-	return runWkhtmltopdf(tmpFile.Name(), outputPath)
-}
-
-// RenderPDFToTemp renders invoice to a temp PDF file and returns the path
-func RenderPDFToTemp(inv *invoice.Invoice, cfg *config.Config, customer *config.Customer, products map[string]config.Product) (string, error) {
-	data := buildTemplateData(inv, cfg, customer, products)
-	html, err := renderHTML(data)
+	rendered, err := os.ReadFile(outputPath)
 	if err != nil {
-		return "", err
+		return fmt.Errorf("reading rendered PDF: %w", err)
 	}
-
-	// Write HTML to temp file
-	tmpHTML, err := os.CreateTemp("", "simplebill-*.html")
+	out, err := appendReceipts(inv, rendered)
 	if err != nil {
-		return "", fmt.Errorf("creating temp file: %w", err)
+		return err
 	}
-	defer os.Remove(tmpHTML.Name())
-
-	if _, err := tmpHTML.Write(html); err != nil {
-		tmpHTML.Close()
-		return "", fmt.Errorf("writing temp file: %w", err)
+	if err := os.WriteFile(outputPath, out, 0644); err != nil {
+		return fmt.Errorf("writing PDF: %w", err)
 	}
-	tmpHTML.Close()
+	return nil
+}
 
-	// Create temp PDF file
+// RenderPDFToTemp renders invoice to a temp PDF file and returns the path
+func RenderPDFToTemp(inv *invoice.Invoice, cfg *config.Config, customer *config.Customer, products map[string]config.Product) (string, error) {
 	tmpPDF, err := os.CreateTemp("", "simplebill-preview-*.pdf")
 	if err != nil {
 		return "", fmt.Errorf("creating temp PDF: %w", err)
 	}
 	tmpPDF.Close()
 
-	if err := runWkhtmltopdf(tmpHTML.Name(), tmpPDF.Name()); err != nil {
+	if err := RenderPDF(inv, cfg, customer, products, tmpPDF.Name()); err != nil {
 		os.Remove(tmpPDF.Name())
 		return "", err
 	}
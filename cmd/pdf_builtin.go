@@ -0,0 +1,778 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"simplebill/internal/config"
+	"simplebill/internal/money"
+	"simplebill/internal/pdf"
+	"simplebill/internal/qr"
+	"simplebill/internal/qrbill"
+)
+
+// pdfLayout is the layout description read from layout.yml
+type pdfLayout struct {
+	FontSize float64           `yaml:"font_size"`
+	Colors   map[string]string `yaml:"colors"`
+	Columns  []string          `yaml:"columns"`
+	Labels   map[string]string `yaml:"labels"`
+}
+
+// loadLayout reads layout.yml over the embedded defaults, so a partial file
+// only needs the settings it changes
+func loadLayout() (*pdfLayout, error) {
+	defaults, err := templates.ReadFile("templates/layout.yml")
+	if err != nil {
+		return nil, fmt.Errorf("could not read embedded layout: %w", err)
+	}
+
+	var layout pdfLayout
+	if err := yaml.Unmarshal(defaults, &layout); err != nil {
+		return nil, fmt.Errorf("parsing embedded layout: %w", err)
+	}
+
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, "layout.yml")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &layout, nil
+		}
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	var custom pdfLayout
+	if err := yaml.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if custom.FontSize > 0 {
+		layout.FontSize = custom.FontSize
+	}
+	if custom.Columns != nil {
+		layout.Columns = custom.Columns
+	}
+	for k, v := range custom.Colors {
+		layout.Colors[k] = v
+	}
+	for k, v := range custom.Labels {
+		layout.Labels[k] = v
+	}
+	return &layout, nil
+}
+
+// builtinRenderer lays out an invoice page by page with the pdf package
+type builtinRenderer struct {
+	doc    *pdf.Document
+	layout *pdfLayout
+	data   TemplateData
+	format func(money.Amount) string
+
+	top, left, right, bottom float64
+	fontSize, lineHeight     float64
+	y                        float64
+}
+
+// renderBuiltin writes the invoice PDF without any external program
+func renderBuiltin(data TemplateData, page pageSetup, fonts [2]*pdf.Font, outputPath string) error {
+	layout, err := loadLayout()
+	if err != nil {
+		return err
+	}
+
+	doc := pdf.New(page.width, page.height)
+	doc.Title = fmt.Sprintf("%s %s", data.Title, data.InvoiceNumber)
+	doc.Author = data.Company.Name
+	if fonts[0] != nil {
+		doc.EmbedFonts(fonts[0], fonts[1])
+	}
+
+	r := &builtinRenderer{
+		doc:        doc,
+		layout:     layout,
+		data:       data,
+		format:     pdfMoneyFormat(data.Currency),
+		top:        page.margins[0],
+		left:       page.margins[3],
+		right:      doc.Width() - page.margins[1],
+		bottom:     doc.Height() - page.margins[2] - layout.FontSize*3,
+		fontSize:   layout.FontSize,
+		lineHeight: layout.FontSize * 1.5,
+	}
+
+	r.newPage()
+	r.header()
+	r.billTo()
+	if data.Reminder != nil {
+		r.reminder()
+		r.footer()
+		return writeBuiltin(doc, outputPath)
+	}
+	r.items()
+	r.totals()
+	r.taxSummary()
+	r.notes()
+	r.giroCode()
+	r.footer()
+	if data.QRBill != nil {
+		r.qrBill(data.QRBill)
+	}
+	return writeBuiltin(doc, outputPath)
+}
+
+func writeBuiltin(doc *pdf.Document, outputPath string) error {
+	out, err := doc.Bytes()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(outputPath, out, 0644); err != nil {
+		return fmt.Errorf("writing PDF: %w", err)
+	}
+	return nil
+}
+
+// systemFonts are regular and bold fonts to embed when config.yml doesn't
+// name any, in order of preference
+var systemFonts = [][2]string{
+	{"/usr/share/fonts/truetype/liberation/LiberationSans-Regular.ttf", "/usr/share/fonts/truetype/liberation/LiberationSans-Bold.ttf"},
+	{"/usr/share/fonts/liberation-sans/LiberationSans-Regular.ttf", "/usr/share/fonts/liberation-sans/LiberationSans-Bold.ttf"},
+	{"/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf", "/usr/share/fonts/truetype/dejavu/DejaVuSans-Bold.ttf"},
+	{"/usr/share/fonts/dejavu-sans-fonts/DejaVuSans.ttf", "/usr/share/fonts/dejavu-sans-fonts/DejaVuSans-Bold.ttf"},
+	{"/System/Library/Fonts/Supplemental/Arial.ttf", "/System/Library/Fonts/Supplemental/Arial Bold.ttf"},
+	{`C:\Windows\Fonts\arial.ttf`, `C:\Windows\Fonts\arialbd.ttf`},
+}
+
+// loadEmbedFonts loads the fonts the built-in renderer embeds for PDF/A
+func loadEmbedFonts(cfg *config.Config) (*pdf.Font, *pdf.Font, error) {
+	paths := [2]string{cfg.EInvoice.Font, cfg.EInvoice.FontBold}
+	if paths[0] == "" {
+		for _, candidate := range systemFonts {
+			if _, err := os.Stat(candidate[0]); err == nil {
+				paths = candidate
+				break
+			}
+		}
+		if paths[0] == "" {
+			return nil, nil, fmt.Errorf("no font found to embed for PDF/A\n\nSet 'einvoice: font:' in config.yml to a .ttf file, e.g. Liberation Sans or DejaVu Sans")
+		}
+	}
+
+	regular, err := pdf.LoadFont(paths[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	bold := regular
+	if _, err := os.Stat(paths[1]); err == nil {
+		if bold, err = pdf.LoadFont(paths[1]); err != nil {
+			return nil, nil, err
+		}
+	}
+	return regular, bold, nil
+}
+
+// pdfMoneyFormat formats amounts like the money template helper, using the
+// currency code where the standard fonts lack the symbol (e.g. ₹)
+func pdfMoneyFormat(c money.Currency) func(money.Amount) string {
+	if !pdf.CanEncode(c.Symbol) {
+		c.Symbol = c.Code
+		c.SymbolAfter = true
+	}
+	return c.Format
+}
+
+func (r *builtinRenderer) label(key string) string {
+	return r.layout.Labels[key]
+}
+
+func (r *builtinRenderer) color(key string) {
+	red, green, blue := parseHexColor(r.layout.Colors[key])
+	r.doc.SetFillColor(red, green, blue)
+}
+
+func (r *builtinRenderer) strokeColor(key string) {
+	red, green, blue := parseHexColor(r.layout.Colors[key])
+	r.doc.SetStrokeColor(red, green, blue)
+}
+
+func (r *builtinRenderer) newPage() {
+	if r.doc.PageCount() > 0 {
+		r.footer()
+	}
+	r.doc.AddPage()
+	r.y = r.top
+}
+
+// ensure starts a new page when height more points don't fit
+func (r *builtinRenderer) ensure(height float64) bool {
+	if r.y+height <= r.bottom {
+		return false
+	}
+	r.newPage()
+	return true
+}
+
+// lines draws each line of text at x, advancing the cursor
+func (r *builtinRenderer) lines(x float64, text string, width float64) {
+	for _, line := range r.doc.Wrap(text, width) {
+		r.y += r.lineHeight
+		r.doc.Text(x, r.y, line)
+	}
+}
+
+func (r *builtinRenderer) header() {
+	d := r.data
+	half := (r.right - r.left) / 2
+
+	// Company details on the left
+	top := r.y
+	r.color("heading")
+	r.doc.SetFont(true, r.fontSize*2)
+	r.y += r.fontSize * 2
+	r.doc.Text(r.left, r.y, d.Company.Name)
+	r.y += r.fontSize * 0.5
+
+	r.color("muted")
+	r.doc.SetFont(false, r.fontSize)
+	r.lines(r.left, d.Company.Address, half)
+	for _, s := range []string{d.Company.ID, d.Company.Email, d.Company.Phone} {
+		if s != "" {
+			r.lines(r.left, s, half)
+		}
+	}
+	leftBottom := r.y
+
+	// Document title, number and dates on the right
+	r.y = top
+	title := r.label("invoice")
+	if d.Reminder != nil {
+		title = strings.ToUpper(d.Reminder.Name)
+	} else if d.CreditFor != "" {
+		title = r.label("credit_note")
+	} else if d.ValidUntil != "" {
+		title = r.label("quote")
+	}
+	r.color("heading")
+	r.doc.SetFont(true, r.fontSize*1.8)
+	r.y += r.fontSize * 2
+	r.doc.TextRight(r.right, r.y, title)
+	r.y += r.fontSize * 0.5
+
+	r.color("muted")
+	r.doc.SetFont(false, r.fontSize)
+	rightLines := []string{d.InvoiceNumber, r.label("date") + " " + d.Date}
+	if d.Reminder != nil {
+		rightLines = []string{
+			r.label("reminder_for") + " " + d.InvoiceNumber,
+			r.label("date") + " " + d.Reminder.Date,
+			r.label("due") + " " + d.DueDate,
+		}
+	} else if d.CreditFor != "" {
+		rightLines = append(rightLines, r.label("credit_for")+" "+d.CreditFor)
+	} else if d.ValidUntil != "" {
+		rightLines = append(rightLines, r.label("valid_until")+" "+d.ValidUntil)
+	} else if d.DueDate != "" {
+		rightLines = append(rightLines, r.label("due")+" "+d.DueDate)
+	}
+	if d.Reminder == nil && d.Period != "" {
+		rightLines = append(rightLines, r.label("period")+" "+d.Period)
+	}
+	if d.Reminder == nil && d.Quote != "" {
+		rightLines = append(rightLines, r.label("from_quote")+" "+d.Quote)
+	}
+	for _, s := range rightLines {
+		r.y += r.lineHeight
+		r.doc.TextRight(r.right, r.y, s)
+	}
+
+	if leftBottom > r.y {
+		r.y = leftBottom
+	}
+	r.y += r.lineHeight * 2
+}
+
+func (r *builtinRenderer) billTo() {
+	d := r.data
+	width := r.right - r.left
+
+	r.color("muted")
+	r.doc.SetFont(true, r.fontSize)
+	r.y += r.lineHeight
+	r.doc.Text(r.left, r.y, r.label("bill_to"))
+
+	r.color("heading")
+	r.lines(r.left, d.Customer.Name, width)
+
+	r.color("muted")
+	r.doc.SetFont(false, r.fontSize)
+	r.lines(r.left, d.Customer.Address, width)
+	for _, s := range []string{d.Customer.ID, d.Customer.Email, d.Customer.Phone} {
+		if s != "" {
+			r.lines(r.left, s, width)
+		}
+	}
+	r.y += r.lineHeight
+
+	if d.Reminder != nil {
+		r.y += r.lineHeight
+		return
+	}
+	if d.CreditFor == "" && d.PaymentTerms != "" {
+		r.y += r.lineHeight
+		r.doc.SetFont(true, r.fontSize)
+		label := r.label("payment_terms") + " "
+		r.doc.Text(r.left, r.y, label)
+		labelWidth := r.doc.StringWidth(label)
+		r.doc.SetFont(false, r.fontSize)
+		r.doc.Text(r.left+labelWidth, r.y, d.PaymentTerms)
+		r.y += r.lineHeight
+	}
+	if d.TaxNote != "" {
+		r.doc.SetFont(false, r.fontSize)
+		r.lines(r.left, d.TaxNote, width)
+		r.y += r.lineHeight
+	}
+	r.y += r.lineHeight
+}
+
+// column is one column of the items table
+type column struct {
+	key   string
+	width float64 // 0 takes the remaining width
+	right bool
+	x     float64
+}
+
+func (r *builtinRenderer) columns() []column {
+	fixed := map[string]float64{
+		"sku":      r.fontSize * 9,
+		"quantity": r.fontSize * 5,
+		"price":    r.fontSize * 9,
+		"total":    r.fontSize * 9,
+	}
+
+	var cols []column
+	used := 0.0
+	for _, key := range r.layout.Columns {
+		col := column{key: key, width: fixed[key], right: key == "quantity" || key == "price" || key == "total"}
+		used += col.width
+		cols = append(cols, col)
+	}
+
+	x := r.left
+	for i := range cols {
+		if cols[i].width == 0 {
+			cols[i].width = r.right - r.left - used
+		}
+		cols[i].x = x
+		x += cols[i].width
+	}
+	return cols
+}
+
+// cell draws text in a table cell, right-aligned for numeric columns
+func (r *builtinRenderer) cell(col column, y float64, text string) {
+	pad := r.fontSize * 0.6
+	if col.right {
+		r.doc.TextRight(col.x+col.width-pad, y, text)
+	} else {
+		r.doc.Text(col.x+pad, y, text)
+	}
+}
+
+func (r *builtinRenderer) tableHeader(cols []column) {
+	r.y += r.lineHeight
+	r.color("muted")
+	r.doc.SetFont(true, r.fontSize)
+	for _, col := range cols {
+		r.cell(col, r.y, r.label(col.key))
+	}
+	r.y += r.lineHeight * 0.6
+	r.strokeColor("rule")
+	r.doc.Line(r.left, r.y, r.right, r.y, 1.5)
+}
+
+func (r *builtinRenderer) items() {
+	cols := r.columns()
+	r.tableHeader(cols)
+
+	pad := r.fontSize * 0.6
+	for _, item := range r.data.Items {
+		// Work out how tall the row is before drawing it
+		var nameWidth float64
+		for _, col := range cols {
+			if col.key == "item" {
+				nameWidth = col.width - 2*pad
+			}
+		}
+		r.doc.SetFont(false, r.fontSize)
+		nameLines := r.doc.Wrap(item.Name, nameWidth)
+		r.doc.SetFont(false, r.fontSize*0.85)
+		var descLines []string
+		if item.Description != "" {
+			descLines = r.doc.Wrap(item.Description, nameWidth)
+		}
+		height := float64(len(nameLines))*r.lineHeight + float64(len(descLines))*r.fontSize*1.3 + r.lineHeight*0.6
+
+		if r.ensure(height) {
+			r.tableHeader(cols)
+		}
+
+		top := r.y
+		r.y += r.lineHeight
+		for _, col := range cols {
+			switch col.key {
+			case "item":
+				r.color("text")
+				r.doc.SetFont(false, r.fontSize)
+				y := r.y
+				for i, line := range nameLines {
+					r.doc.Text(col.x+pad, y+float64(i)*r.lineHeight, line)
+				}
+				y += float64(len(nameLines)-1) * r.lineHeight
+				r.color("muted")
+				r.doc.SetFont(false, r.fontSize*0.85)
+				for _, line := range descLines {
+					y += r.fontSize * 1.3
+					r.doc.Text(col.x+pad, y, line)
+				}
+			case "sku":
+				r.color("muted")
+				r.doc.SetFont(false, r.fontSize)
+				r.cell(col, r.y, item.SKU)
+			case "quantity":
+				r.color("text")
+				r.doc.SetFont(false, r.fontSize)
+				qty := item.Quantity.String()
+				if item.Unit != "" {
+					qty += " " + item.Unit
+				}
+				r.cell(col, r.y, qty)
+			case "price":
+				r.color("text")
+				r.doc.SetFont(false, r.fontSize)
+				r.cell(col, r.y, r.format(item.Price))
+			case "total":
+				r.color("text")
+				r.doc.SetFont(false, r.fontSize)
+				r.cell(col, r.y, r.format(item.Total))
+			}
+		}
+
+		r.y = top + height
+		r.strokeColor("rule")
+		r.doc.Line(r.left, r.y, r.right, r.y, 0.5)
+	}
+}
+
+// totalLine draws a label and amount aligned with the right of the table
+func (r *builtinRenderer) totalLine(label string, amount money.Amount, bold bool) {
+	size := r.fontSize
+	if bold {
+		size = r.fontSize * 1.15
+	}
+	r.ensure(r.lineHeight)
+	r.y += r.lineHeight
+	r.color("heading")
+	r.doc.SetFont(bold, size)
+	labelRight := r.right - r.fontSize*10
+	r.doc.TextRight(labelRight, r.y, label)
+	r.doc.TextRight(r.right-r.fontSize*0.6, r.y, r.format(amount))
+}
+
+func (r *builtinRenderer) totals() {
+	d := r.data
+	r.y += r.lineHeight * 0.5
+
+	if len(d.Taxes) > 0 {
+		r.totalLine(r.label("subtotal"), d.Subtotal, false)
+		for _, t := range d.Taxes {
+			r.totalLine(t.Name+":", t.Amount, false)
+		}
+	}
+
+	label := r.label("total_due")
+	if d.CreditFor != "" {
+		label = r.label("total_credit")
+	}
+	r.y += r.lineHeight * 0.3
+	r.strokeColor("rule")
+	r.doc.Line(r.right-r.fontSize*22, r.y, r.right, r.y, 1.5)
+	r.totalLine(label, d.Total, true)
+
+	if d.AmountPaid != 0 {
+		r.totalLine(r.label("amount_paid"), d.AmountPaid, false)
+		r.totalLine(r.label("balance_due"), d.BalanceDue, true)
+	}
+	r.y += r.lineHeight
+}
+
+func (r *builtinRenderer) taxSummary() {
+	if len(r.data.Taxes) == 0 {
+		return
+	}
+
+	size := r.fontSize * 0.9
+	colWidth := r.fontSize * 8
+	x := []float64{r.right - 4*colWidth, r.right - 2*colWidth, r.right - colWidth, r.right}
+	r.ensure(r.lineHeight * float64(len(r.data.Taxes)+2))
+
+	r.y += r.lineHeight
+	r.color("muted")
+	r.doc.SetFont(true, size)
+	r.doc.Text(x[0], r.y, r.label("tax"))
+	r.doc.TextRight(x[1], r.y, r.label("rate"))
+	r.doc.TextRight(x[2], r.y, r.label("net"))
+	r.doc.TextRight(x[3], r.y, r.label("tax"))
+	r.y += r.lineHeight * 0.4
+	r.strokeColor("rule")
+	r.doc.Line(x[0], r.y, r.right, r.y, 0.5)
+
+	r.color("text")
+	r.doc.SetFont(false, size)
+	for _, t := range r.data.Taxes {
+		r.y += r.lineHeight
+		r.doc.Text(x[0], r.y, t.Name)
+		r.doc.TextRight(x[1], r.y, t.Rate.String()+"%")
+		r.doc.TextRight(x[2], r.y, r.format(t.Net))
+		r.doc.TextRight(x[3], r.y, r.format(t.Amount))
+	}
+	r.y += r.lineHeight
+}
+
+func (r *builtinRenderer) notes() {
+	if r.data.Notes == "" || r.data.CreditFor != "" {
+		return
+	}
+
+	pad := r.fontSize
+	width := r.right - r.left - 2*pad
+	r.doc.SetFont(false, r.fontSize)
+	text := r.doc.Wrap(r.data.Notes, width)
+	height := float64(len(text)+1)*r.lineHeight + 2*pad
+	r.ensure(height + r.lineHeight)
+
+	r.y += r.lineHeight
+	r.color("notes_background")
+	r.doc.FillRect(r.left, r.y, r.right-r.left, height)
+
+	r.y += pad
+	r.color("muted")
+	r.doc.SetFont(true, r.fontSize)
+	r.y += r.lineHeight
+	r.doc.Text(r.left+pad, r.y, r.label("notes"))
+	r.doc.SetFont(false, r.fontSize)
+	for _, line := range text {
+		r.y += r.lineHeight
+		r.doc.Text(r.left+pad, r.y, line)
+	}
+	r.y += pad
+}
+
+// reminder draws the text of a payment reminder and what is owed on the
+// invoice it is about
+func (r *builtinRenderer) reminder() {
+	d := r.data
+	rem := d.Reminder
+
+	r.color("text")
+	r.doc.SetFont(false, r.fontSize)
+	for _, paragraph := range strings.Split(strings.TrimSpace(rem.Text), "\n") {
+		r.lines(r.left, paragraph, r.right-r.left)
+	}
+	r.y += r.lineHeight
+
+	r.y += r.lineHeight * 0.3
+	r.strokeColor("rule")
+	r.doc.Line(r.right-r.fontSize*22, r.y, r.right, r.y, 1.5)
+	r.totalLine(r.label("invoice_total"), d.Total, false)
+	if rem.Paid != 0 {
+		r.totalLine(r.label("reminder_paid"), rem.Paid, false)
+	}
+	if rem.Fees != 0 {
+		r.totalLine(r.label("reminder_fees"), rem.Fees, false)
+	}
+	if rem.LateFees != 0 {
+		r.totalLine(r.label("late_fees"), rem.LateFees, false)
+	}
+	r.totalLine(r.label("amount_due"), rem.AmountDue, true)
+	r.y += r.lineHeight
+}
+
+// footer draws the company name at the bottom of the current page
+func (r *builtinRenderer) footer() {
+	y := r.bottom + r.fontSize*1.5
+	r.strokeColor("rule")
+	r.doc.Line(r.left, y, r.right, y, 0.5)
+	r.color("muted")
+	r.doc.SetFont(false, r.fontSize*0.8)
+	r.doc.TextCenter((r.left+r.right)/2, y+r.fontSize*1.5, r.data.Company.Name)
+}
+
+// qrCode draws the dark modules of code in black as a square of side
+// points, a row of modules at a time
+func (r *builtinRenderer) qrCode(code *qr.Code, x, y, side float64) {
+	r.doc.SetFillColor(0, 0, 0)
+	module := side / float64(code.Size)
+	for row := 0; row < code.Size; row++ {
+		for col := 0; col < code.Size; {
+			if !code.Dark(col, row) {
+				col++
+				continue
+			}
+			run := col
+			for run < code.Size && code.Dark(run, row) {
+				run++
+			}
+			r.doc.FillRect(x+float64(col)*module, y+float64(row)*module, float64(run-col)*module, module)
+			col = run
+		}
+	}
+}
+
+// giroCode draws the EPC QR code with the bank details beside it
+func (r *builtinRenderer) giroCode() {
+	g := r.data.GiroCode
+	if g == nil {
+		return
+	}
+
+	// The code is 30 mm with a quiet zone of 4 modules on each side
+	side := 30 * pointsPerMM
+	quiet := side * 4 / float64(g.code.Size)
+	r.ensure(side + 2*quiet + r.lineHeight)
+	r.y += r.lineHeight
+	r.qrCode(g.code, r.left+quiet, r.y+quiet, side)
+
+	x := r.left + side + 2*quiet + r.fontSize
+	y := r.y + quiet
+	r.color("muted")
+	r.doc.SetFont(true, r.fontSize)
+	y += r.lineHeight
+	r.doc.Text(x, y, r.label("girocode"))
+	r.doc.SetFont(false, r.fontSize)
+	for _, line := range [][2]string{
+		{"girocode_name", g.Name}, {"girocode_iban", g.IBAN}, {"girocode_bic", g.BIC},
+		{"girocode_amount", g.Amount}, {"girocode_reference", g.Remittance},
+	} {
+		if line[1] != "" {
+			y += r.lineHeight
+			r.doc.Text(x, y, r.label(line[0])+" "+line[1])
+		}
+	}
+	r.y += side + 2*quiet
+}
+
+// pointsPerMM converts the millimetres of the QR-bill layout to points
+const pointsPerMM = 72 / 25.4
+
+// qrBill draws the Swiss QR-bill on a page of its own: the receipt and the
+// payment part in the bottom 105 mm, laid out as the style guide asks
+func (r *builtinRenderer) qrBill(b *TemplateQRBill) {
+	r.doc.AddPage()
+	top := r.doc.Height() - 105*pointsPerMM
+	at := func(mm float64) float64 { return mm * pointsPerMM }
+
+	r.doc.SetFillColor(0, 0, 0)
+	r.doc.SetStrokeColor(0, 0, 0)
+	r.doc.Line(0, top, r.doc.Width(), top, 0.5)
+	r.doc.Line(at(62), top, at(62), r.doc.Height(), 0.5)
+
+	// section draws a heading with its values below and returns where the
+	// next section starts
+	section := func(x, y, width, headSize, size float64, heading string, values []string) float64 {
+		r.doc.SetFont(true, headSize)
+		y += headSize
+		r.doc.Text(x, y, heading)
+		r.doc.SetFont(false, size)
+		for _, value := range values {
+			for _, line := range r.doc.Wrap(value, width) {
+				y += size * 1.1
+				r.doc.Text(x, y, line)
+			}
+		}
+		return y + size
+	}
+	// blankBox draws the corner marks of a field for the payer to fill in
+	blankBox := func(x, y, w, h float64) {
+		arm := at(3)
+		for _, c := range [][4]float64{{x, y, 1, 1}, {x + w, y, -1, 1}, {x, y + h, 1, -1}, {x + w, y + h, -1, -1}} {
+			r.doc.Line(c[0], c[1], c[0]+c[2]*arm, c[1], 0.75)
+			r.doc.Line(c[0], c[1], c[0], c[1]+c[3]*arm, 0.75)
+		}
+	}
+	creditor := append([]string{b.Account}, b.Creditor...)
+
+	// Receipt
+	x, width := at(5), at(52)
+	r.doc.SetFont(true, 11)
+	r.doc.Text(x, top+at(5)+11, r.label("qr_receipt"))
+	y := section(x, top+at(12), width, 6, 8, r.label("qr_account"), creditor)
+	if b.Reference != "" {
+		y = section(x, y, width, 6, 8, r.label("qr_reference"), []string{b.Reference})
+	}
+	if len(b.Debtor) > 0 {
+		section(x, y, width, 6, 8, r.label("qr_payable_by"), b.Debtor)
+	} else {
+		section(x, y, width, 6, 8, r.label("qr_payable_by_blank"), nil)
+		blankBox(x, y+at(3), at(52), at(20))
+	}
+	section(x, top+at(68), width, 6, 8, r.label("qr_currency"), []string{b.Currency})
+	section(x+at(12), top+at(68), width, 6, 8, r.label("qr_amount"), []string{b.Amount})
+	r.doc.SetFont(true, 6)
+	r.doc.TextRight(at(57), top+at(82), r.label("qr_acceptance"))
+
+	// Payment part: title, QR code and amount on the left, details on the right
+	x = at(67)
+	r.doc.SetFont(true, 11)
+	r.doc.Text(x, top+at(5)+11, r.label("qr_payment_part"))
+
+	codeTop, side := top+at(17), at(46)
+	r.qrCode(b.code, x, codeTop, side)
+	qrbill.DrawCross(side, func(cx, cy, w, h float64, dark bool) {
+		if dark {
+			r.doc.SetFillColor(0, 0, 0)
+		} else {
+			r.doc.SetFillColor(1, 1, 1)
+		}
+		r.doc.FillRect(x+cx, codeTop+cy, w, h)
+	})
+	r.doc.SetFillColor(0, 0, 0)
+
+	section(x, top+at(68), width, 8, 10, r.label("qr_currency"), []string{b.Currency})
+	section(x+at(15), top+at(68), width, 8, 10, r.label("qr_amount"), []string{b.Amount})
+
+	x, width = at(118), at(87)
+	y = section(x, top+at(5), width, 8, 10, r.label("qr_account"), creditor)
+	if b.Reference != "" {
+		y = section(x, y, width, 8, 10, r.label("qr_reference"), []string{b.Reference})
+	}
+	if b.Message != "" {
+		y = section(x, y, width, 8, 10, r.label("qr_information"), []string{b.Message})
+	}
+	if len(b.Debtor) > 0 {
+		section(x, y, width, 8, 10, r.label("qr_payable_by"), b.Debtor)
+	} else {
+		section(x, y, width, 8, 10, r.label("qr_payable_by_blank"), nil)
+		blankBox(x, y+at(4), at(65), at(25))
+	}
+}
+
+// parseHexColor reads "#rrggbb", falling back to black
+func parseHexColor(s string) (float64, float64, float64) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(s) != 6 {
+		return 0, 0, 0
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return 0, 0, 0
+	}
+	return float64(v>>16&0xff) / 255, float64(v>>8&0xff) / 255, float64(v&0xff) / 255
+}
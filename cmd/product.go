@@ -0,0 +1,551 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"simplebill/internal/config"
+	"simplebill/internal/invoice"
+	"simplebill/internal/money"
+)
+
+func printProductHelp() {
+	fmt.Println("Usage: simplebill product <command>")
+	fmt.Println()
+	fmt.Println("Manage products.yml without editing it by hand. Comments and the order")
+	fmt.Println("of entries in the file are kept.")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  add <key> [options]           Add a product, asking for each detail without options")
+	fmt.Println("  edit <key> [options]          Change the given details, or ask for each one")
+	fmt.Println("  show <key>                    Show all details of a product")
+	fmt.Println("  remove <key> [-y]             Remove a product")
+	fmt.Println("  import <file.csv>             Add or update products from key,name,sku,price rows")
+	fmt.Println()
+	fmt.Println("Options for add and edit (\"-\" clears a value):")
+	for _, f := range productFields {
+		fmt.Printf("  --%-26s %s\n", f.flag+" "+f.arg, f.help)
+	}
+	fmt.Println("  -h, --help                   Show this help message")
+	fmt.Println()
+	fmt.Println("Keys can't contain ':', which separates product and quantity in")
+	fmt.Println("'simplebill invoice'. SKUs must be unique.")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  simplebill product add widget --name \"Standard Widget\" --sku WDG-001 --price 19.99")
+	fmt.Println("  simplebill product edit widget --prices EUR=18.50,GBP=15.99 --tax standard")
+	fmt.Println("  simplebill product add consulting --name Consulting --price 120 --unit h --quantity-decimals 2")
+	fmt.Println("  simplebill product import catalog.csv")
+}
+
+// productField is a product detail that can be given as an option or asked
+// for
+type productField struct {
+	flag, arg, help string
+	prompt          string
+	get             func(p *config.Product) string
+	set             func(p *config.Product, value string) error
+}
+
+var productFields = []productField{
+	{
+		flag: "name", arg: "NAME", prompt: "Name", help: "Name on invoices (required)",
+		get: func(p *config.Product) string { return p.Name },
+		set: func(p *config.Product, value string) error { p.Name = value; return nil },
+	},
+	{
+		flag: "sku", arg: "SKU", prompt: "SKU", help: "Stock keeping unit, unique",
+		get: func(p *config.Product) string { return p.SKU },
+		set: func(p *config.Product, value string) error { p.SKU = value; return nil },
+	},
+	{
+		flag: "description", arg: "TEXT", prompt: "Description", help: "Text shown under the name",
+		get: func(p *config.Product) string { return p.Description },
+		set: func(p *config.Product, value string) error { p.Description = value; return nil },
+	},
+	{
+		flag: "price", arg: "PRICE", prompt: "Price", help: "Price in the company currency",
+		get: func(p *config.Product) string { return p.Price.String() },
+		set: func(p *config.Product, value string) error {
+			if value == "" {
+				p.Price = 0
+				return nil
+			}
+			price, err := money.Parse(value)
+			if err != nil {
+				return err
+			}
+			p.Price = price
+			return nil
+		},
+	},
+	{
+		flag: "prices", arg: "CUR=PRICE,...", prompt: "Prices in other currencies (EUR=18.50, ...)", help: "Prices in other currencies",
+		get: func(p *config.Product) string {
+			var codes []string
+			for code := range p.Prices {
+				codes = append(codes, code)
+			}
+			sort.Strings(codes)
+			var prices []string
+			for _, code := range codes {
+				prices = append(prices, code+"="+p.Prices[code].String())
+			}
+			return strings.Join(prices, ", ")
+		},
+		set: func(p *config.Product, value string) error {
+			p.Prices = nil
+			for _, s := range strings.Split(value, ",") {
+				if s = strings.TrimSpace(s); s == "" {
+					continue
+				}
+				code, amount, ok := strings.Cut(s, "=")
+				if !ok {
+					return fmt.Errorf("invalid price '%s', expected currency=price such as EUR=18.50", s)
+				}
+				price, err := money.Parse(strings.TrimSpace(amount))
+				if err != nil {
+					return err
+				}
+				if p.Prices == nil {
+					p.Prices = map[string]money.Amount{}
+				}
+				p.Prices[strings.ToUpper(strings.TrimSpace(code))] = price
+			}
+			return nil
+		},
+	},
+	{
+		flag: "tax", arg: "TAX", prompt: "Tax (a tax_rates key)", help: "tax_rates key from config.yml",
+		get: func(p *config.Product) string { return p.Tax },
+		set: func(p *config.Product, value string) error { p.Tax = value; return nil },
+	},
+	{
+		flag: "unit", arg: "UNIT", prompt: "Unit (h, day, pcs, ...)", help: "Unit shown with the quantity, e.g. h",
+		get: func(p *config.Product) string { return p.Unit },
+		set: func(p *config.Product, value string) error { p.Unit = value; return nil },
+	},
+	{
+		flag: "quantity-decimals", arg: "N", prompt: "Decimal places in quantities", help: "Allow quantities such as 7.25 (0-4)",
+		get: func(p *config.Product) string {
+			if p.QuantityDecimals == 0 {
+				return ""
+			}
+			return strconv.Itoa(p.QuantityDecimals)
+		},
+		set: func(p *config.Product, value string) error {
+			if value == "" {
+				p.QuantityDecimals = 0
+				return nil
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid number of decimal places '%s'", value)
+			}
+			p.QuantityDecimals = n
+			return nil
+		},
+	},
+}
+
+func RunProduct(args []string) error {
+	if len(args) == 0 {
+		printProductHelp()
+		return nil
+	}
+
+	switch args[0] {
+	case "-h", "--help":
+		printProductHelp()
+		return nil
+	case "add":
+		return saveProduct(args[1:], true)
+	case "edit":
+		return saveProduct(args[1:], false)
+	case "show":
+		return showProduct(args[1:])
+	case "remove":
+		return removeProduct(args[1:])
+	case "import":
+		return importProducts(args[1:])
+	default:
+		return fmt.Errorf("unknown product command '%s'. Use: add, edit, show, remove, import", args[0])
+	}
+}
+
+// saveProduct adds a product or edits an existing one
+func saveProduct(args []string, add bool) error {
+	var key string
+	values := map[string]string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "-h" || arg == "--help" {
+			printProductHelp()
+			return nil
+		}
+		if strings.HasPrefix(arg, "--") {
+			name, value, hasValue := strings.Cut(arg[2:], "=")
+			if findProductField(name) == nil {
+				return fmt.Errorf("unknown option '%s'", arg)
+			}
+			if !hasValue {
+				if i+1 >= len(args) {
+					return fmt.Errorf("%s requires a value", arg)
+				}
+				i++
+				value = args[i]
+			}
+			values[name] = value
+			continue
+		}
+		if key != "" {
+			return fmt.Errorf("unexpected argument '%s'", arg)
+		}
+		key = arg
+	}
+	if key == "" {
+		printProductHelp()
+		return nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	products, err := config.LoadProducts()
+	if err != nil {
+		return err
+	}
+	if products == nil {
+		products = map[string]config.Product{}
+	}
+	path, err := config.ProductsPath()
+	if err != nil {
+		return err
+	}
+	file, err := config.OpenYAML(path)
+	if err != nil {
+		return err
+	}
+
+	product, exists := products[key]
+	if add {
+		if err := config.ValidateKey(key); err != nil {
+			return err
+		}
+		if exists || file.Has(key) {
+			return fmt.Errorf("product '%s' already exists, use 'simplebill product edit %s'", key, key)
+		}
+	} else if !exists {
+		return fmt.Errorf("product '%s' not found in products.yml", key)
+	}
+
+	if len(values) == 0 {
+		reader := bufio.NewReader(os.Stdin)
+		for _, f := range productFields {
+			value, err := promptValue(reader, f.prompt, f.get(&product))
+			if err != nil {
+				return err
+			}
+			if err := f.set(&product, value); err != nil {
+				return fmt.Errorf("product '%s': %w", key, err)
+			}
+		}
+	}
+	for name, value := range values {
+		if value == "-" {
+			value = ""
+		}
+		if err := findProductField(name).set(&product, strings.TrimSpace(value)); err != nil {
+			return fmt.Errorf("product '%s': %w", key, err)
+		}
+	}
+
+	products[key] = product
+	if err := checkProduct(cfg, key, products); err != nil {
+		return err
+	}
+	if err := file.Set(key, product); err != nil {
+		return err
+	}
+	if err := file.Save(); err != nil {
+		return err
+	}
+
+	if add {
+		fmt.Printf("Added product %s\n", key)
+		config.AutoCommit(fmt.Sprintf("simplebill: added product %s", key))
+	} else {
+		fmt.Printf("Updated product %s\n", key)
+		config.AutoCommit(fmt.Sprintf("simplebill: updated product %s", key))
+	}
+	return nil
+}
+
+func findProductField(flag string) *productField {
+	for i := range productFields {
+		if productFields[i].flag == flag {
+			return &productFields[i]
+		}
+	}
+	return nil
+}
+
+// checkProduct validates the product under key against config.yml and the
+// rest of the catalog
+func checkProduct(cfg *config.Config, key string, products map[string]config.Product) error {
+	product := products[key]
+	if err := product.Validate(); err != nil {
+		return fmt.Errorf("product '%s': %w", key, err)
+	}
+	if product.Tax != "" {
+		if _, ok := cfg.TaxRates[product.Tax]; !ok {
+			return fmt.Errorf("product '%s': tax rate '%s' not found in tax_rates in config.yml", key, product.Tax)
+		}
+	}
+	if product.SKU != "" {
+		for other, p := range products {
+			if other != key && p.SKU == product.SKU {
+				return fmt.Errorf("product '%s': SKU %s is already used by '%s'", key, product.SKU, other)
+			}
+		}
+	}
+	return nil
+}
+
+func showProduct(args []string) error {
+	if len(args) != 1 || args[0] == "-h" || args[0] == "--help" {
+		printProductHelp()
+		return nil
+	}
+	key := args[0]
+
+	products, err := config.LoadProducts()
+	if err != nil {
+		return err
+	}
+	product, ok := products[key]
+	if !ok {
+		return fmt.Errorf("product '%s' not found in products.yml", key)
+	}
+
+	fmt.Printf("%-14s %s\n", "key:", key)
+	for _, f := range productFields {
+		if value := f.get(&product); value != "" {
+			fmt.Printf("%-14s %s\n", f.flag+":", value)
+		}
+	}
+
+	used, err := productUse(key)
+	if err != nil {
+		return err
+	}
+	if len(used) > 0 {
+		fmt.Printf("%-14s %s\n", "used by:", strings.Join(used, ", "))
+	}
+	return nil
+}
+
+// productUse lists the invoices, quotes and schedules the product appears in
+func productUse(key string) ([]string, error) {
+	invoices, err := invoice.LoadAll()
+	if err != nil {
+		return nil, err
+	}
+	quotes, err := invoice.LoadQuotes()
+	if err != nil {
+		return nil, err
+	}
+	schedules, err := invoice.LoadSchedules()
+	if err != nil {
+		return nil, err
+	}
+
+	var used []string
+	for _, docs := range [][]invoice.Invoice{invoices, quotes} {
+		sort.Slice(docs, func(i, j int) bool { return docs[i].InvoiceNumber < docs[j].InvoiceNumber })
+		for _, d := range docs {
+			for _, item := range d.Items {
+				if item.Product == key {
+					used = append(used, d.InvoiceNumber)
+					break
+				}
+			}
+		}
+	}
+	for _, s := range schedules {
+		for _, spec := range s.Items {
+			if strings.Split(spec, ":")[0] == key {
+				used = append(used, "schedule "+s.Name)
+				break
+			}
+		}
+	}
+	return used, nil
+}
+
+func removeProduct(args []string) error {
+	var key string
+	var confirmed bool
+	for _, arg := range args {
+		if arg == "--confirm" || arg == "-y" {
+			confirmed = true
+		} else if arg == "-h" || arg == "--help" {
+			printProductHelp()
+			return nil
+		} else {
+			key = arg
+		}
+	}
+	if key == "" {
+		printProductHelp()
+		return nil
+	}
+
+	path, err := config.ProductsPath()
+	if err != nil {
+		return err
+	}
+	file, err := config.OpenYAML(path)
+	if err != nil {
+		return err
+	}
+	if !file.Has(key) {
+		return fmt.Errorf("product '%s' not found in products.yml", key)
+	}
+
+	// Invoices keep the product's name and price, so they are only a warning
+	used, err := productUse(key)
+	if err != nil {
+		return err
+	}
+	if len(used) > 0 {
+		fmt.Printf("Warning: product %s appears in %s.\n", key, strings.Join(used, ", "))
+		fmt.Println("Saved invoices keep their own copy of its details, but recurring schedules\nand invoices from older versions look it up.")
+	}
+
+	if !confirmed {
+		fmt.Printf("Remove product %s? [y/N] ", key)
+		reader := bufio.NewReader(os.Stdin)
+		response, _ := reader.ReadString('\n')
+		response = strings.TrimSpace(strings.ToLower(response))
+		if response != "y" && response != "yes" {
+			fmt.Println("Cancelled.")
+			return nil
+		}
+	}
+
+	file.Remove(key)
+	if err := file.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Removed product %s\n", key)
+	config.AutoCommit(fmt.Sprintf("simplebill: removed product %s", key))
+	return nil
+}
+
+// importProducts adds or updates products from a CSV file with the columns
+// key, name, sku and price, and an optional header row. Rows are checked
+// together, so a bad row leaves products.yml untouched.
+func importProducts(args []string) error {
+	if len(args) != 1 || args[0] == "-h" || args[0] == "--help" {
+		printProductHelp()
+		return nil
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	products, err := config.LoadProducts()
+	if err != nil {
+		return err
+	}
+	if products == nil {
+		products = map[string]config.Product{}
+	}
+	path, err := config.ProductsPath()
+	if err != nil {
+		return err
+	}
+	file, err := config.OpenYAML(path)
+	if err != nil {
+		return err
+	}
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = 4
+	reader.TrimLeadingSpace = true
+	var keys []string
+	seen := map[string]int{}
+	added := 0
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", args[0], err)
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "key") {
+			continue
+		}
+
+		key := strings.TrimSpace(record[0])
+		if err := config.ValidateKey(key); err != nil {
+			return fmt.Errorf("%s line %d: %w", args[0], line, err)
+		}
+		if first, ok := seen[key]; ok {
+			return fmt.Errorf("%s line %d: product '%s' is already on line %d", args[0], line, key, first)
+		}
+		seen[key] = line
+		price, err := money.Parse(strings.TrimSpace(record[3]))
+		if err != nil {
+			return fmt.Errorf("%s line %d: %w", args[0], line, err)
+		}
+
+		// Existing products keep their other details
+		product, exists := products[key]
+		if !exists {
+			added++
+		}
+		product.Name = strings.TrimSpace(record[1])
+		product.SKU = strings.TrimSpace(record[2])
+		product.Price = price
+		products[key] = product
+		keys = append(keys, key)
+	}
+
+	for _, key := range keys {
+		if err := checkProduct(cfg, key, products); err != nil {
+			return fmt.Errorf("%s line %d: %w", args[0], seen[key], err)
+		}
+		if err := file.Set(key, products[key]); err != nil {
+			return err
+		}
+	}
+	if len(keys) == 0 {
+		fmt.Println("No products to import.")
+		return nil
+	}
+	if err := file.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported %d products (%d new, %d updated)\n", len(keys), added, len(keys)-added)
+	config.AutoCommit(fmt.Sprintf("simplebill: imported %d products", len(keys)))
+	return nil
+}
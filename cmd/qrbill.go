@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+
+	"simplebill/internal/address"
+	"simplebill/internal/config"
+	"simplebill/internal/iban"
+	"simplebill/internal/invoice"
+	"simplebill/internal/qr"
+	"simplebill/internal/qrbill"
+)
+
+// TemplateQRBill is the Swiss QR-bill payment part, printed on a page of
+// its own after the invoice
+type TemplateQRBill struct {
+	Image     template.URL // PNG data URI of the QR code, Swiss cross included
+	Account   string       // IBAN in blocks of four
+	Creditor  []string     // name and address lines
+	Reference string       // as printed, empty without a reference
+	Message   string
+	Debtor    []string // empty leaves a box for the payer to fill in
+	Currency  string
+	Amount    string // e.g. "1 234.50"
+
+	code *qr.Code
+}
+
+// swissQRBill builds the QR-bill for inv, or returns nil when the customer
+// doesn't get one or nothing is left to pay
+func swissQRBill(inv *invoice.Invoice, cfg *config.Config, customer *config.Customer) (*qrbill.Bill, error) {
+	setting, err := paymentQR(cfg, customer)
+	if err != nil || setting != paymentQRBill {
+		return nil, err
+	}
+	if inv.IsCreditNote() || inv.IsQuote() || inv.BalanceDue() <= 0 {
+		return nil, nil
+	}
+
+	company := cfg.Company
+	if inv.CompanyInfo != nil {
+		company = *inv.CompanyInfo
+	}
+
+	var problems []string
+	bill := &qrbill.Bill{
+		Amount:   inv.BalanceDue(),
+		Currency: inv.CurrencyInfo().Code,
+	}
+
+	// The reference type decides which account is paid into
+	account, field := company.IBAN, "company.iban"
+	switch strings.ToLower(strings.TrimSpace(company.ReferenceType)) {
+	case "":
+		bill.ReferenceType = qrbill.SCOR
+		if company.QRIBAN != "" {
+			bill.ReferenceType = qrbill.QRR
+		}
+	case "qrr":
+		bill.ReferenceType = qrbill.QRR
+	case "scor":
+		bill.ReferenceType = qrbill.SCOR
+	case "none", "non":
+		bill.ReferenceType = qrbill.NON
+	default:
+		return nil, fmt.Errorf("unknown company.reference_type '%s' in config.yml, expected qrr, scor or none", company.ReferenceType)
+	}
+	if bill.ReferenceType == qrbill.QRR {
+		account, field = company.QRIBAN, "company.qr_iban"
+	}
+	if account == "" {
+		problems = append(problems, fmt.Sprintf("%s is missing in config.yml", field))
+	} else if bill.Account, err = iban.Parse(account); err != nil {
+		problems = append(problems, fmt.Sprintf("%s in config.yml is not valid: %s", field, err))
+	}
+
+	switch bill.ReferenceType {
+	case qrbill.QRR:
+		bill.Reference, err = qrbill.QRReference(inv.InvoiceNumber)
+	case qrbill.SCOR:
+		bill.Reference, err = qrbill.CreditorReference(inv.InvoiceNumber)
+	default:
+		bill.Message = inv.InvoiceNumber
+	}
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	country := strings.ToUpper(strings.TrimSpace(company.Country))
+	creditor, ok := qrAddress(company.Name, company.Address, country)
+	if !ok {
+		problems = append(problems, "the last line of company.address in config.yml must be the postcode and city, e.g. 8001 Zürich")
+	}
+	if country == "" {
+		problems = append(problems, "company.country is missing in config.yml")
+	}
+	bill.Creditor = creditor
+
+	// The payer's address is optional, so one that can't be read is left out
+	debtorCountry := strings.ToUpper(strings.TrimSpace(customer.Country))
+	if debtorCountry == "" {
+		debtorCountry = country
+	}
+	if debtor, ok := qrAddress(customer.Name, customer.Address, debtorCountry); ok {
+		bill.Debtor = &debtor
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("cannot create the QR-bill:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	if err := bill.Validate(); err != nil {
+		return nil, err
+	}
+	return bill, nil
+}
+
+// qrAddress reads a structured address from the free text in the YAML
+// files. It reports false when there is no postcode and city to read.
+func qrAddress(name, text, country string) (qrbill.Address, bool) {
+	a := address.Parse(text)
+	out := qrbill.Address{
+		Name:     strings.TrimSpace(name),
+		Postcode: a.Postcode,
+		City:     a.City,
+		Country:  country,
+	}
+	if len(a.Lines) > 0 && a.City != "" {
+		out.Street, out.Number = address.SplitStreet(a.Lines[0])
+	}
+	return out, a.City != ""
+}
+
+// templateQRBill builds the payment part for the template, or nil when the
+// invoice doesn't get one
+func templateQRBill(inv *invoice.Invoice, cfg *config.Config, customer *config.Customer) (*TemplateQRBill, error) {
+	bill, err := swissQRBill(inv, cfg, customer)
+	if err != nil || bill == nil {
+		return nil, err
+	}
+	code, err := bill.Code()
+	if err != nil {
+		return nil, err
+	}
+	image, err := pngDataURI(qrbill.Image(code, 8))
+	if err != nil {
+		return nil, err
+	}
+
+	t := &TemplateQRBill{
+		Image:     image,
+		Account:   qrbill.FormatAccount(bill.Account),
+		Creditor:  addressLines(bill.Creditor),
+		Reference: qrbill.FormatReference(bill.ReferenceType, bill.Reference),
+		Message:   bill.Message,
+		Currency:  bill.Currency,
+		Amount:    qrbill.FormatAmount(bill.Amount),
+		code:      code,
+	}
+	if bill.Debtor != nil {
+		t.Debtor = addressLines(*bill.Debtor)
+	}
+	return t, nil
+}
+
+// addressLines writes an address as printed on the payment part
+func addressLines(a qrbill.Address) []string {
+	lines := []string{a.Name}
+	if street := strings.TrimSpace(a.Street + " " + a.Number); street != "" {
+		lines = append(lines, street)
+	}
+	return append(lines, a.Postcode+" "+a.City)
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"simplebill/internal/config"
+	"simplebill/internal/invoice"
+)
+
+// defaultQuoteValidDays is how long quotes are valid without
+// invoice.quote_valid_days in config.yml
+const defaultQuoteValidDays = 30
+
+func printQuoteHelp() {
+	fmt.Println("Usage: simplebill quote <customer> <product:qty[:discount[:@price]]>... [-y]")
+	fmt.Println("       simplebill quote accept <quote-number> [-y]")
+	fmt.Println()
+	fmt.Println("Create a quote for a customer, or turn an accepted quote into an invoice.")
+	fmt.Println("Quotes have their own numbers (e.g. Q-2025-0001), are valid for")
+	fmt.Println("invoice.quote_valid_days (default 30) and are saved in ~/.simplebill/quotes/")
+	fmt.Println("using quote.html. Items are given as for 'simplebill invoice'.")
+	fmt.Println()
+	fmt.Println("accept creates a draft invoice with the quote's lines and prices, and")
+	fmt.Println("links the two.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  -y, --yes    Skip preview and save immediately")
+	fmt.Println("  -h, --help   Show this help message")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  simplebill quote acme widget:10 gadget:2:10")
+	fmt.Println("  simplebill quote accept Q-2025-0001")
+	fmt.Println("  simplebill list quotes")
+}
+
+func RunQuote(args []string) error {
+	skipPreview := false
+	var filteredArgs []string
+	for _, arg := range args {
+		if arg == "-y" || arg == "--yes" {
+			skipPreview = true
+		} else if arg == "-h" || arg == "--help" {
+			printQuoteHelp()
+			return nil
+		} else {
+			filteredArgs = append(filteredArgs, arg)
+		}
+	}
+	args = filteredArgs
+
+	if len(args) == 2 && args[0] == "accept" {
+		return acceptQuote(args[1], skipPreview)
+	}
+	if len(args) < 2 {
+		printQuoteHelp()
+		return nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	customers, err := config.LoadCustomers()
+	if err != nil {
+		return err
+	}
+
+	products, err := config.LoadProducts()
+	if err != nil {
+		return err
+	}
+
+	customerKey := args[0]
+	customer, ok := customers[customerKey]
+	if !ok {
+		return fmt.Errorf("customer '%s' not found in customers.yml", customerKey)
+	}
+
+	number, err := invoice.NextQuoteNumber(cfg)
+	if err != nil {
+		return fmt.Errorf("generating quote number: %w", err)
+	}
+	now := time.Now()
+	quote, err := newInvoice(cfg, number, customerKey, customer, products, args[1:], now)
+	if err != nil {
+		return err
+	}
+	validDays := cfg.Invoice.QuoteValidDays
+	if validDays <= 0 {
+		validDays = defaultQuoteValidDays
+	}
+	quote.Type = invoice.TypeQuote
+	quote.DueDate = ""
+	quote.ValidUntil = now.AddDate(0, 0, validDays).Format("2006-01-02")
+
+	saved, err := previewAndSave(quote, cfg, &customer, products, skipPreview)
+	if err != nil || !saved {
+		return err
+	}
+
+	config.AutoCommit(fmt.Sprintf("simplebill: created quote %s", number))
+	return nil
+}
+
+func acceptQuote(number string, skipPreview bool) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	customers, err := config.LoadCustomers()
+	if err != nil {
+		return err
+	}
+
+	products, err := config.LoadProducts()
+	if err != nil {
+		return err
+	}
+
+	quote, err := invoice.LoadQuote(number)
+	if err != nil {
+		return err
+	}
+
+	customer, err := customerFor(quote, customers)
+	if err != nil {
+		return err
+	}
+
+	rounding, err := cfg.Rounding()
+	if err != nil {
+		return err
+	}
+
+	invNumber, err := invoice.NextNumber(cfg)
+	if err != nil {
+		return fmt.Errorf("generating invoice number: %w", err)
+	}
+
+	now := time.Now()
+	if quote.QuoteStatus(now) == invoice.QuoteExpired {
+		fmt.Printf("Note: quote %s expired on %s.\n", number, quote.ValidUntil)
+	}
+	inv, err := invoice.NewInvoiceFromQuote(quote, invNumber, cfg.Invoice.DueDays, rounding, now)
+	if err != nil {
+		return err
+	}
+
+	saved, err := previewAndSave(inv, cfg, customer, products, skipPreview)
+	if err != nil || !saved {
+		return err
+	}
+
+	quote.AcceptedAs = invNumber
+	if err := quote.Save(); err != nil {
+		return err
+	}
+
+	config.AutoCommit(fmt.Sprintf("simplebill: accepted quote %s as invoice %s", number, invNumber))
+	return nil
+}
@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"simplebill/internal/config"
+	"simplebill/internal/invoice"
+)
+
+func printRecurringHelp() {
+	fmt.Println("Usage: simplebill recurring <command>")
+	fmt.Println()
+	fmt.Println("Bill customers the same items at a regular interval. Each schedule is")
+	fmt.Println("a file in ~/.simplebill/recurring/, e.g. recurring/acme-retainer.yml:")
+	fmt.Println()
+	fmt.Println("  customer: acme")
+	fmt.Println("  items: [\"retainer:1\", \"hosting:1:0:@49.00\"]")
+	fmt.Println("  interval: monthly   # monthly, quarterly, half-yearly or yearly")
+	fmt.Println("  start: 2025-01-01")
+	fmt.Println("  end: 2025-12-31     # optional")
+	fmt.Println("  day: 1              # optional: day of the month, default the start day")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  run     Create the draft invoices that are due and not yet created")
+	fmt.Println("  list    List schedules and when they next bill")
+	fmt.Println()
+	fmt.Println("Options for run:")
+	fmt.Println("  --as-of       Date to bill up to (default: today)")
+	fmt.Println("  --dry-run     List the invoices that are due without creating them")
+	fmt.Println("  -h, --help    Show this help message")
+	fmt.Println()
+	fmt.Println("Each period is billed once, so run can be called daily from cron.")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  simplebill recurring run --dry-run")
+	fmt.Println("  simplebill recurring run")
+	fmt.Println("  simplebill recurring list")
+}
+
+func RunRecurring(args []string) error {
+	if len(args) == 0 {
+		printRecurringHelp()
+		return nil
+	}
+
+	switch args[0] {
+	case "-h", "--help":
+		printRecurringHelp()
+		return nil
+	case "run":
+		return runRecurring(args[1:])
+	case "list":
+		return listRecurring(args[1:])
+	default:
+		return fmt.Errorf("unknown recurring command '%s'. Use: run, list", args[0])
+	}
+}
+
+// duePeriod is a billing period of a schedule that has no invoice yet
+type duePeriod struct {
+	schedule invoice.Schedule
+	period   invoice.Period
+}
+
+func runRecurring(args []string) error {
+	asOf := time.Now()
+	dryRun := false
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "-h", "--help":
+			printRecurringHelp()
+			return nil
+		case "--dry-run":
+			dryRun = true
+		case "--as-of":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--as-of requires a value")
+			}
+			i++
+			date, err := parseDate(args[i])
+			if err != nil {
+				return err
+			}
+			asOf = date
+		default:
+			return fmt.Errorf("unexpected argument '%s'", arg)
+		}
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	customers, err := config.LoadCustomers()
+	if err != nil {
+		return err
+	}
+	products, err := config.LoadProducts()
+	if err != nil {
+		return err
+	}
+	schedules, err := invoice.LoadSchedules()
+	if err != nil {
+		return err
+	}
+	invoices, err := invoice.LoadAll()
+	if err != nil {
+		return err
+	}
+
+	var due []duePeriod
+	for _, s := range schedules {
+		for _, p := range s.Periods(asOf) {
+			if !s.Issued(p, invoices) {
+				due = append(due, duePeriod{schedule: s, period: p})
+			}
+		}
+	}
+	if len(due) == 0 {
+		fmt.Println("No recurring invoices due.")
+		return nil
+	}
+
+	if dryRun {
+		fmt.Printf("%-24s  %-30s  %-10s  %s\n", "SCHEDULE", "CUSTOMER", "DATE", "PERIOD")
+		for _, d := range due {
+			name := d.schedule.Customer
+			if c, ok := customers[name]; ok {
+				name = c.Name
+			}
+			fmt.Printf("%-24s  %-30s  %-10s  %s\n", d.schedule.Name, name,
+				d.period.From.Format("2006-01-02"), periodText(d.period.Recurring(d.schedule.Name)))
+		}
+		return nil
+	}
+
+	var created []string
+	failed := make(map[string]bool)
+	for _, d := range due {
+		// Later periods wait until an earlier one of the schedule went through
+		if failed[d.schedule.Name] {
+			continue
+		}
+		inv, err := recurringInvoice(d, cfg, customers, products)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %s\n", d.schedule.Name, err)
+			failed[d.schedule.Name] = true
+			continue
+		}
+		created = append(created, inv.InvoiceNumber)
+	}
+
+	if len(created) > 0 {
+		config.AutoCommit(fmt.Sprintf("simplebill: created recurring invoices %s", strings.Join(created, ", ")))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d schedules could not be billed", len(failed), len(schedules))
+	}
+	return nil
+}
+
+// recurringInvoice creates and saves the draft invoice for a due period
+func recurringInvoice(d duePeriod, cfg *config.Config, customers map[string]config.Customer, products map[string]config.Product) (*invoice.Invoice, error) {
+	customer, ok := customers[d.schedule.Customer]
+	if !ok {
+		return nil, fmt.Errorf("customer '%s' not found in customers.yml", d.schedule.Customer)
+	}
+	// Periods caught up on in January still belong to last year's sequence
+	number, err := invoice.NextNumberIn(cfg, d.period.From.Year())
+	if err != nil {
+		return nil, fmt.Errorf("generating invoice number: %w", err)
+	}
+	inv, err := newInvoice(cfg, number, d.schedule.Customer, customer, products, d.schedule.Items, d.period.From)
+	if err != nil {
+		return nil, err
+	}
+	inv.Recurring = d.period.Recurring(d.schedule.Name)
+
+	fmt.Printf("%s, %s:\n", d.schedule.Name, periodText(inv.Recurring))
+	if _, err := previewAndSave(inv, cfg, &customer, products, true); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+func listRecurring(args []string) error {
+	for _, arg := range args {
+		if arg == "-h" || arg == "--help" {
+			printRecurringHelp()
+			return nil
+		}
+		return fmt.Errorf("unexpected argument '%s'", arg)
+	}
+
+	schedules, err := invoice.LoadSchedules()
+	if err != nil {
+		return err
+	}
+	if len(schedules) == 0 {
+		fmt.Println("No schedules. Add them to ~/.simplebill/recurring/, see 'simplebill recurring --help'.")
+		return nil
+	}
+	invoices, err := invoice.LoadAll()
+	if err != nil {
+		return err
+	}
+
+	today := time.Now()
+	fmt.Printf("%-24s  %-16s  %-11s  %6s  %4s  %s\n", "SCHEDULE", "CUSTOMER", "INTERVAL", "ISSUED", "DUE", "NEXT")
+	for _, s := range schedules {
+		issued, pending := 0, 0
+		for _, p := range s.Periods(today) {
+			if s.Issued(p, invoices) {
+				issued++
+			} else {
+				pending++
+			}
+		}
+
+		// The longest interval is a year, so the next period starts within one
+		next := "ended"
+		for _, p := range s.Periods(today.AddDate(1, 0, 1)) {
+			if p.From.After(today) {
+				next = p.From.Format("2006-01-02")
+				break
+			}
+		}
+		fmt.Printf("%-24s  %-16s  %-11s  %6d  %4d  %s\n", s.Name, s.Customer, s.Interval, issued, pending, next)
+	}
+	return nil
+}
+
+// periodText describes the billing period of a recurring invoice
+func periodText(r *invoice.Recurring) string {
+	return r.From + " to " + r.Through
+}
@@ -0,0 +1,309 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"simplebill/internal/config"
+	"simplebill/internal/invoice"
+	"simplebill/internal/mail"
+)
+
+const defaultReminderSubject = `{{.Reminder.Name}}: invoice {{.InvoiceNumber}} from {{.Company.Name}}`
+
+const defaultReminderBody = `Hello,
+
+{{.Reminder.Text}}
+
+The reminder and the invoice are attached.
+
+Best regards,
+{{.Company.Name}}
+`
+
+func printRemindHelp() {
+	fmt.Println("Usage: simplebill remind [invoice-number...] [--dry-run] [--as-of YYYY-MM-DD] [--no-email]")
+	fmt.Println()
+	fmt.Println("Issue payment reminders for sent invoices that are past due and not")
+	fmt.Println("fully paid. Each invoice moves through the levels under reminders: in")
+	fmt.Println("config.yml one at a time and never gets the same level twice, so this")
+	fmt.Println("can run daily from cron.")
+	fmt.Println()
+	fmt.Println("Each reminder is saved as <invoice-number>-reminder-<level>.pdf next to")
+	fmt.Println("the invoice and, when smtp: is set up in config.yml, emailed to the")
+	fmt.Println("customer with the invoice attached.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --dry-run     List the reminders that are due without issuing them")
+	fmt.Println("  --as-of       Date to check against (default: today)")
+	fmt.Println("  --no-email    Only create the reminder PDFs, e.g. to post them")
+	fmt.Println("  -h, --help    Show this help message")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  simplebill remind --dry-run")
+	fmt.Println("  simplebill remind")
+	fmt.Println("  simplebill remind INV-2025-0001 --no-email")
+}
+
+// dueReminder is a reminder to issue for an invoice
+type dueReminder struct {
+	inv      *invoice.Invoice
+	customer *config.Customer
+	level    int // index into the reminder levels
+}
+
+func RunRemind(args []string) error {
+	var numbers []string
+	asOf := time.Now()
+	dryRun, noEmail := false, false
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "-h", "--help":
+			printRemindHelp()
+			return nil
+		case "--dry-run":
+			dryRun = true
+		case "--no-email":
+			noEmail = true
+		case "--as-of":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--as-of requires a value")
+			}
+			i++
+			date, err := parseDate(args[i])
+			if err != nil {
+				return err
+			}
+			asOf = date
+		default:
+			if strings.HasPrefix(arg, "-") {
+				return fmt.Errorf("unknown option '%s'", arg)
+			}
+			numbers = append(numbers, arg)
+		}
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	levels, err := cfg.ReminderLevels()
+	if err != nil {
+		return err
+	}
+	customers, err := config.LoadCustomers()
+	if err != nil {
+		return err
+	}
+	products, err := config.LoadProducts()
+	if err != nil {
+		return err
+	}
+
+	all, err := invoice.LoadAll()
+	if err != nil {
+		return err
+	}
+	var invoices []*invoice.Invoice
+	if len(numbers) > 0 {
+		for _, number := range numbers {
+			inv, err := invoice.Load(number, all)
+			if err != nil {
+				return err
+			}
+			invoices = append(invoices, inv)
+		}
+	} else {
+		sort.Slice(all, func(i, j int) bool { return all[i].InvoiceNumber < all[j].InvoiceNumber })
+		for i := range all {
+			invoices = append(invoices, &all[i])
+		}
+	}
+
+	var due []dueReminder
+	for _, inv := range invoices {
+		level := inv.NextReminder(levels, asOf)
+		if level < 0 {
+			continue
+		}
+		customer, err := customerFor(inv, customers)
+		if err != nil {
+			return err
+		}
+		due = append(due, dueReminder{inv: inv, customer: customer, level: level})
+	}
+	if len(due) == 0 {
+		fmt.Println("No reminders due.")
+		return nil
+	}
+
+	emailing := cfg.SMTP.Host != "" && !noEmail
+	if dryRun {
+		fmt.Printf("%-15s  %-30s  %5s  %-18s  %14s  %s\n", "NUMBER", "CUSTOMER", "DAYS", "REMINDER", "BALANCE", "DELIVERY")
+		for _, d := range due {
+			delivery := "PDF only"
+			if email := currentCustomer(d.inv, d.customer, customers).Email; emailing && email == "" {
+				delivery = "no email in customers.yml"
+			} else if emailing {
+				delivery = "email to " + email
+			}
+			fmt.Printf("%-15s  %-30s  %5d  %-18s  %s  %s\n",
+				d.inv.InvoiceNumber, d.inv.CustomerName(customers), d.inv.DaysOverdue(asOf),
+				levels[d.level].Name, padLeft(d.inv.CurrencyInfo().Format(d.inv.BalanceDue()), 14), delivery)
+		}
+		return nil
+	}
+
+	var server mail.Server
+	if emailing {
+		if server, err = smtpServer(cfg); err != nil {
+			return err
+		}
+	}
+	backend, err := newPDFBackend(cfg)
+	if err != nil {
+		return err
+	}
+
+	var issued []string
+	failed := 0
+	for _, d := range due {
+		reminder, err := issueReminder(d, levels, cfg, customers, products, backend, asOf, emailing, server)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %s\n", d.inv.InvoiceNumber, err)
+			failed++
+			continue
+		}
+		issued = append(issued, d.inv.InvoiceNumber)
+		if reminder.MessageID != "" {
+			fmt.Printf("Sent %s for %s to %s\n", reminder.Name, d.inv.InvoiceNumber, currentCustomer(d.inv, d.customer, customers).Email)
+		} else {
+			fmt.Printf("Created %s for %s, send %s-reminder-%d.pdf yourself\n", reminder.Name, d.inv.InvoiceNumber, d.inv.InvoiceNumber, reminder.Level)
+		}
+	}
+
+	if len(issued) > 0 {
+		config.AutoCommit(fmt.Sprintf("simplebill: reminders for %s", strings.Join(issued, ", ")))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d reminders could not be issued", failed, len(due))
+	}
+	return nil
+}
+
+// issueReminder renders the reminder for d, emails it when emailing is set
+// and records it on the invoice. Nothing is recorded when sending fails, so
+// the next run tries again.
+func issueReminder(d dueReminder, levels []config.ReminderLevel, cfg *config.Config, customers map[string]config.Customer, products map[string]config.Product, backend pdfBackend, asOf time.Time, emailing bool, server mail.Server) (invoice.Reminder, error) {
+	inv, level := d.inv, levels[d.level]
+	currency := inv.CurrencyInfo()
+	fee, err := level.FeeIn(currency.Code, cfg.DefaultCurrency())
+	if err != nil {
+		return invoice.Reminder{}, err
+	}
+	reminder := invoice.Reminder{
+		Level: d.level + 1,
+		Name:  level.Name,
+		Date:  asOf.Format("2006-01-02"),
+		Fee:   fee,
+	}
+
+	data := buildTemplateData(inv, cfg, d.customer, products)
+	data.Reminder = &TemplateReminder{
+		Level:       reminder.Level,
+		Name:        reminder.Name,
+		Date:        reminder.Date,
+		DaysOverdue: inv.DaysOverdue(asOf),
+		Paid:        inv.Total - inv.BalanceDue(),
+		Fee:         fee,
+		Fees:        inv.ReminderFees() + fee,
+	}
+	if level.LateFees {
+		_, charge, err := lateCharge(inv, cfg, currentCustomer(inv, d.customer, customers), asOf)
+		if err != nil {
+			return invoice.Reminder{}, err
+		}
+		if charge.Total() > 0 {
+			inv.RecordLateFee(charge, "", reminder.Level)
+		}
+	}
+	data.Reminder.LateFees = inv.ReminderLateFees()
+	data.Reminder.AmountDue = inv.BalanceDue() + data.Reminder.Fees + data.Reminder.LateFees
+	if data.Reminder.Text, err = executeConfigTemplate(reminderField(level, "text"), level.Text, data); err != nil {
+		return invoice.Reminder{}, err
+	}
+
+	if emailing && currentCustomer(inv, d.customer, customers).Email == "" {
+		return invoice.Reminder{}, fmt.Errorf("customer '%s' has no email in customers.yml, set it or use --no-email", inv.Customer)
+	}
+	path, err := reminderPath(inv, reminder.Level)
+	if err != nil {
+		return invoice.Reminder{}, err
+	}
+	if err := backend.Render(renderJob{inv: inv, data: data}, path); err != nil {
+		return invoice.Reminder{}, err
+	}
+
+	if emailing {
+		msg, err := reminderMessage(inv, cfg, d.customer, customers, level, data, path)
+		if err != nil {
+			return invoice.Reminder{}, err
+		}
+		if err := mail.Send(server, msg); err != nil {
+			return invoice.Reminder{}, err
+		}
+		reminder.MessageID = msg.MessageID
+	}
+
+	inv.Reminders = append(inv.Reminders, reminder)
+	return reminder, inv.Save()
+}
+
+// reminderMessage builds the email for a reminder, attaching the reminder
+// and the invoice
+func reminderMessage(inv *invoice.Invoice, cfg *config.Config, customer *config.Customer, customers map[string]config.Customer, level config.ReminderLevel, data TemplateData, path string) (*mail.Message, error) {
+	msg, err := customerMessage(inv, cfg, customer, customers, "")
+	if err != nil {
+		return nil, err
+	}
+	subject, body := level.Subject, level.Body
+	if subject == "" {
+		subject = defaultReminderSubject
+	}
+	if body == "" {
+		body = defaultReminderBody
+	}
+	if err := setMessageText(msg, reminderField(level, "subject"), subject, reminderField(level, "body"), body, data); err != nil {
+		return nil, err
+	}
+
+	if err := attachFile(msg, path, "application/pdf"); err != nil {
+		return nil, err
+	}
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	if err := attachFile(msg, filepath.Join(dir, "invoices", inv.InvoiceNumber+".pdf"), "application/pdf"); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// reminderField names a setting of a reminder level in errors
+func reminderField(level config.ReminderLevel, name string) string {
+	return fmt.Sprintf("the %s of reminder '%s'", name, level.Name)
+}
+
+// reminderPath returns where the reminder PDF of a level is saved
+func reminderPath(inv *invoice.Invoice, level int) (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "invoices", fmt.Sprintf("%s-reminder-%d.pdf", inv.InvoiceNumber, level)), nil
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"simplebill/internal/config"
+	"simplebill/internal/invoice"
+)
+
+func printRenderHelp() {
+	fmt.Println("Usage: simplebill render <invoice-number>... | --all")
+	fmt.Println()
+	fmt.Println("Rebuild PDFs from saved invoice YAML, e.g. after changing template.html")
+	fmt.Println("or your company details.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --all        Render every saved invoice and credit note")
+	fmt.Println("  -h, --help   Show this help message")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  simplebill render INV-2025-0001")
+	fmt.Println("  simplebill render --all")
+}
+
+func RunRender(args []string) error {
+	all := false
+	var numbers []string
+	for _, arg := range args {
+		if arg == "--all" {
+			all = true
+		} else if arg == "-h" || arg == "--help" {
+			printRenderHelp()
+			return nil
+		} else {
+			numbers = append(numbers, arg)
+		}
+	}
+
+	if !all && len(numbers) == 0 {
+		printRenderHelp()
+		return nil
+	}
+
+	loaded, err := invoice.LoadAll()
+	if err != nil {
+		return err
+	}
+	var invoices []invoice.Invoice
+	if all {
+		invoices = loaded
+	} else {
+		for _, number := range numbers {
+			inv, err := invoice.Load(number, loaded)
+			if err != nil {
+				return err
+			}
+			invoices = append(invoices, *inv)
+		}
+	}
+
+	if len(invoices) == 0 {
+		fmt.Println("No invoices yet.")
+		return nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	customers, err := config.LoadCustomers()
+	if err != nil {
+		return err
+	}
+
+	products, err := config.LoadProducts()
+	if err != nil {
+		return err
+	}
+
+	var rendered []string
+	for i := range invoices {
+		inv := &invoices[i]
+		customer, err := customerFor(inv, customers)
+		if err != nil {
+			return err
+		}
+		if err := RenderPDF(inv, cfg, customer, products, ""); err != nil {
+			return fmt.Errorf("rendering %s: %w", inv.InvoiceNumber, err)
+		}
+		if _, err := saveEInvoiceXML(inv, cfg, customer); err != nil {
+			return fmt.Errorf("rendering %s: %w", inv.InvoiceNumber, err)
+		}
+		fmt.Printf("Rendered %s\n", inv.InvoiceNumber)
+		rendered = append(rendered, inv.InvoiceNumber)
+	}
+
+	if all {
+		config.AutoCommit("simplebill: re-rendered all invoices")
+	} else {
+		config.AutoCommit(fmt.Sprintf("simplebill: re-rendered %s", strings.Join(rendered, ", ")))
+	}
+	return nil
+}
+
+// customerFor returns the customer details saved on the invoice, falling
+// back to customers.yml for invoices created before details were saved
+func customerFor(inv *invoice.Invoice, customers map[string]config.Customer) (*config.Customer, error) {
+	if inv.CustomerInfo != nil {
+		return inv.CustomerInfo, nil
+	}
+	customer, ok := customers[inv.Customer]
+	if !ok {
+		return nil, fmt.Errorf("customer '%s' of %s not found in customers.yml", inv.Customer, inv.InvoiceNumber)
+	}
+	return &customer, nil
+}
+
+// currentCustomer returns the customer as in customers.yml now, falling back
+// to the details saved on the invoice. Settings that should follow changes,
+// like email addresses, are read from it.
+func currentCustomer(inv *invoice.Invoice, customer *config.Customer, customers map[string]config.Customer) config.Customer {
+	if current, ok := customers[inv.Customer]; ok {
+		return current
+	}
+	return *customer
+}
@@ -0,0 +1,392 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"simplebill/internal/config"
+	"simplebill/internal/invoice"
+	"simplebill/internal/pdf"
+)
+
+// pdfBackend turns one invoice or credit note into a PDF file
+type pdfBackend interface {
+	Name() string
+	Render(job renderJob, outputPath string) error
+}
+
+// renderJob is a document to render. The built-in backend lays out data
+// directly, the others convert the HTML from template.html.
+type renderJob struct {
+	inv  *invoice.Invoice
+	data TemplateData
+	// fonts replace Helvetica in the built-in renderer when set
+	fonts [2]*pdf.Font
+}
+
+func (j renderJob) html() ([]byte, error) {
+	return renderHTML(j.inv, j.data)
+}
+
+// backendNames lists the render backends in the order they are suggested
+var backendNames = []string{"builtin", "wkhtmltopdf", "chromium", "weasyprint", "command"}
+
+// chromiumNames are the executables tried for the chromium backend
+var chromiumNames = []string{"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome", "msedge"}
+
+// newPDFBackend returns the backend chosen by render: in config.yml
+func newPDFBackend(cfg *config.Config) (pdfBackend, error) {
+	r := cfg.Render
+	page, err := newPageSetup(r)
+	if err != nil {
+		return nil, err
+	}
+	if r.DPI < 0 {
+		return nil, fmt.Errorf("invalid render dpi %d", r.DPI)
+	}
+
+	switch r.Backend {
+	case "":
+		if _, err := exec.LookPath("wkhtmltopdf"); err == nil {
+			return wkhtmltopdfBackend{page: page, dpi: r.DPI}, nil
+		}
+		return builtinBackend{page: page}, nil
+	case "builtin":
+		return builtinBackend{page: page}, nil
+	case "wkhtmltopdf":
+		if _, err := exec.LookPath("wkhtmltopdf"); err != nil {
+			return nil, missingBackend("wkhtmltopdf", "Install it with:\n  macOS: brew install wkhtmltopdf\n  Ubuntu/Debian: sudo apt install wkhtmltopdf\n  Fedora: sudo dnf install wkhtmltopdf")
+		}
+		return wkhtmltopdfBackend{page: page, dpi: r.DPI}, nil
+	case "chromium":
+		path := findChromium()
+		if path == "" {
+			return nil, missingBackend("chromium", "Looked for "+strings.Join(chromiumNames, ", "))
+		}
+		return chromiumBackend{path: path, page: page}, nil
+	case "weasyprint":
+		if _, err := exec.LookPath("weasyprint"); err != nil {
+			return nil, missingBackend("weasyprint", "Install it with:\n  pip install weasyprint")
+		}
+		return weasyprintBackend{page: page, dpi: r.DPI}, nil
+	case "command":
+		args := strings.Fields(r.Command)
+		if len(args) == 0 {
+			return nil, fmt.Errorf("render backend 'command' needs 'render: command:' in config.yml, e.g. \"prince {html} -o {pdf}\"")
+		}
+		if !strings.Contains(r.Command, "{html}") || !strings.Contains(r.Command, "{pdf}") {
+			return nil, fmt.Errorf("render command must contain {html} and {pdf}: %s", r.Command)
+		}
+		if _, err := exec.LookPath(args[0]); err != nil {
+			return nil, missingBackend(args[0], "Check 'render: command:' in config.yml")
+		}
+		return commandBackend{args: args, page: page, dpi: r.DPI}, nil
+	default:
+		return nil, fmt.Errorf("unknown render backend '%s', expected one of %s", r.Backend, strings.Join(backendNames, ", "))
+	}
+}
+
+// availableBackends lists the backends that can run on this machine
+func availableBackends() []string {
+	available := []string{"builtin"}
+	if _, err := exec.LookPath("wkhtmltopdf"); err == nil {
+		available = append(available, "wkhtmltopdf")
+	}
+	if findChromium() != "" {
+		available = append(available, "chromium")
+	}
+	if _, err := exec.LookPath("weasyprint"); err == nil {
+		available = append(available, "weasyprint")
+	}
+	return available
+}
+
+func missingBackend(name, hint string) error {
+	return fmt.Errorf("%s not found on PATH\n\n%s\n\nBackends available: %s\nChange it with 'render: backend:' in config.yml",
+		name, hint, strings.Join(availableBackends(), ", "))
+}
+
+func findChromium() string {
+	for _, name := range chromiumNames {
+		if path, err := exec.LookPath(name); err == nil {
+			return path
+		}
+	}
+	if runtime.GOOS == "darwin" {
+		for _, path := range []string{
+			"/Applications/Chromium.app/Contents/MacOS/Chromium",
+			"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+		} {
+			if _, err := os.Stat(path); err == nil {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// pageSetup is the paper and margins from config.yml
+type pageSetup struct {
+	size          string // name as CSS and wkhtmltopdf know it
+	landscape     bool
+	width, height float64    // in points, already turned for landscape
+	margins       [4]float64 // top, right, bottom, left in points
+}
+
+var pageSizes = map[string][2]float64{
+	"letter": {pdf.LetterWidth, pdf.LetterHeight},
+	"legal":  {pdf.LegalWidth, pdf.LegalHeight},
+	"a4":     {pdf.A4Width, pdf.A4Height},
+	"a5":     {pdf.A5Width, pdf.A5Height},
+}
+
+func newPageSetup(r config.RenderConfig) (pageSetup, error) {
+	size := r.PageSize
+	if size == "" {
+		size = "Letter"
+	}
+	dims, ok := pageSizes[strings.ToLower(size)]
+	if !ok {
+		return pageSetup{}, fmt.Errorf("unknown page_size '%s', expected Letter, Legal, A4 or A5", size)
+	}
+	page := pageSetup{size: size, width: dims[0], height: dims[1]}
+
+	switch strings.ToLower(r.Orientation) {
+	case "", "portrait":
+	case "landscape":
+		page.landscape = true
+		page.width, page.height = page.height, page.width
+	default:
+		return pageSetup{}, fmt.Errorf("unknown orientation '%s', expected portrait or landscape", r.Orientation)
+	}
+
+	margins := r.Margins
+	if margins == "" {
+		margins = "10mm"
+	}
+	var values []float64
+	for _, field := range strings.Fields(margins) {
+		v, err := parseLength(field)
+		if err != nil {
+			return pageSetup{}, fmt.Errorf("invalid margins '%s': %w", margins, err)
+		}
+		values = append(values, v)
+	}
+	// Same shorthand as CSS: all, vertical horizontal, top horizontal bottom,
+	// or top right bottom left
+	switch len(values) {
+	case 1:
+		page.margins = [4]float64{values[0], values[0], values[0], values[0]}
+	case 2:
+		page.margins = [4]float64{values[0], values[1], values[0], values[1]}
+	case 3:
+		page.margins = [4]float64{values[0], values[1], values[2], values[1]}
+	case 4:
+		page.margins = [4]float64{values[0], values[1], values[2], values[3]}
+	default:
+		return pageSetup{}, fmt.Errorf("invalid margins '%s', expected 1 to 4 lengths", margins)
+	}
+	if page.margins[1]+page.margins[3] >= page.width || page.margins[0]+page.margins[2] >= page.height {
+		return pageSetup{}, fmt.Errorf("margins '%s' leave no room on a %s page", margins, size)
+	}
+	return page, nil
+}
+
+// parseLength converts a length such as "10mm", "0.5in" or "36pt" to points
+func parseLength(s string) (float64, error) {
+	units := []struct {
+		suffix string
+		points float64
+	}{
+		{"mm", 72 / 25.4},
+		{"cm", 72 / 2.54},
+		{"in", 72},
+		{"pt", 1},
+		{"px", 0.75},
+	}
+	for _, u := range units {
+		if number, ok := strings.CutSuffix(s, u.suffix); ok {
+			v, err := strconv.ParseFloat(number, 64)
+			if err != nil || v < 0 {
+				return 0, fmt.Errorf("invalid length '%s'", s)
+			}
+			return v * u.points, nil
+		}
+	}
+	if s == "0" {
+		return 0, nil
+	}
+	return 0, fmt.Errorf("length '%s' needs a unit: mm, cm, in, pt or px", s)
+}
+
+// mm formats points as millimetres for command line flags
+func mm(points float64) string {
+	return strconv.FormatFloat(points*25.4/72, 'f', 2, 64) + "mm"
+}
+
+// pageCSS is the @page rule for backends that lay out pages with CSS. It
+// goes at the start of <head>, so @page rules in the template still win.
+func (p pageSetup) pageCSS() string {
+	orientation := "portrait"
+	if p.landscape {
+		orientation = "landscape"
+	}
+	return fmt.Sprintf("<style>@page { size: %s %s; margin: %s %s %s %s; }</style>",
+		p.size, orientation, mm(p.margins[0]), mm(p.margins[1]), mm(p.margins[2]), mm(p.margins[3]))
+}
+
+func withPageCSS(html []byte, page pageSetup) []byte {
+	style := []byte(page.pageCSS())
+	if i := bytes.Index(bytes.ToLower(html), []byte("<head>")); i >= 0 {
+		i += len("<head>")
+		out := append([]byte{}, html[:i]...)
+		out = append(out, style...)
+		return append(out, html[i:]...)
+	}
+	return append(style, html...)
+}
+
+// writeTempHTML saves html for an external converter. The caller removes the file.
+func writeTempHTML(html []byte) (string, error) {
+	tmpFile, err := os.CreateTemp("", "simplebill-*.html")
+	if err != nil {
+		return "", fmt.Errorf("creating temp file: %w", err)
+	}
+	if _, err := tmpFile.Write(html); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return "", fmt.Errorf("writing temp file: %w", err)
+	}
+	tmpFile.Close()
+	return tmpFile.Name(), nil
+}
+
+// convertHTML renders the job's HTML to a temp file, optionally adding the
+// @page rule, and runs the converter built by args on it
+func convertHTML(name string, job renderJob, page *pageSetup, args func(htmlPath string) *exec.Cmd) error {
+	html, err := job.html()
+	if err != nil {
+		return err
+	}
+	if page != nil {
+		html = withPageCSS(html, *page)
+	}
+
+	htmlPath, err := writeTempHTML(html)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(htmlPath)
+
+	if output, err := args(htmlPath).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %s\n%s", name, err, output)
+	}
+	return nil
+}
+
+type builtinBackend struct {
+	page pageSetup
+}
+
+func (b builtinBackend) Name() string { return "builtin" }
+
+func (b builtinBackend) Render(job renderJob, outputPath string) error {
+	return renderBuiltin(job.data, b.page, job.fonts, outputPath)
+}
+
+type wkhtmltopdfBackend struct {
+	page pageSetup
+	dpi  int
+}
+
+func (b wkhtmltopdfBackend) Name() string { return "wkhtmltopdf" }
+
+func (b wkhtmltopdfBackend) Render(job renderJob, outputPath string) error {
+	// wkhtmltopdf ignores @page, so the page is set with flags
+	return convertHTML(b.Name(), job, nil, func(htmlPath string) *exec.Cmd {
+		orientation := "Portrait"
+		if b.page.landscape {
+			orientation = "Landscape"
+		}
+		args := []string{
+			"--page-size", b.page.size,
+			"--orientation", orientation,
+			"--margin-top", mm(b.page.margins[0]),
+			"--margin-right", mm(b.page.margins[1]),
+			"--margin-bottom", mm(b.page.margins[2]),
+			"--margin-left", mm(b.page.margins[3]),
+		}
+		if b.dpi > 0 {
+			args = append(args, "--dpi", strconv.Itoa(b.dpi))
+		}
+		if runtime.GOOS != "windows" {
+			args = append(args, "--quiet")
+		}
+		args = append(args, htmlPath, outputPath)
+		return exec.Command("wkhtmltopdf", args...)
+	})
+}
+
+type chromiumBackend struct {
+	path string
+	page pageSetup
+}
+
+func (b chromiumBackend) Name() string { return "chromium" }
+
+func (b chromiumBackend) Render(job renderJob, outputPath string) error {
+	return convertHTML(b.Name(), job, &b.page, func(htmlPath string) *exec.Cmd {
+		return exec.Command(b.path,
+			"--headless",
+			"--disable-gpu",
+			"--no-pdf-header-footer",
+			"--print-to-pdf-no-header",
+			"--print-to-pdf="+outputPath,
+			"file://"+htmlPath,
+		)
+	})
+}
+
+type weasyprintBackend struct {
+	page pageSetup
+	dpi  int
+}
+
+func (b weasyprintBackend) Name() string { return "weasyprint" }
+
+func (b weasyprintBackend) Render(job renderJob, outputPath string) error {
+	return convertHTML(b.Name(), job, &b.page, func(htmlPath string) *exec.Cmd {
+		args := []string{"--quiet"}
+		if b.dpi > 0 {
+			args = append(args, "--dpi", strconv.Itoa(b.dpi))
+		}
+		args = append(args, htmlPath, outputPath)
+		return exec.Command("weasyprint", args...)
+	})
+}
+
+// commandBackend runs render: command: from config.yml. Placeholders are
+// replaced after splitting the command, so paths with spaces stay one argument.
+type commandBackend struct {
+	args []string
+	page pageSetup
+	dpi  int
+}
+
+func (b commandBackend) Name() string { return b.args[0] }
+
+func (b commandBackend) Render(job renderJob, outputPath string) error {
+	return convertHTML(b.Name(), job, &b.page, func(htmlPath string) *exec.Cmd {
+		replacer := strings.NewReplacer("{html}", htmlPath, "{pdf}", outputPath, "{dpi}", strconv.Itoa(b.dpi))
+		args := make([]string, len(b.args))
+		for i, arg := range b.args {
+			args[i] = replacer.Replace(arg)
+		}
+		return exec.Command(args[0], args[1:]...)
+	})
+}
@@ -0,0 +1,344 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"simplebill/internal/config"
+	"simplebill/internal/invoice"
+	"simplebill/internal/mail"
+)
+
+const defaultEmailSubject = `{{if .CreditFor}}Credit note{{else}}Invoice{{end}} {{.InvoiceNumber}} from {{.Company.Name}}`
+
+const defaultEmailBody = `Hello,
+
+{{if .CreditFor}}please find attached credit note {{.InvoiceNumber}} for invoice {{.CreditFor}}.{{else}}please find attached invoice {{.InvoiceNumber}} for {{money .BalanceDue}}, due {{.DueDate}}.{{end}}
+
+Best regards,
+{{.Company.Name}}
+`
+
+func printSendHelp() {
+	fmt.Println("Usage: simplebill send <invoice-number> [--to ADDRESS] [--dry-run [-o FILE]] [--no-xml]")
+	fmt.Println()
+	fmt.Println("Email an invoice or credit note PDF to the customer through the SMTP")
+	fmt.Println("server in config.yml, and mark it as sent. The e-invoice XML is")
+	fmt.Println("attached too when there is one.")
+	fmt.Println()
+	fmt.Println("The subject and body come from email: in config.yml and can use the")
+	fmt.Println("same fields as template.html, e.g. {{.InvoiceNumber}} or {{money .Total}}.")
+	fmt.Println("cc and bcc on the customer in customers.yml get a copy.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --to ADDRESS        Send to ADDRESS instead of the customer's email")
+	fmt.Println("  --dry-run           Write the message to an .eml file instead of sending")
+	fmt.Println("  -o, --output FILE   File for --dry-run (default: <invoice-number>.eml)")
+	fmt.Println("  --no-xml            Don't attach the e-invoice XML")
+	fmt.Println("  -h, --help          Show this help message")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  simplebill send INV-2025-0001")
+	fmt.Println("  simplebill send INV-2025-0001 --dry-run")
+	fmt.Println("  simplebill send INV-2025-0001 --to accounts@acme.com")
+}
+
+func RunSend(args []string) error {
+	var number, to, output string
+	dryRun, noXML := false, false
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "-h", "--help":
+			printSendHelp()
+			return nil
+		case "--dry-run":
+			dryRun = true
+		case "--no-xml":
+			noXML = true
+		case "--to", "-o", "--output":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s requires a value", arg)
+			}
+			i++
+			if arg == "--to" {
+				to = args[i]
+			} else {
+				output = args[i]
+			}
+		default:
+			if number != "" {
+				return fmt.Errorf("unexpected argument '%s'", arg)
+			}
+			number = arg
+		}
+	}
+	if number == "" {
+		printSendHelp()
+		return nil
+	}
+	if output != "" && !dryRun {
+		return fmt.Errorf("--output only applies with --dry-run")
+	}
+
+	invoices, err := invoice.LoadAll()
+	if err != nil {
+		return err
+	}
+	inv, err := invoice.Load(number, invoices)
+	if err != nil {
+		return err
+	}
+	if inv.CurrentStatus() == invoice.StatusVoid {
+		return fmt.Errorf("%s is void and shouldn't be sent", number)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	customers, err := config.LoadCustomers()
+	if err != nil {
+		return err
+	}
+	products, err := config.LoadProducts()
+	if err != nil {
+		return err
+	}
+	customer, err := customerFor(inv, customers)
+	if err != nil {
+		return err
+	}
+
+	msg, err := invoiceMessage(inv, cfg, customer, customers, products, to, !noXML)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		if output == "" {
+			output = number + ".eml"
+		}
+		data, err := msg.Bytes()
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(output, data, 0644); err != nil {
+			return fmt.Errorf("writing %s: %w", output, err)
+		}
+		fmt.Printf("Wrote %s for %s (not sent)\n", output, strings.Join(msg.Recipients(), ", "))
+		return nil
+	}
+
+	server, err := smtpServer(cfg)
+	if err != nil {
+		return err
+	}
+	if err := mail.Send(server, msg); err != nil {
+		return err
+	}
+
+	// Record the delivery. Resending keeps the status but is logged too.
+	inv.Emails = append(inv.Emails, invoice.Email{At: msg.Date, To: msg.Recipients(), MessageID: msg.MessageID})
+	if inv.CurrentStatus() == invoice.StatusDraft {
+		if err := inv.SetStatus(invoice.StatusSent, msg.Date); err != nil {
+			return err
+		}
+	}
+	if err := inv.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Sent %s to %s\n", number, strings.Join(msg.Recipients(), ", "))
+	fmt.Printf("Message-ID: %s\n", msg.MessageID)
+	config.AutoCommit(fmt.Sprintf("simplebill: sent invoice %s", number))
+	return nil
+}
+
+// invoiceMessage builds the email for inv with its PDF, and its e-invoice
+// XML when there is one and withXML is set. to replaces the customer's
+// addresses when given.
+func invoiceMessage(inv *invoice.Invoice, cfg *config.Config, customer *config.Customer, customers map[string]config.Customer, products map[string]config.Product, to string, withXML bool) (*mail.Message, error) {
+	msg, err := customerMessage(inv, cfg, customer, customers, to)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, body := cfg.Email.Subject, cfg.Email.Body
+	if subject == "" {
+		subject = defaultEmailSubject
+	}
+	if body == "" {
+		body = defaultEmailBody
+	}
+	data := buildTemplateData(inv, cfg, customer, products)
+	if err := setMessageText(msg, "email.subject", subject, "email.body", body, data); err != nil {
+		return nil, err
+	}
+
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	pdfPath := filepath.Join(dir, "invoices", inv.InvoiceNumber+".pdf")
+	if err := attachFile(msg, pdfPath, "application/pdf"); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%s not found, run 'simplebill render %s' first", pdfPath, inv.InvoiceNumber)
+		}
+		return nil, err
+	}
+
+	if withXML {
+		path, err := xmlPath(inv.InvoiceNumber)
+		if err != nil {
+			return nil, err
+		}
+		if err := attachFile(msg, path, "application/xml"); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
+// customerMessage starts an email to the customer of inv, copying the cc
+// and bcc lists unless to replaces the recipients
+func customerMessage(inv *invoice.Invoice, cfg *config.Config, customer *config.Customer, customers map[string]config.Customer, to string) (*mail.Message, error) {
+	recipient := currentCustomer(inv, customer, customers)
+
+	from, err := emailFrom(cfg)
+	if err != nil {
+		return nil, err
+	}
+	msg := &mail.Message{From: from, Date: time.Now()}
+	msg.MessageID = mail.NewMessageID(from, msg.Date)
+
+	if to == "" {
+		to = recipient.Email
+		if msg.Cc, err = addressLists(cfg.Email.Cc, recipient.Cc); err != nil {
+			return nil, err
+		}
+		if msg.Bcc, err = addressLists(cfg.Email.Bcc, recipient.Bcc); err != nil {
+			return nil, err
+		}
+	}
+	if msg.To, err = mail.ParseList(to); err != nil {
+		return nil, err
+	}
+	if len(msg.To) == 0 {
+		return nil, fmt.Errorf("customer '%s' has no email in customers.yml, set it or use --to", inv.Customer)
+	}
+	return msg, nil
+}
+
+// setMessageText fills in the subject and body templates, named in errors
+// by their settings in config.yml
+func setMessageText(msg *mail.Message, subjectField, subject, bodyField, body string, data TemplateData) error {
+	var err error
+	if msg.Subject, err = executeConfigTemplate(subjectField, subject, data); err != nil {
+		return err
+	}
+	if msg.Body, err = executeConfigTemplate(bodyField, body, data); err != nil {
+		return err
+	}
+	msg.Subject = strings.Join(strings.Fields(msg.Subject), " ")
+	return nil
+}
+
+// attachFile attaches the file at path under its own name
+func attachFile(msg *mail.Message, path, contentType string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	msg.Attachments = append(msg.Attachments, mail.Attachment{Name: filepath.Base(path), ContentType: contentType, Data: data})
+	return nil
+}
+
+// emailFrom returns email.from from config.yml, or the company name and email
+func emailFrom(cfg *config.Config) (*netmail.Address, error) {
+	if cfg.Email.From != "" {
+		from, err := netmail.ParseAddress(cfg.Email.From)
+		if err != nil {
+			return nil, fmt.Errorf("invalid email.from '%s' in config.yml: %w", cfg.Email.From, err)
+		}
+		return from, nil
+	}
+	if cfg.Company.Email == "" {
+		return nil, fmt.Errorf("set company.email or email.from in config.yml to send invoices")
+	}
+	from, err := netmail.ParseAddress(cfg.Company.Email)
+	if err != nil {
+		return nil, fmt.Errorf("invalid company.email '%s' in config.yml: %w", cfg.Company.Email, err)
+	}
+	if from.Name == "" {
+		from.Name = cfg.Company.Name
+	}
+	return from, nil
+}
+
+// addressLists parses the addresses from config.yml and customers.yml
+func addressLists(lists ...[]string) ([]*netmail.Address, error) {
+	var out []*netmail.Address
+	for _, list := range lists {
+		for _, s := range list {
+			parsed, err := mail.ParseList(s)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, parsed...)
+		}
+	}
+	return out, nil
+}
+
+// executeConfigTemplate fills in a Go template from config.yml, named by
+// field in errors
+func executeConfigTemplate(field, text string, data TemplateData) (string, error) {
+	tmpl, err := template.New(field).Funcs(template.FuncMap(templateFuncs(data.Currency))).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parsing %s in config.yml: %w", field, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("executing %s in config.yml: %w", field, err)
+	}
+	return buf.String(), nil
+}
+
+// smtpServer reads smtp: from config.yml
+func smtpServer(cfg *config.Config) (mail.Server, error) {
+	s := cfg.SMTP
+	if s.Host == "" {
+		return mail.Server{}, fmt.Errorf("smtp.host is missing in config.yml, or use --dry-run to write the message to a file")
+	}
+
+	server := mail.Server{
+		Host:     s.Host,
+		Port:     s.Port,
+		Username: s.Username,
+		Password: s.Password,
+		Security: strings.ToLower(s.TLS),
+	}
+	if password := os.Getenv("SIMPLEBILL_SMTP_PASSWORD"); password != "" {
+		server.Password = password
+	}
+
+	switch server.Security {
+	case "":
+		server.Security = mail.StartTLS
+	case mail.StartTLS, mail.TLS, mail.None:
+	default:
+		return mail.Server{}, fmt.Errorf("unknown smtp.tls '%s' in config.yml, expected starttls, tls or none", s.TLS)
+	}
+	if server.Port == 0 {
+		server.Port = 587
+		if server.Security == mail.TLS {
+			server.Port = 465
+		}
+	}
+	return server, nil
+}
@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"simplebill/internal/config"
+	"simplebill/internal/invoice"
+	"simplebill/internal/money"
+)
+
+func printMarkSentHelp() {
+	fmt.Println("Usage: simplebill mark-sent <invoice-number> [--date YYYY-MM-DD]")
+	fmt.Println()
+	fmt.Println("Mark an invoice or quote as sent to the customer.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --date       Date it was sent (default: today)")
+	fmt.Println("  -h, --help   Show this help message")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  simplebill mark-sent INV-2025-0001")
+	fmt.Println("  simplebill mark-sent Q-2025-0001")
+}
+
+func printMarkPaidHelp() {
+	fmt.Println("Usage: simplebill mark-paid <invoice-number> [--date YYYY-MM-DD] [--amount AMOUNT]")
+	fmt.Println()
+	fmt.Println("Mark an invoice as paid.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --date       Date the payment was received (default: today)")
+	fmt.Println("  --amount     Amount received (default: balance due)")
+	fmt.Println("  -h, --help   Show this help message")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  simplebill mark-paid INV-2025-0001")
+	fmt.Println("  simplebill mark-paid INV-2025-0001 --date 2025-03-01 --amount 150.00")
+}
+
+func printVoidHelp() {
+	fmt.Println("Usage: simplebill void <invoice-number> [--date YYYY-MM-DD]")
+	fmt.Println()
+	fmt.Println("Void an invoice that should no longer be paid. Voided invoices are kept.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --date       Date it was voided (default: today)")
+	fmt.Println("  -h, --help   Show this help message")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  simplebill void INV-2025-0001")
+}
+
+// statusArgs holds the arguments shared by the status commands
+type statusArgs struct {
+	number string
+	date   time.Time
+	amount string
+	help   bool
+}
+
+func parseStatusArgs(args []string, allowAmount bool) (statusArgs, error) {
+	parsed := statusArgs{date: time.Now()}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-h" || arg == "--help":
+			parsed.help = true
+		case arg == "--date":
+			if i+1 >= len(args) {
+				return parsed, fmt.Errorf("--date requires a value")
+			}
+			i++
+			date, err := parseDate(args[i])
+			if err != nil {
+				return parsed, err
+			}
+			parsed.date = date
+		case arg == "--amount" && allowAmount:
+			if i+1 >= len(args) {
+				return parsed, fmt.Errorf("--amount requires a value")
+			}
+			i++
+			parsed.amount = args[i]
+		case strings.HasPrefix(arg, "-"):
+			return parsed, fmt.Errorf("unknown option '%s'", arg)
+		case parsed.number != "":
+			return parsed, fmt.Errorf("unexpected argument '%s'", arg)
+		default:
+			parsed.number = arg
+		}
+	}
+
+	if parsed.number == "" {
+		parsed.help = true
+	}
+	return parsed, nil
+}
+
+// parseDate reads a YYYY-MM-DD date, keeping the current time of day when
+// the date is today so history entries stay in order
+func parseDate(s string) (time.Time, error) {
+	date, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date '%s', expected YYYY-MM-DD", s)
+	}
+	now := time.Now()
+	if date.Format("2006-01-02") == now.Format("2006-01-02") {
+		return now, nil
+	}
+	return date, nil
+}
+
+func RunMarkSent(args []string) error {
+	parsed, err := parseStatusArgs(args, false)
+	if err != nil {
+		return err
+	}
+	if parsed.help {
+		printMarkSentHelp()
+		return nil
+	}
+
+	return updateStatus(parsed.number, "marked sent", func(inv *invoice.Invoice) error {
+		if inv.IsCreditNote() {
+			return fmt.Errorf("%s is a credit note and cannot be marked sent", inv.InvoiceNumber)
+		}
+		return inv.SetStatus(invoice.StatusSent, parsed.date)
+	})
+}
+
+func RunMarkPaid(args []string) error {
+	parsed, err := parseStatusArgs(args, true)
+	if err != nil {
+		return err
+	}
+	if parsed.help {
+		printMarkPaidHelp()
+		return nil
+	}
+
+	return updateStatus(parsed.number, "marked paid", func(inv *invoice.Invoice) error {
+		if inv.IsCreditNote() {
+			return fmt.Errorf("%s is a credit note and cannot be marked paid", inv.InvoiceNumber)
+		}
+		if inv.IsQuote() {
+			return fmt.Errorf("%s is a quote and cannot be marked paid", inv.InvoiceNumber)
+		}
+		amount := inv.BalanceDue()
+		if parsed.amount != "" {
+			amount, err = money.Parse(parsed.amount)
+			if err != nil {
+				return err
+			}
+			// Money going back to the customer is a credit note, not a payment
+			if amount <= 0 {
+				return fmt.Errorf("amount must be positive; use 'simplebill credit' for refunds")
+			}
+			if amount != inv.BalanceDue() {
+				currency := inv.CurrencyInfo()
+				fmt.Printf("Note: amount received %s differs from balance due %s\n",
+					currency.Format(amount), currency.Format(inv.BalanceDue()))
+			}
+		}
+		payment := invoice.Payment{Date: parsed.date.Format("2006-01-02"), Amount: amount}
+		return inv.MarkPaid(payment, parsed.date)
+	})
+}
+
+func RunVoid(args []string) error {
+	parsed, err := parseStatusArgs(args, false)
+	if err != nil {
+		return err
+	}
+	if parsed.help {
+		printVoidHelp()
+		return nil
+	}
+
+	return updateStatus(parsed.number, "voided", func(inv *invoice.Invoice) error {
+		if inv.IsQuote() {
+			return fmt.Errorf("%s is a quote and cannot be voided", inv.InvoiceNumber)
+		}
+		return inv.SetStatus(invoice.StatusVoid, parsed.date)
+	})
+}
+
+// updateStatus loads an invoice, or a quote when there is no invoice by
+// that number, applies change and saves it
+func updateStatus(number, verb string, change func(inv *invoice.Invoice) error) error {
+	invoices, err := invoice.LoadAll()
+	if err != nil {
+		return err
+	}
+	inv, err := invoice.Load(number, invoices)
+	if err != nil {
+		quote, quoteErr := invoice.LoadQuote(number)
+		if quoteErr != nil {
+			return err
+		}
+		inv = quote
+	}
+
+	if err := change(inv); err != nil {
+		return err
+	}
+
+	if err := inv.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s %s\n", number, verb)
+	config.AutoCommit(fmt.Sprintf("simplebill: %s %s %s", verb, documentName(inv), number))
+	return nil
+}
@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"simplebill/internal/config"
+	"simplebill/internal/invoice"
+	"simplebill/internal/money"
+	"simplebill/internal/timesheet"
+)
+
+// How 'invoice --from-timesheet' turns entries into lines
+const (
+	groupByProduct = "product" // one line per product and rate
+	groupByTask    = "task"    // one line per project and task
+	groupByEntry   = "entry"   // one line per entry
+)
+
+// timesheetOptions are the --from-timesheet options of 'simplebill invoice'
+type timesheetOptions struct {
+	path    string
+	source  string
+	by      string
+	product string
+}
+
+// timesheetLine collects the entries billed on one invoice line
+type timesheetLine struct {
+	product     string
+	rate        *money.Amount
+	description string
+	hours       *big.Rat
+	first, last string
+	entries     []timesheet.Entry
+}
+
+// timesheetInvoice builds a draft invoice from the entries of a timesheet
+// export that belong to the customer and haven't been billed yet, followed
+// by items from product:qty specs
+func timesheetInvoice(cfg *config.Config, invNumber, customerKey string, customer config.Customer, products map[string]config.Product, opts timesheetOptions, specs []string, date time.Time) (*invoice.Invoice, error) {
+	switch opts.by {
+	case "":
+		opts.by = groupByTask
+	case groupByProduct, groupByTask, groupByEntry:
+	default:
+		return nil, fmt.Errorf("unknown grouping '%s', expected product, task or entry", opts.by)
+	}
+
+	source, err := cfg.TimesheetSourceFor(opts.source)
+	if err != nil {
+		return nil, err
+	}
+	if opts.product != "" {
+		source.Product = opts.product
+	}
+	entries, err := timesheet.Read(opts.path, opts.source, source)
+	if err != nil {
+		return nil, err
+	}
+
+	invoices, err := invoice.LoadAll()
+	if err != nil {
+		return nil, err
+	}
+	billed := invoice.BilledEntries(invoices)
+
+	var unbilled []timesheet.Entry
+	var others int
+	billedOn := map[string]int{}
+	for _, e := range entries {
+		if e.Customer != "" && !strings.EqualFold(e.Customer, customerKey) && !strings.EqualFold(e.Customer, customer.Name) {
+			others++
+			continue
+		}
+		if number, ok := billed[e.Key]; ok {
+			billedOn[number]++
+			continue
+		}
+		if e.Product == "" {
+			if source.Product == "" {
+				return nil, fmt.Errorf("%s entry %d has no product: use --product, or set product for the timesheet source in config.yml", opts.path, e.Line)
+			}
+			e.Product = source.Product
+		}
+		unbilled = append(unbilled, e)
+	}
+
+	if others > 0 {
+		fmt.Printf("Ignoring %d entries for other customers.\n", others)
+	}
+	if len(billedOn) > 0 {
+		var numbers []string
+		skipped := 0
+		for number, n := range billedOn {
+			numbers = append(numbers, number)
+			skipped += n
+		}
+		sort.Strings(numbers)
+		fmt.Printf("Skipping %d entries already billed on %s.\n", skipped, strings.Join(numbers, ", "))
+	}
+	if len(unbilled) == 0 {
+		return nil, fmt.Errorf("no unbilled entries for customer '%s' in %s", customerKey, opts.path)
+	}
+
+	currency, rounding, err := invoiceRules(cfg, customerKey, customer)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []invoice.Item
+	var keys []string
+	for _, line := range groupEntries(unbilled, opts.by) {
+		product, ok := products[line.product]
+		if !ok {
+			return nil, fmt.Errorf("%s entry %d: product '%s' not found in products.yml", opts.path, line.entries[0].Line, line.product)
+		}
+
+		qty, err := money.RoundDecimal(line.hours, product.QuantityDecimals, rounding.Mode)
+		if err != nil {
+			return nil, fmt.Errorf("%s entry %d: %w", opts.path, line.entries[0].Line, err)
+		}
+		if qty <= 0 {
+			return nil, fmt.Errorf("%s entry %d: %s hours of '%s' round to 0, set quantity_decimals for the product in products.yml",
+				opts.path, line.entries[0].Line, line.hours.FloatString(2), line.product)
+		}
+		item, err := priceItem(cfg, customer, line.product, product, qty, 0, line.rate, currency, rounding)
+		if err != nil {
+			return nil, err
+		}
+		if line.description != "" {
+			item.Description = line.description
+		}
+		items = append(items, item)
+		for _, e := range line.entries {
+			keys = append(keys, e.Key)
+		}
+	}
+
+	more, err := parseItems(specs, cfg, customer, products, currency, rounding)
+	if err != nil {
+		return nil, err
+	}
+	items = append(items, more...)
+
+	fmt.Printf("Billing %d entries from %s.\n", len(keys), opts.path)
+	inv, err := draftInvoice(cfg, invNumber, customerKey, customer, currency, rounding, items, date)
+	if err != nil {
+		return nil, err
+	}
+	inv.Timesheet = keys
+	return inv, nil
+}
+
+// groupEntries sums entries into invoice lines in the order they first
+// appear. Entries at different rates never share a line.
+func groupEntries(entries []timesheet.Entry, by string) []*timesheetLine {
+	var lines []*timesheetLine
+	index := map[string]*timesheetLine{}
+	for _, e := range entries {
+		rate := ""
+		if e.Rate != nil {
+			rate = e.Rate.String()
+		}
+		task := e.Task
+		if e.Project != "" && e.Task != "" {
+			task = e.Project + ": " + e.Task
+		} else if e.Project != "" {
+			task = e.Project
+		}
+
+		key := e.Product + "\x00" + rate
+		switch by {
+		case groupByTask:
+			key += "\x00" + task
+		case groupByEntry:
+			key += "\x00" + e.Key
+		}
+
+		line, ok := index[key]
+		if !ok {
+			line = &timesheetLine{product: e.Product, rate: e.Rate, hours: new(big.Rat), first: e.Date, last: e.Date}
+			switch by {
+			case groupByTask:
+				line.description = task
+			case groupByEntry:
+				line.description = strings.TrimSpace(e.Date + " " + task)
+			}
+			index[key] = line
+			lines = append(lines, line)
+		}
+		line.hours.Add(line.hours, e.Hours())
+		line.entries = append(line.entries, e)
+		if e.Date < line.first {
+			line.first = e.Date
+		}
+		if e.Date > line.last {
+			line.last = e.Date
+		}
+	}
+
+	if by == groupByProduct {
+		for _, line := range lines {
+			line.description = line.first
+			if line.last != line.first {
+				line.description += " to " + line.last
+			}
+		}
+	}
+	return lines
+}
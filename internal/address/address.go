@@ -0,0 +1,70 @@
+// Package address reads the parts of postal addresses written as free text
+// in config.yml and customers.yml, for formats that want them separately.
+package address
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Address is a postal address split into street lines and city
+type Address struct {
+	Lines    []string // street lines, without the postcode and city
+	Postcode string
+	City     string // empty when the last line doesn't look like a city
+	Region   string // US state or similar, when given
+}
+
+// Parse splits a multi-line address. The last line is read as the postcode
+// and city when there is more than one line and it looks like one.
+func Parse(text string) Address {
+	var a Address
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			a.Lines = append(a.Lines, line)
+		}
+	}
+	if n := len(a.Lines); n > 1 {
+		if a.Postcode, a.City, a.Region = splitCity(a.Lines[n-1]); a.City != "" {
+			a.Lines = a.Lines[:n-1]
+		}
+	}
+	return a
+}
+
+// Postcode and city lines as most of Europe writes them ("10115 Berlin",
+// "00-950 Warszawa"), as the US does ("Denver, CO 80202") and as the UK
+// does ("London SW1A 1AA")
+var (
+	europeCity = regexp.MustCompile(`^((?:[A-Z]{1,2}-)?[0-9][0-9 -]{2,7}[0-9])\s+([^0-9].*)$`)
+	usCity     = regexp.MustCompile(`^(.+?),?\s+([A-Z]{2})\s+([0-9]{5}(?:-[0-9]{4})?)$`)
+	ukCity     = regexp.MustCompile(`^(.+?),?\s+([A-Z]{1,2}[0-9][0-9A-Z]?\s*[0-9][A-Z]{2})$`)
+)
+
+// splitCity reads the postcode and city from the last line of an address.
+// The city is empty when the line doesn't look like one.
+func splitCity(line string) (postcode, city, region string) {
+	if m := europeCity.FindStringSubmatch(line); m != nil {
+		return m[1], m[2], ""
+	}
+	if m := usCity.FindStringSubmatch(line); m != nil {
+		return m[3], m[1], m[2]
+	}
+	if m := ukCity.FindStringSubmatch(line); m != nil {
+		return m[2], m[1], ""
+	}
+	return "", "", ""
+}
+
+// streetNumber matches a street line ending in a building number, e.g.
+// "Bahnhofstrasse 12a"
+var streetNumber = regexp.MustCompile(`^(.*[^0-9\s])\s+([0-9]+\s?[a-zA-Z]?)$`)
+
+// SplitStreet separates the building number from the end of a street line.
+// The number is empty when the line doesn't end in one.
+func SplitStreet(line string) (street, number string) {
+	if m := streetNumber.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+		return m[1], m[2]
+	}
+	return strings.TrimSpace(line), ""
+}
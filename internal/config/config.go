@@ -2,48 +2,422 @@ package config
 
 import (
 	"fmt"
+	"net/mail"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
+	"simplebill/internal/money"
 )
 
 type Config struct {
-	Company         Company       `yaml:"company"`
-	Invoice         InvoiceConfig `yaml:"invoice"`
-	AutoCommit      bool          `yaml:"auto_commit"`
-	SkipUpdateCheck bool          `yaml:"skip_update_check"`
+	Company         Company                    `yaml:"company"`
+	Invoice         InvoiceConfig              `yaml:"invoice"`
+	TaxRates        map[string]TaxRate         `yaml:"tax_rates"`
+	Render          RenderConfig               `yaml:"render"`
+	EInvoice        EInvoiceConfig             `yaml:"einvoice"`
+	SMTP            SMTPConfig                 `yaml:"smtp"`
+	Email           EmailConfig                `yaml:"email"`
+	Reminders       []ReminderLevel            `yaml:"reminders"`
+	LateFees        *LateFees                  `yaml:"late_fees"`
+	BaseRates       []BaseRate                 `yaml:"base_rates"`
+	Timesheets      map[string]TimesheetSource `yaml:"timesheets"`
+	AutoCommit      bool                       `yaml:"auto_commit"`
+	SkipUpdateCheck bool                       `yaml:"skip_update_check"`
 }
 
 type Company struct {
-	Name    string `yaml:"name"`
-	Address string `yaml:"address"`
-	Email   string `yaml:"email"`
-	Phone   string `yaml:"phone"`
-	ID      string `yaml:"id"`
+	Name     string `yaml:"name"`
+	Address  string `yaml:"address"`
+	Email    string `yaml:"email"`
+	Phone    string `yaml:"phone"`
+	ID       string `yaml:"id"`
+	Country  string `yaml:"country"`
+	Currency string `yaml:"currency"`
+	// PeppolID is the Peppol participant ID as scheme:id, when it isn't
+	// the VAT ID in id
+	PeppolID string `yaml:"peppol_id,omitempty"`
+	// Contact is the person customers can reach about invoices
+	Contact string `yaml:"contact,omitempty"`
+	IBAN    string `yaml:"iban,omitempty"`
+	BIC     string `yaml:"bic,omitempty"`
+	// QRIBAN is the QR-IBAN that Swiss QR-bills with QR references pay into
+	QRIBAN string `yaml:"qr_iban,omitempty"`
+	// ReferenceType is the QR-bill reference: "qrr", "scor" or "none". When
+	// empty, QR references are used with a QR-IBAN and creditor references
+	// otherwise.
+	ReferenceType string `yaml:"reference_type,omitempty"`
 }
 
 type InvoiceConfig struct {
-	Prefix         string `yaml:"prefix"`
-	StartingNumber string `yaml:"starting_number"`
-	PaymentTerms   string `yaml:"payment_terms"`
-	DueDays        int    `yaml:"due_days"`
-	Notes          string `yaml:"notes"`
+	Prefix           string `yaml:"prefix"`
+	CreditNotePrefix string `yaml:"credit_note_prefix"`
+	QuotePrefix      string `yaml:"quote_prefix"`
+	QuoteValidDays   int    `yaml:"quote_valid_days"` // default 30
+	StartingNumber   string `yaml:"starting_number"`
+	PaymentTerms     string `yaml:"payment_terms"`
+	DueDays          int    `yaml:"due_days"`
+	Notes            string `yaml:"notes"`
+	Rounding         string `yaml:"rounding"`
+	RoundPer         string `yaml:"round_per"`
+	DefaultTax       string `yaml:"default_tax"`
+	ReverseCharge    string `yaml:"reverse_charge_note"`
+	// PaymentQR adds a QR code to pay with to invoices: "qr-bill",
+	// "girocode" or "none" (default). Customers can override it.
+	PaymentQR string `yaml:"payment_qr"`
+	// AttachReceipts appends the receipts of billed expenses to the
+	// invoice PDF
+	AttachReceipts bool `yaml:"attach_receipts"`
 }
 
+// RenderConfig selects how PDFs are produced
+type RenderConfig struct {
+	// Backend is "builtin", "wkhtmltopdf", "chromium", "weasyprint" or
+	// "command". When empty, wkhtmltopdf is used if it is installed and the
+	// built-in renderer otherwise.
+	Backend     string `yaml:"backend"`
+	PageSize    string `yaml:"page_size"`   // Letter (default), Legal, A4 or A5
+	Orientation string `yaml:"orientation"` // portrait (default) or landscape
+	Margins     string `yaml:"margins"`     // CSS shorthand, e.g. "10mm" or "15mm 10mm"
+	DPI         int    `yaml:"dpi"`         // wkhtmltopdf and weasyprint only
+	// Command runs a custom converter for backend "command", with {html},
+	// {pdf} and {dpi} replaced, e.g. "prince {html} -o {pdf}"
+	Command string `yaml:"command"`
+}
+
+// EInvoiceConfig holds defaults for customers that get e-invoices
+type EInvoiceConfig struct {
+	Profile string `yaml:"profile"` // Factur-X profile: minimum, basic or en16931 (default)
+	// Fonts embedded by the built-in renderer, which PDF/A requires. When
+	// empty, a common system font such as DejaVu Sans or Arial is used.
+	Font     string `yaml:"font"`
+	FontBold string `yaml:"font_bold"`
+}
+
+// SMTPConfig is the mail server invoices are sent through
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"` // default 587, or 465 with tls
+	Username string `yaml:"username"`
+	// Password can be left out in favor of $SIMPLEBILL_SMTP_PASSWORD
+	Password string `yaml:"password"`
+	// TLS is "starttls" (default), "tls" or "none" for local test servers
+	TLS string `yaml:"tls"`
+}
+
+// EmailConfig is the message invoices are sent with. Subject and body are
+// Go templates with the same data as template.html.
+type EmailConfig struct {
+	From    string   `yaml:"from"` // default: company name and email
+	Subject string   `yaml:"subject"`
+	Body    string   `yaml:"body"`
+	Cc      []string `yaml:"cc"`  // copied on every invoice
+	Bcc     []string `yaml:"bcc"` // e.g. your bookkeeping inbox
+}
+
+// ReminderLevel is one step of dunning for unpaid invoices, reached Days
+// after the due date. Text, Subject and Body are Go templates with the same
+// data as template.html.
+type ReminderLevel struct {
+	Days int    `yaml:"days"`
+	Name string `yaml:"name"` // title of the notice, e.g. "Second notice"
+	Text string `yaml:"text"` // printed on the notice
+	// Fee is charged with the notice, in the company currency, or from Fees
+	// for customers billed in other currencies
+	Fee  money.Amount            `yaml:"fee,omitempty"`
+	Fees map[string]money.Amount `yaml:"fees,omitempty"`
+	// Subject and Body of the email sending the notice, empty for defaults
+	Subject string `yaml:"subject,omitempty"`
+	Body    string `yaml:"body,omitempty"`
+	// LateFees adds the late fees and interest accrued so far to the notice
+	LateFees bool `yaml:"late_fees,omitempty"`
+}
+
+// DefaultReminderLevels are used when config.yml has no reminders
+var DefaultReminderLevels = []ReminderLevel{
+	{
+		Days: 7,
+		Name: "Payment reminder",
+		Text: "We have not yet received payment of invoice {{.InvoiceNumber}} of {{.Date}}, which was due on {{.DueDate}}. " +
+			"Perhaps it has escaped your attention. Please transfer {{money .Reminder.AmountDue}} within the next few days. " +
+			"If you have already paid, please disregard this reminder.",
+	},
+	{
+		Days: 21,
+		Name: "Second notice",
+		Text: "Despite our reminder, invoice {{.InvoiceNumber}} of {{.Date}} is still unpaid {{.Reminder.DaysOverdue}} days after it was due. " +
+			"Please transfer {{money .Reminder.AmountDue}} within 10 days.",
+	},
+	{
+		Days: 45,
+		Name: "Final notice",
+		Text: "Invoice {{.InvoiceNumber}} of {{.Date}} is now {{.Reminder.DaysOverdue}} days overdue. " +
+			"Please transfer {{money .Reminder.AmountDue}} within 7 days, or we will have to pass the matter on for collection without further notice.",
+	},
+}
+
+// FeeIn returns the fee for an invoice in currency
+func (l ReminderLevel) FeeIn(currency, defaultCurrency string) (money.Amount, error) {
+	if fee, ok := l.Fees[currency]; ok {
+		return fee, nil
+	}
+	if currency == defaultCurrency || l.Fee == 0 {
+		return l.Fee, nil
+	}
+	return 0, fmt.Errorf("no %s fee for reminder '%s', add it under fees: in config.yml", currency, l.Name)
+}
+
+// Interest rules for LateFees
+const (
+	InterestMonthly   = "monthly"   // Rate percent per month
+	InterestStatutory = "statutory" // Rate points over the base rate from base_rates
+)
+
+// LateFees are charged for paying an invoice late: a flat fee once and
+// interest on the balance for every day it is overdue, both starting
+// GraceDays after the due date
+type LateFees struct {
+	// Flat is in the company currency, FlatFees in other currencies
+	Flat      money.Amount            `yaml:"flat,omitempty"`
+	FlatFees  map[string]money.Amount `yaml:"flat_fees,omitempty"`
+	Interest  string                  `yaml:"interest,omitempty"` // monthly, statutory or empty for none
+	Rate      money.Decimal           `yaml:"rate,omitempty"`
+	GraceDays int                     `yaml:"grace_days,omitempty"`
+}
+
+// FlatIn returns the flat fee for an invoice in currency
+func (l LateFees) FlatIn(currency, defaultCurrency string) (money.Amount, error) {
+	if fee, ok := l.FlatFees[currency]; ok {
+		return fee, nil
+	}
+	if currency == defaultCurrency || l.Flat == 0 {
+		return l.Flat, nil
+	}
+	return 0, fmt.Errorf("no %s flat fee in late_fees, add it under flat_fees:", currency)
+}
+
+// Describe summarizes the rule, e.g. "40.00 flat fee, base rate + 9% a year"
+func (l LateFees) Describe() string {
+	var parts []string
+	if l.Flat != 0 || len(l.FlatFees) > 0 {
+		parts = append(parts, "flat fee")
+	}
+	switch l.Interest {
+	case InterestMonthly:
+		parts = append(parts, l.Rate.String()+"% a month")
+	case InterestStatutory:
+		parts = append(parts, "base rate + "+l.Rate.String()+"% a year")
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// BaseRate is a statutory base interest rate in percent a year, such as
+// the German Basiszinssatz, valid from a date until the next one
+type BaseRate struct {
+	From string        `yaml:"from"`
+	Rate money.Decimal `yaml:"rate"`
+}
+
+// TimesheetSource describes the export of a time tracker: which columns (or
+// JSON fields, with dots for nested ones) hold what, and how to read them
+type TimesheetSource struct {
+	Columns TimesheetColumns `yaml:"columns"`
+	// Product is billed for entries without a product column
+	Product string `yaml:"product,omitempty"`
+	// DateFormat is the date layout, e.g. DD.MM.YYYY; default YYYY-MM-DD
+	DateFormat string `yaml:"date_format,omitempty"`
+	// DurationUnit is hours (default), minutes or seconds for durations
+	// given as plain numbers. 1:30 and 1h30m are read as they are.
+	DurationUnit string `yaml:"duration_unit,omitempty"`
+}
+
+// TimesheetColumns names the columns of a timesheet export. Empty names
+// use the defaults, which are the field names themselves.
+type TimesheetColumns struct {
+	Date     string `yaml:"date,omitempty"`
+	Duration string `yaml:"duration,omitempty"`
+	Project  string `yaml:"project,omitempty"`
+	Task     string `yaml:"task,omitempty"`
+	Rate     string `yaml:"rate,omitempty"`
+	Product  string `yaml:"product,omitempty"`
+	Customer string `yaml:"customer,omitempty"`
+	ID       string `yaml:"id,omitempty"`
+}
+
+// Duration units for TimesheetSource
+const (
+	DurationHours   = "hours"
+	DurationMinutes = "minutes"
+	DurationSeconds = "seconds"
+)
+
+// TaxRate is a named percentage from tax_rates in config.yml
+type TaxRate struct {
+	Name string        `yaml:"name"`
+	Rate money.Decimal `yaml:"rate"`
+}
+
+// Customer tax treatments besides naming a tax_rates key
+const (
+	TaxExempt        = "exempt"
+	TaxReverseCharge = "reverse-charge"
+)
+
+const defaultReverseChargeNote = "Reverse charge: VAT to be accounted for by the recipient."
+
 type Customer struct {
-	Name    string `yaml:"name"`
-	Address string `yaml:"address"`
-	Email   string `yaml:"email"`
-	Phone   string `yaml:"phone"`
-	ID      string `yaml:"id"`
+	Name     string `yaml:"name"`
+	Address  string `yaml:"address"`
+	Email    string `yaml:"email"`
+	Phone    string `yaml:"phone"`
+	ID       string `yaml:"id"`
+	Country  string `yaml:"country,omitempty"`
+	Tax      string `yaml:"tax,omitempty"`
+	Currency string `yaml:"currency,omitempty"`
+	// EInvoice attaches structured invoice data for the customer: "facturx",
+	// "ubl" or "xrechnung"
+	EInvoice        string `yaml:"einvoice,omitempty"`
+	EInvoiceProfile string `yaml:"einvoice_profile,omitempty"`
+	PeppolID        string `yaml:"peppol_id,omitempty"`
+	// BuyerReference is the customer's own reference for their invoices,
+	// such as a purchase order or cost center
+	BuyerReference string `yaml:"buyer_reference,omitempty"`
+	// LeitwegID routes invoices to German public authorities
+	LeitwegID string `yaml:"leitweg_id,omitempty"`
+	PaymentQR string `yaml:"payment_qr,omitempty"`
+	// Cc and Bcc get a copy of emailed invoices, besides email
+	Cc  []string `yaml:"cc,omitempty"`
+	Bcc []string `yaml:"bcc,omitempty"`
+	// LateFees replaces late_fees in config.yml for the customer; an empty
+	// one charges nothing
+	LateFees *LateFees `yaml:"late_fees,omitempty"`
+}
+
+// keyPattern is what customer and product keys may look like, so they can
+// be typed on the command line and used in file names
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidateKey checks a customer or product key
+func ValidateKey(key string) error {
+	if !keyPattern.MatchString(key) {
+		return fmt.Errorf("invalid key '%s', use letters, digits, '.', '_' and '-'", key)
+	}
+	return nil
+}
+
+// Validate checks the customer's own details. Settings that depend on
+// config.yml, such as tax keys, are checked where they are used.
+func (c Customer) Validate() error {
+	if strings.TrimSpace(c.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if strings.TrimSpace(c.Address) == "" {
+		return fmt.Errorf("address is required")
+	}
+	if c.Email != "" {
+		if err := validateEmail(c.Email); err != nil {
+			return err
+		}
+	}
+	for _, list := range [][]string{c.Cc, c.Bcc} {
+		for _, addr := range list {
+			if err := validateEmail(addr); err != nil {
+				return err
+			}
+		}
+	}
+	if c.Country != "" && !regexp.MustCompile(`^[A-Z]{2}$`).MatchString(c.Country) {
+		return fmt.Errorf("invalid country '%s', expected an ISO 3166 code such as DE", c.Country)
+	}
+	if c.Currency != "" {
+		if _, err := money.LookupCurrency(c.Currency); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateEmail checks a single plain address such as billing@acme.com
+func validateEmail(addr string) error {
+	parsed, err := mail.ParseAddress(addr)
+	if err != nil || parsed.Address != addr {
+		return fmt.Errorf("invalid email address '%s'", addr)
+	}
+	return nil
 }
 
 type Product struct {
-	Name  string  `yaml:"name"`
-	SKU   string  `yaml:"sku"`
-	Price float64 `yaml:"price"`
+	Name             string                  `yaml:"name"`
+	SKU              string                  `yaml:"sku,omitempty"`
+	Description      string                  `yaml:"description,omitempty"`
+	Price            money.Amount            `yaml:"price"`
+	Prices           map[string]money.Amount `yaml:"prices,omitempty"`
+	Tax              string                  `yaml:"tax,omitempty"`
+	Unit             string                  `yaml:"unit,omitempty"`
+	QuantityDecimals int                     `yaml:"quantity_decimals,omitempty"`
+}
+
+// Validate checks the product's own details. The tax key depends on
+// config.yml and is checked where it is used.
+func (p Product) Validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if p.Price < 0 {
+		return fmt.Errorf("price can't be negative")
+	}
+	for code, price := range p.Prices {
+		if _, err := money.LookupCurrency(code); err != nil {
+			return err
+		}
+		if price < 0 {
+			return fmt.Errorf("%s price can't be negative", code)
+		}
+	}
+	if p.QuantityDecimals < 0 || p.QuantityDecimals > money.MaxPlaces {
+		return fmt.Errorf("quantity_decimals must be 0-%d", money.MaxPlaces)
+	}
+	return nil
+}
+
+// ParseQuantity reads a quantity of the product with at most
+// quantity_decimals decimal places. Negative quantities are adjustment
+// lines, e.g. a returned item.
+func (p Product) ParseQuantity(s string) (money.Decimal, error) {
+	qty, err := money.ParseDecimal(s)
+	if err != nil || qty == 0 {
+		return 0, fmt.Errorf("invalid quantity '%s'", s)
+	}
+	if qty.Places() > p.QuantityDecimals {
+		if p.QuantityDecimals == 0 {
+			return 0, fmt.Errorf("invalid quantity '%s', whole numbers only (set quantity_decimals in products.yml)", s)
+		}
+		return 0, fmt.Errorf("invalid quantity '%s', at most %d decimal places allowed", s, p.QuantityDecimals)
+	}
+	return qty, nil
+}
+
+// PriceIn returns the product's price in the given currency. Price is in the
+// company's default currency; prices lists prices in other currencies.
+// Currency codes match in any case.
+func (p Product) PriceIn(currency, defaultCurrency string) (money.Amount, error) {
+	for code, price := range p.Prices {
+		if strings.EqualFold(code, currency) {
+			return price, nil
+		}
+	}
+	if strings.EqualFold(currency, defaultCurrency) {
+		return p.Price, nil
+	}
+	return 0, fmt.Errorf("no %s price, add it under prices: in products.yml", strings.ToUpper(currency))
 }
 
 func Dir() (string, error) {
@@ -77,13 +451,184 @@ func Load() (*Config, error) {
 	return &cfg, nil
 }
 
-func LoadCustomers() (map[string]Customer, error) {
+// Rounding returns the configured rounding rule, defaulting to half-up per line
+func (c *Config) Rounding() (money.Rounding, error) {
+	mode, err := money.ParseMode(c.Invoice.Rounding)
+	if err != nil {
+		return money.Rounding{}, err
+	}
+
+	switch c.Invoice.RoundPer {
+	case "", "line":
+		return money.Rounding{Mode: mode}, nil
+	case "invoice":
+		return money.Rounding{Mode: mode, PerInvoice: true}, nil
+	default:
+		return money.Rounding{}, fmt.Errorf("unknown round_per '%s', expected line or invoice", c.Invoice.RoundPer)
+	}
+}
+
+// DefaultCurrency returns the company's currency code, USD if unset
+func (c *Config) DefaultCurrency() string {
+	if c.Company.Currency == "" {
+		return money.DefaultCurrency
+	}
+	return strings.ToUpper(c.Company.Currency)
+}
+
+// CurrencyFor returns the currency the customer is billed in
+func (c *Config) CurrencyFor(customer Customer) (money.Currency, error) {
+	code := customer.Currency
+	if code == "" {
+		code = c.DefaultCurrency()
+	}
+	return money.LookupCurrency(code)
+}
+
+// ReminderLevels returns the dunning levels from config.yml in the order
+// they are reached, or DefaultReminderLevels when there are none
+func (c *Config) ReminderLevels() ([]ReminderLevel, error) {
+	if len(c.Reminders) == 0 {
+		return DefaultReminderLevels, nil
+	}
+	for i, l := range c.Reminders {
+		if l.Name == "" {
+			return nil, fmt.Errorf("reminder %d in config.yml has no name", i+1)
+		}
+		if l.Days < 1 || (i > 0 && l.Days <= c.Reminders[i-1].Days) {
+			return nil, fmt.Errorf("reminder '%s' in config.yml: days must be positive and larger than the level before", l.Name)
+		}
+	}
+	return c.Reminders, nil
+}
+
+// LateFeesFor returns the late fee rule for a customer: their own from
+// customers.yml, else the company's, else nil when late payment costs nothing
+func (c *Config) LateFeesFor(customer Customer) (*LateFees, error) {
+	rule := c.LateFees
+	where := "late_fees in config.yml"
+	if customer.LateFees != nil {
+		rule = customer.LateFees
+		where = "late_fees of customer '" + customer.Name + "'"
+	}
+	if rule == nil {
+		return nil, nil
+	}
+
+	switch rule.Interest {
+	case "":
+	case InterestMonthly, InterestStatutory:
+		if rule.Interest == InterestStatutory && len(c.BaseRates) == 0 {
+			return nil, fmt.Errorf("%s uses statutory interest, add base_rates to config.yml", where)
+		}
+	default:
+		return nil, fmt.Errorf("unknown interest '%s' in %s, expected monthly or statutory", rule.Interest, where)
+	}
+	if rule.Interest == InterestMonthly && rule.Rate <= 0 {
+		return nil, fmt.Errorf("%s: monthly interest needs a positive rate", where)
+	}
+	if rule.GraceDays < 0 {
+		return nil, fmt.Errorf("%s: grace_days can't be negative", where)
+	}
+	for i, b := range c.BaseRates {
+		if _, err := time.Parse("2006-01-02", b.From); err != nil {
+			return nil, fmt.Errorf("base_rates in config.yml: invalid from '%s', expected YYYY-MM-DD", b.From)
+		}
+		if i > 0 && b.From <= c.BaseRates[i-1].From {
+			return nil, fmt.Errorf("base_rates in config.yml must be in date order")
+		}
+	}
+	return rule, nil
+}
+
+// TimesheetSourceFor returns the timesheet source name from config.yml. An
+// empty name picks the source called default, or the default columns.
+func (c *Config) TimesheetSourceFor(name string) (TimesheetSource, error) {
+	if name == "" {
+		name = "default"
+	}
+	source, ok := c.Timesheets[name]
+	if name == "default" {
+		ok = true
+	}
+	if !ok {
+		var names []string
+		for n := range c.Timesheets {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return source, fmt.Errorf("timesheet source '%s' not found, add it under timesheets: in config.yml", name)
+		}
+		return source, fmt.Errorf("timesheet source '%s' not found in config.yml, expected one of: %s", name, strings.Join(names, ", "))
+	}
+
+	switch source.DurationUnit {
+	case "", DurationHours, DurationMinutes, DurationSeconds:
+	default:
+		return source, fmt.Errorf("timesheet source '%s': unknown duration_unit '%s', expected hours, minutes or seconds", name, source.DurationUnit)
+	}
+	return source, nil
+}
+
+// TaxRateFor resolves the tax rate applied to a product sold to a customer.
+// The customer's tax setting wins over the product's rate, which wins over
+// invoice.default_tax. The returned key is empty when no tax applies at all.
+func (c *Config) TaxRateFor(customer Customer, product Product) (string, TaxRate, error) {
+	switch customer.Tax {
+	case TaxExempt:
+		return TaxExempt, TaxRate{Name: "Exempt"}, nil
+	case TaxReverseCharge:
+		return TaxReverseCharge, TaxRate{Name: "Reverse charge"}, nil
+	}
+
+	key := customer.Tax
+	if key == "" {
+		key = product.Tax
+	}
+	if key == "" {
+		key = c.Invoice.DefaultTax
+	}
+	if key == "" {
+		return "", TaxRate{}, nil
+	}
+
+	rate, ok := c.TaxRates[key]
+	if !ok {
+		return "", TaxRate{}, fmt.Errorf("tax rate '%s' not found in tax_rates in config.yml", key)
+	}
+	if rate.Name == "" {
+		rate.Name = key
+	}
+	return key, rate, nil
+}
+
+// TaxNote returns the note printed on invoices for the customer's tax treatment
+func (c *Config) TaxNote(customer Customer) string {
+	if customer.Tax != TaxReverseCharge {
+		return ""
+	}
+	if c.Invoice.ReverseCharge != "" {
+		return c.Invoice.ReverseCharge
+	}
+	return defaultReverseChargeNote
+}
+
+// CustomersPath returns the location of customers.yml
+func CustomersPath() (string, error) {
 	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "customers.yml"), nil
+}
+
+func LoadCustomers() (map[string]Customer, error) {
+	path, err := CustomersPath()
 	if err != nil {
 		return nil, err
 	}
 
-	path := filepath.Join(dir, "customers.yml")
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("reading %s: %w", path, err)
@@ -97,13 +642,21 @@ func LoadCustomers() (map[string]Customer, error) {
 	return customers, nil
 }
 
-func LoadProducts() (map[string]Product, error) {
+// ProductsPath returns the location of products.yml
+func ProductsPath() (string, error) {
 	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "products.yml"), nil
+}
+
+func LoadProducts() (map[string]Product, error) {
+	path, err := ProductsPath()
 	if err != nil {
 		return nil, err
 	}
 
-	path := filepath.Join(dir, "products.yml")
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("reading %s: %w", path, err)
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// YAMLFile is a hand-edited YAML mapping, such as customers.yml, changed in
+// place. Comments, the order of entries and values that aren't touched are
+// kept as they were.
+type YAMLFile struct {
+	path string
+	doc  yaml.Node
+	// header is the text of a file holding only comments, like the examples
+	// written by init, which yaml.v3 would otherwise drop
+	header []byte
+}
+
+// OpenYAML reads a YAML mapping file for editing
+func OpenYAML(path string) (*YAMLFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	f := &YAMLFile{path: path}
+	if err := yaml.Unmarshal(data, &f.doc); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if f.doc.Kind == 0 {
+		f.header = bytes.TrimRight(data, "\n")
+		f.doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	if f.mapping().Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s is not a list of keys and values", path)
+	}
+	return f, nil
+}
+
+func (f *YAMLFile) mapping() *yaml.Node {
+	return f.doc.Content[0]
+}
+
+// find returns the position of key's key node in the mapping, or -1
+func (f *YAMLFile) find(key string) int {
+	content := f.mapping().Content
+	for i := 0; i+1 < len(content); i += 2 {
+		if content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// Has reports whether the file has an entry for key
+func (f *YAMLFile) Has(key string) bool {
+	return f.find(key) >= 0
+}
+
+// Set adds the entry for key or updates it to value. Fields of an existing
+// entry keep their comments; fields value no longer has are removed.
+func (f *YAMLFile) Set(key string, value interface{}) error {
+	var node yaml.Node
+	if err := node.Encode(value); err != nil {
+		return fmt.Errorf("encoding %s: %w", key, err)
+	}
+
+	m := f.mapping()
+	if i := f.find(key); i >= 0 {
+		m.Content[i+1] = mergeNode(m.Content[i+1], &node)
+		return nil
+	}
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, &node)
+	return nil
+}
+
+// Remove deletes the entry for key and reports whether there was one
+func (f *YAMLFile) Remove(key string) bool {
+	i := f.find(key)
+	if i < 0 {
+		return false
+	}
+	m := f.mapping()
+	m.Content = append(m.Content[:i], m.Content[i+2:]...)
+	return true
+}
+
+// Rename moves the entry for oldKey to newKey in place and reports whether
+// there was one
+func (f *YAMLFile) Rename(oldKey, newKey string) bool {
+	i := f.find(oldKey)
+	if i < 0 {
+		return false
+	}
+	f.mapping().Content[i].Value = newKey
+	return true
+}
+
+// Save writes the file back with two-space indentation, as in the files
+// init creates
+func (f *YAMLFile) Save() error {
+	var buf bytes.Buffer
+	if f.header != nil {
+		buf.Write(f.header)
+		buf.WriteString("\n\n")
+	}
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&f.doc); err != nil {
+		return fmt.Errorf("encoding %s: %w", f.path, err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("encoding %s: %w", f.path, err)
+	}
+	if err := os.WriteFile(f.path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", f.path, err)
+	}
+	return nil
+}
+
+// mergeNode returns updated with the comments of old carried over, field by
+// field where both are mappings
+func mergeNode(old, updated *yaml.Node) *yaml.Node {
+	updated.HeadComment, updated.LineComment, updated.FootComment = old.HeadComment, old.LineComment, old.FootComment
+	if old.Kind != yaml.MappingNode || updated.Kind != yaml.MappingNode {
+		if old.Kind == updated.Kind && old.Kind == yaml.ScalarNode && old.Value == updated.Value {
+			return old
+		}
+		return updated
+	}
+
+	oldFields := map[string]int{}
+	for i := 0; i+1 < len(old.Content); i += 2 {
+		oldFields[old.Content[i].Value] = i
+	}
+	for i := 0; i+1 < len(updated.Content); i += 2 {
+		j, ok := oldFields[updated.Content[i].Value]
+		if !ok {
+			continue
+		}
+		updated.Content[i] = old.Content[j]
+		updated.Content[i+1] = mergeNode(old.Content[j+1], updated.Content[i+1])
+	}
+	return updated
+}
@@ -0,0 +1,224 @@
+package einvoice
+
+import (
+	"strings"
+)
+
+// CII writes d as a UN/CEFACT Cross Industry Invoice in the given Factur-X
+// profile. The data is validated first, and the XML against the bundled
+// Factur-X schema and EN 16931 schematron.
+func CII(d *Document, profile Profile) ([]byte, error) {
+	if err := d.Validate(profile); err != nil {
+		return nil, err
+	}
+
+	w := newXMLWriter()
+	w.open("rsm:CrossIndustryInvoice",
+		"xmlns:rsm", "urn:un:unece:uncefact:data:standard:CrossIndustryInvoice:100",
+		"xmlns:ram", "urn:un:unece:uncefact:data:standard:ReusableAggregateBusinessInformationEntity:100",
+		"xmlns:qdt", "urn:un:unece:uncefact:data:standard:QualifiedDataType:100",
+		"xmlns:udt", "urn:un:unece:uncefact:data:standard:UnqualifiedDataType:100")
+
+	w.open("rsm:ExchangedDocumentContext")
+	w.open("ram:GuidelineSpecifiedDocumentContextParameter")
+	w.leaf("ram:ID", profile.guideline())
+	w.close("ram:GuidelineSpecifiedDocumentContextParameter")
+	w.close("rsm:ExchangedDocumentContext")
+
+	w.open("rsm:ExchangedDocument")
+	w.leaf("ram:ID", d.Number)
+	w.leaf("ram:TypeCode", d.TypeCode())
+	ciiDate(w, "ram:IssueDateTime", d.IssueDate)
+	if profile != Minimum && d.Notes != "" {
+		w.open("ram:IncludedNote")
+		w.leaf("ram:Content", d.Notes)
+		w.close("ram:IncludedNote")
+	}
+	w.close("rsm:ExchangedDocument")
+
+	w.open("rsm:SupplyChainTradeTransaction")
+	if profile != Minimum {
+		for _, line := range d.Lines {
+			ciiLine(w, line, profile)
+		}
+	}
+
+	w.open("ram:ApplicableHeaderTradeAgreement")
+	w.leaf("ram:BuyerReference", d.BuyerRef)
+	ciiParty(w, "ram:SellerTradeParty", d.Seller, profile, true)
+	ciiParty(w, "ram:BuyerTradeParty", d.Buyer, profile, false)
+	w.close("ram:ApplicableHeaderTradeAgreement")
+
+	w.empty("ram:ApplicableHeaderTradeDelivery")
+
+	w.open("ram:ApplicableHeaderTradeSettlement")
+	if profile != Minimum && !d.CreditNote {
+		w.leaf("ram:PaymentReference", d.Number)
+	}
+	w.leaf("ram:InvoiceCurrencyCode", d.Currency)
+	if profile != Minimum {
+		if d.IBAN != "" {
+			w.open("ram:SpecifiedTradeSettlementPaymentMeans")
+			w.leaf("ram:TypeCode", d.paymentMeans())
+			w.open("ram:PayeePartyCreditorFinancialAccount")
+			w.leaf("ram:IBANID", d.IBAN)
+			w.close("ram:PayeePartyCreditorFinancialAccount")
+			if profile == EN16931 && d.BIC != "" {
+				w.open("ram:PayeeSpecifiedCreditorFinancialInstitution")
+				w.leaf("ram:BICID", d.BIC)
+				w.close("ram:PayeeSpecifiedCreditorFinancialInstitution")
+			}
+			w.close("ram:SpecifiedTradeSettlementPaymentMeans")
+		}
+		for _, t := range d.Taxes {
+			w.open("ram:ApplicableTradeTax")
+			w.leaf("ram:CalculatedAmount", t.Amount.String())
+			w.leaf("ram:TypeCode", "VAT")
+			w.leaf("ram:ExemptionReason", t.Reason)
+			w.leaf("ram:BasisAmount", t.Basis.String())
+			w.leaf("ram:CategoryCode", t.Code)
+			w.leaf("ram:RateApplicablePercent", t.Rate.String())
+			w.close("ram:ApplicableTradeTax")
+		}
+		if d.PaymentTerms != "" || d.DueDate != "" {
+			w.open("ram:SpecifiedTradePaymentTerms")
+			w.leaf("ram:Description", d.PaymentTerms)
+			if d.DueDate != "" {
+				ciiDate(w, "ram:DueDateDateTime", d.DueDate)
+			}
+			w.close("ram:SpecifiedTradePaymentTerms")
+		}
+	}
+
+	w.open("ram:SpecifiedTradeSettlementHeaderMonetarySummation")
+	if profile != Minimum {
+		w.leaf("ram:LineTotalAmount", d.LineTotal.String())
+	}
+	w.leaf("ram:TaxBasisTotalAmount", d.LineTotal.String())
+	w.leaf("ram:TaxTotalAmount", d.TaxTotal.String(), "currencyID", d.Currency)
+	if d.Rounding != 0 && profile == EN16931 {
+		w.leaf("ram:RoundingAmount", d.Rounding.String())
+	}
+	w.leaf("ram:GrandTotalAmount", d.GrandTotal.String())
+	due := d.DuePayable
+	if profile != EN16931 {
+		// Only EN 16931 has a rounding amount to explain the difference
+		due = d.GrandTotal
+	}
+	w.leaf("ram:DuePayableAmount", due.String())
+	w.close("ram:SpecifiedTradeSettlementHeaderMonetarySummation")
+
+	if d.CreditFor != "" && profile != Minimum {
+		w.open("ram:InvoiceReferencedDocument")
+		w.leaf("ram:IssuerAssignedID", d.CreditFor)
+		w.close("ram:InvoiceReferencedDocument")
+	}
+	w.close("ram:ApplicableHeaderTradeSettlement")
+
+	w.close("rsm:SupplyChainTradeTransaction")
+	w.close("rsm:CrossIndustryInvoice")
+	if err := validateCII(d, w.Bytes(), profile); err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+// ciiDate writes a date in format 102, YYYYMMDD
+func ciiDate(w *xmlWriter, name, date string) {
+	w.open(name)
+	w.leaf("udt:DateTimeString", strings.ReplaceAll(date, "-", ""), "format", "102")
+	w.close(name)
+}
+
+func ciiLine(w *xmlWriter, line Line, profile Profile) {
+	w.open("ram:IncludedSupplyChainTradeLineItem")
+	w.open("ram:AssociatedDocumentLineDocument")
+	w.leaf("ram:LineID", line.ID)
+	w.close("ram:AssociatedDocumentLineDocument")
+
+	w.open("ram:SpecifiedTradeProduct")
+	if profile == EN16931 {
+		w.leaf("ram:SellerAssignedID", line.SKU)
+	}
+	w.leaf("ram:Name", line.Name)
+	if profile == EN16931 {
+		w.leaf("ram:Description", line.Description)
+	}
+	w.close("ram:SpecifiedTradeProduct")
+
+	w.open("ram:SpecifiedLineTradeAgreement")
+	w.open("ram:NetPriceProductTradePrice")
+	w.leaf("ram:ChargeAmount", line.Price.String())
+	w.close("ram:NetPriceProductTradePrice")
+	w.close("ram:SpecifiedLineTradeAgreement")
+
+	w.open("ram:SpecifiedLineTradeDelivery")
+	w.leaf("ram:BilledQuantity", line.Quantity.String(), "unitCode", line.Unit)
+	w.close("ram:SpecifiedLineTradeDelivery")
+
+	w.open("ram:SpecifiedLineTradeSettlement")
+	w.open("ram:ApplicableTradeTax")
+	w.leaf("ram:TypeCode", "VAT")
+	w.leaf("ram:CategoryCode", line.Tax.Code)
+	w.leaf("ram:RateApplicablePercent", line.Tax.Rate.String())
+	w.close("ram:ApplicableTradeTax")
+	w.open("ram:SpecifiedTradeSettlementLineMonetarySummation")
+	w.leaf("ram:LineTotalAmount", line.Net.String())
+	w.close("ram:SpecifiedTradeSettlementLineMonetarySummation")
+	w.close("ram:SpecifiedLineTradeSettlement")
+
+	w.close("ram:IncludedSupplyChainTradeLineItem")
+}
+
+// ciiParty writes the seller or buyer. Minimum only has the buyer's name.
+func ciiParty(w *xmlWriter, name string, p Party, profile Profile, seller bool) {
+	full := profile != Minimum || seller
+	w.open(name)
+	w.leaf("ram:Name", p.Name)
+	if profile == EN16931 && (p.Contact != "" || p.Email != "" || p.Phone != "") {
+		w.open("ram:DefinedTradeContact")
+		w.leaf("ram:PersonName", p.Contact)
+		if p.Phone != "" {
+			w.open("ram:TelephoneUniversalCommunication")
+			w.leaf("ram:CompleteNumber", p.Phone)
+			w.close("ram:TelephoneUniversalCommunication")
+		}
+		if p.Email != "" {
+			w.open("ram:EmailURIUniversalCommunication")
+			w.leaf("ram:URIID", p.Email)
+			w.close("ram:EmailURIUniversalCommunication")
+		}
+		w.close("ram:DefinedTradeContact")
+	}
+	if p.Country != "" && full {
+		w.open("ram:PostalTradeAddress")
+		if profile != Minimum {
+			w.leaf("ram:PostcodeCode", p.Postcode)
+			lines := p.Address
+			// CII has three address lines; any more are joined into the last
+			if len(lines) > 3 {
+				lines = append(lines[:2:2], strings.Join(lines[2:], ", "))
+			}
+			for i, line := range lines {
+				w.leaf([]string{"ram:LineOne", "ram:LineTwo", "ram:LineThree"}[i], line)
+			}
+			w.leaf("ram:CityName", p.City)
+		}
+		w.leaf("ram:CountryID", p.Country)
+		if profile != Minimum {
+			w.leaf("ram:CountrySubDivisionName", p.Region)
+		}
+		w.close("ram:PostalTradeAddress")
+	}
+	if profile != Minimum && p.Email != "" {
+		w.open("ram:URIUniversalCommunication")
+		w.leaf("ram:URIID", p.Email, "schemeID", "EM")
+		w.close("ram:URIUniversalCommunication")
+	}
+	if p.VATID != "" && full {
+		w.open("ram:SpecifiedTaxRegistration")
+		w.leaf("ram:ID", p.VATID, "schemeID", "VA")
+		w.close("ram:SpecifiedTaxRegistration")
+	}
+	w.close(name)
+}
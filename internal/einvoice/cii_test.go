@@ -0,0 +1,71 @@
+package einvoice
+
+import (
+	"strings"
+	"testing"
+
+	"simplebill/internal/money"
+)
+
+func testDocument() *Document {
+	rate, _ := money.ParseDecimal("19")
+	standard := Category{Code: "S", Rate: rate}
+	return &Document{
+		Number:    "INV-2025-0001",
+		IssueDate: "2025-03-01",
+		DueDate:   "2025-03-31",
+		Currency:  "EUR",
+		Seller: Party{
+			Name: "Example GmbH", Address: []string{"Hauptstraße 1"}, Postcode: "10115", City: "Berlin",
+			Country: "DE", ID: "DE123456789", VATID: "DE123456789", Email: "billing@example.de",
+		},
+		Buyer: Party{
+			Name: "Client SARL", Address: []string{"1 rue de la Paix"}, Postcode: "75002", City: "Paris",
+			Country: "FR", ID: "FR12345678901", VATID: "FR12345678901", Contact: "Jeanne Martin",
+		},
+		BuyerKey: "client",
+		IBAN:     "DE89370400440532013000",
+		Lines: []Line{
+			{ID: "1", Name: "Consulting", Quantity: money.NewDecimal(3), Unit: "HUR", Price: 10000, Net: 30000, Tax: standard},
+			{ID: "2", Name: "Travel", Quantity: money.NewDecimal(1), Unit: "C62", Price: 4550, Net: 4550, Tax: standard},
+		},
+		Taxes:      []TaxSubtotal{{Category: standard, Basis: 34550, Amount: 6565}},
+		LineTotal:  34550,
+		TaxTotal:   6565,
+		GrandTotal: 41115,
+		DuePayable: 41115,
+	}
+}
+
+func TestCIIValidatesInEveryProfile(t *testing.T) {
+	for _, profile := range []Profile{Minimum, Basic, EN16931} {
+		if _, err := CII(testDocument(), profile); err != nil {
+			t.Errorf("%s: %v", profile, err)
+		}
+	}
+}
+
+func TestCIIReportsSchematronRules(t *testing.T) {
+	d := testDocument()
+	// The tax breakdown no longer adds up to the lines
+	d.Taxes[0].Basis = 30000
+	_, err := CII(d, EN16931)
+	if err == nil || !strings.Contains(err.Error(), "[BR-S-08]") {
+		t.Fatalf("got %v, want a BR-S-08 failure", err)
+	}
+}
+
+func TestValidateCIIReportsSchemaErrors(t *testing.T) {
+	d := testDocument()
+	data, err := CII(d, Basic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Contacts are only part of the EN 16931 profile
+	broken := strings.Replace(string(data), "<ram:PostalTradeAddress>",
+		"<ram:DefinedTradeContact><ram:PersonName>Jeanne</ram:PersonName></ram:DefinedTradeContact><ram:PostalTradeAddress>", 1)
+	err = validateCII(d, []byte(broken), Basic)
+	if err == nil || !strings.Contains(err.Error(), "ram:DefinedTradeContact") {
+		t.Fatalf("got %v, want DefinedTradeContact rejected", err)
+	}
+}
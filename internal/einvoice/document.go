@@ -0,0 +1,245 @@
+package einvoice
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"simplebill/internal/address"
+	"simplebill/internal/config"
+	"simplebill/internal/iban"
+	"simplebill/internal/invoice"
+	"simplebill/internal/money"
+)
+
+// Document is an invoice or credit note in the terms of EN 16931, the
+// European e-invoicing standard. It is built from a stored invoice and
+// written out as CII for Factur-X or as UBL for Peppol.
+type Document struct {
+	Number       string
+	CreditNote   bool
+	CreditFor    string // number of the credited invoice
+	IssueDate    string // YYYY-MM-DD
+	DueDate      string
+	Currency     string
+	Seller       Party
+	Buyer        Party
+	BuyerKey     string // customers.yml key, for error messages
+	BuyerRef     string // the buyer's reference, e.g. a purchase order
+	LeitwegID    string // routing ID of German public authorities
+	IBAN         string // account to pay into
+	BIC          string
+	PaymentTerms string
+	Notes        string
+	Lines        []Line
+	Taxes        []TaxSubtotal
+
+	LineTotal  money.Amount // sum of line net amounts
+	TaxTotal   money.Amount
+	GrandTotal money.Amount // LineTotal + TaxTotal
+	Rounding   money.Amount // difference to the invoice total when rounding per invoice
+	DuePayable money.Amount
+}
+
+// Party is the seller or the buyer
+type Party struct {
+	Name     string
+	Address  []string // street lines, without the country
+	Postcode string   // from the last address line, when it can be read
+	City     string
+	Region   string // US state or similar, when given
+	Country  string // ISO 3166-1 alpha-2 code
+	ID       string // id from the YAML files
+	VATID    string // ID when it is a VAT number, e.g. DE123456789
+	Email    string
+	Phone    string
+	Peppol   string // Peppol participant ID as scheme:id, if set
+	Contact  string // contact person
+}
+
+// Line is one invoice line
+type Line struct {
+	ID          string
+	Name        string
+	SKU         string
+	Description string
+	Quantity    money.Decimal
+	Unit        string       // UN/ECE Recommendation 20 unit code
+	Price       money.Amount // net unit price
+	Net         money.Amount
+	Tax         Category
+}
+
+// Category is a VAT category from the UNCL5305 code list. Code is empty for
+// lines invoiced without any tax rate.
+type Category struct {
+	Code   string // S (standard), Z (zero rated), E (exempt) or AE (reverse charge)
+	Rate   money.Decimal
+	Reason string // exemption reason, required for E and AE
+}
+
+// TaxSubtotal is the VAT breakdown for one category and rate
+type TaxSubtotal struct {
+	Category
+	Basis  money.Amount
+	Amount money.Amount
+}
+
+// FromInvoice gathers the e-invoice data for inv. The company and customer
+// details saved on the invoice take precedence over config.yml.
+func FromInvoice(inv *invoice.Invoice, cfg *config.Config, customer config.Customer) *Document {
+	company := cfg.Company
+	if inv.CompanyInfo != nil {
+		company = *inv.CompanyInfo
+	}
+
+	d := &Document{
+		Number:       inv.InvoiceNumber,
+		CreditNote:   inv.IsCreditNote(),
+		CreditFor:    inv.CreditFor,
+		IssueDate:    inv.Date,
+		DueDate:      inv.DueDate,
+		Currency:     inv.CurrencyInfo().Code,
+		Seller:       party(company.Name, company.Address, company.Country, company.ID, company.Email, company.Phone, company.PeppolID),
+		Buyer:        party(customer.Name, customer.Address, customer.Country, customer.ID, customer.Email, customer.Phone, customer.PeppolID),
+		BuyerKey:     inv.Customer,
+		BuyerRef:     strings.TrimSpace(customer.BuyerReference),
+		LeitwegID:    strings.ToUpper(strings.TrimSpace(customer.LeitwegID)),
+		IBAN:         strings.TrimSpace(company.IBAN),
+		BIC:          strings.TrimSpace(company.BIC),
+		PaymentTerms: cfg.Invoice.PaymentTerms,
+		Notes:        cfg.Invoice.Notes,
+	}
+	d.Seller.Contact = strings.TrimSpace(company.Contact)
+	if account, err := iban.Parse(d.IBAN); err == nil {
+		d.IBAN = account
+	}
+	if d.CreditNote {
+		// The invoice's terms don't apply, but BR-CO-25 wants terms for
+		// the amount owed back
+		d.DueDate = ""
+		d.PaymentTerms = "Credit for invoice " + inv.CreditFor
+	}
+
+	// Tax bases are summed from the rounded line amounts, as EN 16931
+	// requires, and rates that map to the same category are merged. Any
+	// difference to the stored subtotal from rounding per invoice is
+	// carried as the rounding amount.
+	bases := map[Category]money.Amount{}
+	for i, item := range inv.Items {
+		line := Line{
+			ID:          strconv.Itoa(i + 1),
+			Name:        item.Name,
+			SKU:         item.SKU,
+			Description: item.Description,
+			Quantity:    item.Quantity,
+			Unit:        unitCode(item.Unit),
+			Price:       item.UnitPrice,
+			Net:         item.Total,
+		}
+		if line.Name == "" {
+			line.Name = item.Product
+		}
+		if item.Tax != nil {
+			line.Tax = category(*item.Tax, inv.TaxNote)
+			bases[line.Tax] += item.Total
+		}
+		d.Lines = append(d.Lines, line)
+		d.LineTotal += item.Total
+	}
+
+	index := map[Category]int{}
+	for _, t := range inv.Taxes {
+		c := category(t.Tax, inv.TaxNote)
+		i, ok := index[c]
+		if !ok {
+			i = len(d.Taxes)
+			index[c] = i
+			d.Taxes = append(d.Taxes, TaxSubtotal{Category: c, Basis: bases[c]})
+		}
+		d.Taxes[i].Amount += t.Amount
+		d.TaxTotal += t.Amount
+	}
+
+	d.GrandTotal = d.LineTotal + d.TaxTotal
+	d.Rounding = inv.Total - d.GrandTotal
+	d.DuePayable = inv.Total
+	return d
+}
+
+// vatPattern matches VAT numbers, which start with a country prefix
+var vatPattern = regexp.MustCompile(`^[A-Z]{2}[0-9A-Z+*.]{2,13}$`)
+
+func party(name, addr, country, id, email, phone, peppol string) Party {
+	p := Party{
+		Name:    strings.TrimSpace(name),
+		Country: strings.ToUpper(strings.TrimSpace(country)),
+		ID:      strings.TrimSpace(id),
+		Email:   strings.TrimSpace(email),
+		Phone:   strings.TrimSpace(phone),
+		Peppol:  strings.TrimSpace(peppol),
+	}
+	if vat := strings.ToUpper(strings.ReplaceAll(p.ID, " ", "")); vatPattern.MatchString(vat) {
+		p.VATID = vat
+	}
+	a := address.Parse(addr)
+	p.Address, p.Postcode, p.City, p.Region = a.Lines, a.Postcode, a.City, a.Region
+	return p
+}
+
+// unitCodes maps units commonly written in products.yml to UN/ECE
+// Recommendation 20 codes
+var unitCodes = map[string]string{
+	"h": "HUR", "hr": "HUR", "hrs": "HUR", "hour": "HUR", "hours": "HUR",
+	"min": "MIN", "minute": "MIN", "minutes": "MIN",
+	"d": "DAY", "day": "DAY", "days": "DAY",
+	"week": "WEE", "weeks": "WEE",
+	"month": "MON", "months": "MON",
+	"year": "ANN", "years": "ANN",
+	"kg": "KGM", "g": "GRM", "t": "TNE",
+	"m": "MTR", "km": "KMT", "m2": "MTK", "m³": "MTQ", "m3": "MTQ", "m²": "MTK",
+	"l":   "LTR",
+	"kwh": "KWH",
+}
+
+// unitCode returns the code for an item's unit. Units it doesn't know, and
+// items without one, are counted in C62, the code for "one".
+func unitCode(unit string) string {
+	if code, ok := unitCodes[strings.ToLower(strings.TrimSpace(unit))]; ok {
+		return code
+	}
+	return "C62"
+}
+
+// category maps a tax rate from the invoice to its VAT category
+func category(t invoice.Tax, note string) Category {
+	switch {
+	case t.Key == config.TaxReverseCharge:
+		if note == "" {
+			note = "Reverse charge"
+		}
+		return Category{Code: "AE", Reason: note}
+	case t.Key == config.TaxExempt:
+		return Category{Code: "E", Reason: "Exempt from VAT"}
+	case t.Rate == 0:
+		return Category{Code: "Z"}
+	}
+	return Category{Code: "S", Rate: t.Rate}
+}
+
+// paymentMeans is the UNCL4461 code for paying into IBAN: 58 for SEPA
+// credit transfers, 30 for other credit transfers
+func (d *Document) paymentMeans() string {
+	if iban.SEPA(d.IBAN) {
+		return "58"
+	}
+	return "30"
+}
+
+// TypeCode is the UNCL1001 document type: 380 invoice, 381 credit note
+func (d *Document) TypeCode() string {
+	if d.CreditNote {
+		return "381"
+	}
+	return "380"
+}
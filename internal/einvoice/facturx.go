@@ -0,0 +1,134 @@
+package einvoice
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"simplebill/internal/pdf"
+)
+
+// Profile is a Factur-X (ZUGFeRD 2) conformance level, from the bare
+// totals of Minimum to the full EN 16931 invoice
+type Profile string
+
+const (
+	Minimum Profile = "minimum"
+	Basic   Profile = "basic"
+	EN16931 Profile = "en16931"
+)
+
+// FacturX is the einvoice setting for customers that get Factur-X PDFs
+const FacturX = "facturx"
+
+// ParseProfile reads a profile name from config. Empty means EN 16931, and
+// ZUGFeRD's name for it, "comfort", is accepted too.
+func ParseProfile(s string) (Profile, error) {
+	name := strings.ToLower(strings.NewReplacer(" ", "", "-", "", "_", "").Replace(s))
+	switch name {
+	case "minimum":
+		return Minimum, nil
+	case "basic":
+		return Basic, nil
+	case "", "en16931", "comfort":
+		return EN16931, nil
+	}
+	return "", fmt.Errorf("unknown Factur-X profile '%s', expected minimum, basic or en16931", s)
+}
+
+// guideline is the specification identifier written into the XML
+func (p Profile) guideline() string {
+	switch p {
+	case Minimum:
+		return "urn:factur-x.eu:1p0:minimum"
+	case Basic:
+		return "urn:cen.eu:en16931:2017#compliant#urn:factur-x.eu:1p0:basic"
+	}
+	return "urn:cen.eu:en16931:2017"
+}
+
+// conformance is the profile name in the Factur-X XMP metadata
+func (p Profile) conformance() string {
+	switch p {
+	case Minimum:
+		return "MINIMUM"
+	case Basic:
+		return "BASIC"
+	}
+	return "EN 16931"
+}
+
+// EmbedFacturX turns a rendered invoice PDF into a Factur-X file: PDF/A-3
+// with the CII XML attached as factur-x.xml
+func EmbedFacturX(pdfData []byte, d *Document, profile Profile) ([]byte, error) {
+	xml, err := CII(d, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	// Minimum isn't a complete invoice on its own, so the XML is only
+	// supporting data; for the other profiles it's equivalent to the PDF
+	relationship := "Alternative"
+	if profile == Minimum {
+		relationship = "Data"
+	}
+
+	title := fmt.Sprintf("Invoice %s", d.Number)
+	if d.CreditNote {
+		title = fmt.Sprintf("Credit note %s", d.Number)
+	}
+
+	return pdf.ConvertPDFA3(pdfData, pdf.PDFA3{
+		Title:  title,
+		Author: d.Seller.Name,
+		Date:   time.Now(),
+		XMP:    facturXMP(profile),
+		Attachments: []pdf.Attachment{{
+			Name:         "factur-x.xml",
+			Description:  "Factur-X invoice data",
+			MimeType:     "text/xml",
+			Relationship: relationship,
+			Data:         xml,
+		}},
+	})
+}
+
+// facturXMP declares the Factur-X metadata and, since PDF/A only allows
+// known XMP properties, the extension schema describing them
+func facturXMP(profile Profile) string {
+	property := func(name, description string) string {
+		return fmt.Sprintf(`<rdf:li rdf:parseType="Resource">
+<pdfaProperty:name>%s</pdfaProperty:name>
+<pdfaProperty:valueType>Text</pdfaProperty:valueType>
+<pdfaProperty:category>external</pdfaProperty:category>
+<pdfaProperty:description>%s</pdfaProperty:description>
+</rdf:li>
+`, name, description)
+	}
+
+	return `<rdf:Description rdf:about="" xmlns:fx="urn:factur-x:pdfa:CrossIndustryDocument:invoice:1p0#">
+<fx:DocumentType>INVOICE</fx:DocumentType>
+<fx:DocumentFileName>factur-x.xml</fx:DocumentFileName>
+<fx:Version>1.0</fx:Version>
+<fx:ConformanceLevel>` + profile.conformance() + `</fx:ConformanceLevel>
+</rdf:Description>
+<rdf:Description rdf:about="" xmlns:pdfaExtension="http://www.aiim.org/pdfa/ns/extension/" xmlns:pdfaSchema="http://www.aiim.org/pdfa/ns/schema#" xmlns:pdfaProperty="http://www.aiim.org/pdfa/ns/property#">
+<pdfaExtension:schemas>
+<rdf:Bag>
+<rdf:li rdf:parseType="Resource">
+<pdfaSchema:schema>Factur-X PDFA Extension Schema</pdfaSchema:schema>
+<pdfaSchema:namespaceURI>urn:factur-x:pdfa:CrossIndustryDocument:invoice:1p0#</pdfaSchema:namespaceURI>
+<pdfaSchema:prefix>fx</pdfaSchema:prefix>
+<pdfaSchema:property>
+<rdf:Seq>
+` + property("DocumentFileName", "The name of the embedded XML document") +
+		property("DocumentType", "The type of the hybrid document in capital letters, e.g. INVOICE or ORDER") +
+		property("Version", "The actual version of the standard applying to the embedded XML document") +
+		property("ConformanceLevel", "The conformance level of the embedded XML document") + `</rdf:Seq>
+</pdfaSchema:property>
+</rdf:li>
+</rdf:Bag>
+</pdfaExtension:schemas>
+</rdf:Description>
+`
+}
@@ -0,0 +1,73 @@
+package einvoice
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"simplebill/internal/xmlcheck"
+)
+
+// The Factur-X schemas and EN 16931 rules CII output is validated against,
+// so no validator or network access is needed
+//
+//go:embed schema/facturx
+var schemas embed.FS
+
+// facturXSchemas are loaded on first use, once per profile
+var facturXSchemas struct {
+	sync.Mutex
+	xsd map[Profile]*xmlcheck.Schema
+	sch *xmlcheck.Schematron
+}
+
+func facturXRules(profile Profile) (*xmlcheck.Schema, *xmlcheck.Schematron, error) {
+	facturXSchemas.Lock()
+	defer facturXSchemas.Unlock()
+
+	if facturXSchemas.sch == nil {
+		sch, err := xmlcheck.LoadSchematron(schemas, "schema/facturx/en16931.sch")
+		if err != nil {
+			return nil, nil, fmt.Errorf("loading the bundled schematron: %w", err)
+		}
+		facturXSchemas.sch = sch
+		facturXSchemas.xsd = map[Profile]*xmlcheck.Schema{}
+	}
+	xsd, ok := facturXSchemas.xsd[profile]
+	if !ok {
+		var err error
+		if xsd, err = xmlcheck.LoadSchema(schemas, "schema/facturx/"+string(profile)+"/invoice.xsd"); err != nil {
+			return nil, nil, fmt.Errorf("loading the bundled %s schema: %w", profile.conformance(), err)
+		}
+		facturXSchemas.xsd[profile] = xsd
+	}
+	return xsd, facturXSchemas.sch, nil
+}
+
+// validateCII checks CII output against the Factur-X schema of the profile
+// and the EN 16931 schematron rules. Problems found here are bugs in the
+// XML simplebill writes rather than in the invoice data, which Validate
+// checks first.
+func validateCII(d *Document, data []byte, profile Profile) error {
+	xsd, sch, err := facturXRules(profile)
+	if err != nil {
+		return err
+	}
+	var problems []string
+	for _, err := range []error{xsd.Validate(data), sch.Validate(data, string(profile))} {
+		var invalid *xmlcheck.Error
+		switch {
+		case errors.As(err, &invalid):
+			problems = append(problems, invalid.Problems...)
+		case err != nil:
+			return err
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("the Factur-X XML for %s %s fails validation against the bundled %s schema and schematron:\n  - %s",
+			d.kind(), d.Number, profile.conformance(), strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
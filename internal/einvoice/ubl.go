@@ -0,0 +1,277 @@
+package einvoice
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"simplebill/internal/money"
+)
+
+// UBL is the einvoice setting for customers that get a Peppol UBL file
+// alongside the PDF
+const UBL = "ubl"
+
+const (
+	peppolCustomization = "urn:cen.eu:en16931:2017#compliant#urn:fdc:peppol.eu:2017:poacc:billing:3.0"
+	peppolProfile       = "urn:fdc:peppol.eu:2017:poacc:billing:01:1.0"
+)
+
+// vatSchemes maps countries to the Peppol (EAS) scheme of their VAT
+// numbers, so a VAT ID can double as the endpoint ID. Countries that route
+// by other numbers, like Denmark, Norway and Sweden, need peppol_id set.
+var vatSchemes = map[string]string{
+	"AD": "9922", "AL": "9923", "AT": "9914", "BA": "9924", "BE": "9925",
+	"BG": "9926", "CH": "9927", "CY": "9928", "CZ": "9929", "DE": "9930",
+	"EE": "9931", "ES": "9920", "FI": "0213", "FR": "9957", "GB": "9932",
+	"GR": "9933", "HR": "9934", "HU": "9910", "IE": "9935", "IT": "0211",
+	"LI": "9936", "LT": "9937", "LU": "9938", "LV": "9939", "MC": "9940",
+	"ME": "9941", "MK": "9942", "MT": "9943", "NL": "9944", "PL": "9945",
+	"PT": "9946", "RO": "9947", "RS": "9948", "SI": "9949", "SK": "9950",
+	"SM": "9951", "TR": "9952", "VA": "9953",
+}
+
+var peppolPattern = regexp.MustCompile(`^[0-9]{4}:[^\s:]+$`)
+
+// endpoint is an electronic address: an ID and the EAS code of its scheme
+type endpoint struct {
+	scheme, id string
+}
+
+// endpoint returns the party's Peppol address: peppol_id when set,
+// otherwise the VAT ID. ok is false when there is neither.
+func (p Party) endpoint() (e endpoint, ok bool) {
+	if p.Peppol != "" {
+		e.scheme, e.id, _ = strings.Cut(p.Peppol, ":")
+		return e, peppolPattern.MatchString(p.Peppol)
+	}
+	// Greek VAT numbers start with EL rather than the country code
+	country := p.VATID[:min(2, len(p.VATID))]
+	if country == "EL" {
+		country = "GR"
+	}
+	e.scheme, ok = vatSchemes[country]
+	e.id = p.VATID
+	return e, ok
+}
+
+// ValidatePeppol checks d against EN 16931 and the extra rules of Peppol
+// BIS Billing 3.0
+func (d *Document) ValidatePeppol() error {
+	problems := d.problems(EN16931)
+	if _, ok := d.Seller.endpoint(); !ok {
+		if d.Seller.Peppol != "" {
+			problems = append(problems, fmt.Sprintf("company.peppol_id '%s' in config.yml must be scheme:id, e.g. 0088:5790000436057 (PEPPOL-EN16931-R020)", d.Seller.Peppol))
+		} else if d.Seller.VATID != "" {
+			problems = append(problems, "company.peppol_id is missing in config.yml: your VAT ID can't be used as Peppol ID in your country, set it as scheme:id, e.g. 0192:123456789 (PEPPOL-EN16931-R020)")
+		}
+	}
+	if _, ok := d.Buyer.endpoint(); !ok {
+		switch {
+		case d.Buyer.Peppol != "":
+			problems = append(problems, fmt.Sprintf("%s must be scheme:id, e.g. 0088:5790000436057 (PEPPOL-EN16931-R010)", d.customerField(fmt.Sprintf("peppol_id '%s'", d.Buyer.Peppol))))
+		case d.Buyer.VATID != "":
+			problems = append(problems, fmt.Sprintf("%s is missing: the VAT ID can't be used as Peppol ID in %s, set it as scheme:id, e.g. 0192:123456789 (PEPPOL-EN16931-R010)", d.customerField("peppol_id"), d.Buyer.Country))
+		default:
+			problems = append(problems, fmt.Sprintf("%s is missing: set the customer's VAT ID in id, or their Peppol ID as scheme:id in peppol_id (PEPPOL-EN16931-R010)", d.customerField("peppol_id")))
+		}
+	}
+	return d.result(problems)
+}
+
+// WriteUBL writes d as a Peppol BIS Billing 3.0 UBL Invoice, or CreditNote
+// for credit notes, after validating it
+func WriteUBL(d *Document) ([]byte, error) {
+	if err := d.ValidatePeppol(); err != nil {
+		return nil, err
+	}
+	seller, _ := d.Seller.endpoint()
+	buyer, _ := d.Buyer.endpoint()
+
+	// Peppol needs a buyer or order reference; without one from the buyer,
+	// the invoice number is the usual stand-in
+	ref := d.BuyerRef
+	if ref == "" {
+		ref = d.Number
+	}
+	return writeUBL(d, peppolCustomization, ref, seller, buyer), nil
+}
+
+// writeUBL writes d as UBL for the given specification
+func writeUBL(d *Document, customization, buyerRef string, seller, buyer endpoint) []byte {
+	root, lineName, quantityName := "Invoice", "cac:InvoiceLine", "cbc:InvoicedQuantity"
+	if d.CreditNote {
+		root, lineName, quantityName = "CreditNote", "cac:CreditNoteLine", "cbc:CreditedQuantity"
+	}
+	w := newXMLWriter()
+	amount := func(name string, a money.Amount) {
+		w.leaf(name, a.String(), "currencyID", d.Currency)
+	}
+
+	w.open(root,
+		"xmlns", "urn:oasis:names:specification:ubl:schema:xsd:"+root+"-2",
+		"xmlns:cac", "urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2",
+		"xmlns:cbc", "urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2")
+	w.leaf("cbc:CustomizationID", customization)
+	w.leaf("cbc:ProfileID", peppolProfile)
+	w.leaf("cbc:ID", d.Number)
+	w.leaf("cbc:IssueDate", d.IssueDate)
+	if !d.CreditNote {
+		w.leaf("cbc:DueDate", d.DueDate)
+	}
+	w.leaf("cbc:"+root+"TypeCode", d.TypeCode())
+	w.leaf("cbc:Note", d.Notes)
+	w.leaf("cbc:DocumentCurrencyCode", d.Currency)
+	w.leaf("cbc:BuyerReference", buyerRef)
+
+	if d.CreditFor != "" {
+		w.open("cac:BillingReference")
+		w.open("cac:InvoiceDocumentReference")
+		w.leaf("cbc:ID", d.CreditFor)
+		w.close("cac:InvoiceDocumentReference")
+		w.close("cac:BillingReference")
+	}
+
+	w.open("cac:AccountingSupplierParty")
+	ublParty(w, d.Seller, seller)
+	w.close("cac:AccountingSupplierParty")
+	w.open("cac:AccountingCustomerParty")
+	ublParty(w, d.Buyer, buyer)
+	w.close("cac:AccountingCustomerParty")
+
+	if d.IBAN != "" {
+		w.open("cac:PaymentMeans")
+		w.leaf("cbc:PaymentMeansCode", d.paymentMeans())
+		if !d.CreditNote {
+			w.leaf("cbc:PaymentID", d.Number)
+		}
+		w.open("cac:PayeeFinancialAccount")
+		w.leaf("cbc:ID", d.IBAN)
+		if d.BIC != "" {
+			w.open("cac:FinancialInstitutionBranch")
+			w.leaf("cbc:ID", d.BIC)
+			w.close("cac:FinancialInstitutionBranch")
+		}
+		w.close("cac:PayeeFinancialAccount")
+		w.close("cac:PaymentMeans")
+	}
+
+	if d.PaymentTerms != "" {
+		w.open("cac:PaymentTerms")
+		w.leaf("cbc:Note", d.PaymentTerms)
+		w.close("cac:PaymentTerms")
+	}
+
+	w.open("cac:TaxTotal")
+	amount("cbc:TaxAmount", d.TaxTotal)
+	for _, t := range d.Taxes {
+		w.open("cac:TaxSubtotal")
+		amount("cbc:TaxableAmount", t.Basis)
+		amount("cbc:TaxAmount", t.Amount)
+		ublCategory(w, "cac:TaxCategory", t.Category)
+		w.close("cac:TaxSubtotal")
+	}
+	w.close("cac:TaxTotal")
+
+	w.open("cac:LegalMonetaryTotal")
+	amount("cbc:LineExtensionAmount", d.LineTotal)
+	amount("cbc:TaxExclusiveAmount", d.LineTotal)
+	amount("cbc:TaxInclusiveAmount", d.GrandTotal)
+	if d.Rounding != 0 {
+		amount("cbc:PayableRoundingAmount", d.Rounding)
+	}
+	amount("cbc:PayableAmount", d.DuePayable)
+	w.close("cac:LegalMonetaryTotal")
+
+	for _, line := range d.Lines {
+		w.open(lineName)
+		w.leaf("cbc:ID", line.ID)
+		w.leaf(quantityName, line.Quantity.String(), "unitCode", line.Unit)
+		amount("cbc:LineExtensionAmount", line.Net)
+		w.open("cac:Item")
+		w.leaf("cbc:Description", line.Description)
+		w.leaf("cbc:Name", line.Name)
+		if line.SKU != "" {
+			w.open("cac:SellersItemIdentification")
+			w.leaf("cbc:ID", line.SKU)
+			w.close("cac:SellersItemIdentification")
+		}
+		ublCategory(w, "cac:ClassifiedTaxCategory", Category{Code: line.Tax.Code, Rate: line.Tax.Rate})
+		w.close("cac:Item")
+		w.open("cac:Price")
+		amount("cbc:PriceAmount", line.Price)
+		w.close("cac:Price")
+		w.close(lineName)
+	}
+
+	w.close(root)
+	return w.Bytes()
+}
+
+func ublParty(w *xmlWriter, p Party, e endpoint) {
+	w.open("cac:Party")
+	w.leaf("cbc:EndpointID", e.id, "schemeID", e.scheme)
+	w.open("cac:PartyName")
+	w.leaf("cbc:Name", p.Name)
+	w.close("cac:PartyName")
+
+	// UBL has two street lines and one more address line; any more are
+	// joined into the last
+	w.open("cac:PostalAddress")
+	lines := p.Address
+	if len(lines) > 3 {
+		lines = append(lines[:2:2], strings.Join(lines[2:], ", "))
+	}
+	if len(lines) > 0 {
+		w.leaf("cbc:StreetName", lines[0])
+	}
+	if len(lines) > 1 {
+		w.leaf("cbc:AdditionalStreetName", lines[1])
+	}
+	w.leaf("cbc:CityName", p.City)
+	w.leaf("cbc:PostalZone", p.Postcode)
+	w.leaf("cbc:CountrySubentity", p.Region)
+	if len(lines) > 2 {
+		w.open("cac:AddressLine")
+		w.leaf("cbc:Line", lines[2])
+		w.close("cac:AddressLine")
+	}
+	w.open("cac:Country")
+	w.leaf("cbc:IdentificationCode", p.Country)
+	w.close("cac:Country")
+	w.close("cac:PostalAddress")
+
+	if p.VATID != "" {
+		w.open("cac:PartyTaxScheme")
+		w.leaf("cbc:CompanyID", p.VATID)
+		w.open("cac:TaxScheme")
+		w.leaf("cbc:ID", "VAT")
+		w.close("cac:TaxScheme")
+		w.close("cac:PartyTaxScheme")
+	}
+	w.open("cac:PartyLegalEntity")
+	w.leaf("cbc:RegistrationName", p.Name)
+	w.close("cac:PartyLegalEntity")
+
+	if p.Contact != "" || p.Phone != "" || p.Email != "" {
+		w.open("cac:Contact")
+		w.leaf("cbc:Name", p.Contact)
+		w.leaf("cbc:Telephone", p.Phone)
+		w.leaf("cbc:ElectronicMail", p.Email)
+		w.close("cac:Contact")
+	}
+	w.close("cac:Party")
+}
+
+// ublCategory writes a VAT category. Peppol wants the percentage for every
+// category simplebill uses, 0 for all but standard rated.
+func ublCategory(w *xmlWriter, name string, c Category) {
+	w.open(name)
+	w.leaf("cbc:ID", c.Code)
+	w.leaf("cbc:Percent", c.Rate.String())
+	w.leaf("cbc:TaxExemptionReason", c.Reason)
+	w.open("cac:TaxScheme")
+	w.leaf("cbc:ID", "VAT")
+	w.close("cac:TaxScheme")
+	w.close(name)
+}
@@ -0,0 +1,130 @@
+package einvoice
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"simplebill/internal/iban"
+)
+
+// ValidationError lists every rule an e-invoice breaks. Problems name the
+// YAML field to fix where there is one, and the EN 16931 rule in brackets.
+type ValidationError struct {
+	Document string
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s is not a valid e-invoice:\n  - %s", e.Document, strings.Join(e.Problems, "\n  - "))
+}
+
+var countryPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// Validate checks d against the EN 16931 business rules that apply to data
+// simplebill stores, naming the YAML field to fix. Profile Minimum only
+// needs the document totals, so line and address rules are skipped for it.
+// CII runs this first and then validates the XML itself.
+func (d *Document) Validate(profile Profile) error {
+	return d.result(d.problems(profile))
+}
+
+// problems lists the EN 16931 rules d breaks
+func (d *Document) problems(profile Profile) []string {
+	var problems []string
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	customer := d.customerField
+
+	if d.Number == "" {
+		add("invoice_number is missing (BR-02)")
+	}
+	if _, err := time.Parse("2006-01-02", d.IssueDate); err != nil {
+		add("date '%s' is not a valid YYYY-MM-DD date (BR-03)", d.IssueDate)
+	}
+	if d.DueDate != "" {
+		if _, err := time.Parse("2006-01-02", d.DueDate); err != nil {
+			add("due_date '%s' is not a valid YYYY-MM-DD date", d.DueDate)
+		}
+	}
+
+	if d.Seller.Name == "" {
+		add("company.name is missing in config.yml (BR-06)")
+	}
+	switch {
+	case d.Seller.Country == "":
+		add("company.country is missing in config.yml, e.g. country: DE (BR-09)")
+	case !countryPattern.MatchString(d.Seller.Country):
+		add("company.country '%s' in config.yml must be a two-letter ISO 3166 code such as DE (BR-09)", d.Seller.Country)
+	}
+	switch {
+	case d.Seller.ID == "":
+		add("company.id is missing in config.yml: set your VAT ID, e.g. DE123456789 (BR-CO-26)")
+	case d.Seller.VATID == "":
+		add("company.id '%s' in config.yml must be a VAT ID starting with the country code, e.g. DE123456789 (BR-CO-9)", d.Seller.ID)
+	}
+
+	if d.IBAN != "" {
+		if _, err := iban.Parse(d.IBAN); err != nil {
+			add("company.iban in config.yml is not valid: %s", err)
+		}
+	}
+
+	if d.Buyer.Name == "" {
+		add("%s is missing (BR-07)", customer("name"))
+	}
+	if d.Buyer.Country != "" && !countryPattern.MatchString(d.Buyer.Country) {
+		add("%s must be a two-letter ISO 3166 code such as FR (BR-11)", customer(fmt.Sprintf("country '%s'", d.Buyer.Country)))
+	}
+
+	if profile != Minimum {
+		if d.Buyer.Country == "" {
+			add("%s is missing, e.g. country: FR (BR-11)", customer("country"))
+		}
+		if len(d.Lines) == 0 {
+			add("the %s has no items (BR-16)", d.kind())
+		}
+		for i, line := range d.Lines {
+			if line.Name == "" {
+				add("item %d has no name: set name for the product in products.yml (BR-25)", i+1)
+			}
+			if line.Price < 0 {
+				add("item %d (%s) has a negative price (BR-27)", i+1, line.Name)
+			}
+			if line.Tax.Code == "" {
+				add("item %d (%s) has no tax rate: set default_tax in config.yml or tax for the product in products.yml (BR-CO-4)", i+1, line.Name)
+			}
+		}
+		for _, t := range d.Taxes {
+			if t.Code == "AE" && d.Buyer.VATID == "" {
+				add("%s must be the customer's VAT ID for a reverse charge invoice (BR-AE-2)", customer("id"))
+			}
+		}
+		if !d.CreditNote && d.DuePayable > 0 && d.DueDate == "" && d.PaymentTerms == "" {
+			add("set invoice.due_days or invoice.payment_terms in config.yml (BR-CO-25)")
+		}
+	}
+
+	return problems
+}
+
+func (d *Document) result(problems []string) error {
+	if len(problems) > 0 {
+		return &ValidationError{Document: d.kind() + " " + d.Number, Problems: problems}
+	}
+	return nil
+}
+
+// customerField names a field of the buyer in customers.yml
+func (d *Document) customerField(field string) string {
+	return fmt.Sprintf("%s for customer '%s' in customers.yml", field, d.BuyerKey)
+}
+
+func (d *Document) kind() string {
+	if d.CreditNote {
+		return "credit note"
+	}
+	return "invoice"
+}
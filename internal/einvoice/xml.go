@@ -0,0 +1,69 @@
+package einvoice
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+)
+
+// xmlWriter writes indented XML with namespace prefixes, which
+// encoding/xml can't produce from structs
+type xmlWriter struct {
+	buf   bytes.Buffer
+	depth int
+}
+
+func newXMLWriter() *xmlWriter {
+	w := &xmlWriter{}
+	w.buf.WriteString(xml.Header)
+	return w
+}
+
+func (w *xmlWriter) indent() {
+	w.buf.WriteString(strings.Repeat("  ", w.depth))
+}
+
+// tag writes the start of an element with attributes given as name, value pairs
+func (w *xmlWriter) tag(name string, attrs []string) {
+	w.indent()
+	w.buf.WriteString("<" + name)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		w.buf.WriteString(" " + attrs[i] + `="`)
+		xml.EscapeText(&w.buf, []byte(attrs[i+1]))
+		w.buf.WriteString(`"`)
+	}
+	w.buf.WriteString(">")
+}
+
+// open starts an element whose children follow
+func (w *xmlWriter) open(name string, attrs ...string) {
+	w.tag(name, attrs)
+	w.buf.WriteString("\n")
+	w.depth++
+}
+
+func (w *xmlWriter) close(name string) {
+	w.depth--
+	w.indent()
+	w.buf.WriteString("</" + name + ">\n")
+}
+
+// empty writes an element with no content
+func (w *xmlWriter) empty(name string) {
+	w.indent()
+	w.buf.WriteString("<" + name + "/>\n")
+}
+
+// leaf writes an element with text content, or nothing when value is empty
+func (w *xmlWriter) leaf(name, value string, attrs ...string) {
+	if value == "" {
+		return
+	}
+	w.tag(name, attrs)
+	xml.EscapeText(&w.buf, []byte(value))
+	w.buf.WriteString("</" + name + ">\n")
+}
+
+func (w *xmlWriter) Bytes() []byte {
+	return w.buf.Bytes()
+}
@@ -0,0 +1,79 @@
+package einvoice
+
+import (
+	"fmt"
+	"regexp"
+
+	"simplebill/internal/iban"
+)
+
+// XRechnung is the einvoice setting for German public-sector customers
+const XRechnung = "xrechnung"
+
+const xrechnungCustomization = "urn:cen.eu:en16931:2017#compliant#urn:xeinkauf.de:kosit:xrechnung_3.0"
+
+// leitwegPattern is the Leitweg-ID format: a coarse address of up to 12
+// digits, an optional fine address and two check digits
+var leitwegPattern = regexp.MustCompile(`^[0-9]{2,12}(-[0-9A-Z]{1,30})?-[0-9]{2}$`)
+
+// ValidateXRechnung checks d against EN 16931 and the German rules of
+// XRechnung 3.0, which want full contact and address details
+func (d *Document) ValidateXRechnung() error {
+	problems := d.problems(EN16931)
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if d.Seller.Contact == "" {
+		add("company.contact is missing in config.yml: set the name of the person to contact about invoices (BR-DE-5)")
+	}
+	if d.Seller.Phone == "" {
+		add("company.phone is missing in config.yml (BR-DE-6)")
+	}
+	if d.Seller.Email == "" {
+		add("company.email is missing in config.yml (BR-DE-7)")
+	}
+	if d.Seller.City == "" {
+		add("the last line of company.address in config.yml must be the postcode and city, e.g. 10115 Berlin (BR-DE-3, BR-DE-4)")
+	}
+	if d.Buyer.City == "" {
+		add("the last line of %s must be the postcode and city, e.g. 10115 Berlin (BR-DE-8, BR-DE-9)", d.customerField("address"))
+	}
+
+	if d.IBAN == "" {
+		add("company.iban is missing in config.yml: XRechnung needs payment instructions (BR-DE-1)")
+	}
+
+	switch {
+	case d.LeitwegID == "":
+		add("%s is missing: set the Leitweg-ID the authority gave you, e.g. 991-12345-73 (BR-DE-15)", d.customerField("leitweg_id"))
+	case !leitwegPattern.MatchString(d.LeitwegID):
+		add("%s must look like 991-12345-73 (BR-DE-15)", d.customerField(fmt.Sprintf("leitweg_id '%s'", d.LeitwegID)))
+	case iban.Mod97(d.LeitwegID) != 1:
+		add("%s has wrong check digits, check it for typos (BR-DE-15)", d.customerField(fmt.Sprintf("leitweg_id '%s'", d.LeitwegID)))
+	}
+
+	return d.result(problems)
+}
+
+// WriteXRechnung writes d as an XRechnung 3.0 invoice in UBL syntax, after
+// validating it. The Leitweg-ID is the buyer's electronic address and,
+// unless the customer has a buyer_reference, the buyer reference too.
+func WriteXRechnung(d *Document) ([]byte, error) {
+	if err := d.ValidateXRechnung(); err != nil {
+		return nil, err
+	}
+
+	// The seller can be reached by Peppol ID or, failing that, by email
+	seller, ok := d.Seller.endpoint()
+	if !ok {
+		seller = endpoint{scheme: "EM", id: d.Seller.Email}
+	}
+	buyer := endpoint{scheme: "0204", id: d.LeitwegID}
+
+	ref := d.BuyerRef
+	if ref == "" {
+		ref = d.LeitwegID
+	}
+	return writeUBL(d, xrechnungCustomization, ref, seller, buyer), nil
+}
@@ -0,0 +1,60 @@
+package iban
+
+import (
+	"fmt"
+	"strings"
+)
+
+// lengths is the IBAN length of each country in the SEPA area
+var lengths = map[string]int{
+	"AD": 24, "AT": 20, "BE": 16, "BG": 22, "CH": 21, "CY": 28, "CZ": 24,
+	"DE": 22, "DK": 18, "EE": 20, "ES": 24, "FI": 18, "FR": 27, "GB": 22,
+	"GI": 23, "GR": 27, "HR": 21, "HU": 28, "IE": 22, "IS": 26, "IT": 27,
+	"LI": 21, "LT": 20, "LU": 20, "LV": 21, "MC": 27, "MT": 31, "NL": 18,
+	"NO": 15, "PL": 28, "PT": 25, "RO": 24, "SE": 24, "SI": 19, "SK": 24,
+	"SM": 27, "VA": 22,
+}
+
+// Parse checks an IBAN and returns it in electronic form, without spaces
+// and in upper case
+func Parse(s string) (string, error) {
+	iban := strings.ToUpper(strings.Join(strings.Fields(s), ""))
+	if len(iban) < 15 || len(iban) > 34 {
+		return "", fmt.Errorf("IBAN '%s' has the wrong length", s)
+	}
+	for _, c := range iban {
+		if (c < '0' || c > '9') && (c < 'A' || c > 'Z') {
+			return "", fmt.Errorf("IBAN '%s' contains '%c'", s, c)
+		}
+	}
+	if n, ok := lengths[iban[:2]]; ok && len(iban) != n {
+		return "", fmt.Errorf("IBAN '%s' should have %d characters for %s", s, n, iban[:2])
+	}
+	// The check digits make the rearranged IBAN leave 1
+	if Mod97(iban[4:]+iban[:4]) != 1 {
+		return "", fmt.Errorf("IBAN '%s' has wrong check digits", s)
+	}
+	return iban, nil
+}
+
+// SEPA reports whether a valid IBAN is from a country in the SEPA area
+func SEPA(iban string) bool {
+	_, ok := lengths[iban[:2]]
+	return ok
+}
+
+// Mod97 is the ISO 7064 MOD 97-10 remainder of s with letters counted as
+// 10 to 35 and anything else skipped, the check used by IBANs and other
+// identifiers
+func Mod97(s string) int {
+	r := 0
+	for _, c := range strings.ToUpper(s) {
+		switch {
+		case c >= '0' && c <= '9':
+			r = (r*10 + int(c-'0')) % 97
+		case c >= 'A' && c <= 'Z':
+			r = (r*100 + int(c-'A') + 10) % 97
+		}
+	}
+	return r
+}
@@ -0,0 +1,144 @@
+package invoice
+
+import (
+	"fmt"
+	"time"
+
+	"simplebill/internal/money"
+)
+
+const TypeCreditNote = "credit_note"
+
+// CreditLine asks for a quantity of a product on the original invoice to be credited
+type CreditLine struct {
+	Product  string
+	Quantity money.Decimal
+}
+
+// IsCreditNote reports whether the document is a credit note
+func (inv *Invoice) IsCreditNote() bool {
+	return inv.Type == TypeCreditNote
+}
+
+// CreditedBy returns the total of the credit notes among invoices issued
+// against the invoice number. Void credit notes don't count, so voiding or
+// deleting one gives the amount back to the invoice.
+func CreditedBy(number string, invoices []Invoice) money.Amount {
+	var credited money.Amount
+	for _, cn := range invoices {
+		if cn.IsCreditNote() && cn.CreditFor == number && cn.CurrentStatus() != StatusVoid {
+			credited += cn.Total
+		}
+	}
+	return credited
+}
+
+// ApplyCredits sets Credited on every invoice from the credit notes in the
+// same list
+func ApplyCredits(invoices []Invoice) {
+	for i := range invoices {
+		invoices[i].Credited = CreditedBy(invoices[i].InvoiceNumber, invoices)
+	}
+}
+
+// creditedQuantities returns how much of each line of original has already
+// been credited by the given credit notes
+func creditedQuantities(original *Invoice, creditNotes []Invoice) []money.Decimal {
+	credited := make([]money.Decimal, len(original.Items))
+	for _, cn := range creditNotes {
+		if !cn.IsCreditNote() || cn.CreditFor != original.InvoiceNumber || cn.CurrentStatus() == StatusVoid {
+			continue
+		}
+		for _, item := range cn.Items {
+			if item.Line >= 1 && item.Line <= len(credited) {
+				credited[item.Line-1] += item.Quantity
+			}
+		}
+	}
+	return credited
+}
+
+// NewCreditNote builds a credit note against original. With no lines,
+// everything not yet credited is credited. Lines are priced and taxed exactly
+// as on the original, and each records the original line it credits.
+func NewCreditNote(original *Invoice, number string, lines []CreditLine, existing []Invoice, rounding money.Rounding, now time.Time) (*Invoice, error) {
+	if original.IsCreditNote() {
+		return nil, fmt.Errorf("%s is a credit note and cannot be credited", original.InvoiceNumber)
+	}
+	if original.CurrentStatus() == StatusVoid {
+		return nil, fmt.Errorf("invoice %s is void", original.InvoiceNumber)
+	}
+
+	credited := creditedQuantities(original, existing)
+	remaining := make([]money.Decimal, len(original.Items))
+	for i, item := range original.Items {
+		remaining[i] = item.Quantity - credited[i]
+	}
+
+	take := make([]money.Decimal, len(original.Items))
+	if len(lines) == 0 {
+		copy(take, remaining)
+	}
+	for _, line := range lines {
+		if line.Quantity <= 0 {
+			return nil, fmt.Errorf("invalid quantity %s for product '%s'", line.Quantity, line.Product)
+		}
+		qty := line.Quantity
+		found := false
+		for i, item := range original.Items {
+			if item.Product != line.Product {
+				continue
+			}
+			found = true
+			n := remaining[i] - take[i]
+			// Nothing left, or an adjustment line that isn't credited back
+			if n <= 0 {
+				continue
+			}
+			if n > qty {
+				n = qty
+			}
+			take[i] += n
+			qty -= n
+		}
+		if !found {
+			return nil, fmt.Errorf("product '%s' is not on invoice %s", line.Product, original.InvoiceNumber)
+		}
+		if qty > 0 {
+			return nil, fmt.Errorf("only %s more of '%s' can be credited on invoice %s",
+				line.Quantity-qty, line.Product, original.InvoiceNumber)
+		}
+	}
+
+	var items []Item
+	for i, item := range original.Items {
+		if take[i] == 0 {
+			continue
+		}
+		item.Quantity = take[i]
+		item.Line = i + 1
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("invoice %s has already been fully credited", original.InvoiceNumber)
+	}
+
+	cn := &Invoice{
+		Type:          TypeCreditNote,
+		InvoiceNumber: number,
+		Date:          now.Format("2006-01-02"),
+		Customer:      original.Customer,
+		CustomerInfo:  original.CustomerInfo,
+		Currency:      original.Currency,
+		Items:         items,
+		TaxNote:       original.TaxNote,
+		CreditFor:     original.InvoiceNumber,
+		Status:        StatusDraft,
+		History:       []StatusChange{{Status: StatusDraft, At: now}},
+		CreatedAt:     now,
+	}
+	if err := cn.Recalculate(rounding.In(original.CurrencyInfo())); err != nil {
+		return nil, err
+	}
+	return cn, nil
+}
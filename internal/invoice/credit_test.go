@@ -0,0 +1,95 @@
+package invoice
+
+import (
+	"testing"
+	"time"
+
+	"simplebill/internal/money"
+)
+
+func TestCreditVoidRecredit(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	rounding := money.Rounding{Mode: money.HalfUp}
+
+	original := Invoice{
+		InvoiceNumber: "INV-2025-0001",
+		Status:        StatusSent,
+		Items: []Item{
+			{Product: "widget", Quantity: money.NewDecimal(3), UnitPrice: 28999},
+		},
+	}
+	if err := original.Recalculate(rounding); err != nil {
+		t.Fatal(err)
+	}
+	if original.Total != 86997 {
+		t.Fatalf("total = %s, want 869.97", original.Total)
+	}
+
+	first, err := NewCreditNote(&original, "CN-2025-0001", nil, nil, rounding, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	invoices := []Invoice{original, *first}
+	ApplyCredits(invoices)
+	if got := invoices[0].BalanceDue(); got != 0 {
+		t.Fatalf("balance after credit = %s, want 0", got)
+	}
+	if _, err := NewCreditNote(&invoices[0], "CN-2025-0002", nil, invoices, rounding, now); err == nil {
+		t.Fatal("crediting a fully credited invoice again should fail")
+	}
+
+	// Voiding the credit note gives the lines and the amount back
+	if err := invoices[1].SetStatus(StatusVoid, now); err != nil {
+		t.Fatal(err)
+	}
+	ApplyCredits(invoices)
+	if got := invoices[0].Credited; got != 0 {
+		t.Fatalf("credited after void = %s, want 0", got)
+	}
+	if got := invoices[0].BalanceDue(); got != 86997 {
+		t.Fatalf("balance after void = %s, want 869.97", got)
+	}
+
+	second, err := NewCreditNote(&invoices[0], "CN-2025-0002", nil, invoices, rounding, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	invoices = append(invoices, *second)
+	ApplyCredits(invoices)
+	if got := invoices[0].Credited; got != 86997 {
+		t.Fatalf("credited after re-credit = %s, want 869.97", got)
+	}
+	if got := invoices[0].BalanceDue(); got != 0 {
+		t.Fatalf("balance after re-credit = %s, want 0", got)
+	}
+}
+
+func TestCreditSkipsAdjustmentLines(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	rounding := money.Rounding{Mode: money.HalfUp}
+
+	// Three widgets sold and one taken back on the same invoice
+	original := Invoice{
+		InvoiceNumber: "INV-2025-0001",
+		Status:        StatusSent,
+		Items: []Item{
+			{Product: "widget", Quantity: money.NewDecimal(3), UnitPrice: 10000},
+			{Product: "widget", Quantity: money.NewDecimal(-1), UnitPrice: 10000},
+		},
+	}
+	if err := original.Recalculate(rounding); err != nil {
+		t.Fatal(err)
+	}
+	if original.Total != 20000 {
+		t.Fatalf("total = %s, want 200.00", original.Total)
+	}
+
+	lines := []CreditLine{{Product: "widget", Quantity: money.NewDecimal(2)}}
+	cn, err := NewCreditNote(&original, "CN-2025-0001", lines, nil, rounding, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cn.Items) != 1 || cn.Items[0].Line != 1 || cn.Total != 20000 {
+		t.Fatalf("credit note items = %+v, total %s, want 2 widgets from line 1", cn.Items, cn.Total)
+	}
+}
@@ -0,0 +1,170 @@
+package invoice
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"simplebill/internal/config"
+	"simplebill/internal/money"
+)
+
+// Expense is a cost incurred on a customer's behalf, billed on their next
+// invoice. Expenses are kept in expenses/<id>.yml, next to their receipts.
+type Expense struct {
+	ID          string        `yaml:"-"` // file name without .yml, e.g. EXP-2025-0001
+	Customer    string        `yaml:"customer"`
+	Date        string        `yaml:"date"`
+	Description string        `yaml:"description"`
+	Amount      money.Amount  `yaml:"amount"`
+	Currency    string        `yaml:"currency"`
+	Markup      money.Decimal `yaml:"markup,omitempty"` // percentage added to the amount
+	Tax         string        `yaml:"tax,omitempty"`    // tax rate key, as for products
+	Receipt     string        `yaml:"receipt,omitempty"`
+	CreatedAt   time.Time     `yaml:"created_at"`
+}
+
+// ExpensesDir returns the directory expenses and their receipts are kept in
+func ExpensesDir() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "expenses"), nil
+}
+
+// NextExpenseID returns the id for a new expense
+func NextExpenseID() (string, error) {
+	return nextNumber("expenses", "EXP", 0, time.Now().Year())
+}
+
+// LoadExpenses reads every expense, sorted by date and id. Like schedules,
+// an expense that can't be parsed is an error, so it can't go unbilled
+// unnoticed.
+func LoadExpenses() ([]Expense, error) {
+	dir, err := ExpensesDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var expenses []Expense
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".yml") {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+
+		var e Expense
+		if err := yaml.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+		e.ID = strings.TrimSuffix(entry.Name(), ".yml")
+		if err := e.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		expenses = append(expenses, e)
+	}
+
+	sort.Slice(expenses, func(i, j int) bool {
+		if expenses[i].Date != expenses[j].Date {
+			return expenses[i].Date < expenses[j].Date
+		}
+		return expenses[i].ID < expenses[j].ID
+	})
+	return expenses, nil
+}
+
+// Validate checks an expense for mistakes before it is billed
+func (e Expense) Validate() error {
+	if e.Customer == "" {
+		return fmt.Errorf("customer is required")
+	}
+	if e.Description == "" {
+		return fmt.Errorf("description is required")
+	}
+	if e.Amount <= 0 {
+		return fmt.Errorf("amount must be positive")
+	}
+	if _, err := time.Parse("2006-01-02", e.Date); err != nil {
+		return fmt.Errorf("invalid date '%s', expected YYYY-MM-DD", e.Date)
+	}
+	if _, err := money.LookupCurrency(e.Currency); err != nil {
+		return err
+	}
+	if e.Markup < 0 {
+		return fmt.Errorf("markup can't be negative")
+	}
+	return nil
+}
+
+// Price is the amount billed: the cost plus the markup, unrounded
+func (e Expense) Price() *big.Rat {
+	price := e.Amount.Rat()
+	return price.Add(price, e.Markup.Percent(e.Amount.Rat()))
+}
+
+// ReceiptPath returns the location of the expense's receipt, or "" when it
+// has none
+func (e Expense) ReceiptPath() (string, error) {
+	if e.Receipt == "" {
+		return "", nil
+	}
+	dir, err := ExpensesDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, e.Receipt), nil
+}
+
+// Save writes the expense to expenses/<id>.yml
+func (e Expense) Save() error {
+	dir, err := ExpensesDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating %s: %w", dir, err)
+	}
+
+	data, err := yaml.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshaling expense: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, e.ID+".yml"), data, 0644); err != nil {
+		return fmt.Errorf("writing expense: %w", err)
+	}
+	return nil
+}
+
+// BilledExpenses maps the ids of expenses already billed to the invoice
+// billing them. Expenses on void invoices can be billed again.
+func BilledExpenses(invoices []Invoice) map[string]string {
+	billed := map[string]string{}
+	for _, inv := range invoices {
+		if inv.CurrentStatus() == StatusVoid {
+			continue
+		}
+		for _, id := range inv.Expenses {
+			billed[id] = inv.InvoiceNumber
+		}
+	}
+	return billed
+}
@@ -2,50 +2,291 @@ package invoice
 
 import (
 	"fmt"
+	"math/big"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
 	"simplebill/internal/config"
+	"simplebill/internal/money"
 )
 
 type Invoice struct {
-	InvoiceNumber string    `yaml:"invoice_number"`
-	Date          string    `yaml:"date"`
-	DueDate       string    `yaml:"due_date"`
-	Customer      string    `yaml:"customer"`
-	Items         []Item    `yaml:"items"`
-	Total         float64   `yaml:"total"`
-	CreatedAt     time.Time `yaml:"created_at"`
+	Type          string           `yaml:"type,omitempty"`
+	InvoiceNumber string           `yaml:"invoice_number"`
+	Date          string           `yaml:"date"`
+	DueDate       string           `yaml:"due_date"`
+	ValidUntil    string           `yaml:"valid_until,omitempty"`
+	Customer      string           `yaml:"customer"`
+	CustomerInfo  *config.Customer `yaml:"customer_details,omitempty"`
+	CompanyInfo   *config.Company  `yaml:"company,omitempty"`
+	Currency      string           `yaml:"currency,omitempty"`
+	Items         []Item           `yaml:"items"`
+	Subtotal      money.Amount     `yaml:"subtotal"`
+	Taxes         []TaxLine        `yaml:"taxes,omitempty"`
+	TaxNote       string           `yaml:"tax_note,omitempty"`
+	Total         money.Amount     `yaml:"total"`
+	Status        Status           `yaml:"status,omitempty"`
+	History       []StatusChange   `yaml:"history,omitempty"`
+	Emails        []Email          `yaml:"emails,omitempty"`
+	Reminders     []Reminder       `yaml:"reminders,omitempty"`
+	LateFees      []LateFee        `yaml:"late_fees,omitempty"`
+	LateFeeFor    string           `yaml:"late_fee_for,omitempty"`
+	Recurring     *Recurring       `yaml:"recurring,omitempty"`
+	Timesheet     []string         `yaml:"timesheet,omitempty"` // keys of the timesheet entries billed
+	Expenses      []string         `yaml:"expenses,omitempty"`  // ids of the expenses billed
+	Receipts      []string         `yaml:"receipts,omitempty"`  // receipt files in expenses/ appended to the PDF
+	Quote         string           `yaml:"quote,omitempty"`
+	AcceptedAs    string           `yaml:"accepted_as,omitempty"`
+	Payments      []Payment        `yaml:"payments,omitempty"`
+	CreditFor     string           `yaml:"credit_for,omitempty"`
+	Credited      money.Amount     `yaml:"-"` // total of the credit notes against it, see ApplyCredits
+	CreatedAt     time.Time        `yaml:"created_at"`
 }
 
 type Item struct {
-	Product   string  `yaml:"product"`
-	Quantity  int     `yaml:"quantity"`
-	UnitPrice float64 `yaml:"unit_price"`
-	Total     float64 `yaml:"total"`
-	Discount  int     `yaml:"discount,omitempty"`
+	Product     string        `yaml:"product"`
+	Name        string        `yaml:"name,omitempty"`
+	SKU         string        `yaml:"sku,omitempty"`
+	Description string        `yaml:"description,omitempty"`
+	Quantity    money.Decimal `yaml:"quantity"`
+	Unit        string        `yaml:"unit,omitempty"`
+	UnitPrice   money.Amount  `yaml:"unit_price"`
+	Total       money.Amount  `yaml:"total"`
+	Discount    int           `yaml:"discount,omitempty"`
+	ListPrice   money.Amount  `yaml:"list_price,omitempty"`
+	Tax         *Tax          `yaml:"tax,omitempty"`
+	Line        int           `yaml:"line,omitempty"`
+}
+
+// Tax is the rate applied to an item, copied from config.yml when the
+// invoice is created so later rate changes don't alter issued invoices
+type Tax struct {
+	Key  string        `yaml:"key"`
+	Name string        `yaml:"name"`
+	Rate money.Decimal `yaml:"rate"`
+}
+
+// TaxLine is the tax due at one rate, part of the invoice's tax breakdown
+type TaxLine struct {
+	Tax    `yaml:",inline"`
+	Net    money.Amount `yaml:"net"`
+	Amount money.Amount `yaml:"amount"`
+}
+
+// Describe copies the product's name, SKU, description and unit onto the
+// item so the invoice keeps showing them if products.yml changes later
+func (item *Item) Describe(product config.Product) {
+	item.Name = product.Name
+	item.SKU = product.SKU
+	item.Description = product.Description
+	item.Unit = product.Unit
+}
+
+// NewItem prices a line item from its list price and percentage discount.
+// Totals are filled in by Recalculate.
+func NewItem(product string, qty money.Decimal, listPrice money.Amount, discount int, rounding money.Rounding) Item {
+	item := Item{
+		Product:   product,
+		Quantity:  qty,
+		UnitPrice: listPrice,
+		Discount:  discount,
+	}
+	if discount > 0 {
+		item.ListPrice = listPrice
+		// A discounted price is never larger than the list price, so it fits
+		item.UnitPrice, _ = rounding.Round(item.exactUnitPrice())
+	}
+	return item
+}
+
+// exactUnitPrice is the discounted unit price before rounding. Invoices
+// written before list prices were recorded only have the rounded price.
+func (item Item) exactUnitPrice() *big.Rat {
+	if item.Discount == 0 || item.ListPrice == 0 {
+		return item.UnitPrice.Rat()
+	}
+	r := item.ListPrice.Rat()
+	return r.Mul(r, big.NewRat(int64(100-item.Discount), 100))
+}
+
+// Recalculate computes line totals, the tax breakdown and the invoice total.
+// Rounding per line bills the rounded unit price times quantity, so rows
+// always add up to the subtotal; rounding per invoice keeps lines exact and
+// rounds the subtotal once. Tax is rounded once per rate. Totals too large
+// to hold in cents are an error.
+func (inv *Invoice) Recalculate(rounding money.Rounding) error {
+	sum := new(big.Rat)
+	nets := map[string]*big.Rat{}
+	var taxes []TaxLine
+
+	for i := range inv.Items {
+		item := &inv.Items[i]
+		qty := item.Quantity.Rat()
+
+		var exact *big.Rat
+		if rounding.PerInvoice {
+			exact = new(big.Rat).Mul(item.exactUnitPrice(), qty)
+		} else {
+			exact = new(big.Rat).Mul(item.UnitPrice.Rat(), qty)
+		}
+
+		var err error
+		if item.Total, err = rounding.Round(exact); err != nil {
+			return fmt.Errorf("item %d: %w", i+1, err)
+		}
+		net := exact
+		if !rounding.PerInvoice {
+			net = item.Total.Rat()
+		}
+		sum.Add(sum, net)
+
+		if item.Tax == nil {
+			continue
+		}
+		if _, ok := nets[item.Tax.Key]; !ok {
+			nets[item.Tax.Key] = new(big.Rat)
+			taxes = append(taxes, TaxLine{Tax: *item.Tax})
+		}
+		nets[item.Tax.Key].Add(nets[item.Tax.Key], net)
+	}
+
+	subtotal, err := rounding.Round(sum)
+	if err != nil {
+		return fmt.Errorf("subtotal: %w", err)
+	}
+	total := subtotal.Rat()
+	for i := range taxes {
+		net := nets[taxes[i].Key]
+		if taxes[i].Net, err = rounding.Round(net); err != nil {
+			return fmt.Errorf("tax %s: %w", taxes[i].Name, err)
+		}
+		if taxes[i].Amount, err = rounding.Round(taxes[i].Rate.Percent(net)); err != nil {
+			return fmt.Errorf("tax %s: %w", taxes[i].Name, err)
+		}
+		total.Add(total, taxes[i].Amount.Rat())
+	}
+	// The total is whole cents already; this only checks it fits
+	if inv.Total, err = money.FromRat(total, rounding.Mode); err != nil {
+		return fmt.Errorf("total: %w", err)
+	}
+	inv.Subtotal = subtotal
+	inv.Taxes = taxes
+	return nil
+}
+
+// CustomerName returns the customer's name as saved on the invoice, falling
+// back to customers.yml and then to the customer key
+func (inv *Invoice) CustomerName(customers map[string]config.Customer) string {
+	if inv.CustomerInfo != nil && inv.CustomerInfo.Name != "" {
+		return inv.CustomerInfo.Name
+	}
+	if c, ok := customers[inv.Customer]; ok {
+		return c.Name
+	}
+	return inv.Customer
+}
+
+// CurrencyInfo returns the formatting rules for the invoice's currency.
+// Invoices saved before currencies were recorded are in US dollars.
+func (inv *Invoice) CurrencyInfo() money.Currency {
+	c, err := money.LookupCurrency(inv.Currency)
+	if err != nil {
+		c, _ = money.LookupCurrency(money.DefaultCurrency)
+	}
+	return c
+}
+
+// Validate checks a hand-edited invoice for mistakes Recalculate can't fix
+func (inv *Invoice) Validate() error {
+	if inv.InvoiceNumber == "" {
+		return fmt.Errorf("invoice_number is required")
+	}
+	if _, err := time.Parse("2006-01-02", inv.Date); err != nil {
+		return fmt.Errorf("invalid date '%s', expected YYYY-MM-DD", inv.Date)
+	}
+	if inv.DueDate != "" {
+		if _, err := time.Parse("2006-01-02", inv.DueDate); err != nil {
+			return fmt.Errorf("invalid due_date '%s', expected YYYY-MM-DD", inv.DueDate)
+		}
+	}
+	if inv.Customer == "" {
+		return fmt.Errorf("customer is required")
+	}
+	if _, err := money.LookupCurrency(inv.Currency); err != nil {
+		return err
+	}
+	if _, ok := transitions[inv.CurrentStatus()]; !ok {
+		return fmt.Errorf("unknown status '%s'", inv.Status)
+	}
+	if len(inv.Items) == 0 {
+		return fmt.Errorf("at least one item is required")
+	}
+	for i, item := range inv.Items {
+		if item.Product == "" {
+			return fmt.Errorf("item %d: product is required", i+1)
+		}
+		if item.Quantity == 0 {
+			return fmt.Errorf("item %d (%s): quantity can't be zero", i+1, item.Product)
+		}
+		if item.UnitPrice < 0 || item.ListPrice < 0 {
+			return fmt.Errorf("item %d (%s): price can't be negative", i+1, item.Product)
+		}
+		if item.Discount < 0 || item.Discount > 100 {
+			return fmt.Errorf("item %d (%s): discount must be 0-100", i+1, item.Product)
+		}
+	}
+	return nil
+}
+
+// Net returns the total before tax. Invoices saved before tax support
+// have no subtotal, so their total is the net amount.
+func (inv *Invoice) Net() money.Amount {
+	if inv.Subtotal == 0 && len(inv.Taxes) == 0 {
+		return inv.Total
+	}
+	return inv.Subtotal
 }
 
 // NextNumber determines the next invoice number based on existing invoices or starting_number
 func NextNumber(cfg *config.Config) (string, error) {
+	return NextNumberIn(cfg, time.Now().Year())
+}
+
+// NextNumberIn determines the next invoice number in year's sequence, for
+// invoices dated in a year other than the current one
+func NextNumberIn(cfg *config.Config, year int) (string, error) {
+	startingNum, _ := strconv.Atoi(cfg.Invoice.StartingNumber)
+	return nextNumber("invoices", cfg.Invoice.Prefix, startingNum, year)
+}
+
+// NextCreditNoteNumber determines the next credit note number. Credit notes
+// have their own sequence, e.g. CN-2026-0001.
+func NextCreditNoteNumber(cfg *config.Config) (string, error) {
+	prefix := cfg.Invoice.CreditNotePrefix
+	if prefix == "" {
+		prefix = "CN"
+	}
+	return nextNumber("invoices", prefix, 0, time.Now().Year())
+}
+
+// nextNumber finds the next number in the prefix's sequence for year among
+// the documents in subdir
+func nextNumber(subdir, prefix string, startingNum, year int) (string, error) {
 	dir, err := config.Dir()
 	if err != nil {
 		return "", err
 	}
 
-	invoicesDir := filepath.Join(dir, "invoices")
-	year := time.Now().Year()
-	prefix := cfg.Invoice.Prefix
+	invoicesDir := filepath.Join(dir, subdir)
 
 	// Pattern to match invoice files: PREFIX-YEAR-NNNN.yml
 	pattern := regexp.MustCompile(fmt.Sprintf(`^%s-%d-(\d{4})\.yml$`, regexp.QuoteMeta(prefix), year))
 
-	startingNum, _ := strconv.Atoi(cfg.Invoice.StartingNumber)
-
 	entries, err := os.ReadDir(invoicesDir)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -72,14 +313,137 @@ func NextNumber(cfg *config.Config) (string, error) {
 	return fmt.Sprintf("%s-%d-%04d", prefix, year, maxSeq+1), nil
 }
 
+// Path returns the location of an invoice's YAML file
+func Path(number string) (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "invoices", number+".yml"), nil
+}
+
+// Load reads a saved invoice by number. What was credited and which late
+// fees stand depend on the other invoices, not on this file, so they are
+// derived from invoices, which callers read once with LoadAll.
+func Load(number string, invoices []Invoice) (*Invoice, error) {
+	path, err := Path(number)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("invoice %s not found", number)
+		}
+		return nil, fmt.Errorf("reading invoice: %w", err)
+	}
+
+	var inv Invoice
+	if err := yaml.Unmarshal(data, &inv); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	inv.Credited = CreditedBy(inv.InvoiceNumber, invoices)
+	inv.voidLateFees(invoices)
+	return &inv, nil
+}
+
+// LoadAll reads every saved invoice, skipping files that can't be parsed
+func LoadAll() ([]Invoice, error) {
+	invoices, err := loadDir("invoices", false)
+	if err != nil {
+		return nil, err
+	}
+	ApplyCredits(invoices)
+	for i := range invoices {
+		invoices[i].voidLateFees(invoices)
+	}
+	return invoices, nil
+}
+
+// LoadDocuments reads every saved invoice, credit note and quote. Unlike
+// LoadAll and LoadQuotes it fails on a file that can't be read or parsed,
+// for commands that rewrite them all.
+func LoadDocuments() ([]Invoice, error) {
+	invoices, err := loadDir("invoices", true)
+	if err != nil {
+		return nil, err
+	}
+	quotes, err := loadDir("quotes", true)
+	if err != nil {
+		return nil, err
+	}
+	return append(invoices, quotes...), nil
+}
+
+// loadDir reads the documents saved in the named directory. Files that
+// can't be read or parsed are skipped, or an error when strict is set.
+func loadDir(name string, strict bool) ([]Invoice, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	docsDir := filepath.Join(dir, name)
+	entries, err := os.ReadDir(docsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var docs []Invoice
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".yml") {
+			continue
+		}
+
+		path := filepath.Join(docsDir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if strict {
+				return nil, fmt.Errorf("reading %s: %w", path, err)
+			}
+			continue
+		}
+
+		var inv Invoice
+		if err := yaml.Unmarshal(data, &inv); err != nil {
+			if strict {
+				return nil, fmt.Errorf("parsing %s: %w", path, err)
+			}
+			continue
+		}
+		docs = append(docs, inv)
+	}
+	return docs, nil
+}
+
+// Dir returns the directory the document and its PDF are saved in:
+// quotes/ for quotes and invoices/ for everything else
+func (inv *Invoice) Dir() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	if inv.IsQuote() {
+		return filepath.Join(dir, "quotes"), nil
+	}
+	return filepath.Join(dir, "invoices"), nil
+}
+
 // Save writes the invoice to a YAML file
 func (inv *Invoice) Save() error {
-	dir, err := config.Dir()
+	dir, err := inv.Dir()
 	if err != nil {
 		return err
 	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating %s: %w", dir, err)
+	}
 
-	path := filepath.Join(dir, "invoices", inv.InvoiceNumber+".yml")
+	path := filepath.Join(dir, inv.InvoiceNumber+".yml")
 	data, err := yaml.Marshal(inv)
 	if err != nil {
 		return fmt.Errorf("marshaling invoice: %w", err)
@@ -0,0 +1,250 @@
+package invoice
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"simplebill/internal/config"
+	"simplebill/internal/money"
+)
+
+// LateFee records late payment charges billed for the invoice, either on a
+// fee invoice or added to a reminder
+type LateFee struct {
+	Through  string       `yaml:"through"` // interest is charged up to and including this day
+	Flat     money.Amount `yaml:"flat,omitempty"`
+	Interest money.Amount `yaml:"interest,omitempty"`
+	Invoice  string       `yaml:"invoice,omitempty"`
+	Reminder int          `yaml:"reminder,omitempty"`
+	Void     bool         `yaml:"-"` // the fee invoice was voided or deleted, see voidLateFees
+}
+
+// InterestPeriod is a stretch of days with the same balance and rate
+type InterestPeriod struct {
+	From, Through string
+	Days          int
+	Balance       money.Amount
+	Rate          money.Decimal // percent a year
+	Interest      *big.Rat      // exact, rounded only in the sum
+}
+
+// LateCharge is what late payment of an invoice costs up to a day, beyond
+// what was charged before
+type LateCharge struct {
+	Through  string
+	Flat     money.Amount
+	Interest money.Amount
+	Periods  []InterestPeriod
+}
+
+// Total returns the flat fee and interest together
+func (c LateCharge) Total() money.Amount {
+	return c.Flat + c.Interest
+}
+
+// daysPerYear is the day count interest is divided by, every year alike
+const daysPerYear = 365
+
+// AccrueLateFees works out the flat fee and interest owed under rule for
+// paying late, up to and including day, that haven't been charged yet.
+// Interest runs from grace days after the due date on the balance of each
+// day, after credit notes and the payments made by then. The flat fee is
+// charged once, with the first charge after the invoice became late.
+func (inv *Invoice) AccrueLateFees(rule config.LateFees, flat money.Amount, baseRates []config.BaseRate, day time.Time, rounding money.Rounding) (LateCharge, error) {
+	charge := LateCharge{Through: day.Format("2006-01-02")}
+	if inv.IsCreditNote() || inv.CurrentStatus() == StatusDraft || inv.CurrentStatus() == StatusVoid {
+		return charge, nil
+	}
+	due, err := time.Parse("2006-01-02", inv.DueDate)
+	if err != nil {
+		return charge, fmt.Errorf("invoice %s has no valid due date", inv.InvoiceNumber)
+	}
+	start := due.AddDate(0, 0, rule.GraceDays+1)
+	end := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+
+	// Late at all: a balance left on the first day fees apply
+	if end.Before(start) || inv.balanceOn(start) <= 0 {
+		return charge, nil
+	}
+
+	flatCharged := false
+	from := start
+	for _, f := range inv.LateFees {
+		if f.Void {
+			continue
+		}
+		if f.Flat != 0 {
+			flatCharged = true
+		}
+		if through, err := time.Parse("2006-01-02", f.Through); err == nil && !through.Before(from) {
+			from = through.AddDate(0, 0, 1)
+		}
+	}
+	if !flatCharged {
+		charge.Flat = flat
+	}
+	if rule.Interest == "" || end.Before(from) {
+		return charge, nil
+	}
+
+	total := new(big.Rat)
+	var current *InterestPeriod
+	for d := from; !d.After(end); d = d.AddDate(0, 0, 1) {
+		balance := inv.balanceOn(d)
+		rate, err := interestRate(rule, baseRates, d)
+		if err != nil {
+			return charge, err
+		}
+		if balance <= 0 || rate <= 0 {
+			current = nil
+			continue
+		}
+		date := d.Format("2006-01-02")
+		if current == nil || current.Balance != balance || current.Rate != rate {
+			charge.Periods = append(charge.Periods, InterestPeriod{From: date, Balance: balance, Rate: rate, Interest: new(big.Rat)})
+			current = &charge.Periods[len(charge.Periods)-1]
+		}
+		current.Through = date
+		current.Days++
+
+		// balance * rate / 100 / 365 for the day
+		daily := rate.Percent(balance.Rat())
+		daily.Quo(daily, big.NewRat(daysPerYear, 1))
+		current.Interest.Add(current.Interest, daily)
+		total.Add(total, daily)
+	}
+	interest, err := rounding.In(inv.CurrencyInfo()).Round(total)
+	if err != nil {
+		return charge, fmt.Errorf("interest: %w", err)
+	}
+	charge.Interest = interest
+	return charge, nil
+}
+
+// balanceOn returns what was owed at the end of day, counting credit notes
+// from the start and payments from the day they were made
+func (inv *Invoice) balanceOn(day time.Time) money.Amount {
+	balance := inv.Total - inv.Credited
+	date := day.Format("2006-01-02")
+	if len(inv.Payments) == 0 && inv.CurrentStatus() == StatusPaid {
+		// Marked paid without payments: paid on the day it was marked
+		if at, ok := inv.StatusAt(StatusPaid); ok && at.Format("2006-01-02") <= date {
+			return 0
+		}
+		return balance
+	}
+	for _, p := range inv.Payments {
+		if p.Date <= date {
+			balance -= p.Amount
+		}
+	}
+	return balance
+}
+
+// interestRate returns the yearly rate in percent on day
+func interestRate(rule config.LateFees, baseRates []config.BaseRate, day time.Time) (money.Decimal, error) {
+	if rule.Interest == config.InterestMonthly {
+		return rule.Rate * 12, nil
+	}
+	date := day.Format("2006-01-02")
+	i := sort.Search(len(baseRates), func(i int) bool { return baseRates[i].From > date })
+	if i == 0 {
+		return 0, fmt.Errorf("no base rate for %s, add one to base_rates in config.yml", date)
+	}
+	return baseRates[i-1].Rate + rule.Rate, nil
+}
+
+// ReminderLateFees returns the late fees and interest added to reminders
+func (inv *Invoice) ReminderLateFees() money.Amount {
+	var total money.Amount
+	for _, f := range inv.LateFees {
+		if f.Reminder > 0 && !f.Void {
+			total += f.Flat + f.Interest
+		}
+	}
+	return total
+}
+
+// RecordLateFee notes on the invoice that charge was billed, on a fee
+// invoice or with the reminder of the given level
+func (inv *Invoice) RecordLateFee(charge LateCharge, feeInvoice string, reminder int) {
+	inv.LateFees = append(inv.LateFees, LateFee{
+		Through:  charge.Through,
+		Flat:     charge.Flat,
+		Interest: charge.Interest,
+		Invoice:  feeInvoice,
+		Reminder: reminder,
+	})
+}
+
+// voidLateFees marks the late fees of inv billed on an invoice that is void
+// or no longer among invoices, so they can be charged again
+func (inv *Invoice) voidLateFees(invoices []Invoice) {
+	for i, f := range inv.LateFees {
+		if f.Invoice == "" {
+			continue
+		}
+		inv.LateFees[i].Void = true
+		for _, fee := range invoices {
+			if fee.InvoiceNumber == f.Invoice && fee.LateFeeFor == inv.InvoiceNumber && fee.CurrentStatus() != StatusVoid {
+				inv.LateFees[i].Void = false
+				break
+			}
+		}
+	}
+}
+
+// NewLateFeeInvoice bills charge for paying original late on an invoice of
+// its own, without tax, due dueDays from now
+func NewLateFeeInvoice(original *Invoice, number string, charge LateCharge, dueDays int, rounding money.Rounding, now time.Time) (*Invoice, error) {
+	if charge.Total() <= 0 {
+		return nil, fmt.Errorf("nothing to charge for late payment of %s", original.InvoiceNumber)
+	}
+	currency := original.CurrencyInfo()
+
+	var items []Item
+	if charge.Flat > 0 {
+		items = append(items, Item{
+			Product:     "late-fee",
+			Name:        "Late payment fee",
+			Description: "Invoice " + original.InvoiceNumber + ", due " + original.DueDate,
+			Quantity:    money.NewDecimal(1),
+			UnitPrice:   charge.Flat,
+		})
+	}
+	if charge.Interest > 0 {
+		var lines []string
+		for _, p := range charge.Periods {
+			lines = append(lines, fmt.Sprintf("%s to %s: %d days on %s at %s%% a year",
+				p.From, p.Through, p.Days, currency.Format(p.Balance), p.Rate))
+		}
+		items = append(items, Item{
+			Product:     "interest",
+			Name:        "Interest on invoice " + original.InvoiceNumber,
+			Description: strings.Join(lines, "\n"),
+			Quantity:    money.NewDecimal(1),
+			UnitPrice:   charge.Interest,
+		})
+	}
+
+	inv := &Invoice{
+		InvoiceNumber: number,
+		Date:          now.Format("2006-01-02"),
+		DueDate:       now.AddDate(0, 0, dueDays).Format("2006-01-02"),
+		Customer:      original.Customer,
+		CustomerInfo:  original.CustomerInfo,
+		Currency:      original.Currency,
+		Items:         items,
+		LateFeeFor:    original.InvoiceNumber,
+		Status:        StatusDraft,
+		History:       []StatusChange{{Status: StatusDraft, At: now}},
+		CreatedAt:     now,
+	}
+	if err := inv.Recalculate(rounding.In(currency)); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
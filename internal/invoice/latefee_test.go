@@ -0,0 +1,74 @@
+package invoice
+
+import (
+	"testing"
+	"time"
+
+	"simplebill/internal/config"
+	"simplebill/internal/money"
+)
+
+func TestLateFeesOnVoidFeeInvoice(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	rounding := money.Rounding{Mode: money.HalfUp}
+	rule := config.LateFees{Flat: 4000, Interest: config.InterestMonthly, Rate: money.NewDecimal(1)}
+
+	original := Invoice{
+		InvoiceNumber: "INV-2025-0001",
+		DueDate:       "2025-01-31",
+		Status:        StatusSent,
+		Items: []Item{
+			{Product: "widget", Quantity: money.NewDecimal(1), UnitPrice: 100000},
+		},
+	}
+	if err := original.Recalculate(rounding); err != nil {
+		t.Fatal(err)
+	}
+
+	day := time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC)
+	first, err := original.AccrueLateFees(rule, rule.Flat, nil, day, rounding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Flat != 4000 || first.Interest == 0 {
+		t.Fatalf("first charge = %s flat, %s interest, want 40.00 and some interest", first.Flat, first.Interest)
+	}
+	fee, err := NewLateFeeInvoice(&original, "INV-2025-0002", first, 14, rounding, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original.RecordLateFee(first, fee.InvoiceNumber, 0)
+
+	invoices := []Invoice{original, *fee}
+	invoices[0].voidLateFees(invoices)
+	again, err := invoices[0].AccrueLateFees(rule, rule.Flat, nil, day, rounding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Total() != 0 {
+		t.Fatalf("charge after billing = %s, want nothing", again.Total())
+	}
+
+	// Voiding the fee invoice makes the fees owed again
+	if err := invoices[1].SetStatus(StatusVoid, now); err != nil {
+		t.Fatal(err)
+	}
+	invoices[0].voidLateFees(invoices)
+	again, err = invoices[0].AccrueLateFees(rule, rule.Flat, nil, day, rounding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Total() != first.Total() {
+		t.Fatalf("charge after void = %s, want %s", again.Total(), first.Total())
+	}
+
+	// So does deleting it
+	invoices[0].voidLateFees(invoices[:1])
+	again, err = invoices[0].AccrueLateFees(rule, rule.Flat, nil, day, rounding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Total() != first.Total() {
+		t.Fatalf("charge after delete = %s, want %s", again.Total(), first.Total())
+	}
+}
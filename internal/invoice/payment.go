@@ -0,0 +1,75 @@
+package invoice
+
+import (
+	"fmt"
+	"time"
+
+	"simplebill/internal/money"
+)
+
+// Payment is money received against an invoice
+type Payment struct {
+	Date      string       `yaml:"date"`
+	Amount    money.Amount `yaml:"amount"`
+	Method    string       `yaml:"method,omitempty"`
+	Reference string       `yaml:"reference,omitempty"`
+}
+
+// AmountPaid returns the sum of recorded payments. Invoices marked paid
+// before payments were recorded count as paid in full.
+func (inv *Invoice) AmountPaid() money.Amount {
+	if len(inv.Payments) == 0 && inv.CurrentStatus() == StatusPaid {
+		return inv.Total - inv.Credited
+	}
+	var paid money.Amount
+	for _, p := range inv.Payments {
+		paid += p.Amount
+	}
+	return paid
+}
+
+// BalanceDue returns what is still owed after payments and credit notes.
+// Void invoices owe nothing.
+func (inv *Invoice) BalanceDue() money.Amount {
+	if inv.CurrentStatus() == StatusVoid {
+		return 0
+	}
+	return inv.Total - inv.Credited - inv.AmountPaid()
+}
+
+// AddPayment records a payment, marking the invoice paid once nothing is
+// left to pay
+func (inv *Invoice) AddPayment(p Payment, at time.Time) error {
+	if inv.IsCreditNote() {
+		return fmt.Errorf("%s is a credit note and cannot take payments", inv.InvoiceNumber)
+	}
+	switch inv.CurrentStatus() {
+	case StatusVoid:
+		return fmt.Errorf("invoice %s is void and cannot take payments", inv.InvoiceNumber)
+	case StatusPaid:
+		return fmt.Errorf("invoice %s is already paid", inv.InvoiceNumber)
+	}
+	if p.Amount <= 0 {
+		return fmt.Errorf("payment amount must be positive")
+	}
+
+	inv.Payments = append(inv.Payments, p)
+	if inv.BalanceDue() <= 0 {
+		return inv.SetStatus(StatusPaid, at)
+	}
+	return nil
+}
+
+// MarkPaid records a final payment and marks the invoice paid even if the
+// amount doesn't cover the balance. A zero amount records no payment.
+func (inv *Invoice) MarkPaid(p Payment, at time.Time) error {
+	if p.Amount > 0 {
+		if err := inv.AddPayment(p, at); err != nil {
+			return err
+		}
+		if inv.CurrentStatus() == StatusPaid {
+			return nil
+		}
+	}
+	return inv.SetStatus(StatusPaid, at)
+}
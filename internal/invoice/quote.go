@@ -0,0 +1,113 @@
+package invoice
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"simplebill/internal/config"
+	"simplebill/internal/money"
+)
+
+const TypeQuote = "quote"
+
+// Quote statuses beyond draft and sent, derived like overdue
+const (
+	QuoteAccepted = "accepted"
+	QuoteExpired  = "expired"
+)
+
+// IsQuote reports whether the document is a quote. Quotes are kept in
+// quotes/ so they never show up among invoices.
+func (inv *Invoice) IsQuote() bool {
+	return inv.Type == TypeQuote
+}
+
+// QuoteStatus returns accepted once the quote became an invoice, expired
+// after its validity date, and otherwise its stored status
+func (inv *Invoice) QuoteStatus(day time.Time) string {
+	if inv.AcceptedAs != "" {
+		return QuoteAccepted
+	}
+	if inv.ValidUntil != "" && day.Format("2006-01-02") > inv.ValidUntil {
+		return QuoteExpired
+	}
+	return string(inv.CurrentStatus())
+}
+
+// NextQuoteNumber determines the next quote number. Quotes have their own
+// sequence, e.g. Q-2026-0001.
+func NextQuoteNumber(cfg *config.Config) (string, error) {
+	prefix := cfg.Invoice.QuotePrefix
+	if prefix == "" {
+		prefix = "Q"
+	}
+	return nextNumber("quotes", prefix, 0, time.Now().Year())
+}
+
+// LoadQuote reads a saved quote by number
+func LoadQuote(number string) (*Invoice, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, "quotes", number+".yml")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("quote %s not found", number)
+		}
+		return nil, fmt.Errorf("reading quote: %w", err)
+	}
+
+	var q Invoice
+	if err := yaml.Unmarshal(data, &q); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return &q, nil
+}
+
+// LoadQuotes reads every saved quote, skipping files that can't be parsed
+func LoadQuotes() ([]Invoice, error) {
+	return loadDir("quotes", false)
+}
+
+// NewInvoiceFromQuote turns an accepted quote into a draft invoice with the
+// same lines, prices and taxes, linked to the quote
+func NewInvoiceFromQuote(quote *Invoice, number string, dueDays int, rounding money.Rounding, now time.Time) (*Invoice, error) {
+	if !quote.IsQuote() {
+		return nil, fmt.Errorf("%s is not a quote", quote.InvoiceNumber)
+	}
+	if quote.AcceptedAs != "" {
+		return nil, fmt.Errorf("quote %s was already accepted as invoice %s", quote.InvoiceNumber, quote.AcceptedAs)
+	}
+	if quote.CurrentStatus() == StatusVoid {
+		return nil, fmt.Errorf("quote %s is void", quote.InvoiceNumber)
+	}
+
+	items := make([]Item, len(quote.Items))
+	copy(items, quote.Items)
+
+	inv := &Invoice{
+		InvoiceNumber: number,
+		Date:          now.Format("2006-01-02"),
+		DueDate:       now.AddDate(0, 0, dueDays).Format("2006-01-02"),
+		Customer:      quote.Customer,
+		CustomerInfo:  quote.CustomerInfo,
+		CompanyInfo:   quote.CompanyInfo,
+		Currency:      quote.Currency,
+		Items:         items,
+		TaxNote:       quote.TaxNote,
+		Quote:         quote.InvoiceNumber,
+		Status:        StatusDraft,
+		History:       []StatusChange{{Status: StatusDraft, At: now}},
+		CreatedAt:     now,
+	}
+	if err := inv.Recalculate(rounding.In(inv.CurrencyInfo())); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
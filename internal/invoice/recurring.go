@@ -0,0 +1,201 @@
+package invoice
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"simplebill/internal/config"
+)
+
+// intervals maps the schedule intervals to their length in months
+var intervals = map[string]int{
+	"monthly":     1,
+	"quarterly":   3,
+	"half-yearly": 6,
+	"yearly":      12,
+}
+
+// Schedule bills a customer the same items at a regular interval. Schedules
+// are kept in recurring/<name>.yml.
+type Schedule struct {
+	Name     string   `yaml:"-"` // file name without .yml
+	Customer string   `yaml:"customer"`
+	Items    []string `yaml:"items"` // product:qty[:discount[:@price]], as on the command line
+	Interval string   `yaml:"interval"`
+	Start    string   `yaml:"start"`
+	End      string   `yaml:"end,omitempty"`
+	Day      int      `yaml:"day,omitempty"` // day of the month invoices are issued, default the start day
+}
+
+// Recurring records the schedule and billing period an invoice was
+// generated for
+type Recurring struct {
+	Schedule string `yaml:"schedule"`
+	From     string `yaml:"from"`
+	Through  string `yaml:"through"`
+}
+
+// Period is one billing period of a schedule, invoiced on its first day
+type Period struct {
+	From, Through time.Time
+}
+
+// Recurring returns the record an invoice for the period carries
+func (p Period) Recurring(schedule string) *Recurring {
+	return &Recurring{
+		Schedule: schedule,
+		From:     p.From.Format("2006-01-02"),
+		Through:  p.Through.Format("2006-01-02"),
+	}
+}
+
+// SchedulesDir returns the directory recurring schedules are kept in
+func SchedulesDir() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "recurring"), nil
+}
+
+// LoadSchedules reads every schedule in the recurring directory, sorted by
+// name. Unlike invoices, a schedule that can't be parsed is an error, so a
+// typo doesn't silently stop billing.
+func LoadSchedules() ([]Schedule, error) {
+	dir, err := SchedulesDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var schedules []Schedule
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".yml") {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+
+		var s Schedule
+		if err := yaml.Unmarshal(data, &s); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+		s.Name = strings.TrimSuffix(entry.Name(), ".yml")
+		if err := s.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		schedules = append(schedules, s)
+	}
+
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].Name < schedules[j].Name })
+	return schedules, nil
+}
+
+// Validate checks a schedule for mistakes before any invoice is generated
+func (s Schedule) Validate() error {
+	if s.Customer == "" {
+		return fmt.Errorf("customer is required")
+	}
+	if len(s.Items) == 0 {
+		return fmt.Errorf("at least one item is required")
+	}
+	if _, ok := intervals[s.Interval]; !ok {
+		return fmt.Errorf("unknown interval '%s', expected monthly, quarterly, half-yearly or yearly", s.Interval)
+	}
+	start, err := time.Parse("2006-01-02", s.Start)
+	if err != nil {
+		return fmt.Errorf("invalid start '%s', expected YYYY-MM-DD", s.Start)
+	}
+	if s.End != "" {
+		end, err := time.Parse("2006-01-02", s.End)
+		if err != nil {
+			return fmt.Errorf("invalid end '%s', expected YYYY-MM-DD", s.End)
+		}
+		if end.Before(start) {
+			return fmt.Errorf("end %s is before start %s", s.End, s.Start)
+		}
+	}
+	if s.Day < 0 || s.Day > 31 {
+		return fmt.Errorf("day must be 1-31")
+	}
+	return nil
+}
+
+// Periods returns the billing periods of the schedule that start on or
+// before day. Periods start on the schedule's day of the month, or the last
+// day of shorter months, beginning with the first such day on or after the
+// start date. No period starts or runs past the end date.
+func (s Schedule) Periods(day time.Time) []Period {
+	start, _ := time.Parse("2006-01-02", s.Start)
+	months := intervals[s.Interval]
+	dayOfMonth := s.Day
+	if dayOfMonth == 0 {
+		dayOfMonth = start.Day()
+	}
+	last := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	var end time.Time
+	if s.End != "" {
+		end, _ = time.Parse("2006-01-02", s.End)
+		if end.Before(last) {
+			last = end
+		}
+	}
+
+	// First month whose issue day isn't before the start
+	year, month := start.Year(), start.Month()
+	if issueDay(year, month, dayOfMonth).Before(start) {
+		month++
+	}
+
+	var periods []Period
+	for k := 0; ; k++ {
+		from := issueDay(year, month+time.Month(k*months), dayOfMonth)
+		if from.After(last) {
+			return periods
+		}
+		through := issueDay(year, month+time.Month((k+1)*months), dayOfMonth).AddDate(0, 0, -1)
+		if !end.IsZero() && end.Before(through) {
+			through = end
+		}
+		periods = append(periods, Period{From: from, Through: through})
+	}
+}
+
+// issueDay returns the given day of a month, or the month's last day when
+// it is shorter. Months past December roll over into later years.
+func issueDay(year int, month time.Month, day int) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// Issued reports whether one of invoices was generated for the schedule's
+// period. Void invoices count, so voiding one doesn't bring it back.
+func (s Schedule) Issued(p Period, invoices []Invoice) bool {
+	from := p.From.Format("2006-01-02")
+	for _, inv := range invoices {
+		if inv.Recurring != nil && inv.Recurring.Schedule == s.Name && inv.Recurring.From == from {
+			return true
+		}
+	}
+	return false
+}
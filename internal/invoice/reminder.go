@@ -0,0 +1,80 @@
+package invoice
+
+import (
+	"time"
+
+	"simplebill/internal/config"
+	"simplebill/internal/money"
+)
+
+// Reminder records a payment reminder issued for an overdue invoice
+type Reminder struct {
+	Level     int          `yaml:"level"` // 1 for the first of reminders: in config.yml
+	Name      string       `yaml:"name"`
+	Date      string       `yaml:"date"`
+	Fee       money.Amount `yaml:"fee,omitempty"`
+	MessageID string       `yaml:"message_id,omitempty"` // empty when it wasn't emailed
+}
+
+// DunningLevel returns the highest reminder level reached, 0 before the first
+func (inv *Invoice) DunningLevel() int {
+	level := 0
+	for _, r := range inv.Reminders {
+		if r.Level > level {
+			level = r.Level
+		}
+	}
+	return level
+}
+
+// ReminderFees returns the fees charged by reminders so far
+func (inv *Invoice) ReminderFees() money.Amount {
+	var fees money.Amount
+	for _, r := range inv.Reminders {
+		fees += r.Fee
+	}
+	return fees
+}
+
+// DaysOverdue returns how many days past its due date the invoice is on day,
+// 0 when it isn't due yet
+func (inv *Invoice) DaysOverdue(day time.Time) int {
+	due, err := time.Parse("2006-01-02", inv.DueDate)
+	if err != nil {
+		return 0
+	}
+	days := daysBetween(due, day)
+	if days < 0 {
+		return 0
+	}
+	return days
+}
+
+// NextReminder returns the index in levels of the reminder due on day, or
+// -1 when none is. Only sent invoices with a balance get reminders, and
+// each level is reached once, in order: a level is due once the invoice is
+// its days overdue and, after the first, as many days have passed since
+// the previous reminder as lie between the two levels.
+func (inv *Invoice) NextReminder(levels []config.ReminderLevel, day time.Time) int {
+	if inv.IsCreditNote() || inv.StatusOn(day) != StatusOverdue || inv.BalanceDue() <= 0 {
+		return -1
+	}
+	next := inv.DunningLevel()
+	if next >= len(levels) || inv.DaysOverdue(day) < levels[next].Days {
+		return -1
+	}
+	if next > 0 {
+		last, err := time.Parse("2006-01-02", inv.Reminders[len(inv.Reminders)-1].Date)
+		if err == nil && daysBetween(last, day) < levels[next].Days-levels[next-1].Days {
+			return -1
+		}
+	}
+	return next
+}
+
+// daysBetween counts calendar days from one date to another
+func daysBetween(from, to time.Time) int {
+	a := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	b := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(b.Sub(a).Hours() / 24)
+}
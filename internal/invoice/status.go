@@ -0,0 +1,96 @@
+package invoice
+
+import (
+	"fmt"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusDraft   Status = "draft"
+	StatusSent    Status = "sent"
+	StatusPaid    Status = "paid"
+	StatusOverdue Status = "overdue"
+	StatusVoid    Status = "void"
+)
+
+// transitions lists the statuses each status may move to. Overdue is never
+// stored; it is derived from a sent invoice's due date.
+var transitions = map[Status][]Status{
+	StatusDraft: {StatusSent, StatusPaid, StatusVoid},
+	StatusSent:  {StatusPaid, StatusVoid},
+	StatusPaid:  {},
+	StatusVoid:  {},
+}
+
+// StatusChange records when an invoice moved to a status
+type StatusChange struct {
+	Status Status    `yaml:"status"`
+	At     time.Time `yaml:"at"`
+}
+
+// Email records a message that sent the invoice to the customer
+type Email struct {
+	At        time.Time `yaml:"at"`
+	To        []string  `yaml:"to"`
+	MessageID string    `yaml:"message_id"`
+}
+
+// CurrentStatus returns the stored status. Invoices saved before statuses
+// existed are drafts.
+func (inv *Invoice) CurrentStatus() Status {
+	if inv.Status == "" {
+		return StatusDraft
+	}
+	return inv.Status
+}
+
+// StatusOn returns the status as of the given day, reporting sent invoices
+// past their due date as overdue
+func (inv *Invoice) StatusOn(day time.Time) Status {
+	status := inv.CurrentStatus()
+	if status != StatusSent {
+		return status
+	}
+	due, err := time.Parse("2006-01-02", inv.DueDate)
+	if err != nil {
+		return status
+	}
+	if day.Format("2006-01-02") > due.Format("2006-01-02") {
+		return StatusOverdue
+	}
+	return status
+}
+
+// SetStatus moves the invoice to a new status, recording when it happened
+func (inv *Invoice) SetStatus(status Status, at time.Time) error {
+	current := inv.CurrentStatus()
+	allowed, ok := transitions[current]
+	if !ok {
+		return fmt.Errorf("invoice %s has unknown status '%s'", inv.InvoiceNumber, current)
+	}
+
+	for _, s := range allowed {
+		if s == status {
+			inv.Status = status
+			inv.History = append(inv.History, StatusChange{Status: status, At: at})
+			return nil
+		}
+	}
+
+	if current == status {
+		return fmt.Errorf("invoice %s is already %s", inv.InvoiceNumber, status)
+	}
+	return fmt.Errorf("invoice %s is %s and cannot be marked %s", inv.InvoiceNumber, current, status)
+}
+
+// StatusAt returns when the invoice last moved to status, if it did
+func (inv *Invoice) StatusAt(status Status) (time.Time, bool) {
+	for i := len(inv.History) - 1; i >= 0; i-- {
+		if inv.History[i].Status == status {
+			return inv.History[i].At, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,16 @@
+package invoice
+
+// BilledEntries maps the keys of timesheet entries already billed to the
+// invoice billing them. Entries on void invoices can be billed again.
+func BilledEntries(invoices []Invoice) map[string]string {
+	billed := map[string]string{}
+	for _, inv := range invoices {
+		if inv.CurrentStatus() == StatusVoid {
+			continue
+		}
+		for _, key := range inv.Timesheet {
+			billed[key] = inv.InvoiceNumber
+		}
+	}
+	return billed
+}
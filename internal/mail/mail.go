@@ -0,0 +1,224 @@
+// Package mail builds MIME messages with attachments and sends them over
+// SMTP, for emailing invoices.
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Attachment is a file attached to a message
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Message is a plain text email with attachments
+type Message struct {
+	From        *mail.Address
+	To, Cc, Bcc []*mail.Address
+	Subject     string
+	Body        string
+	Attachments []Attachment
+	Date        time.Time
+	MessageID   string // with angle brackets, e.g. <123.abc@example.com>
+}
+
+// ParseList reads addresses separated by commas, e.g.
+// "Jane Doe <jane@example.com>, billing@example.com"
+func ParseList(s string) ([]*mail.Address, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	list, err := mail.ParseAddressList(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid email address '%s': %w", s, err)
+	}
+	return list, nil
+}
+
+// NewMessageID returns a unique Message-ID in the domain of from
+func NewMessageID(from *mail.Address, at time.Time) string {
+	domain := "localhost"
+	if i := strings.LastIndex(from.Address, "@"); i >= 0 {
+		domain = from.Address[i+1:]
+	}
+	random := make([]byte, 8)
+	rand.Read(random)
+	return fmt.Sprintf("<%d.%s@%s>", at.UnixNano(), hex.EncodeToString(random), domain)
+}
+
+// Recipients lists the addresses the message is delivered to, Bcc included
+func (m *Message) Recipients() []string {
+	var out []string
+	for _, list := range [][]*mail.Address{m.To, m.Cc, m.Bcc} {
+		for _, a := range list {
+			out = append(out, a.Address)
+		}
+	}
+	return out
+}
+
+// Bytes writes the message in RFC 5322 format with CRLF line endings, as
+// sent over SMTP and saved in .eml files. Bcc recipients are left out.
+func (m *Message) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+
+	header := func(name, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+	}
+	header("From", m.From.String())
+	header("To", addressList(m.To))
+	if len(m.Cc) > 0 {
+		header("Cc", addressList(m.Cc))
+	}
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", m.Date.Format(time.RFC1123Z))
+	header("Message-ID", m.MessageID)
+	header("MIME-Version", "1.0")
+	header("Content-Type", "multipart/mixed; boundary="+w.Boundary())
+	buf.WriteString("\r\n")
+
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	qp := quotedprintable.NewWriter(part)
+	body := strings.ReplaceAll(strings.ReplaceAll(m.Body, "\r\n", "\n"), "\n", "\r\n")
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+
+	for _, a := range m.Attachments {
+		name := mime.QEncoding.Encode("utf-8", a.Name)
+		part, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {fmt.Sprintf("%s; name=\"%s\"", a.ContentType, name)},
+			"Content-Disposition":       {fmt.Sprintf("attachment; filename=\"%s\"", name)},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		// Base64 in lines of 76 characters
+		encoded := base64.StdEncoding.EncodeToString(a.Data)
+		for len(encoded) > 76 {
+			part.Write([]byte(encoded[:76] + "\r\n"))
+			encoded = encoded[76:]
+		}
+		part.Write([]byte(encoded + "\r\n"))
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func addressList(list []*mail.Address) string {
+	var parts []string
+	for _, a := range list {
+		parts = append(parts, a.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Connection security
+const (
+	StartTLS = "starttls" // upgrade a plain connection, usually on port 587
+	TLS      = "tls"      // TLS from the start, usually on port 465
+	None     = "none"     // no encryption, for local test servers only
+)
+
+// Server is an SMTP server to send through
+type Server struct {
+	Host     string
+	Port     int
+	Username string // empty sends without logging in
+	Password string
+	Security string
+}
+
+// Send delivers m through s
+func Send(s Server, m *Message) error {
+	data, err := m.Bytes()
+	if err != nil {
+		return err
+	}
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	tlsConfig := &tls.Config{ServerName: s.Host}
+
+	var c *smtp.Client
+	if s.Security == TLS {
+		conn, err := tls.Dial("tcp", addr, tlsConfig)
+		if err != nil {
+			return fmt.Errorf("connecting to %s: %w", addr, err)
+		}
+		c, err = smtp.NewClient(conn, s.Host)
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("connecting to %s: %w", addr, err)
+		}
+	} else {
+		c, err = smtp.Dial(addr)
+		if err != nil {
+			return fmt.Errorf("connecting to %s: %w", addr, err)
+		}
+	}
+	defer c.Close()
+
+	if s.Security == StartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s does not support STARTTLS", addr)
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("starting TLS with %s: %w", addr, err)
+		}
+	}
+	if s.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return fmt.Errorf("logging in to %s: %w", addr, err)
+		}
+	}
+
+	if err := c.Mail(m.From.Address); err != nil {
+		return fmt.Errorf("sender %s rejected: %w", m.From.Address, err)
+	}
+	for _, rcpt := range m.Recipients() {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("recipient %s rejected: %w", rcpt, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("sending message: %w", err)
+	}
+	return c.Quit()
+}
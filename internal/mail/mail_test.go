@@ -0,0 +1,148 @@
+package mail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpStub accepts one SMTP session and records its envelope and message
+type smtpStub struct {
+	from string
+	rcpt []string
+	data []byte
+	err  error
+}
+
+func (s *smtpStub) serve(l net.Listener, done chan<- struct{}) {
+	defer close(done)
+	conn, err := l.Accept()
+	if err != nil {
+		s.err = err
+		return
+	}
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 stub ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			s.err = err
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250 stub")
+		case "MAIL":
+			s.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			s.rcpt = append(s.rcpt, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			if s.data, err = tp.ReadDotBytes(); err != nil {
+				s.err = err
+				return
+			}
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	stub := &smtpStub{}
+	done := make(chan struct{})
+	go stub.serve(l, done)
+
+	from := &mail.Address{Name: "Example Co", Address: "billing@example.com"}
+	to, _ := ParseList("Jane Doe <jane@client.com>")
+	cc, _ := ParseList("accounts@client.com")
+	bcc, _ := ParseList("archive@example.com")
+	pdf := bytes.Repeat([]byte("%PDF-1.7 binary \x00\xff"), 20)
+	m := &Message{
+		From: from, To: to, Cc: cc, Bcc: bcc,
+		Subject:     "Invoice INV-2025-0001",
+		Body:        "Hello,\nplease find the invoice attached.\n",
+		Attachments: []Attachment{{Name: "INV-2025-0001.pdf", ContentType: "application/pdf", Data: pdf}},
+		Date:        time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC),
+		MessageID:   NewMessageID(from, time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)),
+	}
+
+	port := l.Addr().(*net.TCPAddr).Port
+	if err := Send(Server{Host: "127.0.0.1", Port: port, Security: None}, m); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if stub.err != nil {
+		t.Fatal(stub.err)
+	}
+
+	if stub.from != "billing@example.com" {
+		t.Errorf("MAIL FROM = %s", stub.from)
+	}
+	want := []string{"jane@client.com", "accounts@client.com", "archive@example.com"}
+	if strings.Join(stub.rcpt, ",") != strings.Join(want, ",") {
+		t.Errorf("RCPT TO = %v, want %v", stub.rcpt, want)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(stub.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := msg.Header.Get("Message-ID"); got != m.MessageID || !strings.HasSuffix(got, "@example.com>") {
+		t.Errorf("Message-ID = %s, want %s", got, m.MessageID)
+	}
+	if msg.Header.Get("Bcc") != "" || bytes.Contains(stub.data, []byte("archive@example.com")) {
+		t.Error("Bcc recipient appears in the message")
+	}
+
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	var attachments []string
+	for {
+		part, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if part.FileName() == "" {
+			continue
+		}
+		attachments = append(attachments, part.FileName())
+		data, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, part))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, pdf) {
+			t.Errorf("attachment %s doesn't match what was attached", part.FileName())
+		}
+	}
+	if strings.Join(attachments, ",") != "INV-2025-0001.pdf" {
+		t.Errorf("attachments = %v", attachments)
+	}
+}
@@ -0,0 +1,101 @@
+package money
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Currency describes how amounts in one ISO 4217 currency are written
+type Currency struct {
+	Code        string
+	Symbol      string
+	Decimals    int // 0 or 2, e.g. JPY has no minor units
+	DecimalSep  string
+	GroupSep    string
+	SymbolAfter bool
+}
+
+// DefaultCurrency is used when neither the company nor the customer sets one
+const DefaultCurrency = "USD"
+
+var currencies = map[string]Currency{
+	"USD": {Code: "USD", Symbol: "$", Decimals: 2, DecimalSep: ".", GroupSep: ","},
+	"CAD": {Code: "CAD", Symbol: "CA$", Decimals: 2, DecimalSep: ".", GroupSep: ","},
+	"AUD": {Code: "AUD", Symbol: "A$", Decimals: 2, DecimalSep: ".", GroupSep: ","},
+	"NZD": {Code: "NZD", Symbol: "NZ$", Decimals: 2, DecimalSep: ".", GroupSep: ","},
+	"GBP": {Code: "GBP", Symbol: "£", Decimals: 2, DecimalSep: ".", GroupSep: ","},
+	"EUR": {Code: "EUR", Symbol: "€", Decimals: 2, DecimalSep: ",", GroupSep: ".", SymbolAfter: true},
+	"CHF": {Code: "CHF", Symbol: "CHF", Decimals: 2, DecimalSep: ".", GroupSep: "'"},
+	"SEK": {Code: "SEK", Symbol: "kr", Decimals: 2, DecimalSep: ",", GroupSep: " ", SymbolAfter: true},
+	"NOK": {Code: "NOK", Symbol: "kr", Decimals: 2, DecimalSep: ",", GroupSep: " ", SymbolAfter: true},
+	"DKK": {Code: "DKK", Symbol: "kr.", Decimals: 2, DecimalSep: ",", GroupSep: ".", SymbolAfter: true},
+	"PLN": {Code: "PLN", Symbol: "zł", Decimals: 2, DecimalSep: ",", GroupSep: " ", SymbolAfter: true},
+	"CZK": {Code: "CZK", Symbol: "Kč", Decimals: 2, DecimalSep: ",", GroupSep: " ", SymbolAfter: true},
+	"JPY": {Code: "JPY", Symbol: "¥", Decimals: 0, DecimalSep: ".", GroupSep: ","},
+	"KRW": {Code: "KRW", Symbol: "₩", Decimals: 0, DecimalSep: ".", GroupSep: ","},
+	"INR": {Code: "INR", Symbol: "₹", Decimals: 2, DecimalSep: ".", GroupSep: ","},
+}
+
+// LookupCurrency returns the formatting rules for an ISO 4217 code. Unknown
+// codes are written as "1,234.56 XYZ" with two decimals.
+func LookupCurrency(code string) (Currency, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		code = DefaultCurrency
+	}
+	if c, ok := currencies[code]; ok {
+		return c, nil
+	}
+	if len(code) != 3 || strings.Trim(code, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return Currency{}, fmt.Errorf("invalid currency code '%s', expected an ISO 4217 code like EUR", code)
+	}
+	return Currency{Code: code, Symbol: code, Decimals: 2, DecimalSep: ".", GroupSep: ",", SymbolAfter: true}, nil
+}
+
+// Number formats the amount with the currency's separators and minor units,
+// without a symbol, e.g. "1.234,50" for EUR
+func (c Currency) Number(a Amount) string {
+	sign := ""
+	v := int64(a)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+
+	whole := v / 100
+	cents := v % 100
+	if c.Decimals == 0 && cents >= 50 {
+		whole++
+	}
+
+	digits := fmt.Sprintf("%d", whole)
+	var grouped strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			grouped.WriteString(c.GroupSep)
+		}
+		grouped.WriteRune(d)
+	}
+
+	s := sign + grouped.String()
+	if c.Decimals > 0 {
+		s += fmt.Sprintf("%s%02d", c.DecimalSep, cents)
+	}
+	return s
+}
+
+// Format formats the amount with symbol, e.g. "$1,234.50", "-$5.00" or "1.234,50 €"
+func (c Currency) Format(a Amount) string {
+	if c.SymbolAfter {
+		return c.Number(a) + " " + c.Symbol
+	}
+	sign := ""
+	if a < 0 {
+		sign = "-"
+		a = -a
+	}
+	if len([]rune(c.Symbol)) > 1 && !strings.HasSuffix(c.Symbol, "$") {
+		return sign + c.Symbol + " " + c.Number(a)
+	}
+	return sign + c.Symbol + c.Number(a)
+}
@@ -0,0 +1,114 @@
+package money
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Decimal is an exact number with up to four decimal places, used for
+// percentages such as tax rates and for item quantities
+type Decimal int64
+
+const decimalScale = 10000
+
+// MaxPlaces is the number of decimal places a Decimal holds
+const MaxPlaces = 4
+
+// NewDecimal returns the whole number n
+func NewDecimal(n int) Decimal {
+	return Decimal(n) * decimalScale
+}
+
+// ParseDecimal reads a decimal string such as "19" or "7.7"
+func ParseDecimal(s string) (Decimal, error) {
+	r, ok := parseRat(s)
+	if !ok {
+		return 0, fmt.Errorf("invalid number '%s'", s)
+	}
+	scaled := new(big.Rat).Mul(r, big.NewRat(decimalScale, 1))
+	if !scaled.IsInt() {
+		return 0, fmt.Errorf("invalid number '%s', at most 4 decimal places allowed", s)
+	}
+	if !scaled.Num().IsInt64() {
+		return 0, fmt.Errorf("number '%s' out of range", s)
+	}
+	return Decimal(scaled.Num().Int64()), nil
+}
+
+// RoundDecimal rounds an exact value to places decimal places using mode.
+// Values too large for a Decimal are an error.
+func RoundDecimal(r *big.Rat, places int, mode Mode) (Decimal, error) {
+	unit := int64(1)
+	for i := places; i < MaxPlaces; i++ {
+		unit *= 10
+	}
+	scaled := new(big.Rat).Mul(r, big.NewRat(decimalScale/unit, 1))
+	n, err := roundRat(scaled, mode)
+	if err != nil || n > math.MaxInt64/unit || n < math.MinInt64/unit {
+		return 0, fmt.Errorf("number %s out of range", r.FloatString(places))
+	}
+	return Decimal(n * unit), nil
+}
+
+// Rat returns the exact value
+func (d Decimal) Rat() *big.Rat {
+	return big.NewRat(int64(d), decimalScale)
+}
+
+// Places returns the number of decimal places the value needs, e.g. 1 for 7.5
+func (d Decimal) Places() int {
+	places := MaxPlaces
+	for v := int64(d); places > 0 && v%10 == 0; v /= 10 {
+		places--
+	}
+	return places
+}
+
+// Percent returns amount * d / 100 as an exact value in currency units
+func (d Decimal) Percent(a *big.Rat) *big.Rat {
+	r := new(big.Rat).Mul(a, d.Rat())
+	return r.Quo(r, big.NewRat(100, 1))
+}
+
+// String formats the value without trailing zeros, e.g. "7.7"
+func (d Decimal) String() string {
+	sign := ""
+	v := int64(d)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	whole := v / decimalScale
+	frac := v % decimalScale
+	if frac == 0 {
+		return fmt.Sprintf("%s%d", sign, whole)
+	}
+	fracStr := strings.TrimRight(fmt.Sprintf("%04d", frac), "0")
+	return fmt.Sprintf("%s%d.%s", sign, whole, fracStr)
+}
+
+// MarshalYAML writes the value as a plain number
+func (d Decimal) MarshalYAML() (interface{}, error) {
+	tag := "!!int"
+	if d%decimalScale != 0 {
+		tag = "!!float"
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: d.String()}, nil
+}
+
+// UnmarshalYAML reads an integer or decimal number
+func (d *Decimal) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: expected a number", value.Line)
+	}
+	parsed, err := ParseDecimal(value.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", value.Line, err)
+	}
+	*d = parsed
+	return nil
+}
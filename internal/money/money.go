@@ -0,0 +1,207 @@
+package money
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Amount is an exact monetary value stored as an integer number of cents
+type Amount int64
+
+// Mode is the rule used to round a computed value to whole cents
+type Mode string
+
+const (
+	HalfUp   Mode = "half-up"
+	HalfEven Mode = "half-even"
+)
+
+// Rounding describes how computed amounts are rounded. With PerInvoice unset,
+// every line is rounded and the invoice total is the sum of the rounded lines.
+// With PerInvoice set, lines are kept exact and only the total is rounded.
+// WholeUnits rounds to whole currency units for currencies without cents.
+type Rounding struct {
+	Mode       Mode
+	PerInvoice bool
+	WholeUnits bool
+}
+
+// In returns the rounding rule adjusted to the currency's minor units
+func (r Rounding) In(c Currency) Rounding {
+	r.WholeUnits = c.Decimals == 0
+	return r
+}
+
+// Round rounds an exact value in currency units. Values too large for an
+// Amount are an error.
+func (r Rounding) Round(x *big.Rat) (Amount, error) {
+	if r.WholeUnits {
+		units, err := roundRat(x, r.Mode)
+		if err != nil || units > math.MaxInt64/100 || units < math.MinInt64/100 {
+			return 0, fmt.Errorf("amount %s out of range", x.FloatString(0))
+		}
+		return Amount(units * 100), nil
+	}
+	return FromRat(x, r.Mode)
+}
+
+// ParseMode validates a rounding mode name. An empty name means half-up.
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case "", HalfUp:
+		return HalfUp, nil
+	case HalfEven:
+		return HalfEven, nil
+	default:
+		return "", fmt.Errorf("unknown rounding mode '%s', expected half-up or half-even", s)
+	}
+}
+
+// Parse reads a decimal string such as "19.99" or "-5". More than two
+// decimal places is an error, so user input is never silently rounded.
+func Parse(s string) (Amount, error) {
+	r, ok := parseRat(s)
+	if !ok {
+		return 0, fmt.Errorf("invalid amount '%s'", s)
+	}
+	cents := new(big.Rat).Mul(r, big.NewRat(100, 1))
+	if !cents.IsInt() {
+		return 0, fmt.Errorf("invalid amount '%s', at most 2 decimal places allowed", s)
+	}
+	if !cents.Num().IsInt64() {
+		return 0, fmt.Errorf("amount '%s' out of range", s)
+	}
+	return Amount(cents.Num().Int64()), nil
+}
+
+// FromRat rounds an exact value in currency units to cents using mode.
+// Values too large for an Amount are an error.
+func FromRat(r *big.Rat, mode Mode) (Amount, error) {
+	cents := new(big.Rat).Mul(r, big.NewRat(100, 1))
+	n, err := roundRat(cents, mode)
+	if err != nil {
+		return 0, fmt.Errorf("amount %s out of range", r.FloatString(2))
+	}
+	return Amount(n), nil
+}
+
+// Rat returns the amount as an exact value in currency units
+func (a Amount) Rat() *big.Rat {
+	return big.NewRat(int64(a), 100)
+}
+
+// Mul returns the amount multiplied by a whole number
+func (a Amount) Mul(n int) Amount {
+	return a * Amount(n)
+}
+
+// String formats the amount with exactly two decimal places, e.g. "1234.50"
+func (a Amount) String() string {
+	sign := ""
+	v := int64(a)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/100, v%100)
+}
+
+// Format lets templates and format strings written for float64 values
+// (e.g. printf "%.2f") keep working with Amount.
+func (a Amount) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 'd':
+		fmt.Fprint(f, int64(a))
+		return
+	case 'f', 'F', 'g', 'v', 's':
+	default:
+		fmt.Fprintf(f, "%%!%c(money.Amount=%s)", verb, a.String())
+		return
+	}
+
+	s := a.String()
+	if w, ok := f.Width(); ok && len(s) < w {
+		pad := strings.Repeat(" ", w-len(s))
+		if f.Flag('-') {
+			s += pad
+		} else {
+			s = pad + s
+		}
+	}
+	fmt.Fprint(f, s)
+}
+
+// MarshalYAML writes the amount as a plain decimal number
+func (a Amount) MarshalYAML() (interface{}, error) {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: a.String()}, nil
+}
+
+// UnmarshalYAML accepts integers, decimals and float values written by older
+// versions. Values with more than two decimal places are rounded half-up.
+func (a *Amount) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: expected an amount", value.Line)
+	}
+	r, ok := parseRat(value.Value)
+	if !ok {
+		return fmt.Errorf("line %d: invalid amount '%s'", value.Line, value.Value)
+	}
+	amount, err := FromRat(r, HalfUp)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", value.Line, err)
+	}
+	*a = amount
+	return nil
+}
+
+func parseRat(s string) (*big.Rat, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, false
+	}
+	// Reject things big.Rat accepts but are not decimal amounts, like "1/3"
+	if strings.ContainsAny(s, "/") {
+		return nil, false
+	}
+	if _, err := strconv.ParseFloat(s, 64); err != nil {
+		return nil, false
+	}
+	return new(big.Rat).SetString(s)
+}
+
+// roundRat rounds r to the nearest integer using mode. It fails when the
+// result doesn't fit in an int64.
+func roundRat(r *big.Rat, mode Mode) (int64, error) {
+	num := new(big.Int).Set(r.Num())
+	den := r.Denom()
+
+	neg := num.Sign() < 0
+	if neg {
+		num.Neg(num)
+	}
+
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	twice := new(big.Int).Mul(rem, big.NewInt(2))
+
+	switch twice.Cmp(den) {
+	case 1:
+		q.Add(q, big.NewInt(1))
+	case 0:
+		if mode != HalfEven || q.Bit(0) == 1 {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+
+	if neg {
+		q.Neg(q)
+	}
+	if !q.IsInt64() {
+		return 0, fmt.Errorf("%s out of range", q)
+	}
+	return q.Int64(), nil
+}
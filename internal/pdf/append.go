@@ -0,0 +1,400 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg" // decoders for image receipts
+	_ "image/png"
+	"strconv"
+	"strings"
+)
+
+// AppendPages adds the pages of other documents to the end of a PDF as an
+// incremental update. Each file is a PDF, whose pages are copied, or a JPEG
+// or PNG image, which gets a page of its own, scaled to fit.
+func AppendPages(data []byte, files [][]byte) ([]byte, error) {
+	f, err := openPDF(data)
+	if err != nil {
+		return nil, err
+	}
+	rootRef, _ := f.trailer.get("Root")
+	rootNum, _, ok := ref(rootRef)
+	if !ok {
+		return nil, fmt.Errorf("invalid /Root in PDF trailer")
+	}
+	rootValue, err := f.object(rootNum)
+	if err != nil {
+		return nil, fmt.Errorf("reading catalog: %w", err)
+	}
+	catalog, _, err := parseDict([]byte(rootValue), 0)
+	if err != nil {
+		return nil, fmt.Errorf("reading catalog: %w", err)
+	}
+	pagesRef, _ := catalog.get("Pages")
+	pagesNum, pagesGen, ok := ref(pagesRef)
+	if !ok {
+		return nil, fmt.Errorf("invalid /Pages in PDF catalog")
+	}
+	pagesValue, err := f.object(pagesNum)
+	if err != nil {
+		return nil, fmt.Errorf("reading page tree: %w", err)
+	}
+	pages, _, err := parseDict([]byte(pagesValue), 0)
+	if err != nil {
+		return nil, fmt.Errorf("reading page tree: %w", err)
+	}
+
+	kidsValue, _ := pages.get("Kids")
+	if kidsValue, err = f.resolve(kidsValue); err != nil {
+		return nil, fmt.Errorf("reading page tree: %w", err)
+	}
+	kids := strings.TrimSpace(strings.Trim(strings.TrimSpace(kidsValue), "[]"))
+	countValue, _ := pages.get("Count")
+	if countValue, err = f.resolve(countValue); err != nil {
+		return nil, fmt.Errorf("reading page tree: %w", err)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(countValue))
+	if err != nil {
+		return nil, fmt.Errorf("invalid page count '%s'", countValue)
+	}
+
+	// Images get pages the size of the document's
+	width, height := pageSize(f, pages, kids)
+
+	u := newUpdate(f)
+	var added []string
+	for i, file := range files {
+		var refs []string
+		if bytes.HasPrefix(file, []byte("%PDF")) {
+			refs, err = copyPages(u, file, pagesRef)
+		} else {
+			var page string
+			page, err = imagePage(u, file, pagesRef, width, height)
+			refs = []string{page}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("file %d of %d: %w", i+1, len(files), err)
+		}
+		added = append(added, refs...)
+	}
+
+	pages = pages.without("Kids", "Count")
+	pages = append(pages,
+		dictEntry{"Kids", "[" + strings.TrimSpace(kids+" "+strings.Join(added, " ")) + "]"},
+		dictEntry{"Count", strconv.Itoa(count + len(added))},
+	)
+	u.replace(pagesNum, pagesGen, pages.String())
+
+	info := 0
+	if v, ok := f.trailer.get("Info"); ok {
+		info, _, _ = ref(v)
+	}
+	if info == 0 {
+		info = u.add("<< /Producer (simplebill) >>", nil)
+	}
+	return u.finish(rootRef, info), nil
+}
+
+// pageSize returns the size of the document's first page, or A4
+func pageSize(f *pdfFile, pages dict, kids string) (float64, float64) {
+	box, ok := pages.get("MediaBox")
+	if !ok {
+		if first := strings.Fields(kids); len(first) >= 3 {
+			if value, err := f.resolve(strings.Join(first[:3], " ")); err == nil {
+				if page, _, err := parseDict([]byte(value), 0); err == nil {
+					box, ok = page.get("MediaBox")
+				}
+			}
+		}
+	}
+	if ok {
+		if value, err := f.resolve(box); err == nil {
+			fields := strings.Fields(strings.Trim(strings.TrimSpace(value), "[]"))
+			if len(fields) == 4 {
+				var n [4]float64
+				valid := true
+				for i, s := range fields {
+					var err error
+					if n[i], err = strconv.ParseFloat(s, 64); err != nil {
+						valid = false
+					}
+				}
+				if valid && n[2] > n[0] && n[3] > n[1] {
+					return n[2] - n[0], n[3] - n[1]
+				}
+			}
+		}
+	}
+	return A4Width, A4Height
+}
+
+// copyPages copies every page of a PDF, with everything the pages use, into
+// u and returns references to the copies, which belong to parent
+func copyPages(u *update, data []byte, parent string) ([]string, error) {
+	src, err := openPDF(data)
+	if err != nil {
+		return nil, err
+	}
+	rootRef, _ := src.trailer.get("Root")
+	root, err := src.resolve(rootRef)
+	if err != nil {
+		return nil, fmt.Errorf("reading catalog: %w", err)
+	}
+	catalog, _, err := parseDict([]byte(root), 0)
+	if err != nil {
+		return nil, fmt.Errorf("reading catalog: %w", err)
+	}
+	pagesRef, _ := catalog.get("Pages")
+
+	c := &copier{src: src, u: u, numbers: map[int]int{}}
+	var refs []string
+	// Pages inherit these from their parents in the tree
+	inheritable := []string{"Resources", "MediaBox", "CropBox", "Rotate"}
+	var walk func(nodeRef string, inherited dict, depth int) error
+	walk = func(nodeRef string, inherited dict, depth int) error {
+		if depth > 32 {
+			return fmt.Errorf("page tree too deep")
+		}
+		value, err := src.resolve(nodeRef)
+		if err != nil {
+			return err
+		}
+		node, _, err := parseDict([]byte(value), 0)
+		if err != nil {
+			return fmt.Errorf("reading page tree: %w", err)
+		}
+
+		if t, _ := node.get("Type"); t == "/Pages" {
+			for _, key := range inheritable {
+				if v, ok := node.get(key); ok {
+					inherited = append(inherited.without(key), dictEntry{key, v})
+				}
+			}
+			kids, _ := node.get("Kids")
+			if kids, err = src.resolve(kids); err != nil {
+				return err
+			}
+			fields := strings.Fields(strings.Trim(strings.TrimSpace(kids), "[]"))
+			for i := 0; i+2 < len(fields); i += 3 {
+				if err := walk(strings.Join(fields[i:i+3], " "), inherited, depth+1); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		for _, e := range inherited {
+			if _, ok := node.get(e.key); !ok {
+				node = append(node, e)
+			}
+		}
+		// Annotations and structure point back into the source document
+		node = node.without("Parent", "Annots", "B", "StructParents", "Thumb")
+		copied, err := c.value(node.String())
+		if err != nil {
+			return err
+		}
+		num := u.add(strings.TrimSuffix(copied, " >>")+" /Parent "+parent+" >>", nil)
+		refs = append(refs, fmt.Sprintf("%d 0 R", num))
+		return nil
+	}
+	if err := walk(pagesRef, nil, 0); err != nil {
+		return nil, err
+	}
+	if len(refs) == 0 {
+		return nil, fmt.Errorf("PDF has no pages")
+	}
+	return refs, nil
+}
+
+// copier copies objects from src into u, numbering them anew
+type copier struct {
+	src     *pdfFile
+	u       *update
+	numbers map[int]int
+}
+
+// value rewrites the references in value to copies of the objects
+func (c *copier) value(value string) (string, error) {
+	data := []byte(value)
+	var out strings.Builder
+	last := 0
+	for pos := 0; pos < len(data); {
+		switch ch := data[pos]; {
+		case ch == '(':
+			end, err := skipValue(data, pos)
+			if err != nil {
+				return "", err
+			}
+			pos = end
+		case ch == '<':
+			if pos+1 < len(data) && data[pos+1] == '<' {
+				pos += 2
+				continue
+			}
+			end, err := skipValue(data, pos)
+			if err != nil {
+				return "", err
+			}
+			pos = end
+		case ch >= '0' && ch <= '9':
+			end := token(data, pos)
+			if num, gen, ok := refAt(data, pos); ok {
+				copied, err := c.object(num)
+				if err != nil {
+					return "", err
+				}
+				out.Write(data[last:pos])
+				fmt.Fprintf(&out, "%d 0 R", copied)
+				pos = gen
+				last = pos
+				continue
+			}
+			pos = end
+		case ch == '/':
+			pos = token(data, pos+1)
+		default:
+			pos++
+		}
+	}
+	out.Write(data[last:])
+	return out.String(), nil
+}
+
+// refAt parses "num gen R" at pos and returns the object number and where
+// the reference ends
+func refAt(data []byte, pos int) (int, int, bool) {
+	end := token(data, pos)
+	num, err := strconv.Atoi(string(data[pos:end]))
+	if err != nil {
+		return 0, 0, false
+	}
+	_, genEnd, err := readInt(data, end)
+	if err != nil {
+		return 0, 0, false
+	}
+	r := skipSpace(data, genEnd)
+	if r < len(data) && data[r] == 'R' && token(data, r) == r+1 {
+		return num, r + 1, true
+	}
+	return 0, 0, false
+}
+
+// object copies object num and returns the number of the copy
+func (c *copier) object(num int) (int, error) {
+	if copied, ok := c.numbers[num]; ok {
+		return copied, nil
+	}
+	copied := c.u.reserve()
+	c.numbers[num] = copied
+
+	e, ok := c.src.objects[num]
+	if !ok || e.offset < 0 {
+		// A missing object is null
+		c.u.set(copied, "null", nil)
+		return copied, nil
+	}
+	if e.stream != 0 {
+		value, err := c.src.object(num)
+		if err != nil {
+			return 0, err
+		}
+		if value, err = c.value(value); err != nil {
+			return 0, err
+		}
+		c.u.set(copied, value, nil)
+		return copied, nil
+	}
+
+	value, d, body, err := c.src.readObjectAt(e.offset)
+	if err != nil {
+		return 0, err
+	}
+	if d == nil {
+		if value, err = c.value(value); err != nil {
+			return 0, err
+		}
+		c.u.set(copied, value, nil)
+		return copied, nil
+	}
+
+	// Streams keep their encoding; only the length may have been indirect
+	raw, err := c.src.rawStream(d, body)
+	if err != nil {
+		return 0, err
+	}
+	d = append(d.without("Length"), dictEntry{"Length", strconv.Itoa(len(raw))})
+	dictValue, err := c.value(d.String())
+	if err != nil {
+		return 0, err
+	}
+	c.u.set(copied, dictValue, raw)
+	return copied, nil
+}
+
+// imagePage adds a page showing a JPEG or PNG image and returns its reference
+func imagePage(u *update, data []byte, parent string, width, height float64) (string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("not a PDF, JPEG or PNG file")
+	}
+	if cfg.Width == 0 || cfg.Height == 0 {
+		return "", fmt.Errorf("empty image")
+	}
+
+	var xobject string
+	switch {
+	case format == "jpeg" && cfg.ColorModel == color.GrayModel:
+		xobject = fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>",
+			cfg.Width, cfg.Height, len(data))
+	case format == "jpeg" && cfg.ColorModel == color.YCbCrModel:
+		xobject = fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>",
+			cfg.Width, cfg.Height, len(data))
+	default:
+		// Anything else, like PNG with transparency or CMYK JPEG, is
+		// flattened onto white and stored as RGB
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return "", err
+		}
+		rgb := rgbPixels(img)
+		if data, err = compress(rgb); err != nil {
+			return "", err
+		}
+		xobject = fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode /Length %d >>",
+			cfg.Width, cfg.Height, len(data))
+	}
+	imageRef := u.add(xobject, data)
+
+	// Fit the image inside a margin, keeping its proportions
+	const margin = 36
+	w, h := float64(cfg.Width), float64(cfg.Height)
+	scale := (width - 2*margin) / w
+	if s := (height - 2*margin) / h; s < scale {
+		scale = s
+	}
+	w, h = w*scale, h*scale
+	content := []byte(fmt.Sprintf("q %s 0 0 %s %s %s cm /Im0 Do Q", num(w), num(h), num((width-w)/2), num((height-h)/2)))
+	contentRef := u.add(fmt.Sprintf("<< /Length %d >>", len(content)), content)
+
+	page := u.add(fmt.Sprintf("<< /Type /Page /Parent %s /MediaBox [0 0 %s %s] /Resources << /XObject << /Im0 %d 0 R >> >> /Contents %d 0 R >>",
+		parent, num(width), num(height), imageRef, contentRef), nil)
+	return fmt.Sprintf("%d 0 R", page), nil
+}
+
+// rgbPixels returns the image as 8-bit RGB rows, flattened onto white
+func rgbPixels(img image.Image) []byte {
+	b := img.Bounds()
+	canvas := image.NewRGBA(b)
+	draw.Draw(canvas, b, image.White, image.Point{}, draw.Src)
+	draw.Draw(canvas, b, img, b.Min, draw.Over)
+
+	out := make([]byte, 0, b.Dx()*b.Dy()*3)
+	for i := 0; i < len(canvas.Pix); i += 4 {
+		out = append(out, canvas.Pix[i], canvas.Pix[i+1], canvas.Pix[i+2])
+	}
+	return out
+}
@@ -0,0 +1,96 @@
+package pdf
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// srgbProfile builds an ICC v2 display profile for sRGB IEC61966-2.1, the
+// output intent PDF/A needs for documents drawn in DeviceRGB
+func srgbProfile() []byte {
+	xyz := func(x, y, z float64) []byte {
+		b := []byte("XYZ \x00\x00\x00\x00")
+		for _, v := range []float64{x, y, z} {
+			b = binary.BigEndian.AppendUint32(b, uint32(int32(math.Round(v*65536))))
+		}
+		return b
+	}
+
+	desc := "sRGB IEC61966-2.1"
+	descTag := []byte("desc\x00\x00\x00\x00")
+	descTag = binary.BigEndian.AppendUint32(descTag, uint32(len(desc)+1))
+	descTag = append(descTag, desc...)
+	descTag = append(descTag, 0)
+	descTag = append(descTag, make([]byte, 4+4+2+1+67)...) // empty Unicode and ScriptCode parts
+
+	cprtTag := append([]byte("text\x00\x00\x00\x00"), "No copyright, use freely\x00"...)
+
+	// The sRGB transfer curve, sampled
+	const samples = 1024
+	curve := []byte("curv\x00\x00\x00\x00")
+	curve = binary.BigEndian.AppendUint32(curve, samples)
+	for i := 0; i < samples; i++ {
+		v := float64(i) / (samples - 1)
+		if v <= 0.04045 {
+			v /= 12.92
+		} else {
+			v = math.Pow((v+0.055)/1.055, 2.4)
+		}
+		curve = binary.BigEndian.AppendUint16(curve, uint16(math.Round(v*65535)))
+	}
+
+	// Primaries adapted to the D50 profile connection space
+	tags := []struct {
+		sig  string
+		data []byte
+	}{
+		{"desc", descTag},
+		{"cprt", cprtTag},
+		{"wtpt", xyz(0.9642, 1.0, 0.8249)},
+		{"rXYZ", xyz(0.4361, 0.2225, 0.0139)},
+		{"gXYZ", xyz(0.3851, 0.7169, 0.0971)},
+		{"bXYZ", xyz(0.1431, 0.0606, 0.7141)},
+		{"rTRC", curve},
+		{"gTRC", curve},
+		{"bTRC", curve},
+	}
+
+	header := make([]byte, 128)
+	table := binary.BigEndian.AppendUint32(nil, uint32(len(tags)))
+	var body []byte
+	offset := 128 + 4 + 12*len(tags)
+	curveOffset := 0
+	for _, tag := range tags {
+		at := offset + len(body)
+		// The three curves are identical and share their data
+		if tag.sig != "rTRC" && tag.sig[1:] == "TRC" {
+			at = curveOffset
+		} else {
+			if tag.sig == "rTRC" {
+				curveOffset = at
+			}
+			body = append(body, tag.data...)
+			for len(body)%4 != 0 {
+				body = append(body, 0)
+			}
+		}
+		table = append(table, tag.sig...)
+		table = binary.BigEndian.AppendUint32(table, uint32(at))
+		table = binary.BigEndian.AppendUint32(table, uint32(len(tag.data)))
+	}
+
+	size := 128 + len(table) + len(body)
+	binary.BigEndian.PutUint32(header[0:], uint32(size))
+	binary.BigEndian.PutUint32(header[8:], 0x02100000) // version 2.1
+	copy(header[12:], "mntr")
+	copy(header[16:], "RGB ")
+	copy(header[20:], "XYZ ")
+	for i, v := range []uint16{2026, 1, 1, 0, 0, 0} {
+		binary.BigEndian.PutUint16(header[24+i*2:], v)
+	}
+	copy(header[36:], "acsp")
+	copy(header[68:], xyz(0.9642, 1.0, 0.8249)[8:]) // D50 illuminant
+
+	out := append(header, table...)
+	return append(out, body...)
+}
@@ -0,0 +1,336 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Page sizes in points (1/72 inch)
+const (
+	LetterWidth  = 612.0
+	LetterHeight = 792.0
+	LegalWidth   = 612.0
+	LegalHeight  = 1008.0
+	A4Width      = 595.28
+	A4Height     = 841.89
+	A5Width      = 419.53
+	A5Height     = 595.28
+)
+
+// Document is a minimal PDF writer for text, lines and filled rectangles
+// using the standard Helvetica fonts, which every PDF viewer provides.
+// Coordinates are in points from the top-left corner of the page.
+type Document struct {
+	Title   string
+	Author  string
+	Subject string
+
+	width  float64
+	height float64
+	pages  []*bytes.Buffer
+	page   *bytes.Buffer
+	bold   bool
+	size   float64
+	fonts  [2]*Font
+}
+
+// New creates an empty document with the given page size in points
+func New(width, height float64) *Document {
+	return &Document{width: width, height: height, size: 10}
+}
+
+// Width returns the page width in points
+func (d *Document) Width() float64 { return d.width }
+
+// Height returns the page height in points
+func (d *Document) Height() float64 { return d.height }
+
+// PageCount returns the number of pages added so far
+func (d *Document) PageCount() int { return len(d.pages) }
+
+// AddPage starts a new page. Drawing always goes to the last page added.
+func (d *Document) AddPage() {
+	d.page = new(bytes.Buffer)
+	d.pages = append(d.pages, d.page)
+}
+
+// SetFont selects regular or bold Helvetica at size points
+func (d *Document) SetFont(bold bool, size float64) {
+	d.bold = bold
+	d.size = size
+}
+
+// EmbedFonts draws text with TrueType fonts embedded in the file instead of
+// the standard Helvetica fonts
+func (d *Document) EmbedFonts(regular, bold *Font) {
+	d.fonts = [2]*Font{regular, bold}
+}
+
+// FontSize returns the current font size
+func (d *Document) FontSize() float64 { return d.size }
+
+// SetFillColor sets the color used for text and filled shapes, 0-1 per channel
+func (d *Document) SetFillColor(r, g, b float64) {
+	fmt.Fprintf(d.page, "%s %s %s rg\n", num(r), num(g), num(b))
+}
+
+// SetStrokeColor sets the color used for lines, 0-1 per channel
+func (d *Document) SetStrokeColor(r, g, b float64) {
+	fmt.Fprintf(d.page, "%s %s %s RG\n", num(r), num(g), num(b))
+}
+
+// Text draws s with its baseline at y, starting at x
+func (d *Document) Text(x, y float64, s string) {
+	font := "F1"
+	if d.bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.page, "BT /%s %s Tf %s %s Td (%s) Tj ET\n",
+		font, num(d.size), num(x), num(d.height-y), escape(encode(s)))
+}
+
+// TextRight draws s so that it ends at x
+func (d *Document) TextRight(x, y float64, s string) {
+	d.Text(x-d.StringWidth(s), y, s)
+}
+
+// TextCenter draws s centered on x
+func (d *Document) TextCenter(x, y float64, s string) {
+	d.Text(x-d.StringWidth(s)/2, y, s)
+}
+
+// StringWidth returns the width of s in the current font
+func (d *Document) StringWidth(s string) float64 {
+	if f := d.font(); f != nil {
+		return f.Width(s, d.size)
+	}
+	return TextWidth(s, d.bold, d.size)
+}
+
+func (d *Document) font() *Font {
+	if d.bold {
+		return d.fonts[1]
+	}
+	return d.fonts[0]
+}
+
+// Line draws a straight line of the given width
+func (d *Document) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(d.page, "%s w %s %s m %s %s l S\n",
+		num(width), num(x1), num(d.height-y1), num(x2), num(d.height-y2))
+}
+
+// FillRect fills a rectangle whose top-left corner is at x, y
+func (d *Document) FillRect(x, y, w, h float64) {
+	fmt.Fprintf(d.page, "%s %s %s %s re f\n", num(x), num(d.height-y-h), num(w), num(h))
+}
+
+// Wrap splits s into lines no wider than width in the current font,
+// breaking at spaces and keeping existing line breaks
+func (d *Document) Wrap(s string, width float64) []string {
+	var lines []string
+	for _, para := range strings.Split(strings.TrimRight(s, "\n"), "\n") {
+		words := strings.Fields(para)
+		if len(words) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+		line := words[0]
+		for _, w := range words[1:] {
+			if d.StringWidth(line+" "+w) > width {
+				lines = append(lines, line)
+				line = w
+			} else {
+				line += " " + w
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Bytes assembles the finished PDF
+func (d *Document) Bytes() ([]byte, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	w := newWriter()
+	catalog := w.reserve()
+	pagesRef := w.reserve()
+	regular, err := w.font(d.fonts[0], "Helvetica")
+	if err != nil {
+		return nil, err
+	}
+	bold, err := w.font(d.fonts[1], "Helvetica-Bold")
+	if err != nil {
+		return nil, err
+	}
+
+	var kids []string
+	for _, page := range d.pages {
+		content, err := compress(page.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		stream := w.add(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>", len(content)), content)
+		ref := w.add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] "+
+			"/Resources << /Font << /F1 %d 0 R /F2 %d 0 R >> >> /Contents %d 0 R >>",
+			pagesRef, num(d.width), num(d.height), regular, bold, stream), nil)
+		kids = append(kids, fmt.Sprintf("%d 0 R", ref))
+	}
+
+	w.set(pagesRef, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)), nil)
+	w.set(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesRef), nil)
+
+	info := w.add(fmt.Sprintf("<< /Producer (simplebill) /CreationDate (%s)%s%s%s >>",
+		Date(time.Now()),
+		optionalString("Title", d.Title),
+		optionalString("Author", d.Author),
+		optionalString("Subject", d.Subject)), nil)
+
+	return w.finish(catalog, info), nil
+}
+
+// Date formats t as a PDF date string
+func Date(t time.Time) string {
+	_, offset := t.Zone()
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	return fmt.Sprintf("D:%s%s%02d'%02d'", t.Format("20060102150405"), sign, offset/3600, offset%3600/60)
+}
+
+func optionalString(key, value string) string {
+	if value == "" {
+		return ""
+	}
+	return fmt.Sprintf(" /%s (%s)", key, escape(encode(value)))
+}
+
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// escape quotes a byte string for use inside a PDF literal string
+func escape(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		switch c {
+		case '(', ')', '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\n':
+			sb.WriteString(`\n`)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// num formats a coordinate compactly
+func num(f float64) string {
+	s := fmt.Sprintf("%.2f", f)
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// writer numbers objects and produces the file body and cross-reference table
+type writer struct {
+	objects []object
+}
+
+type object struct {
+	dict   string
+	stream []byte
+}
+
+func newWriter() *writer {
+	return &writer{}
+}
+
+// reserve allocates an object number to be filled in later with set
+func (w *writer) reserve() int {
+	w.objects = append(w.objects, object{})
+	return len(w.objects)
+}
+
+func (w *writer) set(ref int, dict string, stream []byte) {
+	w.objects[ref-1] = object{dict: dict, stream: stream}
+}
+
+func (w *writer) add(dict string, stream []byte) int {
+	ref := w.reserve()
+	w.set(ref, dict, stream)
+	return ref
+}
+
+// font adds a font dictionary, embedding f or else naming a standard font
+func (w *writer) font(f *Font, standard string) (int, error) {
+	if f == nil {
+		return w.add(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", standard), nil), nil
+	}
+
+	program, err := compress(f.program)
+	if err != nil {
+		return 0, err
+	}
+	file := w.add(fmt.Sprintf("<< /Length %d /Length1 %d /Filter /FlateDecode >>", len(program), len(f.program)), program)
+	descriptor := w.add(fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%d %d %d %d] "+
+		"/ItalicAngle %s /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		f.name, f.bbox[0], f.bbox[1], f.bbox[2], f.bbox[3], num(f.italic), f.ascent, f.descent, f.capHeight, file), nil)
+
+	widths := make([]string, 0, 224)
+	for b := 32; b < 256; b++ {
+		widths = append(widths, fmt.Sprint(f.widths[b]))
+	}
+	return w.add(fmt.Sprintf("<< /Type /Font /Subtype /TrueType /BaseFont /%s /FirstChar 32 /LastChar 255 "+
+		"/Widths [%s] /Encoding /WinAnsiEncoding /FontDescriptor %d 0 R >>",
+		f.name, strings.Join(widths, " "), descriptor), nil), nil
+}
+
+func (w *writer) finish(root, info int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+
+	offsets := make([]int, len(w.objects))
+	for i, obj := range w.objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\n", i+1, obj.dict)
+		if obj.stream != nil {
+			buf.WriteString("stream\n")
+			buf.Write(obj.stream)
+			buf.WriteString("\nendstream\n")
+		}
+		buf.WriteString("endobj\n")
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(w.objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(w.objects)+1, root, info, xref)
+	return buf.Bytes()
+}
@@ -0,0 +1,209 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Attachment is a file embedded in a PDF/A-3 document, e.g. invoice XML
+type Attachment struct {
+	Name        string
+	Description string
+	MimeType    string
+	// Relationship is the AFRelationship to the document: Data, Source,
+	// Alternative, Supplement or Unspecified
+	Relationship string
+	Data         []byte
+}
+
+// PDFA3 is the metadata written when converting a file to PDF/A-3b
+type PDFA3 struct {
+	Title  string
+	Author string
+	Date   time.Time
+	// XMP holds extra rdf:Description elements, such as an extension schema
+	XMP         string
+	Attachments []Attachment
+}
+
+// ConvertPDFA3 appends an incremental update with what PDF/A-3b requires on
+// top of a plain PDF: XMP metadata, an sRGB output intent and a file ID,
+// plus the attachments. The fonts in the file must already be embedded.
+func ConvertPDFA3(data []byte, opts PDFA3) ([]byte, error) {
+	f, err := openPDF(data)
+	if err != nil {
+		return nil, err
+	}
+	rootRef, _ := f.trailer.get("Root")
+	rootNum, rootGen, ok := ref(rootRef)
+	if !ok {
+		return nil, fmt.Errorf("invalid /Root in PDF trailer")
+	}
+	rootValue, err := f.object(rootNum)
+	if err != nil {
+		return nil, fmt.Errorf("reading catalog: %w", err)
+	}
+	catalog, _, err := parseDict([]byte(rootValue), 0)
+	if err != nil {
+		return nil, fmt.Errorf("reading catalog: %w", err)
+	}
+
+	date := opts.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	date = date.Truncate(time.Second)
+
+	u := newUpdate(f)
+
+	metadata := xmpPacket(opts, date)
+	metadataRef := u.add(fmt.Sprintf("<< /Type /Metadata /Subtype /XML /Length %d >>", len(metadata)), metadata)
+
+	icc, err := compress(srgbProfile())
+	if err != nil {
+		return nil, err
+	}
+	iccRef := u.add(fmt.Sprintf("<< /N 3 /Length %d /Filter /FlateDecode >>", len(icc)), icc)
+	intentRef := u.add(fmt.Sprintf("<< /Type /OutputIntent /S /GTS_PDFA1 /OutputConditionIdentifier (sRGB IEC61966-2.1) "+
+		"/Info (sRGB IEC61966-2.1) /DestOutputProfile %d 0 R >>", iccRef), nil)
+
+	attachments := append([]Attachment{}, opts.Attachments...)
+	sort.Slice(attachments, func(i, j int) bool { return attachments[i].Name < attachments[j].Name })
+	var names, af []string
+	for _, a := range attachments {
+		content, err := compress(a.Data)
+		if err != nil {
+			return nil, err
+		}
+		fileRef := u.add(fmt.Sprintf("<< /Type /EmbeddedFile /Subtype %s /Params << /Size %d /ModDate (%s) >> /Length %d /Filter /FlateDecode >>",
+			nameObject(a.MimeType), len(a.Data), Date(date), len(content)), content)
+		relationship := a.Relationship
+		if relationship == "" {
+			relationship = "Unspecified"
+		}
+		specRef := u.add(fmt.Sprintf("<< /Type /Filespec /F %s /UF %s /Desc %s /AFRelationship %s /EF << /F %d 0 R /UF %d 0 R >> >>",
+			textString(a.Name), textString(a.Name), textString(a.Description), nameObject(relationship), fileRef, fileRef), nil)
+		names = append(names, fmt.Sprintf("%s %d 0 R", textString(a.Name), specRef))
+		af = append(af, fmt.Sprintf("%d 0 R", specRef))
+	}
+
+	catalog = catalog.without("Metadata", "OutputIntents", "AF", "Version")
+	catalog = append(catalog,
+		dictEntry{"Version", "/1.7"},
+		dictEntry{"Metadata", fmt.Sprintf("%d 0 R", metadataRef)},
+		dictEntry{"OutputIntents", fmt.Sprintf("[%d 0 R]", intentRef)},
+	)
+	if len(attachments) > 0 {
+		// Keep other name trees, such as named destinations
+		var nameTrees dict
+		if v, ok := catalog.get("Names"); ok {
+			resolved, err := f.resolve(v)
+			if err != nil {
+				return nil, fmt.Errorf("reading catalog names: %w", err)
+			}
+			if nameTrees, _, err = parseDict([]byte(resolved), 0); err != nil {
+				return nil, fmt.Errorf("reading catalog names: %w", err)
+			}
+		}
+		nameTrees = append(nameTrees.without("EmbeddedFiles"),
+			dictEntry{"EmbeddedFiles", fmt.Sprintf("<< /Names [%s] >>", strings.Join(names, " "))})
+		catalog = append(catalog.without("Names"),
+			dictEntry{"Names", nameTrees.String()},
+			dictEntry{"AF", "[" + strings.Join(af, " ") + "]"},
+		)
+	}
+	u.replace(rootNum, rootGen, catalog.String())
+
+	// The document info must match the XMP metadata
+	info := u.add(fmt.Sprintf("<< /Producer (simplebill) /CreationDate (%s) /ModDate (%s)%s%s >>",
+		Date(date), Date(date), optionalText("Title", opts.Title), optionalText("Author", opts.Author)), nil)
+
+	return u.finish(rootRef, info), nil
+}
+
+// xmpPacket builds the XMP metadata stream identifying the file as PDF/A-3b
+func xmpPacket(opts PDFA3, date time.Time) []byte {
+	esc := func(s string) string {
+		var buf bytes.Buffer
+		xml.EscapeText(&buf, []byte(s))
+		return buf.String()
+	}
+	stamp := date.Format("2006-01-02T15:04:05-07:00")
+
+	var b strings.Builder
+	b.WriteString("<?xpacket begin=\"\xef\xbb\xbf\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	b.WriteString("<rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	b.WriteString("<rdf:Description rdf:about=\"\" xmlns:pdfaid=\"http://www.aiim.org/pdfa/ns/id/\">\n")
+	b.WriteString("<pdfaid:part>3</pdfaid:part>\n<pdfaid:conformance>B</pdfaid:conformance>\n")
+	b.WriteString("</rdf:Description>\n")
+	b.WriteString("<rdf:Description rdf:about=\"\" xmlns:dc=\"http://purl.org/dc/elements/1.1/\">\n")
+	b.WriteString("<dc:format>application/pdf</dc:format>\n")
+	if opts.Title != "" {
+		fmt.Fprintf(&b, "<dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:title>\n", esc(opts.Title))
+	}
+	if opts.Author != "" {
+		fmt.Fprintf(&b, "<dc:creator><rdf:Seq><rdf:li>%s</rdf:li></rdf:Seq></dc:creator>\n", esc(opts.Author))
+	}
+	b.WriteString("</rdf:Description>\n")
+	b.WriteString("<rdf:Description rdf:about=\"\" xmlns:pdf=\"http://ns.adobe.com/pdf/1.3/\">\n")
+	b.WriteString("<pdf:Producer>simplebill</pdf:Producer>\n")
+	b.WriteString("</rdf:Description>\n")
+	b.WriteString("<rdf:Description rdf:about=\"\" xmlns:xmp=\"http://ns.adobe.com/xap/1.0/\">\n")
+	fmt.Fprintf(&b, "<xmp:CreateDate>%s</xmp:CreateDate>\n<xmp:ModifyDate>%s</xmp:ModifyDate>\n<xmp:MetadataDate>%s</xmp:MetadataDate>\n",
+		stamp, stamp, stamp)
+	b.WriteString("</rdf:Description>\n")
+	b.WriteString(opts.XMP)
+	b.WriteString("</rdf:RDF>\n</x:xmpmeta>\n")
+	// Padding lets other tools edit the metadata in place
+	b.WriteString(strings.Repeat(strings.Repeat(" ", 99)+"\n", 20))
+	b.WriteString("<?xpacket end=\"w\"?>")
+	return []byte(b.String())
+}
+
+// nameObject writes s as a PDF name, e.g. "text/xml" as /text#2Fxml
+func nameObject(s string) string {
+	var b strings.Builder
+	b.WriteByte('/')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < '!' || c > '~' || c == '#' || isDelimiter(c) {
+			fmt.Fprintf(&b, "#%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// textString writes s as a PDF text string, in UTF-16 when it isn't ASCII
+func textString(s string) string {
+	ascii := true
+	for _, r := range s {
+		if r > 126 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return "(" + escape([]byte(s)) + ")"
+	}
+	buf := []byte{0xFE, 0xFF}
+	for _, u := range utf16.Encode([]rune(s)) {
+		buf = append(buf, byte(u>>8), byte(u))
+	}
+	return "<" + strings.ToUpper(hex.EncodeToString(buf)) + ">"
+}
+
+func optionalText(key, value string) string {
+	if value == "" {
+		return ""
+	}
+	return fmt.Sprintf(" /%s %s", key, textString(value))
+}
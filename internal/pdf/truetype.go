@@ -0,0 +1,491 @@
+package pdf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// Font is a TrueType font to embed instead of the standard Helvetica, which
+// PDF/A requires. Only the glyphs for WinAnsiEncoding are kept.
+type Font struct {
+	name      string
+	widths    [256]int // per WinAnsi byte, in 1/1000 of the font size
+	bbox      [4]int
+	ascent    int
+	descent   int
+	capHeight int
+	italic    float64
+	program   []byte // subset font file
+}
+
+// LoadFont reads a .ttf file
+func LoadFont(path string) (*Font, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading font: %w", err)
+	}
+	f, err := parseTrueType(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Width returns the width of s in points
+func (f *Font) Width(s string, size float64) float64 {
+	total := 0
+	for _, b := range encode(s) {
+		total += f.widths[b]
+	}
+	return float64(total) * size / 1000
+}
+
+type sfnt struct {
+	tables map[string][]byte
+}
+
+func (t *sfnt) table(tag string, minLen int) ([]byte, error) {
+	b, ok := t.tables[tag]
+	if !ok || len(b) < minLen {
+		return nil, fmt.Errorf("missing or short '%s' table", tag)
+	}
+	return b, nil
+}
+
+func parseTrueType(data []byte) (*Font, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("not a TrueType font")
+	}
+	if v := binary.BigEndian.Uint32(data); v != 0x00010000 && v != 0x74727565 {
+		return nil, fmt.Errorf("not a TrueType font with glyf outlines")
+	}
+	t := &sfnt{tables: map[string][]byte{}}
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < numTables; i++ {
+		rec := 12 + i*16
+		if rec+16 > len(data) {
+			return nil, fmt.Errorf("truncated table directory")
+		}
+		tag := string(data[rec : rec+4])
+		off := int(binary.BigEndian.Uint32(data[rec+8:]))
+		length := int(binary.BigEndian.Uint32(data[rec+12:]))
+		if off < 0 || length < 0 || off+length > len(data) {
+			return nil, fmt.Errorf("table '%s' out of range", tag)
+		}
+		t.tables[tag] = data[off : off+length]
+	}
+
+	head, err := t.table("head", 54)
+	if err != nil {
+		return nil, err
+	}
+	hhea, err := t.table("hhea", 36)
+	if err != nil {
+		return nil, err
+	}
+	hmtx, err := t.table("hmtx", 4)
+	if err != nil {
+		return nil, err
+	}
+	maxp, err := t.table("maxp", 6)
+	if err != nil {
+		return nil, err
+	}
+
+	unitsPerEm := float64(binary.BigEndian.Uint16(head[18:]))
+	if unitsPerEm == 0 {
+		return nil, fmt.Errorf("invalid unitsPerEm")
+	}
+	scale := func(v int16) int {
+		return int(math.Round(float64(v) * 1000 / unitsPerEm))
+	}
+
+	f := &Font{
+		name: fontName(t),
+		bbox: [4]int{
+			scale(int16(binary.BigEndian.Uint16(head[36:]))),
+			scale(int16(binary.BigEndian.Uint16(head[38:]))),
+			scale(int16(binary.BigEndian.Uint16(head[40:]))),
+			scale(int16(binary.BigEndian.Uint16(head[42:]))),
+		},
+		ascent:  scale(int16(binary.BigEndian.Uint16(hhea[4:]))),
+		descent: scale(int16(binary.BigEndian.Uint16(hhea[6:]))),
+	}
+	f.capHeight = f.ascent
+	if os2, ok := t.tables["OS/2"]; ok && len(os2) >= 90 && binary.BigEndian.Uint16(os2) >= 2 {
+		f.capHeight = scale(int16(binary.BigEndian.Uint16(os2[88:])))
+	}
+	if post, ok := t.tables["post"]; ok && len(post) >= 8 {
+		f.italic = float64(int32(binary.BigEndian.Uint32(post[4:]))) / 65536
+	}
+
+	numGlyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+	numMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	if numMetrics == 0 || len(hmtx) < numMetrics*4 {
+		return nil, fmt.Errorf("invalid 'hmtx' table")
+	}
+	advance := func(glyph int) int {
+		if glyph >= numMetrics {
+			glyph = numMetrics - 1
+		}
+		return int(math.Round(float64(binary.BigEndian.Uint16(hmtx[glyph*4:])) * 1000 / unitsPerEm))
+	}
+
+	cmap, err := unicodeCmap(t)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := map[int]bool{0: true}
+	for b := 0; b < 256; b++ {
+		r, ok := decodeWinAnsi(byte(b))
+		glyph := 0
+		if ok {
+			glyph = cmap(r)
+		}
+		if glyph >= numGlyphs {
+			glyph = 0
+		}
+		f.widths[b] = advance(glyph)
+		keep[glyph] = true
+	}
+
+	f.program, err = subset(t, keep, numGlyphs)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// decodeWinAnsi returns the character a WinAnsiEncoding byte stands for
+func decodeWinAnsi(b byte) (rune, bool) {
+	switch {
+	case b >= 32 && b <= 126, b >= 0xA0:
+		return rune(b), true
+	}
+	for r, c := range winAnsi {
+		if c == b {
+			return r, true
+		}
+	}
+	return 0, false
+}
+
+// fontName returns the PostScript name from the 'name' table
+func fontName(t *sfnt) string {
+	name := "EmbeddedFont"
+	tbl, ok := t.tables["name"]
+	if !ok || len(tbl) < 6 {
+		return name
+	}
+	count := int(binary.BigEndian.Uint16(tbl[2:]))
+	storage := int(binary.BigEndian.Uint16(tbl[4:]))
+	for i := 0; i < count; i++ {
+		rec := 6 + i*12
+		if rec+12 > len(tbl) {
+			break
+		}
+		platform := binary.BigEndian.Uint16(tbl[rec:])
+		nameID := binary.BigEndian.Uint16(tbl[rec+6:])
+		length := int(binary.BigEndian.Uint16(tbl[rec+8:]))
+		off := storage + int(binary.BigEndian.Uint16(tbl[rec+10:]))
+		if nameID != 6 || off+length > len(tbl) {
+			continue
+		}
+		raw := tbl[off : off+length]
+		var s string
+		if platform == 3 || platform == 0 {
+			u := make([]uint16, len(raw)/2)
+			for j := range u {
+				u[j] = binary.BigEndian.Uint16(raw[j*2:])
+			}
+			s = string(utf16.Decode(u))
+		} else {
+			s = string(raw)
+		}
+		// PDF names can't contain spaces or delimiters
+		s = strings.Map(func(r rune) rune {
+			if r <= 32 || r > 126 || strings.ContainsRune("()<>[]{}/%#", r) {
+				return -1
+			}
+			return r
+		}, s)
+		if s != "" {
+			return s
+		}
+	}
+	return name
+}
+
+// unicodeCmap returns a lookup from the Windows Unicode (3,1) format 4
+// subtable, which PDF viewers use for non-symbolic TrueType fonts
+func unicodeCmap(t *sfnt) (func(rune) int, error) {
+	cmap, err := t.table("cmap", 4)
+	if err != nil {
+		return nil, err
+	}
+	count := int(binary.BigEndian.Uint16(cmap[2:]))
+	for i := 0; i < count; i++ {
+		rec := 4 + i*8
+		if rec+8 > len(cmap) {
+			break
+		}
+		platform := binary.BigEndian.Uint16(cmap[rec:])
+		encoding := binary.BigEndian.Uint16(cmap[rec+2:])
+		off := int(binary.BigEndian.Uint32(cmap[rec+4:]))
+		if platform != 3 || encoding != 1 || off+14 > len(cmap) {
+			continue
+		}
+		sub := cmap[off:]
+		if binary.BigEndian.Uint16(sub) != 4 {
+			continue
+		}
+		segX2 := int(binary.BigEndian.Uint16(sub[6:]))
+		if 16+segX2*4 > len(sub) {
+			return nil, fmt.Errorf("truncated cmap subtable")
+		}
+		ends := sub[14:]
+		starts := sub[16+segX2:]
+		deltas := sub[16+segX2*2:]
+		ranges := sub[16+segX2*3:]
+		return func(r rune) int {
+			if r > 0xFFFF {
+				return 0
+			}
+			c := int(r)
+			for s := 0; s < segX2; s += 2 {
+				end := int(binary.BigEndian.Uint16(ends[s:]))
+				if c > end {
+					continue
+				}
+				start := int(binary.BigEndian.Uint16(starts[s:]))
+				if c < start {
+					return 0
+				}
+				delta := int(binary.BigEndian.Uint16(deltas[s:]))
+				rangeOff := int(binary.BigEndian.Uint16(ranges[s:]))
+				if rangeOff == 0 {
+					return (c + delta) & 0xFFFF
+				}
+				pos := 16 + segX2*3 + s + rangeOff + (c-start)*2
+				if pos+2 > len(sub) {
+					return 0
+				}
+				g := int(binary.BigEndian.Uint16(sub[pos:]))
+				if g == 0 {
+					return 0
+				}
+				return (g + delta) & 0xFFFF
+			}
+			return 0
+		}, nil
+	}
+	return nil, fmt.Errorf("font has no Windows Unicode cmap")
+}
+
+// subsetTables are the tables a PDF viewer needs from an embedded TrueType font
+var subsetTables = []string{"OS/2", "cmap", "cvt ", "fpgm", "glyf", "head", "hhea", "hmtx", "loca", "maxp", "name", "post", "prep"}
+
+// subset rebuilds the font with the outlines of every glyph not in keep
+// emptied, so glyph ids and the cmap stay valid
+func subset(t *sfnt, keep map[int]bool, numGlyphs int) ([]byte, error) {
+	head, _ := t.table("head", 54)
+	glyf, err := t.table("glyf", 0)
+	if err != nil {
+		return nil, err
+	}
+	loca, err := t.table("loca", 0)
+	if err != nil {
+		return nil, err
+	}
+	long := binary.BigEndian.Uint16(head[50:]) == 1
+
+	offset := func(glyph int) (int, error) {
+		if long {
+			if glyph*4+4 > len(loca) {
+				return 0, fmt.Errorf("truncated 'loca' table")
+			}
+			return int(binary.BigEndian.Uint32(loca[glyph*4:])), nil
+		}
+		if glyph*2+2 > len(loca) {
+			return 0, fmt.Errorf("truncated 'loca' table")
+		}
+		return int(binary.BigEndian.Uint16(loca[glyph*2:])) * 2, nil
+	}
+	glyphData := func(glyph int) ([]byte, error) {
+		start, err := offset(glyph)
+		if err != nil {
+			return nil, err
+		}
+		end, err := offset(glyph + 1)
+		if err != nil {
+			return nil, err
+		}
+		if start > end || end > len(glyf) {
+			return nil, fmt.Errorf("glyph %d out of range", glyph)
+		}
+		return glyf[start:end], nil
+	}
+
+	// Composite glyphs are built from other glyphs, which must be kept too
+	queue := make([]int, 0, len(keep))
+	for g := range keep {
+		queue = append(queue, g)
+	}
+	for len(queue) > 0 {
+		g := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		data, err := glyphData(g)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range components(data) {
+			if c < numGlyphs && !keep[c] {
+				keep[c] = true
+				queue = append(queue, c)
+			}
+		}
+	}
+
+	var newGlyf []byte
+	newLoca := make([]byte, 0, (numGlyphs+1)*4)
+	putOffset := func(off int) {
+		if long {
+			newLoca = binary.BigEndian.AppendUint32(newLoca, uint32(off))
+		} else {
+			newLoca = binary.BigEndian.AppendUint16(newLoca, uint16(off/2))
+		}
+	}
+	for g := 0; g < numGlyphs; g++ {
+		putOffset(len(newGlyf))
+		if !keep[g] {
+			continue
+		}
+		data, err := glyphData(g)
+		if err != nil {
+			return nil, err
+		}
+		newGlyf = append(newGlyf, data...)
+		for len(newGlyf)%4 != 0 {
+			newGlyf = append(newGlyf, 0)
+		}
+	}
+	putOffset(len(newGlyf))
+
+	tables := map[string][]byte{}
+	for _, tag := range subsetTables {
+		if b, ok := t.tables[tag]; ok {
+			tables[tag] = b
+		}
+	}
+	// Glyph names aren't needed, so post drops to version 3
+	if post, ok := tables["post"]; ok && len(post) >= 32 {
+		tables["post"] = append([]byte{0, 3, 0, 0}, post[4:32]...)
+	}
+	tables["glyf"] = newGlyf
+	tables["loca"] = newLoca
+	tables["head"] = append([]byte{}, head...)
+	binary.BigEndian.PutUint32(tables["head"][8:], 0) // checkSumAdjustment, set below
+	return buildSfnt(tables), nil
+}
+
+// components lists the glyphs a composite glyph refers to
+func components(data []byte) []int {
+	if len(data) < 10 || int16(binary.BigEndian.Uint16(data)) >= 0 {
+		return nil
+	}
+	const (
+		argsAreWords  = 0x0001
+		haveScale     = 0x0008
+		moreComponent = 0x0020
+		haveXYScale   = 0x0040
+		haveTwoByTwo  = 0x0080
+	)
+	var glyphs []int
+	pos := 10
+	for pos+4 <= len(data) {
+		flags := binary.BigEndian.Uint16(data[pos:])
+		glyphs = append(glyphs, int(binary.BigEndian.Uint16(data[pos+2:])))
+		pos += 4
+		if flags&argsAreWords != 0 {
+			pos += 4
+		} else {
+			pos += 2
+		}
+		switch {
+		case flags&haveScale != 0:
+			pos += 2
+		case flags&haveXYScale != 0:
+			pos += 4
+		case flags&haveTwoByTwo != 0:
+			pos += 8
+		}
+		if flags&moreComponent == 0 {
+			break
+		}
+	}
+	return glyphs
+}
+
+// buildSfnt writes a font file from its tables, with checksums
+func buildSfnt(tables map[string][]byte) []byte {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	n := len(tags)
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= n {
+		entrySelector++
+	}
+	searchRange := (1 << entrySelector) * 16
+
+	out := make([]byte, 0, 12+16*n)
+	out = binary.BigEndian.AppendUint32(out, 0x00010000)
+	out = binary.BigEndian.AppendUint16(out, uint16(n))
+	out = binary.BigEndian.AppendUint16(out, uint16(searchRange))
+	out = binary.BigEndian.AppendUint16(out, uint16(entrySelector))
+	out = binary.BigEndian.AppendUint16(out, uint16(n*16-searchRange))
+
+	offset := 12 + 16*n
+	var body []byte
+	headOffset := 0
+	for _, tag := range tags {
+		data := tables[tag]
+		if tag == "head" {
+			headOffset = offset
+		}
+		out = append(out, tag...)
+		out = binary.BigEndian.AppendUint32(out, checksum(data))
+		out = binary.BigEndian.AppendUint32(out, uint32(offset))
+		out = binary.BigEndian.AppendUint32(out, uint32(len(data)))
+		body = append(body, data...)
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+		offset = 12 + 16*n + len(body)
+	}
+	out = append(out, body...)
+
+	if headOffset > 0 {
+		binary.BigEndian.PutUint32(out[headOffset+8:], 0xB1B0AFBA-checksum(out))
+	}
+	return out
+}
+
+func checksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
@@ -0,0 +1,788 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// This file reads just enough of an existing PDF to append an incremental
+// update: the cross-reference table or stream, the trailer and single
+// objects such as the catalog. Files from wkhtmltopdf, Chromium and
+// WeasyPrint all use one or the other cross-reference form.
+
+// dictEntry is one key of a dictionary with its value as PDF source text
+type dictEntry struct {
+	key   string
+	value string
+}
+
+type dict []dictEntry
+
+func (d dict) get(key string) (string, bool) {
+	for _, e := range d {
+		if e.key == key {
+			return e.value, true
+		}
+	}
+	return "", false
+}
+
+func (d dict) int(key string) (int, bool) {
+	v, ok := d.get(key)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(v)
+	return n, err == nil
+}
+
+// without returns d minus the given keys
+func (d dict) without(keys ...string) dict {
+	var out dict
+	for _, e := range d {
+		drop := false
+		for _, k := range keys {
+			if e.key == k {
+				drop = true
+			}
+		}
+		if !drop {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func (d dict) String() string {
+	var sb strings.Builder
+	sb.WriteString("<<")
+	for _, e := range d {
+		fmt.Fprintf(&sb, " /%s %s", e.key, e.value)
+	}
+	sb.WriteString(" >>")
+	return sb.String()
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// skipSpace skips whitespace and comments
+func skipSpace(data []byte, pos int) int {
+	for pos < len(data) {
+		switch {
+		case isSpace(data[pos]):
+			pos++
+		case data[pos] == '%':
+			for pos < len(data) && data[pos] != '\n' && data[pos] != '\r' {
+				pos++
+			}
+		default:
+			return pos
+		}
+	}
+	return pos
+}
+
+// token returns the end of the regular characters starting at pos
+func token(data []byte, pos int) int {
+	for pos < len(data) && !isSpace(data[pos]) && !isDelimiter(data[pos]) {
+		pos++
+	}
+	return pos
+}
+
+// readInt reads an unsigned integer at pos, after any whitespace
+func readInt(data []byte, pos int) (int, int, error) {
+	pos = skipSpace(data, pos)
+	end := pos
+	for end < len(data) && data[end] >= '0' && data[end] <= '9' {
+		end++
+	}
+	if end == pos {
+		return 0, pos, fmt.Errorf("expected a number at offset %d", pos)
+	}
+	n, err := strconv.Atoi(string(data[pos:end]))
+	return n, end, err
+}
+
+// skipValue returns where the value starting at pos (after whitespace) ends
+func skipValue(data []byte, pos int) (int, error) {
+	pos = skipSpace(data, pos)
+	if pos >= len(data) {
+		return 0, fmt.Errorf("unexpected end of file")
+	}
+	switch c := data[pos]; {
+	case c == '/':
+		return token(data, pos+1), nil
+	case c == '(':
+		depth := 0
+		for i := pos; i < len(data); i++ {
+			switch data[i] {
+			case '\\':
+				i++
+			case '(':
+				depth++
+			case ')':
+				depth--
+				if depth == 0 {
+					return i + 1, nil
+				}
+			}
+		}
+		return 0, fmt.Errorf("unterminated string at offset %d", pos)
+	case c == '<' && pos+1 < len(data) && data[pos+1] == '<':
+		_, end, err := parseDict(data, pos)
+		return end, err
+	case c == '<':
+		end := bytes.IndexByte(data[pos:], '>')
+		if end < 0 {
+			return 0, fmt.Errorf("unterminated hex string at offset %d", pos)
+		}
+		return pos + end + 1, nil
+	case c == '[':
+		pos++
+		for {
+			pos = skipSpace(data, pos)
+			if pos >= len(data) {
+				return 0, fmt.Errorf("unterminated array")
+			}
+			if data[pos] == ']' {
+				return pos + 1, nil
+			}
+			end, err := skipValue(data, pos)
+			if err != nil {
+				return 0, err
+			}
+			pos = end
+		}
+	case isDelimiter(c):
+		return 0, fmt.Errorf("unexpected '%c' at offset %d", c, pos)
+	default:
+		end := token(data, pos)
+		// An indirect reference is "num gen R"
+		if _, err := strconv.Atoi(string(data[pos:end])); err == nil {
+			if _, genEnd, err := readInt(data, end); err == nil {
+				r := skipSpace(data, genEnd)
+				if r < len(data) && data[r] == 'R' && token(data, r) == r+1 {
+					return r + 1, nil
+				}
+			}
+		}
+		return end, nil
+	}
+}
+
+// parseDict reads the dictionary starting at pos
+func parseDict(data []byte, pos int) (dict, int, error) {
+	pos = skipSpace(data, pos)
+	if !bytes.HasPrefix(data[pos:], []byte("<<")) {
+		return nil, 0, fmt.Errorf("expected a dictionary at offset %d", pos)
+	}
+	pos += 2
+	var d dict
+	for {
+		pos = skipSpace(data, pos)
+		if pos >= len(data) {
+			return nil, 0, fmt.Errorf("unterminated dictionary")
+		}
+		if bytes.HasPrefix(data[pos:], []byte(">>")) {
+			return d, pos + 2, nil
+		}
+		if data[pos] != '/' {
+			return nil, 0, fmt.Errorf("expected a name at offset %d", pos)
+		}
+		keyEnd := token(data, pos+1)
+		key := string(data[pos+1 : keyEnd])
+		start := skipSpace(data, keyEnd)
+		end, err := skipValue(data, start)
+		if err != nil {
+			return nil, 0, err
+		}
+		d = append(d, dictEntry{key: key, value: string(data[start:end])})
+		pos = end
+	}
+}
+
+// xrefEntry says where an object is: at offset, or at index inside the
+// object stream stream
+type xrefEntry struct {
+	offset int
+	gen    int
+	stream int
+	index  int
+}
+
+// pdfFile is an existing PDF opened for an incremental update
+type pdfFile struct {
+	data       []byte
+	objects    map[int]xrefEntry
+	trailer    dict
+	startxref  int
+	xrefStream bool
+	streams    map[int]objectStream
+}
+
+// objectStream is a decoded stream of compressed objects
+type objectStream struct {
+	data  []byte
+	first int
+}
+
+func openPDF(data []byte) (*pdfFile, error) {
+	tail := data
+	if len(tail) > 2048 {
+		tail = tail[len(tail)-2048:]
+	}
+	i := bytes.LastIndex(tail, []byte("startxref"))
+	if i < 0 {
+		return nil, fmt.Errorf("not a PDF file: no startxref")
+	}
+	startxref, _, err := readInt(tail, i+len("startxref"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid startxref: %w", err)
+	}
+
+	f := &pdfFile{data: data, objects: map[int]xrefEntry{}, startxref: startxref, streams: map[int]objectStream{}}
+	seen := map[int]bool{}
+	for offset, first := startxref, true; ; first = false {
+		if seen[offset] || offset <= 0 || offset >= len(data) {
+			return nil, fmt.Errorf("invalid cross-reference offset %d", offset)
+		}
+		seen[offset] = true
+
+		trailer, err := f.readXref(offset)
+		if err != nil {
+			return nil, err
+		}
+		if first {
+			f.trailer = trailer
+			f.xrefStream = !bytes.HasPrefix(data[skipSpace(data, offset):], []byte("xref"))
+		}
+		// Hybrid files keep compressed objects in a separate stream
+		if stm, ok := trailer.int("XRefStm"); ok && !seen[stm] {
+			seen[stm] = true
+			if _, err := f.readXref(stm); err != nil {
+				return nil, err
+			}
+		}
+		prev, ok := trailer.int("Prev")
+		if !ok {
+			break
+		}
+		offset = prev
+	}
+
+	if _, ok := f.trailer.get("Encrypt"); ok {
+		return nil, fmt.Errorf("encrypted PDFs are not supported")
+	}
+	if _, ok := f.trailer.get("Root"); !ok {
+		return nil, fmt.Errorf("PDF trailer has no /Root")
+	}
+	return f, nil
+}
+
+// add records an entry unless a newer section already has the object
+func (f *pdfFile) add(num int, e xrefEntry) {
+	if _, ok := f.objects[num]; !ok {
+		f.objects[num] = e
+	}
+}
+
+// readXref reads one cross-reference section and returns its trailer
+func (f *pdfFile) readXref(offset int) (dict, error) {
+	data := f.data
+	pos := skipSpace(data, offset)
+	if !bytes.HasPrefix(data[pos:], []byte("xref")) {
+		return f.readXrefStream(pos)
+	}
+
+	pos += len("xref")
+	for {
+		pos = skipSpace(data, pos)
+		if bytes.HasPrefix(data[pos:], []byte("trailer")) {
+			trailer, _, err := parseDict(data, pos+len("trailer"))
+			return trailer, err
+		}
+		start, next, err := readInt(data, pos)
+		if err != nil {
+			return nil, fmt.Errorf("reading xref table: %w", err)
+		}
+		count, next, err := readInt(data, next)
+		if err != nil {
+			return nil, fmt.Errorf("reading xref table: %w", err)
+		}
+		pos = next
+		for i := 0; i < count; i++ {
+			off, next, err := readInt(data, pos)
+			if err != nil {
+				return nil, fmt.Errorf("reading xref table: %w", err)
+			}
+			gen, next, err := readInt(data, next)
+			if err != nil {
+				return nil, fmt.Errorf("reading xref table: %w", err)
+			}
+			next = skipSpace(data, next)
+			if next >= len(data) {
+				return nil, fmt.Errorf("truncated xref table")
+			}
+			if data[next] == 'n' {
+				f.add(start+i, xrefEntry{offset: off, gen: gen})
+			} else {
+				f.add(start+i, xrefEntry{offset: -1})
+			}
+			pos = next + 1
+		}
+	}
+}
+
+// readXrefStream reads a cross-reference stream, used since PDF 1.5
+func (f *pdfFile) readXrefStream(pos int) (dict, error) {
+	_, d, body, err := f.readObjectAt(pos)
+	if err != nil {
+		return nil, fmt.Errorf("reading xref stream: %w", err)
+	}
+	if t, _ := d.get("Type"); t != "/XRef" {
+		return nil, fmt.Errorf("no cross-reference table at offset %d", pos)
+	}
+
+	raw, err := f.streamData(d, body)
+	if err != nil {
+		return nil, fmt.Errorf("reading xref stream: %w", err)
+	}
+	w, err := intArray(d, "W")
+	if err != nil || len(w) != 3 {
+		return nil, fmt.Errorf("invalid /W in xref stream")
+	}
+	size, _ := d.int("Size")
+	index := []int{0, size}
+	if _, ok := d.get("Index"); ok {
+		if index, err = intArray(d, "Index"); err != nil || len(index)%2 != 0 {
+			return nil, fmt.Errorf("invalid /Index in xref stream")
+		}
+	}
+
+	field := func(b []byte) int {
+		n := 0
+		for _, c := range b {
+			n = n<<8 | int(c)
+		}
+		return n
+	}
+	rowLen := w[0] + w[1] + w[2]
+	row := 0
+	for i := 0; i < len(index); i += 2 {
+		for n := 0; n < index[i+1]; n++ {
+			if (row+1)*rowLen > len(raw) {
+				return nil, fmt.Errorf("truncated xref stream")
+			}
+			b := raw[row*rowLen : (row+1)*rowLen]
+			row++
+			kind := 1
+			if w[0] > 0 {
+				kind = field(b[:w[0]])
+			}
+			a, c := field(b[w[0]:w[0]+w[1]]), field(b[w[0]+w[1]:])
+			switch kind {
+			case 0:
+				f.add(index[i]+n, xrefEntry{offset: -1})
+			case 1:
+				f.add(index[i]+n, xrefEntry{offset: a, gen: c})
+			case 2:
+				f.add(index[i]+n, xrefEntry{stream: a, index: c})
+			}
+		}
+	}
+	return d, nil
+}
+
+func intArray(d dict, key string) ([]int, error) {
+	v, _ := d.get(key)
+	v = strings.TrimSpace(v)
+	if !strings.HasPrefix(v, "[") || !strings.HasSuffix(v, "]") {
+		return nil, fmt.Errorf("/%s is not an array", key)
+	}
+	var out []int
+	for _, s := range strings.Fields(v[1 : len(v)-1]) {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// readObjectAt reads "num gen obj" at pos. For a stream it returns the
+// dictionary and the offset after the stream keyword; otherwise the value.
+func (f *pdfFile) readObjectAt(pos int) (string, dict, int, error) {
+	data := f.data
+	_, next, err := readInt(data, pos)
+	if err != nil {
+		return "", nil, 0, err
+	}
+	if _, next, err = readInt(data, next); err != nil {
+		return "", nil, 0, err
+	}
+	next = skipSpace(data, next)
+	if !bytes.HasPrefix(data[next:], []byte("obj")) {
+		return "", nil, 0, fmt.Errorf("expected an object at offset %d", pos)
+	}
+	start := skipSpace(data, next+3)
+	end, err := skipValue(data, start)
+	if err != nil {
+		return "", nil, 0, err
+	}
+	value := string(data[start:end])
+
+	after := skipSpace(data, end)
+	if !bytes.HasPrefix(data[after:], []byte("stream")) {
+		return value, nil, 0, nil
+	}
+	d, _, err := parseDict(data, start)
+	if err != nil {
+		return "", nil, 0, err
+	}
+	body := after + len("stream")
+	if bytes.HasPrefix(data[body:], []byte("\r\n")) {
+		body += 2
+	} else if body < len(data) && (data[body] == '\n' || data[body] == '\r') {
+		body++
+	}
+	return value, d, body, nil
+}
+
+// streamData returns the decoded content of the stream starting at body
+func (f *pdfFile) streamData(d dict, body int) ([]byte, error) {
+	length, ok := d.int("Length")
+	if !ok || body+length > len(f.data) {
+		// An indirect /Length: find the end instead
+		end := bytes.Index(f.data[body:], []byte("endstream"))
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated stream")
+		}
+		length = end
+	}
+	raw := f.data[body : body+length]
+
+	filter, _ := d.get("Filter")
+	filter = strings.Trim(filter, "[] ")
+	switch filter {
+	case "":
+		return raw, nil
+	case "/FlateDecode":
+	default:
+		return nil, fmt.Errorf("unsupported stream filter %s", filter)
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	out, err := io.ReadAll(zr)
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+
+	params, _ := d.get("DecodeParms")
+	params = strings.Trim(params, "[] ")
+	if params == "" || params == "null" {
+		return out, nil
+	}
+	p, _, err := parseDict([]byte(params), 0)
+	if err != nil {
+		return nil, err
+	}
+	predictor, _ := p.int("Predictor")
+	if predictor < 10 {
+		return out, nil
+	}
+	columns, ok := p.int("Columns")
+	if !ok {
+		columns = 1
+	}
+	return unpredictPNG(out, columns)
+}
+
+// rawStream returns the content of the stream starting at body as stored,
+// still encoded
+func (f *pdfFile) rawStream(d dict, body int) ([]byte, error) {
+	value, _ := d.get("Length")
+	value, err := f.resolve(value)
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || length < 0 || body+length > len(f.data) {
+		end := bytes.Index(f.data[body:], []byte("endstream"))
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated stream")
+		}
+		length = end
+		// The end of line before endstream is not part of the data
+		for length > 0 && (f.data[body+length-1] == '\n' || f.data[body+length-1] == '\r') {
+			length--
+		}
+	}
+	return f.data[body : body+length], nil
+}
+
+// unpredictPNG undoes PNG row filters, as used by cross-reference streams
+func unpredictPNG(data []byte, columns int) ([]byte, error) {
+	rowLen := columns + 1
+	if len(data)%rowLen != 0 {
+		return nil, fmt.Errorf("invalid PNG predictor data")
+	}
+	out := make([]byte, 0, len(data)/rowLen*columns)
+	prev := make([]byte, columns)
+	for i := 0; i < len(data); i += rowLen {
+		filter, row := data[i], append([]byte{}, data[i+1:i+rowLen]...)
+		for j := range row {
+			var left, upLeft byte
+			if j > 0 {
+				left, upLeft = row[j-1], prev[j-1]
+			}
+			switch filter {
+			case 1:
+				row[j] += left
+			case 2:
+				row[j] += prev[j]
+			case 3:
+				row[j] += byte((int(left) + int(prev[j])) / 2)
+			case 4:
+				row[j] += paeth(left, prev[j], upLeft)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// object returns the value of object num as PDF source text
+func (f *pdfFile) object(num int) (string, error) {
+	e, ok := f.objects[num]
+	if !ok || e.offset < 0 {
+		return "", fmt.Errorf("object %d not found", num)
+	}
+	if e.stream == 0 {
+		value, _, _, err := f.readObjectAt(e.offset)
+		return value, err
+	}
+
+	stm, ok := f.streams[e.stream]
+	if !ok {
+		se, ok := f.objects[e.stream]
+		if !ok || se.stream != 0 || se.offset < 0 {
+			return "", fmt.Errorf("object stream %d not found", e.stream)
+		}
+		_, d, body, err := f.readObjectAt(se.offset)
+		if err != nil || d == nil {
+			return "", fmt.Errorf("reading object stream %d: %v", e.stream, err)
+		}
+		raw, err := f.streamData(d, body)
+		if err != nil {
+			return "", fmt.Errorf("reading object stream %d: %w", e.stream, err)
+		}
+		first, _ := d.int("First")
+		stm = objectStream{data: raw, first: first}
+		f.streams[e.stream] = stm
+	}
+
+	// The stream starts with pairs of object number and offset
+	data := stm.data
+	pos := 0
+	offset := -1
+	for i := 0; i <= e.index; i++ {
+		var err error
+		if _, pos, err = readInt(data, pos); err != nil {
+			return "", fmt.Errorf("invalid object stream %d", e.stream)
+		}
+		if offset, pos, err = readInt(data, pos); err != nil {
+			return "", fmt.Errorf("invalid object stream %d", e.stream)
+		}
+	}
+	start := stm.first + offset
+	if start < 0 || start >= len(data) {
+		return "", fmt.Errorf("invalid object stream %d", e.stream)
+	}
+	end, err := skipValue(data, start)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data[start:end])), nil
+}
+
+// resolve follows an indirect reference
+func (f *pdfFile) resolve(value string) (string, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 3 && fields[2] == "R" {
+		num, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return "", fmt.Errorf("invalid reference %s", value)
+		}
+		return f.object(num)
+	}
+	return value, nil
+}
+
+// ref parses "num gen R"
+func ref(value string) (int, int, bool) {
+	fields := strings.Fields(value)
+	if len(fields) != 3 || fields[2] != "R" {
+		return 0, 0, false
+	}
+	num, err1 := strconv.Atoi(fields[0])
+	gen, err2 := strconv.Atoi(fields[1])
+	return num, gen, err1 == nil && err2 == nil
+}
+
+// update collects objects to append to f
+type update struct {
+	file    *pdfFile
+	next    int
+	objects map[int]object
+	gens    map[int]int
+}
+
+func newUpdate(f *pdfFile) *update {
+	// Trust the objects over /Size, which some writers get wrong
+	size, _ := f.trailer.int("Size")
+	for num := range f.objects {
+		if num >= size {
+			size = num + 1
+		}
+	}
+	return &update{file: f, next: size, objects: map[int]object{}, gens: map[int]int{}}
+}
+
+func (u *update) add(dict string, stream []byte) int {
+	num := u.next
+	u.next++
+	u.objects[num] = object{dict: dict, stream: stream}
+	return num
+}
+
+// reserve returns a number for an object that is set later, so objects
+// can refer to each other
+func (u *update) reserve() int {
+	num := u.next
+	u.next++
+	return num
+}
+
+func (u *update) set(num int, dict string, stream []byte) {
+	u.objects[num] = object{dict: dict, stream: stream}
+}
+
+// replace writes a new version of an existing object
+func (u *update) replace(num, gen int, dict string) {
+	u.objects[num] = object{dict: dict}
+	u.gens[num] = gen
+}
+
+// finish returns the original file with the update appended
+func (u *update) finish(root string, info int) []byte {
+	var buf bytes.Buffer
+	buf.Write(u.file.data)
+	if !bytes.HasSuffix(u.file.data, []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+
+	nums := make([]int, 0, len(u.objects))
+	for num := range u.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+
+	offsets := map[int]int{}
+	for _, num := range nums {
+		obj := u.objects[num]
+		offsets[num] = buf.Len()
+		fmt.Fprintf(&buf, "%d %d obj\n%s\n", num, u.gens[num], obj.dict)
+		if obj.stream != nil {
+			buf.WriteString("stream\n")
+			buf.Write(obj.stream)
+			buf.WriteString("\nendstream\n")
+		}
+		buf.WriteString("endobj\n")
+	}
+
+	id, ok := u.file.trailer.get("ID")
+	if !ok {
+		sum := fmt.Sprintf("%x", md5.Sum(u.file.data))
+		id = fmt.Sprintf("[<%s> <%s>]", sum, sum)
+	}
+	trailer := dict{
+		{"Size", strconv.Itoa(u.next)},
+		{"Root", root},
+		{"Info", fmt.Sprintf("%d 0 R", info)},
+		{"ID", id},
+		{"Prev", strconv.Itoa(u.file.startxref)},
+	}
+
+	xref := buf.Len()
+	if !u.file.xrefStream {
+		buf.WriteString("xref\n")
+		for _, num := range nums {
+			fmt.Fprintf(&buf, "%d 1\n%010d %05d n \n", num, offsets[num], u.gens[num])
+		}
+		fmt.Fprintf(&buf, "trailer\n%s\nstartxref\n%d\n%%%%EOF\n", trailer, xref)
+		return buf.Bytes()
+	}
+
+	// A file with cross-reference streams is updated with one too
+	self := u.next
+	nums = append(nums, self)
+	offsets[self] = xref
+	trailer[0].value = strconv.Itoa(self + 1)
+
+	var index []string
+	var rows []byte
+	for _, num := range nums {
+		index = append(index, fmt.Sprintf("%d 1", num))
+		rows = append(rows, 1)
+		rows = binary.BigEndian.AppendUint32(rows, uint32(offsets[num]))
+		rows = binary.BigEndian.AppendUint16(rows, uint16(u.gens[num]))
+	}
+	trailer = append(trailer,
+		dictEntry{"Type", "/XRef"},
+		dictEntry{"W", "[1 4 2]"},
+		dictEntry{"Index", "[" + strings.Join(index, " ") + "]"},
+		dictEntry{"Length", strconv.Itoa(len(rows))},
+	)
+	fmt.Fprintf(&buf, "%d 0 obj\n%s\nstream\n", self, trailer)
+	buf.Write(rows)
+	fmt.Fprintf(&buf, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", xref)
+	return buf.Bytes()
+}
@@ -0,0 +1,252 @@
+// Package qrbill builds the payment part of Swiss QR-bills: the SPC payload
+// in the QR code, the references and how the slip writes its values.
+package qrbill
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"simplebill/internal/iban"
+	"simplebill/internal/money"
+	"simplebill/internal/qr"
+)
+
+// Reference types
+const (
+	QRR  = "QRR"  // QR reference, 27 digits, only with a QR-IBAN
+	SCOR = "SCOR" // ISO 11649 creditor reference, RF...
+	NON  = "NON"  // no reference
+)
+
+// Address is a structured address, the only kind the payload allows
+type Address struct {
+	Name     string
+	Street   string
+	Number   string // building number
+	Postcode string
+	City     string
+	Country  string // ISO 3166-1 alpha-2 code
+}
+
+// Bill is the content of one payment part
+type Bill struct {
+	Account       string // IBAN or QR-IBAN in electronic form
+	Creditor      Address
+	Amount        money.Amount // 0 leaves the amount for the payer to fill in
+	Currency      string       // CHF or EUR
+	Debtor        *Address     // nil leaves a box for the payer's details
+	ReferenceType string
+	Reference     string // QR or creditor reference in electronic form
+	Message       string // unstructured message to the payer's bank
+}
+
+// Validate checks b against the limits of the QR-bill standard
+func (b *Bill) Validate() error {
+	var problems []string
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if country := b.Account[:min(2, len(b.Account))]; country != "CH" && country != "LI" {
+		add("QR-bills need a Swiss or Liechtenstein IBAN, not '%s'", b.Account)
+	}
+	switch {
+	case b.ReferenceType == QRR && !IsQRIBAN(b.Account):
+		add("QR references need a QR-IBAN, '%s' isn't one", b.Account)
+	case b.ReferenceType != QRR && IsQRIBAN(b.Account):
+		add("a QR-IBAN such as '%s' only takes QR references", b.Account)
+	}
+	if b.Currency != "CHF" && b.Currency != "EUR" {
+		add("QR-bills can only be in CHF or EUR, not %s", b.Currency)
+	}
+	if b.Amount < 0 || b.Amount > 99999999999 {
+		add("amount %s is out of range for a QR-bill", b.Amount)
+	}
+	checkAddress := func(who string, a Address) {
+		if a.Name == "" || a.City == "" || a.Postcode == "" || a.Country == "" {
+			add("the %s needs a name, postcode, city and country", who)
+		}
+		for _, f := range []struct {
+			name, value string
+			max         int
+		}{
+			{"name", a.Name, 70}, {"street", a.Street, 70}, {"building number", a.Number, 16},
+			{"postcode", a.Postcode, 16}, {"city", a.City, 35},
+		} {
+			if utf8.RuneCountInString(f.value) > f.max {
+				add("the %s's %s is longer than %d characters", who, f.name, f.max)
+			}
+		}
+	}
+	checkAddress("creditor", b.Creditor)
+	if b.Debtor != nil {
+		checkAddress("debtor", *b.Debtor)
+	}
+	if utf8.RuneCountInString(b.Message) > 140 {
+		add("the message is longer than 140 characters")
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("cannot create the QR-bill:\n  - %s", strings.Join(problems, "\n  - "))
+}
+
+// Payload is the text encoded in the QR code, following version 2.3 of the
+// Swiss Implementation Guidelines
+func (b *Bill) Payload() string {
+	fields := []string{"SPC", "0200", "1", b.Account}
+	fields = append(fields, addressFields(&b.Creditor)...)
+	fields = append(fields, addressFields(nil)...) // ultimate creditor, reserved
+	amount := ""
+	if b.Amount > 0 {
+		amount = b.Amount.String()
+	}
+	fields = append(fields, amount, b.Currency)
+	fields = append(fields, addressFields(b.Debtor)...)
+	fields = append(fields, b.ReferenceType, b.Reference, b.Message, "EPD")
+	return strings.Join(fields, "\r\n")
+}
+
+func addressFields(a *Address) []string {
+	if a == nil {
+		return make([]string, 7)
+	}
+	return []string{"S", a.Name, a.Street, a.Number, a.Postcode, a.City, a.Country}
+}
+
+// Code encodes the payload at error correction level M, as the standard
+// requires. The Swiss cross goes on top of it with DrawCross.
+func (b *Bill) Code() (*qr.Code, error) {
+	return qr.Encode([]byte(b.Payload()), qr.M)
+}
+
+// Image draws code with the Swiss cross and no border, scale pixels per module
+func Image(code *qr.Code, scale int) *image.Gray {
+	img := code.Image(scale, 0)
+	DrawCross(float64(code.Size*scale), func(x, y, w, h float64, dark bool) {
+		c := color.Gray{Y: 0xff}
+		if dark {
+			c = color.Gray{}
+		}
+		for py := int(math.Round(y)); py < int(math.Round(y+h)); py++ {
+			for px := int(math.Round(x)); px < int(math.Round(x+w)); px++ {
+				img.SetGray(px, py, c)
+			}
+		}
+	})
+	return img
+}
+
+// DrawCross draws the Swiss cross at the centre of a code size units wide.
+// The cross is 7 mm on the 46 mm code: a white frame, a black square and a
+// white cross in the proportions of the flag.
+func DrawCross(size float64, fill func(x, y, w, h float64, dark bool)) {
+	side := size * 7 / 46
+	frame := side / 14
+	square := side - 2*frame
+	arm, width := square*20/32, square*6/32
+
+	x := (size - side) / 2
+	fill(x, x, side, side, false)
+	fill(x+frame, x+frame, square, square, true)
+	c := size / 2
+	fill(c-width/2, c-arm/2, width, arm, false)
+	fill(c-arm/2, c-width/2, arm, width, false)
+}
+
+// IsQRIBAN reports whether an IBAN is a QR-IBAN, whose bank number (the
+// IID) is in the range 30000 to 31999
+func IsQRIBAN(account string) bool {
+	if len(account) < 9 {
+		return false
+	}
+	iid, err := strconv.Atoi(account[4:9])
+	return err == nil && iid >= 30000 && iid <= 31999
+}
+
+// QRReference derives a 27 digit QR reference from the digits of an
+// invoice number, e.g. INV-2025-0042 gives 000000000000000000202500423
+func QRReference(number string) (string, error) {
+	var digits strings.Builder
+	for _, c := range number {
+		if c >= '0' && c <= '9' {
+			digits.WriteRune(c)
+		}
+	}
+	if digits.Len() == 0 || digits.Len() > 26 {
+		return "", fmt.Errorf("invoice number '%s' needs 1 to 26 digits for a QR reference", number)
+	}
+	ref := strings.Repeat("0", 26-digits.Len()) + digits.String()
+	return ref + strconv.Itoa(mod10(ref)), nil
+}
+
+// mod10 is the recursive modulo 10 check digit of Swiss payment references
+func mod10(digits string) int {
+	table := [10]int{0, 9, 4, 6, 8, 2, 7, 1, 3, 5}
+	carry := 0
+	for _, c := range digits {
+		carry = table[(carry+int(c-'0'))%10]
+	}
+	return (10 - carry) % 10
+}
+
+// CreditorReference derives an ISO 11649 creditor reference from the
+// letters and digits of an invoice number, e.g. INV-2025-0042 gives
+// RF25INV20250042
+func CreditorReference(number string) (string, error) {
+	var body strings.Builder
+	for _, c := range strings.ToUpper(number) {
+		if (c >= '0' && c <= '9') || (c >= 'A' && c <= 'Z') {
+			body.WriteRune(c)
+		}
+	}
+	if body.Len() == 0 || body.Len() > 21 {
+		return "", fmt.Errorf("invoice number '%s' needs 1 to 21 letters and digits for a creditor reference", number)
+	}
+	check := 98 - iban.Mod97(body.String()+"RF00")
+	return fmt.Sprintf("RF%02d%s", check, body.String()), nil
+}
+
+// FormatAccount writes an IBAN in blocks of four characters
+func FormatAccount(account string) string {
+	return blocks(account, 4, 0)
+}
+
+// FormatReference writes a reference as printed on the slip: QR references
+// in blocks of five from the right after the first two digits, creditor
+// references in blocks of four
+func FormatReference(referenceType, ref string) string {
+	if referenceType == QRR {
+		return blocks(ref, 5, len(ref)%5)
+	}
+	return blocks(ref, 4, 0)
+}
+
+// blocks groups s with spaces, putting the first lead characters on their own
+func blocks(s string, size, lead int) string {
+	var parts []string
+	if lead > 0 {
+		parts = append(parts, s[:lead])
+		s = s[lead:]
+	}
+	for len(s) > size {
+		parts = append(parts, s[:size])
+		s = s[size:]
+	}
+	if s != "" {
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, " ")
+}
+
+// FormatAmount writes an amount with a space between thousands and a
+// decimal point, e.g. "1 234.50"
+func FormatAmount(a money.Amount) string {
+	return money.Currency{Decimals: 2, DecimalSep: ".", GroupSep: " "}.Number(a)
+}
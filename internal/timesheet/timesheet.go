@@ -0,0 +1,307 @@
+// Package timesheet reads the CSV and JSON exports of time trackers into
+// entries that can be billed
+package timesheet
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"simplebill/internal/config"
+	"simplebill/internal/money"
+)
+
+// Entry is one block of tracked time
+type Entry struct {
+	// Key identifies the entry across imports so it is billed only once:
+	// the source name and the id column, or a hash of the entry's details
+	Key      string
+	Line     int    // row of a CSV file or position in a JSON list
+	Date     string // YYYY-MM-DD
+	Project  string
+	Task     string
+	Customer string
+	Product  string
+	Duration time.Duration
+	Rate     *money.Amount // nil when the export has no rate for the entry
+}
+
+// Hours returns the duration as an exact number of hours
+func (e Entry) Hours() *big.Rat {
+	return big.NewRat(int64(e.Duration), int64(time.Hour))
+}
+
+// record looks up a column of one entry
+type record func(column string) string
+
+// Read reads the entries of a timesheet export. Files ending in .json hold a
+// list of objects, anything else is CSV with a header row. Entries without
+// any time are skipped.
+func Read(path, sourceName string, source config.TimesheetSource) ([]Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if sourceName == "" {
+		sourceName = "default"
+	}
+
+	columns := source.Columns
+	for _, c := range []struct {
+		name *string
+		def  string
+	}{
+		{&columns.Date, "date"}, {&columns.Duration, "duration"}, {&columns.Project, "project"},
+		{&columns.Task, "task"}, {&columns.Rate, "rate"}, {&columns.Product, "product"},
+		{&columns.Customer, "customer"}, {&columns.ID, "id"},
+	} {
+		if *c.name == "" {
+			*c.name = c.def
+		}
+	}
+
+	var records []record
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		records, err = readJSON(data)
+	} else {
+		records, err = readCSV(data, columns)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	layout := strings.NewReplacer("YYYY", "2006", "MM", "01", "DD", "02").Replace(source.DateFormat)
+	if layout == "" {
+		layout = "2006-01-02"
+	}
+	unit := time.Hour
+	switch source.DurationUnit {
+	case config.DurationMinutes:
+		unit = time.Minute
+	case config.DurationSeconds:
+		unit = time.Second
+	}
+
+	var entries []Entry
+	seen := map[string]int{}
+	for i, get := range records {
+		line := i + 1
+		if !strings.EqualFold(filepath.Ext(path), ".json") {
+			line++ // after the header row
+		}
+		e := Entry{
+			Line:     line,
+			Project:  get(columns.Project),
+			Task:     get(columns.Task),
+			Customer: get(columns.Customer),
+			Product:  get(columns.Product),
+		}
+
+		date := get(columns.Date)
+		if len(date) > len(layout) {
+			date = date[:len(layout)] // a timestamp, the time is not needed
+		}
+		day, err := time.Parse(layout, date)
+		if err != nil {
+			return nil, fmt.Errorf("%s entry %d: invalid date '%s', expected %s", path, line, get(columns.Date), dateFormat(source))
+		}
+		e.Date = day.Format("2006-01-02")
+
+		e.Duration, err = parseDuration(get(columns.Duration), unit)
+		if err != nil {
+			return nil, fmt.Errorf("%s entry %d: %w", path, line, err)
+		}
+		if e.Duration == 0 {
+			continue
+		}
+
+		if rate := get(columns.Rate); rate != "" {
+			amount, err := money.Parse(rate)
+			if err != nil || amount < 0 {
+				return nil, fmt.Errorf("%s entry %d: invalid rate '%s'", path, line, rate)
+			}
+			e.Rate = &amount
+		}
+
+		if id := get(columns.ID); id != "" {
+			e.Key = sourceName + ":" + id
+		} else {
+			e.Key = sourceName + ":" + e.hash()
+		}
+		// Identical entries, like two calls on the same day, are told apart
+		// by how often they occurred before
+		seen[e.Key]++
+		if n := seen[e.Key]; n > 1 {
+			e.Key += "-" + strconv.Itoa(n)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// hash identifies an entry by its details, for exports without ids
+func (e Entry) hash() string {
+	rate := ""
+	if e.Rate != nil {
+		rate = e.Rate.String()
+	}
+	sum := sha1.Sum([]byte(strings.Join([]string{
+		e.Date, e.Project, e.Task, e.Customer, e.Product, e.Duration.String(), rate,
+	}, "\x00")))
+	return hex.EncodeToString(sum[:6])
+}
+
+func dateFormat(source config.TimesheetSource) string {
+	if source.DateFormat == "" {
+		return "YYYY-MM-DD"
+	}
+	return source.DateFormat
+}
+
+// parseDuration reads 1:30, 1:30:00, 1h30m or a plain number of unit, with
+// a decimal point or comma
+func parseDuration(s string, unit time.Duration) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("no duration")
+	}
+
+	if strings.Contains(s, ":") {
+		parts := strings.Split(s, ":")
+		if len(parts) > 3 {
+			return 0, fmt.Errorf("invalid duration '%s', expected h:mm or h:mm:ss", s)
+		}
+		var d time.Duration
+		units := []time.Duration{time.Hour, time.Minute, time.Second}
+		for i, p := range parts {
+			n, err := strconv.Atoi(p)
+			if err != nil || n < 0 || (i > 0 && n > 59) {
+				return 0, fmt.Errorf("invalid duration '%s', expected h:mm or h:mm:ss", s)
+			}
+			d += time.Duration(n) * units[i]
+		}
+		return d, nil
+	}
+
+	if last := s[len(s)-1]; last == 'h' || last == 'm' || last == 's' {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return 0, fmt.Errorf("invalid duration '%s'", s)
+		}
+		return d, nil
+	}
+
+	if !strings.Contains(s, ".") {
+		s = strings.Replace(s, ",", ".", 1)
+	}
+	if _, err := strconv.ParseFloat(s, 64); err != nil {
+		return 0, fmt.Errorf("invalid duration '%s'", s)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || r.Sign() < 0 {
+		return 0, fmt.Errorf("invalid duration '%s'", s)
+	}
+	r.Mul(r, big.NewRat(int64(unit), 1))
+	return time.Duration(new(big.Int).Quo(r.Num(), r.Denom()).Int64()), nil
+}
+
+// readCSV reads rows by header name, ignoring case. Exports using
+// semicolons, as spreadsheets in many European locales write them, are
+// recognized by their header row.
+func readCSV(data []byte, columns config.TimesheetColumns) ([]record, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	header, _, _ := bytes.Cut(data, []byte("\n"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	if bytes.Count(header, []byte(";")) > bytes.Count(header, []byte(",")) {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("file is empty")
+	}
+
+	index := map[string]int{}
+	for i, name := range rows[0] {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{columns.Date, columns.Duration} {
+		if _, ok := index[strings.ToLower(required)]; !ok {
+			return nil, fmt.Errorf("no '%s' column, found: %s (map the columns under timesheets: in config.yml)",
+				required, strings.Join(rows[0], ", "))
+		}
+	}
+
+	var records []record
+	for _, row := range rows[1:] {
+		row := row
+		if len(row) == 1 && strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		records = append(records, func(column string) string {
+			i, ok := index[strings.ToLower(column)]
+			if !ok || i >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[i])
+		})
+	}
+	return records, nil
+}
+
+// readJSON reads a list of objects. Nested fields are named with dots, such
+// as project.name.
+func readJSON(data []byte) ([]record, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var list []map[string]interface{}
+	if err := decoder.Decode(&list); err != nil {
+		return nil, fmt.Errorf("expected a list of entries: %w", err)
+	}
+
+	var records []record
+	for _, object := range list {
+		fields := map[string]string{}
+		flatten("", object, fields)
+		records = append(records, func(column string) string {
+			return fields[strings.ToLower(column)]
+		})
+	}
+	return records, nil
+}
+
+func flatten(prefix string, value interface{}, fields map[string]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for name, field := range v {
+			flatten(prefix+strings.ToLower(name)+".", field, fields)
+		}
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		sort.Strings(values)
+		fields[strings.TrimSuffix(prefix, ".")] = strings.Join(values, ", ")
+	case nil:
+		fields[strings.TrimSuffix(prefix, ".")] = ""
+	default:
+		fields[strings.TrimSuffix(prefix, ".")] = strings.TrimSpace(fmt.Sprint(v))
+	}
+}
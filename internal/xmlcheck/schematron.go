@@ -0,0 +1,206 @@
+package xmlcheck
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+const schNS = "http://purl.oclc.org/dsdl/schematron"
+
+// Schematron is an ISO Schematron schema with XPath 1.0 rules. Abstract
+// patterns, variables and value-of in messages are not supported.
+type Schematron struct {
+	patterns map[string]*pattern
+	order    []string            // pattern ids as declared
+	phases   map[string][]string // active pattern ids by phase
+}
+
+type pattern struct {
+	id    string
+	rules []*rule
+}
+
+type rule struct {
+	context *XPath
+	checks  []*check
+}
+
+type check struct {
+	id     string
+	test   *XPath
+	report bool // fires when the test holds, where an assert fires when it doesn't
+	text   string
+}
+
+// LoadSchematron reads the schematron schema name from fsys
+func LoadSchematron(fsys fs.FS, name string) (*Schematron, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	root, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	errorf := func(e *Element, format string, args ...any) error {
+		return fmt.Errorf("%s line %d: %s", name, e.Line, fmt.Sprintf(format, args...))
+	}
+	if root.Space != schNS || root.Local != "schema" {
+		return nil, errorf(root, "not a schematron schema")
+	}
+	if binding, ok := root.Attr("queryBinding"); ok && binding != "xslt" && binding != "xpath" {
+		return nil, errorf(root, "query binding %s is not supported", binding)
+	}
+
+	s := &Schematron{patterns: map[string]*pattern{}, phases: map[string][]string{}}
+	ns := map[string]string{}
+	for _, e := range root.Children {
+		if e.Space == schNS && e.Local == "ns" {
+			prefix, _ := e.Attr("prefix")
+			uri, _ := e.Attr("uri")
+			ns[prefix] = uri
+		}
+	}
+
+	for _, e := range root.Children {
+		if e.Space != schNS {
+			return nil, errorf(e, "unexpected element %s", e.Name())
+		}
+		switch e.Local {
+		case "ns", "title", "p":
+		case "phase":
+			id, _ := e.Attr("id")
+			for _, a := range e.Children {
+				if a.Space != schNS || a.Local != "active" {
+					return nil, errorf(a, "%s is not supported in a phase", a.Name())
+				}
+				active, _ := a.Attr("pattern")
+				s.phases[id] = append(s.phases[id], active)
+			}
+		case "pattern":
+			p := &pattern{}
+			p.id, _ = e.Attr("id")
+			if _, abstract := e.Attr("abstract"); abstract || p.id == "" {
+				return nil, errorf(e, "patterns need an id and can't be abstract")
+			}
+			for _, r := range e.Children {
+				if r.Space != schNS || r.Local != "rule" {
+					return nil, errorf(r, "%s is not supported in a pattern", r.Name())
+				}
+				rl, err := readRule(r, ns, errorf)
+				if err != nil {
+					return nil, err
+				}
+				p.rules = append(p.rules, rl)
+			}
+			s.patterns[p.id] = p
+			s.order = append(s.order, p.id)
+		default:
+			return nil, errorf(e, "%s is not supported", e.Name())
+		}
+	}
+
+	for phase, ids := range s.phases {
+		for _, id := range ids {
+			if s.patterns[id] == nil {
+				return nil, fmt.Errorf("%s: phase %s has unknown pattern %s", name, phase, id)
+			}
+		}
+	}
+	return s, nil
+}
+
+func readRule(e *Element, ns map[string]string, errorf func(*Element, string, ...any) error) (*rule, error) {
+	context, ok := e.Attr("context")
+	if !ok {
+		return nil, errorf(e, "rule without context")
+	}
+	// A rule fires for the nodes its context matches anywhere in the document
+	if !strings.HasPrefix(context, "/") {
+		context = "//" + context
+	}
+	x, err := CompileXPath(context, ns)
+	if err != nil {
+		return nil, errorf(e, "%v", err)
+	}
+	r := &rule{context: x}
+	for _, c := range e.Children {
+		if c.Space != schNS || c.Local != "assert" && c.Local != "report" {
+			return nil, errorf(c, "%s is not supported in a rule", c.Name())
+		}
+		if len(c.Children) > 0 {
+			return nil, errorf(c, "only plain text messages are supported")
+		}
+		test, _ := c.Attr("test")
+		x, err := CompileXPath(test, ns)
+		if err != nil {
+			return nil, errorf(c, "%v", err)
+		}
+		id, _ := c.Attr("id")
+		r.checks = append(r.checks, &check{
+			id:     id,
+			test:   x,
+			report: c.Local == "report",
+			text:   strings.Join(strings.Fields(c.Text), " "),
+		})
+	}
+	return r, nil
+}
+
+// Validate checks data against the patterns active in phase, or every
+// pattern when phase is empty, and lists every failed assertion
+func (s *Schematron) Validate(data []byte, phase string) error {
+	root, err := Parse(data)
+	if err != nil {
+		return &Error{Problems: []string{err.Error()}}
+	}
+	ids := s.order
+	if phase != "" {
+		var ok bool
+		if ids, ok = s.phases[phase]; !ok {
+			return fmt.Errorf("unknown schematron phase %s", phase)
+		}
+	}
+
+	type problem struct {
+		at   node
+		text string
+	}
+	var problems []problem
+	doc := document(root)
+	for _, id := range ids {
+		// Each node is checked by the first rule of the pattern it matches
+		fired := map[node]bool{}
+		for _, r := range s.patterns[id].rules {
+			nodes, _ := r.context.expr.eval(&context{node: doc, pos: 1, size: 1, current: doc}).([]node)
+			for _, n := range nodes {
+				if fired[n] {
+					continue
+				}
+				fired[n] = true
+				for _, c := range r.checks {
+					holds := toBool(c.test.expr.eval(&context{node: n, pos: 1, size: 1, current: n}))
+					if holds == c.report {
+						text := c.text
+						if c.id != "" {
+							text = "[" + c.id + "] " + text
+						}
+						problems = append(problems, problem{n, text})
+					}
+				}
+			}
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].at.el.Line < problems[j].at.el.Line })
+	e := &Error{}
+	for _, p := range problems {
+		e.Problems = append(e.Problems, fmt.Sprintf("line %d: %s", p.at.el.Line, p.text))
+	}
+	return e
+}
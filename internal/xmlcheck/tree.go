@@ -0,0 +1,171 @@
+// Package xmlcheck validates XML documents offline against XSD schemas and
+// ISO Schematron rules. It supports the parts of both that the e-invoice
+// schemas bundled with simplebill use, and rejects schemas that need more.
+package xmlcheck
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Element is an element of a parsed document
+type Element struct {
+	Space    string // namespace URI
+	Local    string
+	Prefix   string // as written, for messages
+	Attrs    []Attr
+	Children []*Element
+	Text     string // character data directly inside the element
+	Parent   *Element
+	Line     int
+
+	ns    map[string]string // namespaces in scope, by prefix
+	order int               // position in document order
+}
+
+// Attr is an attribute. Namespace declarations are not kept as attributes.
+type Attr struct {
+	Space, Local, Value string
+}
+
+// Name returns the element's name as written, e.g. ram:ID
+func (e *Element) Name() string {
+	if e.Prefix == "" {
+		return e.Local
+	}
+	return e.Prefix + ":" + e.Local
+}
+
+// Attr returns the value of the unqualified attribute name
+func (e *Element) Attr(name string) (string, bool) {
+	for _, a := range e.Attrs {
+		if a.Space == "" && a.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// StringValue returns all character data inside the element, as XPath does
+func (e *Element) StringValue() string {
+	if len(e.Children) == 0 {
+		return e.Text
+	}
+	var b strings.Builder
+	b.WriteString(e.Text)
+	for _, c := range e.Children {
+		b.WriteString(c.StringValue())
+	}
+	return b.String()
+}
+
+// ResolveQName splits a prefixed name such as xs:string written in the
+// element's content or attributes, using the namespaces in scope
+func (e *Element) ResolveQName(qname string) (space, local string, err error) {
+	prefix, local, ok := strings.Cut(qname, ":")
+	if !ok {
+		prefix, local = "", qname
+	}
+	space, found := e.ns[prefix]
+	if !found && prefix != "" {
+		return "", "", fmt.Errorf("line %d: undeclared namespace prefix '%s'", e.Line, prefix)
+	}
+	return space, local, nil
+}
+
+// Parse reads a document into a tree of elements and returns the root
+func Parse(data []byte) (*Element, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var root, current *Element
+	order := 0
+	scope := map[string]string{"xml": "http://www.w3.org/XML/1998/namespace"}
+
+	for {
+		line, _ := d.InputPos()
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if current == nil && root != nil {
+				return nil, fmt.Errorf("line %d: more than one root element", line)
+			}
+			order++
+			e := &Element{Prefix: t.Name.Space, Local: t.Name.Local, Parent: current, Line: line, ns: scope, order: order}
+			if current != nil {
+				e.ns = current.ns
+			}
+			// Namespace declarations first, since attributes may use them
+			for _, a := range t.Attr {
+				switch {
+				case a.Name.Space == "xmlns":
+					e.ns = withNamespace(e.ns, a.Name.Local, a.Value)
+				case a.Name.Space == "" && a.Name.Local == "xmlns":
+					e.ns = withNamespace(e.ns, "", a.Value)
+				}
+			}
+			for _, a := range t.Attr {
+				if a.Name.Space == "xmlns" || a.Name.Space == "" && a.Name.Local == "xmlns" {
+					continue
+				}
+				attr := Attr{Local: a.Name.Local, Value: a.Value}
+				if a.Name.Space != "" {
+					space, ok := e.ns[a.Name.Space]
+					if !ok {
+						return nil, fmt.Errorf("line %d: undeclared namespace prefix '%s'", line, a.Name.Space)
+					}
+					attr.Space = space
+				}
+				e.Attrs = append(e.Attrs, attr)
+			}
+			space, ok := e.ns[e.Prefix]
+			if !ok && e.Prefix != "" {
+				return nil, fmt.Errorf("line %d: undeclared namespace prefix '%s'", line, e.Prefix)
+			}
+			e.Space = space
+
+			if current == nil {
+				root = e
+			} else {
+				current.Children = append(current.Children, e)
+			}
+			current = e
+		case xml.EndElement:
+			if current == nil || t.Name.Space != current.Prefix || t.Name.Local != current.Local {
+				return nil, fmt.Errorf("line %d: unexpected end element </%s>", line, t.Name.Local)
+			}
+			current = current.Parent
+		case xml.CharData:
+			if current != nil {
+				current.Text += string(t)
+			} else if len(bytes.TrimSpace(t)) > 0 {
+				return nil, fmt.Errorf("line %d: text outside the root element", line)
+			}
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("no root element")
+	}
+	if current != nil {
+		return nil, fmt.Errorf("element <%s> is not closed", current.Name())
+	}
+	return root, nil
+}
+
+// withNamespace returns scope with prefix bound to uri, leaving scope as it
+// is for the enclosing elements
+func withNamespace(scope map[string]string, prefix, uri string) map[string]string {
+	ns := make(map[string]string, len(scope)+1)
+	for k, v := range scope {
+		ns[k] = v
+	}
+	ns[prefix] = uri
+	return ns
+}
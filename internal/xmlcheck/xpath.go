@@ -0,0 +1,1002 @@
+package xmlcheck
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// XPath is a compiled XPath 1.0 expression. Variables and the namespace,
+// text, comment and processing-instruction node kinds are not supported.
+type XPath struct {
+	src  string
+	expr expr
+}
+
+// node is an element, an attribute of one, or the document node above the
+// root element
+type node struct {
+	el   *Element
+	attr int // index into el.Attrs, or -1 for the element itself
+}
+
+func (n node) isDocument() bool {
+	return n.attr < 0 && n.el.Local == "" && n.el.Parent == nil
+}
+
+func (n node) stringValue() string {
+	if n.attr >= 0 {
+		return n.el.Attrs[n.attr].Value
+	}
+	return n.el.StringValue()
+}
+
+// before reports whether n comes before o in document order
+func (n node) before(o node) bool {
+	if n.el.order != o.el.order {
+		return n.el.order < o.el.order
+	}
+	return n.attr < o.attr
+}
+
+// XPath values are a []node, string, float64 or bool
+type value any
+
+type context struct {
+	node      node
+	pos, size int
+	current   node // the node the rule fired for, see current()
+}
+
+type expr interface {
+	eval(c *context) value
+}
+
+// document wraps a parsed document in the document node paths start from
+func document(root *Element) node {
+	doc := &Element{Children: []*Element{root}}
+	root.Parent = doc
+	return node{el: doc, attr: -1}
+}
+
+// CompileXPath compiles expression with the namespace prefixes it uses
+func CompileXPath(expression string, namespaces map[string]string) (*XPath, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, fmt.Errorf("xpath '%s': %w", expression, err)
+	}
+	p := &xpathParser{tokens: tokens, ns: namespaces}
+	e, err := p.parseExpr()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected '%s'", p.tokens[p.pos].text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("xpath '%s': %w", expression, err)
+	}
+	return &XPath{src: expression, expr: e}, nil
+}
+
+func (x *XPath) String() string {
+	return x.src
+}
+
+// token kinds
+const (
+	tokName     = iota // a name test or function name, possibly prefixed
+	tokOperator        // and, or, div, mod, *, /, //, |, +, -, =, !=, <, <=, >, >=
+	tokLiteral
+	tokNumber
+	tokPunct // ( ) [ ] . .. @ , ::
+)
+
+type token struct {
+	kind int
+	text string
+}
+
+var numberPattern = regexp.MustCompile(`^(\d+(\.\d*)?|\.\d+)`)
+
+func isNameStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isNameChar(r rune) bool {
+	return isNameStart(r) || r == '-' || r == '.' || unicode.IsDigit(r)
+}
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	// An operator name or * is an operator unless it starts an expression
+	operatorAllowed := func() bool {
+		if len(tokens) == 0 {
+			return false
+		}
+		last := tokens[len(tokens)-1]
+		switch {
+		case last.kind == tokOperator:
+			return false
+		case last.kind == tokPunct:
+			return last.text == ")" || last.text == "]" || last.text == "." || last.text == ".."
+		}
+		return true
+	}
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		rest := string(runes[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			end := strings.IndexRune(string(runes[i+1:]), r)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			text := string(runes[i+1:])[:end]
+			tokens = append(tokens, token{tokLiteral, text})
+			i += 2 + len([]rune(text))
+		case numberPattern.MatchString(rest):
+			text := numberPattern.FindString(rest)
+			tokens = append(tokens, token{tokNumber, text})
+			i += len(text)
+		case strings.HasPrefix(rest, ".."):
+			tokens = append(tokens, token{tokPunct, ".."})
+			i += 2
+		case strings.HasPrefix(rest, "::"):
+			tokens = append(tokens, token{tokPunct, "::"})
+			i += 2
+		case strings.HasPrefix(rest, "//"), strings.HasPrefix(rest, "!="), strings.HasPrefix(rest, "<="), strings.HasPrefix(rest, ">="):
+			tokens = append(tokens, token{tokOperator, rest[:2]})
+			i += 2
+		case strings.ContainsRune("()[].@,", r):
+			tokens = append(tokens, token{tokPunct, string(r)})
+			i++
+		case r == '*':
+			if operatorAllowed() {
+				tokens = append(tokens, token{tokOperator, "*"})
+			} else {
+				tokens = append(tokens, token{tokName, "*"})
+			}
+			i++
+		case strings.ContainsRune("/|+-=<>", r):
+			tokens = append(tokens, token{tokOperator, string(r)})
+			i++
+		case isNameStart(r):
+			j := i + 1
+			for j < len(runes) && isNameChar(runes[j]) {
+				j++
+			}
+			name := string(runes[i:j])
+			// A prefixed name or prefix:*, but not an axis
+			if j+1 < len(runes) && runes[j] == ':' && runes[j+1] != ':' {
+				if runes[j+1] == '*' {
+					name += ":*"
+					j += 2
+				} else if isNameStart(runes[j+1]) {
+					k := j + 2
+					for k < len(runes) && isNameChar(runes[k]) {
+						k++
+					}
+					name = string(runes[i:k])
+					j = k
+				}
+			}
+			switch {
+			case operatorAllowed() && (name == "and" || name == "or" || name == "div" || name == "mod"):
+				tokens = append(tokens, token{tokOperator, name})
+			default:
+				tokens = append(tokens, token{tokName, name})
+			}
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected '%c'", r)
+		}
+	}
+	return tokens, nil
+}
+
+type xpathParser struct {
+	tokens []token
+	pos    int
+	ns     map[string]string
+}
+
+func (p *xpathParser) peek() (token, bool) {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos], true
+	}
+	return token{}, false
+}
+
+func (p *xpathParser) peekIs(kind int, text string) bool {
+	t, ok := p.peek()
+	return ok && t.kind == kind && t.text == text
+}
+
+func (p *xpathParser) expect(kind int, text string) error {
+	if !p.peekIs(kind, text) {
+		if t, ok := p.peek(); ok {
+			return fmt.Errorf("expected '%s', found '%s'", text, t.text)
+		}
+		return fmt.Errorf("expected '%s' at the end", text)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *xpathParser) parseExpr() (expr, error) {
+	return p.parseBinary(0)
+}
+
+// precedence lists the binary operators from the loosest binding
+var precedence = [][]string{
+	{"or"},
+	{"and"},
+	{"=", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "div", "mod"},
+}
+
+func (p *xpathParser) parseBinary(level int) (expr, error) {
+	if level == len(precedence) {
+		return p.parseUnary()
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind != tokOperator || !contains(precedence[level], t.text) {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: t.text, left: left, right: right}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *xpathParser) parseUnary() (expr, error) {
+	if p.peekIs(tokOperator, "-") {
+		p.pos++
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &negateExpr{e}, nil
+	}
+	left, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekIs(tokOperator, "|") {
+		p.pos++
+		right, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		left = &unionExpr{left, right}
+	}
+	return left, nil
+}
+
+// parsePath reads a location path, or a filter expression optionally
+// followed by a relative path
+func (p *xpathParser) parsePath() (expr, error) {
+	t, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("unexpected end")
+	}
+
+	filter := t.kind == tokLiteral || t.kind == tokNumber || t.kind == tokPunct && t.text == "("
+	if t.kind == tokName && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].kind == tokPunct && p.tokens[p.pos+1].text == "(" && t.text != "node" && t.text != "text" {
+		filter = true
+	}
+	if !filter {
+		return p.parseLocationPath()
+	}
+
+	primary, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	preds, err := p.parsePredicates()
+	if err != nil {
+		return nil, err
+	}
+	var e expr = primary
+	if len(preds) > 0 {
+		e = &filterExpr{primary, preds}
+	}
+	if p.peekIs(tokOperator, "/") || p.peekIs(tokOperator, "//") {
+		path := &pathExpr{start: e}
+		if err := p.parseSteps(path); err != nil {
+			return nil, err
+		}
+		return path, nil
+	}
+	return e, nil
+}
+
+func (p *xpathParser) parsePrimary() (expr, error) {
+	t, _ := p.peek()
+	p.pos++
+	switch t.kind {
+	case tokLiteral:
+		return literal{t.text}, nil
+	case tokNumber:
+		n, _ := strconv.ParseFloat(t.text, 64)
+		return literal{n}, nil
+	case tokPunct:
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(tokPunct, ")")
+	}
+
+	// A function call
+	fn, ok := functions[t.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s()", t.text)
+	}
+	if err := p.expect(tokPunct, "("); err != nil {
+		return nil, err
+	}
+	call := &callExpr{name: t.text, fn: fn}
+	for !p.peekIs(tokPunct, ")") {
+		if len(call.args) > 0 {
+			if err := p.expect(tokPunct, ","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+	}
+	p.pos++
+	if len(call.args) < fn.min || fn.max >= 0 && len(call.args) > fn.max {
+		return nil, fmt.Errorf("wrong number of arguments for %s()", t.text)
+	}
+	return call, nil
+}
+
+func (p *xpathParser) parsePredicates() ([]expr, error) {
+	var preds []expr
+	for p.peekIs(tokPunct, "[") {
+		p.pos++
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokPunct, "]"); err != nil {
+			return nil, err
+		}
+		preds = append(preds, e)
+	}
+	return preds, nil
+}
+
+func (p *xpathParser) parseLocationPath() (expr, error) {
+	path := &pathExpr{}
+	if p.peekIs(tokOperator, "/") || p.peekIs(tokOperator, "//") {
+		path.absolute = true
+		if p.peekIs(tokOperator, "/") {
+			// A lone / is the document node
+			if p.pos+1 >= len(p.tokens) || !p.startsStep(p.tokens[p.pos+1]) {
+				p.pos++
+				return path, nil
+			}
+		}
+		return path, p.parseSteps(path)
+	}
+	s, err := p.parseStep()
+	if err != nil {
+		return nil, err
+	}
+	path.steps = append(path.steps, s)
+	return path, p.parseSteps(path)
+}
+
+func (p *xpathParser) startsStep(t token) bool {
+	return t.kind == tokName || t.kind == tokPunct && (t.text == "." || t.text == ".." || t.text == "@")
+}
+
+// parseSteps reads the steps that follow / or //
+func (p *xpathParser) parseSteps(path *pathExpr) error {
+	for {
+		switch {
+		case p.peekIs(tokOperator, "/"):
+			p.pos++
+		case p.peekIs(tokOperator, "//"):
+			p.pos++
+			path.steps = append(path.steps, &step{axis: "descendant-or-self", test: nodeTest{any: true, kind: true}})
+		default:
+			return nil
+		}
+		s, err := p.parseStep()
+		if err != nil {
+			return err
+		}
+		path.steps = append(path.steps, s)
+	}
+}
+
+var axes = map[string]bool{
+	"child": true, "attribute": true, "self": true, "parent": true,
+	"descendant": true, "descendant-or-self": true,
+	"ancestor": true, "ancestor-or-self": true,
+	"following-sibling": true, "preceding-sibling": true,
+}
+
+func (p *xpathParser) parseStep() (*step, error) {
+	t, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("unexpected end of path")
+	}
+	if t.kind == tokPunct && t.text == "." {
+		p.pos++
+		return &step{axis: "self", test: nodeTest{any: true, kind: true}}, nil
+	}
+	if t.kind == tokPunct && t.text == ".." {
+		p.pos++
+		return &step{axis: "parent", test: nodeTest{any: true, kind: true}}, nil
+	}
+
+	s := &step{axis: "child"}
+	if t.kind == tokPunct && t.text == "@" {
+		s.axis = "attribute"
+		p.pos++
+	} else if t.kind == tokName && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].text == "::" {
+		if !axes[t.text] {
+			return nil, fmt.Errorf("axis %s is not supported", t.text)
+		}
+		s.axis = t.text
+		p.pos += 2
+	}
+
+	t, ok = p.peek()
+	if !ok || t.kind != tokName {
+		return nil, fmt.Errorf("expected a name test")
+	}
+	p.pos++
+	switch {
+	case t.text == "node" && p.peekIs(tokPunct, "("):
+		p.pos++
+		if err := p.expect(tokPunct, ")"); err != nil {
+			return nil, err
+		}
+		s.test = nodeTest{any: true, kind: true}
+	case t.text == "text" && p.peekIs(tokPunct, "("):
+		return nil, fmt.Errorf("text() is not supported, use the element's string value")
+	case t.text == "*":
+		s.test = nodeTest{any: true}
+	default:
+		prefix, local, prefixed := strings.Cut(t.text, ":")
+		if !prefixed {
+			prefix, local = "", t.text
+		}
+		space := ""
+		if prefixed {
+			var found bool
+			if space, found = p.ns[prefix]; !found {
+				return nil, fmt.Errorf("undeclared namespace prefix '%s'", prefix)
+			}
+		}
+		s.test = nodeTest{space: space, local: local, anyLocal: local == "*"}
+	}
+
+	preds, err := p.parsePredicates()
+	if err != nil {
+		return nil, err
+	}
+	s.preds = preds
+	return s, nil
+}
+
+// nodeTest matches nodes by name. kind matches every kind of node, for
+// node(); any matches every node of the axis' principal kind, for *.
+type nodeTest struct {
+	space, local string
+	anyLocal     bool
+	any, kind    bool
+}
+
+func (t nodeTest) matches(n node, attributeAxis bool) bool {
+	if t.kind {
+		return true
+	}
+	if attributeAxis != (n.attr >= 0) || n.isDocument() {
+		return false
+	}
+	if t.any {
+		return true
+	}
+	space, local := n.el.Space, n.el.Local
+	if n.attr >= 0 {
+		space, local = n.el.Attrs[n.attr].Space, n.el.Attrs[n.attr].Local
+	}
+	return space == t.space && (t.anyLocal || local == t.local)
+}
+
+type step struct {
+	axis  string
+	test  nodeTest
+	preds []expr
+}
+
+// axisNodes returns the nodes along the axis from n, nearest first
+func axisNodes(axis string, n node) []node {
+	var out []node
+	switch axis {
+	case "self":
+		out = append(out, n)
+	case "attribute":
+		if n.attr < 0 {
+			for i := range n.el.Attrs {
+				out = append(out, node{el: n.el, attr: i})
+			}
+		}
+	case "child":
+		if n.attr < 0 {
+			for _, c := range n.el.Children {
+				out = append(out, node{el: c, attr: -1})
+			}
+		}
+	case "descendant", "descendant-or-self":
+		if axis == "descendant-or-self" {
+			out = append(out, n)
+		}
+		if n.attr < 0 {
+			var walk func(e *Element)
+			walk = func(e *Element) {
+				for _, c := range e.Children {
+					out = append(out, node{el: c, attr: -1})
+					walk(c)
+				}
+			}
+			walk(n.el)
+		}
+	case "parent":
+		if n.attr >= 0 {
+			out = append(out, node{el: n.el, attr: -1})
+		} else if n.el.Parent != nil {
+			out = append(out, node{el: n.el.Parent, attr: -1})
+		}
+	case "ancestor", "ancestor-or-self":
+		if axis == "ancestor-or-self" {
+			out = append(out, n)
+		}
+		e := n.el
+		if n.attr >= 0 {
+			out = append(out, node{el: e, attr: -1})
+		}
+		for e = e.Parent; e != nil; e = e.Parent {
+			out = append(out, node{el: e, attr: -1})
+		}
+	case "following-sibling", "preceding-sibling":
+		if n.attr >= 0 || n.el.Parent == nil {
+			break
+		}
+		siblings := n.el.Parent.Children
+		i := 0
+		for siblings[i] != n.el {
+			i++
+		}
+		if axis == "following-sibling" {
+			for _, s := range siblings[i+1:] {
+				out = append(out, node{el: s, attr: -1})
+			}
+		} else {
+			for j := i - 1; j >= 0; j-- {
+				out = append(out, node{el: siblings[j], attr: -1})
+			}
+		}
+	}
+	return out
+}
+
+type pathExpr struct {
+	absolute bool
+	start    expr // a filter expression the path continues, or nil
+	steps    []*step
+}
+
+func (e *pathExpr) eval(c *context) value {
+	var nodes []node
+	switch {
+	case e.start != nil:
+		v, ok := e.start.eval(c).([]node)
+		if !ok {
+			return []node{}
+		}
+		nodes = v
+	case e.absolute:
+		doc := c.node.el
+		for doc.Parent != nil {
+			doc = doc.Parent
+		}
+		nodes = []node{{el: doc, attr: -1}}
+	default:
+		nodes = []node{c.node}
+	}
+
+	for _, s := range e.steps {
+		var next []node
+		for _, n := range nodes {
+			var matched []node
+			for _, m := range axisNodes(s.axis, n) {
+				if s.test.matches(m, s.axis == "attribute") {
+					matched = append(matched, m)
+				}
+			}
+			for _, pred := range s.preds {
+				matched = filter(matched, pred, c.current)
+			}
+			next = append(next, matched...)
+		}
+		nodes = sortNodes(next)
+	}
+	return nodes
+}
+
+// filter keeps the nodes for which pred holds, with positions counted in the
+// order given
+func filter(nodes []node, pred expr, current node) []node {
+	var out []node
+	for i, n := range nodes {
+		v := pred.eval(&context{node: n, pos: i + 1, size: len(nodes), current: current})
+		if f, ok := v.(float64); ok {
+			if f == float64(i+1) {
+				out = append(out, n)
+			}
+		} else if toBool(v) {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// sortNodes puts nodes in document order without duplicates
+func sortNodes(nodes []node) []node {
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].before(nodes[j]) })
+	out := nodes[:0]
+	for i, n := range nodes {
+		if i == 0 || n != nodes[i-1] {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+type filterExpr struct {
+	primary expr
+	preds   []expr
+}
+
+func (e *filterExpr) eval(c *context) value {
+	nodes, ok := e.primary.eval(c).([]node)
+	if !ok {
+		return []node{}
+	}
+	for _, pred := range e.preds {
+		nodes = filter(nodes, pred, c.current)
+	}
+	return nodes
+}
+
+type unionExpr struct {
+	left, right expr
+}
+
+func (e *unionExpr) eval(c *context) value {
+	left, _ := e.left.eval(c).([]node)
+	right, _ := e.right.eval(c).([]node)
+	return sortNodes(append(append([]node{}, left...), right...))
+}
+
+type literal struct {
+	v value
+}
+
+func (e literal) eval(*context) value {
+	return e.v
+}
+
+type negateExpr struct {
+	e expr
+}
+
+func (e *negateExpr) eval(c *context) value {
+	return -toNumber(e.e.eval(c))
+}
+
+type binaryExpr struct {
+	op          string
+	left, right expr
+}
+
+func (e *binaryExpr) eval(c *context) value {
+	switch e.op {
+	case "or":
+		return toBool(e.left.eval(c)) || toBool(e.right.eval(c))
+	case "and":
+		return toBool(e.left.eval(c)) && toBool(e.right.eval(c))
+	case "=", "!=", "<", "<=", ">", ">=":
+		return compare(e.op, e.left.eval(c), e.right.eval(c))
+	}
+	a, b := toNumber(e.left.eval(c)), toNumber(e.right.eval(c))
+	switch e.op {
+	case "+":
+		return a + b
+	case "-":
+		return a - b
+	case "*":
+		return a * b
+	case "div":
+		return a / b
+	}
+	return math.Mod(a, b)
+}
+
+// compare applies a comparison the XPath 1.0 way: a node-set compares true
+// when any of its nodes does
+func compare(op string, a, b value) bool {
+	if nodes, ok := a.([]node); ok {
+		for _, n := range nodes {
+			if compare(op, n.stringValue(), b) {
+				return true
+			}
+		}
+		return false
+	}
+	if nodes, ok := b.([]node); ok {
+		for _, n := range nodes {
+			if compareAtomic(op, a, n.stringValue()) {
+				return true
+			}
+		}
+		return false
+	}
+	return compareAtomic(op, a, b)
+}
+
+func compareAtomic(op string, a, b value) bool {
+	if op == "=" || op == "!=" {
+		var equal bool
+		_, aBool := a.(bool)
+		_, bBool := b.(bool)
+		_, aNum := a.(float64)
+		_, bNum := b.(float64)
+		switch {
+		case aBool || bBool:
+			equal = toBool(a) == toBool(b)
+		case aNum || bNum:
+			equal = toNumber(a) == toNumber(b)
+		default:
+			equal = toString(a) == toString(b)
+		}
+		return equal == (op == "=")
+	}
+	x, y := toNumber(a), toNumber(b)
+	switch op {
+	case "<":
+		return x < y
+	case "<=":
+		return x <= y
+	case ">":
+		return x > y
+	}
+	return x >= y
+}
+
+func toBool(v value) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case string:
+		return v != ""
+	case []node:
+		return len(v) > 0
+	}
+	return false
+}
+
+var xpathNumber = regexp.MustCompile(`^-?(\d+(\.\d*)?|\.\d+)$`)
+
+func toNumber(v value) float64 {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	case float64:
+		return v
+	case string:
+		s := strings.TrimSpace(v)
+		if !xpathNumber.MatchString(s) {
+			return math.NaN()
+		}
+		n, _ := strconv.ParseFloat(s, 64)
+		return n
+	case []node:
+		return toNumber(toString(v))
+	}
+	return math.NaN()
+}
+
+func toString(v value) string {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return "NaN"
+		case math.IsInf(v, 1):
+			return "Infinity"
+		case math.IsInf(v, -1):
+			return "-Infinity"
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	case []node:
+		if len(v) == 0 {
+			return ""
+		}
+		return v[0].stringValue()
+	}
+	return ""
+}
+
+type callExpr struct {
+	name string
+	fn   function
+	args []expr
+}
+
+func (e *callExpr) eval(c *context) value {
+	args := make([]value, len(e.args))
+	for i, a := range e.args {
+		args[i] = a.eval(c)
+	}
+	return e.fn.call(c, args)
+}
+
+// function is an XPath function taking min to max arguments, max -1 for any
+type function struct {
+	min, max int
+	call     func(c *context, args []value) value
+}
+
+// arg returns the argument, or the context node when it's left out
+func arg(c *context, args []value) value {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return []node{c.node}
+}
+
+var functions = map[string]function{
+	"last":     {0, 0, func(c *context, _ []value) value { return float64(c.size) }},
+	"position": {0, 0, func(c *context, _ []value) value { return float64(c.pos) }},
+	"current":  {0, 0, func(c *context, _ []value) value { return []node{c.current} }},
+	"count": {1, 1, func(_ *context, args []value) value {
+		nodes, _ := args[0].([]node)
+		return float64(len(nodes))
+	}},
+	"sum": {1, 1, func(_ *context, args []value) value {
+		nodes, _ := args[0].([]node)
+		total := 0.0
+		for _, n := range nodes {
+			total += toNumber(n.stringValue())
+		}
+		return total
+	}},
+	"local-name": {0, 1, func(c *context, args []value) value {
+		nodes, _ := arg(c, args).([]node)
+		if len(nodes) == 0 || nodes[0].isDocument() {
+			return ""
+		}
+		if nodes[0].attr >= 0 {
+			return nodes[0].el.Attrs[nodes[0].attr].Local
+		}
+		return nodes[0].el.Local
+	}},
+	"string":          {0, 1, func(c *context, args []value) value { return toString(arg(c, args)) }},
+	"number":          {0, 1, func(c *context, args []value) value { return toNumber(arg(c, args)) }},
+	"boolean":         {1, 1, func(_ *context, args []value) value { return toBool(args[0]) }},
+	"not":             {1, 1, func(_ *context, args []value) value { return !toBool(args[0]) }},
+	"true":            {0, 0, func(*context, []value) value { return true }},
+	"false":           {0, 0, func(*context, []value) value { return false }},
+	"floor":           {1, 1, func(_ *context, args []value) value { return math.Floor(toNumber(args[0])) }},
+	"ceiling":         {1, 1, func(_ *context, args []value) value { return math.Ceil(toNumber(args[0])) }},
+	"round":           {1, 1, func(_ *context, args []value) value { return math.Floor(toNumber(args[0]) + 0.5) }},
+	"string-length":   {0, 1, func(c *context, args []value) value { return float64(len([]rune(toString(arg(c, args))))) }},
+	"normalize-space": {0, 1, func(c *context, args []value) value { return strings.Join(strings.Fields(toString(arg(c, args))), " ") }},
+	"concat": {2, -1, func(_ *context, args []value) value {
+		var b strings.Builder
+		for _, a := range args {
+			b.WriteString(toString(a))
+		}
+		return b.String()
+	}},
+	"contains": {2, 2, func(_ *context, args []value) value {
+		return strings.Contains(toString(args[0]), toString(args[1]))
+	}},
+	"starts-with": {2, 2, func(_ *context, args []value) value {
+		return strings.HasPrefix(toString(args[0]), toString(args[1]))
+	}},
+	"substring-before": {2, 2, func(_ *context, args []value) value {
+		before, _, found := strings.Cut(toString(args[0]), toString(args[1]))
+		if !found {
+			return ""
+		}
+		return before
+	}},
+	"substring-after": {2, 2, func(_ *context, args []value) value {
+		_, after, _ := strings.Cut(toString(args[0]), toString(args[1]))
+		return after
+	}},
+	"substring": {2, 3, func(_ *context, args []value) value {
+		s := []rune(toString(args[0]))
+		// Positions count from 1 and are rounded, as XPath specifies
+		from := math.Floor(toNumber(args[1]) + 0.5)
+		to := math.Inf(1)
+		if len(args) == 3 {
+			to = from + math.Floor(toNumber(args[2])+0.5)
+		}
+		var b strings.Builder
+		for i, r := range s {
+			if p := float64(i + 1); p >= from && p < to {
+				b.WriteRune(r)
+			}
+		}
+		return b.String()
+	}},
+	"translate": {3, 3, func(_ *context, args []value) value {
+		from, to := []rune(toString(args[1])), []rune(toString(args[2]))
+		var b strings.Builder
+	runes:
+		for _, r := range toString(args[0]) {
+			for i, f := range from {
+				if f == r {
+					// Characters without a replacement are removed
+					if i < len(to) {
+						b.WriteRune(to[i])
+					}
+					continue runes
+				}
+			}
+			b.WriteRune(r)
+		}
+		return b.String()
+	}},
+}
@@ -0,0 +1,48 @@
+package xmlcheck
+
+import "testing"
+
+func TestXPath(t *testing.T) {
+	root, err := Parse([]byte(`<a:doc xmlns:a="urn:a">
+  <a:line id="1"><a:net>10.50</a:net><a:cat>S</a:cat></a:line>
+  <a:line id="2"><a:net>4</a:net><a:cat>Z</a:cat></a:line>
+  <a:total>14.50</a:total>
+</a:doc>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := document(root)
+	ns := map[string]string{"a": "urn:a"}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"count(//a:line)", "2"},
+		{"sum(/a:doc/a:line/a:net)", "14.5"},
+		{"/a:doc/a:total = sum(//a:net)", "true"},
+		{"//a:line[a:cat = 'Z']/@id", "2"},
+		{"//a:line[2]/a:net", "4"},
+		{"//a:line[last()]/@id * 2 div 4", "1"},
+		{"//a:net[. > 5]/../a:cat", "S"},
+		{"not(//a:line[a:cat = 'E']) and //a:total", "true"},
+		{"concat(substring-before('10.50', '.'), '-', translate('abc', 'b', 'B'))", "10-aBc"},
+		{"string-length(substring-after(//a:net, '.')) <= 2", "true"},
+		{"contains(' S Z E ', concat(' ', normalize-space(//a:line[2]/a:cat), ' '))", "true"},
+		{"round(2.5) + floor(-1.5) - ceiling(0.2) mod 2", "0"},
+	}
+	for _, tt := range tests {
+		x, err := CompileXPath(tt.expr, ns)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if got := toString(x.expr.eval(&context{node: doc, pos: 1, size: 1, current: doc})); got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+
+	if _, err := CompileXPath("b:line", ns); err == nil {
+		t.Error("undeclared prefix compiled")
+	}
+}
@@ -0,0 +1,849 @@
+package xmlcheck
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	xsNS  = "http://www.w3.org/2001/XMLSchema"
+	xsiNS = "http://www.w3.org/2001/XMLSchema-instance"
+)
+
+// Error lists every problem found in a document
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return strings.Join(e.Problems, "\n")
+}
+
+type qname struct {
+	space, local string
+}
+
+// Schema is a set of XSD schema documents loaded together, one per target
+// namespace, with the imports and includes between them
+type Schema struct {
+	fsys     fs.FS
+	loaded   map[string]bool
+	elements map[qname]*elementDecl
+	complex  map[qname]*complexType
+	simple   map[qname]*simpleType
+	decls    []*elementDecl // every declaration, to resolve types once loaded
+	simples  []*simpleType
+	complexs []*complexType
+}
+
+type elementDecl struct {
+	name     qname
+	ref      qname // set for <xs:element ref="...">
+	typeName qname
+	complex  *complexType
+	simple   *simpleType
+	where    string
+}
+
+// particle kinds
+const (
+	partElement = iota
+	partSequence
+	partChoice
+)
+
+type particle struct {
+	kind     int
+	elem     *elementDecl
+	items    []*particle
+	min, max int // max is -1 for unbounded
+}
+
+type complexType struct {
+	content  *particle // nil for empty or simple content
+	simple   *simpleType
+	baseName qname // simpleContent extension base
+	attrs    []*attrDecl
+	where    string
+}
+
+type attrDecl struct {
+	name     string
+	typeName qname
+	typ      *simpleType
+	required bool
+}
+
+type simpleType struct {
+	builtin  string // local name of an XSD built-in type
+	baseName qname
+	base     *simpleType
+	enums    []string
+	patterns []*regexp.Regexp
+	length   int // facets are -1 when not set
+	minLen   int
+	maxLen   int
+	fraction int
+	where    string
+}
+
+// builtins are the XSD built-in types supported, with the pattern their
+// values must match after whitespace is collapsed
+var builtins = map[string]*regexp.Regexp{
+	"string":           nil,
+	"normalizedString": nil,
+	"token":            nil,
+	"anyURI":           nil,
+	"base64Binary":     regexp.MustCompile(`^[A-Za-z0-9+/= ]*$`),
+	"decimal":          regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)$`),
+	"integer":          regexp.MustCompile(`^[+-]?\d+$`),
+	"boolean":          regexp.MustCompile(`^(true|false|1|0)$`),
+	"date":             regexp.MustCompile(`^-?\d{4,}-\d{2}-\d{2}(Z|[+-]\d{2}:\d{2})?$`),
+}
+
+// LoadSchema reads the schema document name from fsys together with every
+// schema it imports or includes
+func LoadSchema(fsys fs.FS, name string) (*Schema, error) {
+	s := &Schema{
+		fsys:     fsys,
+		loaded:   map[string]bool{},
+		elements: map[qname]*elementDecl{},
+		complex:  map[qname]*complexType{},
+		simple:   map[qname]*simpleType{},
+	}
+	for local := range builtins {
+		s.simple[qname{xsNS, local}] = &simpleType{builtin: local, length: -1, minLen: -1, maxLen: -1, fraction: -1}
+	}
+	if err := s.load(name); err != nil {
+		return nil, err
+	}
+	if err := s.resolve(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// schemaReader reads the declarations of one schema document
+type schemaReader struct {
+	s      *Schema
+	file   string
+	target string
+}
+
+func (r *schemaReader) errorf(e *Element, format string, args ...any) error {
+	return fmt.Errorf("%s line %d: %s", r.file, e.Line, fmt.Sprintf(format, args...))
+}
+
+func (r *schemaReader) where(e *Element) string {
+	return fmt.Sprintf("%s line %d", r.file, e.Line)
+}
+
+func (s *Schema) load(name string) error {
+	if s.loaded[name] {
+		return nil
+	}
+	s.loaded[name] = true
+
+	data, err := fs.ReadFile(s.fsys, name)
+	if err != nil {
+		return err
+	}
+	root, err := Parse(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	r := &schemaReader{s: s, file: name}
+	if root.Space != xsNS || root.Local != "schema" {
+		return r.errorf(root, "not an XSD schema")
+	}
+	r.target, _ = root.Attr("targetNamespace")
+	if form, _ := root.Attr("elementFormDefault"); form != "qualified" {
+		return r.errorf(root, "only elementFormDefault=\"qualified\" is supported")
+	}
+
+	for _, e := range root.Children {
+		if e.Space != xsNS {
+			return r.errorf(e, "unexpected element %s", e.Name())
+		}
+		switch e.Local {
+		case "annotation":
+		case "import", "include":
+			location, ok := e.Attr("schemaLocation")
+			if !ok {
+				return r.errorf(e, "%s without schemaLocation", e.Name())
+			}
+			if err := s.load(path.Join(path.Dir(name), location)); err != nil {
+				return err
+			}
+		case "element":
+			decl, err := r.element(e)
+			if err != nil {
+				return err
+			}
+			s.elements[decl.name] = decl
+		case "complexType":
+			name, _ := e.Attr("name")
+			ct, err := r.complexType(e)
+			if err != nil {
+				return err
+			}
+			s.complex[qname{r.target, name}] = ct
+		case "simpleType":
+			name, _ := e.Attr("name")
+			st, err := r.simpleType(e)
+			if err != nil {
+				return err
+			}
+			s.simple[qname{r.target, name}] = st
+		default:
+			return r.errorf(e, "%s is not supported", e.Name())
+		}
+	}
+	return nil
+}
+
+// qnameAttr reads an attribute holding a prefixed name
+func (r *schemaReader) qnameAttr(e *Element, attr string) (qname, bool, error) {
+	value, ok := e.Attr(attr)
+	if !ok {
+		return qname{}, false, nil
+	}
+	space, local, err := e.ResolveQName(strings.TrimSpace(value))
+	if err != nil {
+		return qname{}, false, fmt.Errorf("%s: %w", r.file, err)
+	}
+	return qname{space, local}, true, nil
+}
+
+func (r *schemaReader) element(e *Element) (*elementDecl, error) {
+	decl := &elementDecl{where: r.where(e)}
+	ref, isRef, err := r.qnameAttr(e, "ref")
+	if err != nil {
+		return nil, err
+	}
+	if isRef {
+		decl.ref = ref
+		r.s.decls = append(r.s.decls, decl)
+		return decl, nil
+	}
+	name, ok := e.Attr("name")
+	if !ok {
+		return nil, r.errorf(e, "element without name or ref")
+	}
+	decl.name = qname{r.target, name}
+	if decl.typeName, _, err = r.qnameAttr(e, "type"); err != nil {
+		return nil, err
+	}
+	for _, c := range e.Children {
+		switch {
+		case c.Space == xsNS && c.Local == "annotation":
+		case c.Space == xsNS && c.Local == "complexType":
+			if decl.complex, err = r.complexType(c); err != nil {
+				return nil, err
+			}
+		case c.Space == xsNS && c.Local == "simpleType":
+			if decl.simple, err = r.simpleType(c); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, r.errorf(c, "%s is not supported in an element", c.Name())
+		}
+	}
+	if decl.typeName == (qname{}) && decl.complex == nil && decl.simple == nil {
+		return nil, r.errorf(e, "element %s has no type", name)
+	}
+	r.s.decls = append(r.s.decls, decl)
+	return decl, nil
+}
+
+// occurs reads minOccurs and maxOccurs
+func (r *schemaReader) occurs(e *Element) (int, int, error) {
+	min, max := 1, 1
+	if v, ok := e.Attr("minOccurs"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, r.errorf(e, "invalid minOccurs '%s'", v)
+		}
+		min = n
+	}
+	if v, ok := e.Attr("maxOccurs"); ok {
+		if v == "unbounded" {
+			max = -1
+		} else {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < min {
+				return 0, 0, r.errorf(e, "invalid maxOccurs '%s'", v)
+			}
+			max = n
+		}
+	}
+	return min, max, nil
+}
+
+func (r *schemaReader) particle(e *Element) (*particle, error) {
+	if e.Space != xsNS {
+		return nil, r.errorf(e, "unexpected element %s", e.Name())
+	}
+	p := &particle{}
+	var err error
+	if p.min, p.max, err = r.occurs(e); err != nil {
+		return nil, err
+	}
+	switch e.Local {
+	case "element":
+		p.kind = partElement
+		if p.elem, err = r.element(e); err != nil {
+			return nil, err
+		}
+		return p, nil
+	case "sequence":
+		p.kind = partSequence
+	case "choice":
+		p.kind = partChoice
+	default:
+		return nil, r.errorf(e, "%s is not supported in a content model", e.Name())
+	}
+	for _, c := range e.Children {
+		if c.Space == xsNS && c.Local == "annotation" {
+			continue
+		}
+		item, err := r.particle(c)
+		if err != nil {
+			return nil, err
+		}
+		p.items = append(p.items, item)
+	}
+	return p, nil
+}
+
+func (r *schemaReader) complexType(e *Element) (*complexType, error) {
+	ct := &complexType{where: r.where(e)}
+	if mixed, _ := e.Attr("mixed"); mixed == "true" {
+		return nil, r.errorf(e, "mixed content is not supported")
+	}
+	for _, c := range e.Children {
+		if c.Space != xsNS {
+			return nil, r.errorf(c, "unexpected element %s", c.Name())
+		}
+		switch c.Local {
+		case "annotation":
+		case "sequence", "choice":
+			if ct.content != nil {
+				return nil, r.errorf(c, "more than one content model")
+			}
+			p, err := r.particle(c)
+			if err != nil {
+				return nil, err
+			}
+			ct.content = p
+		case "attribute":
+			a, err := r.attribute(c)
+			if err != nil {
+				return nil, err
+			}
+			ct.attrs = append(ct.attrs, a)
+		case "simpleContent":
+			if err := r.simpleContent(c, ct); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, r.errorf(c, "%s is not supported in a complex type", c.Name())
+		}
+	}
+	r.s.complexs = append(r.s.complexs, ct)
+	return ct, nil
+}
+
+// simpleContent reads an extension of a simple type with attributes
+func (r *schemaReader) simpleContent(e *Element, ct *complexType) error {
+	var ext *Element
+	for _, c := range e.Children {
+		switch {
+		case c.Space == xsNS && c.Local == "annotation":
+		case c.Space == xsNS && c.Local == "extension" && ext == nil:
+			ext = c
+		default:
+			return r.errorf(c, "%s is not supported in simple content", c.Name())
+		}
+	}
+	if ext == nil {
+		return r.errorf(e, "simple content without an extension")
+	}
+	base, ok, err := r.qnameAttr(ext, "base")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return r.errorf(ext, "extension without base")
+	}
+	ct.baseName = base
+	for _, c := range ext.Children {
+		switch {
+		case c.Space == xsNS && c.Local == "annotation":
+		case c.Space == xsNS && c.Local == "attribute":
+			a, err := r.attribute(c)
+			if err != nil {
+				return err
+			}
+			ct.attrs = append(ct.attrs, a)
+		default:
+			return r.errorf(c, "%s is not supported in an extension", c.Name())
+		}
+	}
+	return nil
+}
+
+func (r *schemaReader) attribute(e *Element) (*attrDecl, error) {
+	name, ok := e.Attr("name")
+	if !ok {
+		return nil, r.errorf(e, "attribute without name")
+	}
+	a := &attrDecl{name: name}
+	use, _ := e.Attr("use")
+	switch use {
+	case "", "optional":
+	case "required":
+		a.required = true
+	default:
+		return nil, r.errorf(e, "attribute use '%s' is not supported", use)
+	}
+	var err error
+	if a.typeName, ok, err = r.qnameAttr(e, "type"); err != nil {
+		return nil, err
+	}
+	if !ok {
+		a.typeName = qname{xsNS, "string"}
+	}
+	for _, c := range e.Children {
+		switch {
+		case c.Space == xsNS && c.Local == "annotation":
+		case c.Space == xsNS && c.Local == "simpleType":
+			if a.typ, err = r.simpleType(c); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, r.errorf(c, "%s is not supported in an attribute", c.Name())
+		}
+	}
+	return a, nil
+}
+
+func (r *schemaReader) simpleType(e *Element) (*simpleType, error) {
+	st := &simpleType{length: -1, minLen: -1, maxLen: -1, fraction: -1, where: r.where(e)}
+	var restriction *Element
+	for _, c := range e.Children {
+		switch {
+		case c.Space == xsNS && c.Local == "annotation":
+		case c.Space == xsNS && c.Local == "restriction" && restriction == nil:
+			restriction = c
+		default:
+			return nil, r.errorf(c, "%s is not supported in a simple type", c.Name())
+		}
+	}
+	if restriction == nil {
+		return nil, r.errorf(e, "simple type without a restriction")
+	}
+	base, ok, err := r.qnameAttr(restriction, "base")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, r.errorf(restriction, "restriction without base")
+	}
+	st.baseName = base
+
+	for _, f := range restriction.Children {
+		if f.Space != xsNS {
+			return nil, r.errorf(f, "unexpected element %s", f.Name())
+		}
+		value, _ := f.Attr("value")
+		number := func() (int, error) {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return 0, r.errorf(f, "invalid %s '%s'", f.Local, value)
+			}
+			return n, nil
+		}
+		switch f.Local {
+		case "annotation":
+		case "enumeration":
+			st.enums = append(st.enums, value)
+		case "pattern":
+			re, err := regexp.Compile("^(?:" + value + ")$")
+			if err != nil {
+				return nil, r.errorf(f, "pattern '%s' is not supported: %s", value, err)
+			}
+			st.patterns = append(st.patterns, re)
+		case "length":
+			if st.length, err = number(); err != nil {
+				return nil, err
+			}
+		case "minLength":
+			if st.minLen, err = number(); err != nil {
+				return nil, err
+			}
+		case "maxLength":
+			if st.maxLen, err = number(); err != nil {
+				return nil, err
+			}
+		case "fractionDigits":
+			if st.fraction, err = number(); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, r.errorf(f, "facet %s is not supported", f.Name())
+		}
+	}
+	r.s.simples = append(r.s.simples, st)
+	return st, nil
+}
+
+// resolve links the type names used in the schemas to their definitions
+func (s *Schema) resolve() error {
+	for _, st := range s.simples {
+		base, ok := s.simple[st.baseName]
+		if !ok {
+			return fmt.Errorf("%s: unknown simple type %s", st.where, st.baseName.local)
+		}
+		st.base = base
+	}
+	for _, ct := range s.complexs {
+		if ct.baseName == (qname{}) {
+			continue
+		}
+		if st, ok := s.simple[ct.baseName]; ok {
+			ct.simple = st
+		} else if base, ok := s.complex[ct.baseName]; ok && base.content == nil && base.baseName != (qname{}) {
+			// An extension of another type with simple content
+			ct.baseName = base.baseName
+			ct.attrs = append(append([]*attrDecl{}, base.attrs...), ct.attrs...)
+			ct.simple = base.simple
+			if ct.simple == nil {
+				ct.simple = s.simple[base.baseName]
+			}
+		} else {
+			return fmt.Errorf("%s: unknown base type %s for simple content", ct.where, ct.baseName.local)
+		}
+	}
+	for _, ct := range s.complexs {
+		for _, a := range ct.attrs {
+			if a.typ == nil {
+				st, ok := s.simple[a.typeName]
+				if !ok {
+					return fmt.Errorf("%s: unknown attribute type %s", ct.where, a.typeName.local)
+				}
+				a.typ = st
+			}
+		}
+	}
+	for _, decl := range s.decls {
+		if decl.complex != nil || decl.simple != nil || decl.ref != (qname{}) {
+			continue
+		}
+		if ct, ok := s.complex[decl.typeName]; ok {
+			decl.complex = ct
+		} else if st, ok := s.simple[decl.typeName]; ok {
+			decl.simple = st
+		} else {
+			return fmt.Errorf("%s: unknown type %s", decl.where, decl.typeName.local)
+		}
+	}
+	// References last, once the global declarations have their types
+	for _, decl := range s.decls {
+		if decl.ref != (qname{}) {
+			global, ok := s.elements[decl.ref]
+			if !ok {
+				return fmt.Errorf("%s: unknown element %s", decl.where, decl.ref.local)
+			}
+			decl.name, decl.typeName, decl.complex, decl.simple = global.name, global.typeName, global.complex, global.simple
+		}
+	}
+	return nil
+}
+
+// Validate checks data against the schema and lists every problem found
+func (s *Schema) Validate(data []byte) error {
+	root, err := Parse(data)
+	if err != nil {
+		return &Error{Problems: []string{err.Error()}}
+	}
+	v := &validator{}
+	decl, ok := s.elements[qname{root.Space, root.Local}]
+	if !ok {
+		v.problemf(root, "root element %s is not declared in the schema", root.Name())
+	} else {
+		v.element(root, decl)
+	}
+	if len(v.problems) > 0 {
+		return &Error{Problems: v.problems}
+	}
+	return nil
+}
+
+type validator struct {
+	problems []string
+	want     map[int][]qname // elements that would have fit at each position
+}
+
+func (v *validator) problemf(e *Element, format string, args ...any) {
+	v.problems = append(v.problems, fmt.Sprintf("line %d: %s: %s", e.Line, e.Name(), fmt.Sprintf(format, args...)))
+}
+
+// display names q with the prefix bound to its namespace at e
+func display(q qname, e *Element) string {
+	for prefix, uri := range e.ns {
+		if uri == q.space && prefix != "" {
+			return prefix + ":" + q.local
+		}
+	}
+	return q.local
+}
+
+func (v *validator) element(e *Element, decl *elementDecl) {
+	if decl.simple != nil {
+		v.attributes(e, nil)
+		if len(e.Children) > 0 {
+			v.problemf(e, "element %s is not allowed in it", e.Children[0].Name())
+			return
+		}
+		v.value(e, e.Text, decl.simple)
+		return
+	}
+
+	ct := decl.complex
+	v.attributes(e, ct.attrs)
+	if ct.simple != nil {
+		if len(e.Children) > 0 {
+			v.problemf(e, "element %s is not allowed in it", e.Children[0].Name())
+			return
+		}
+		v.value(e, e.Text, ct.simple)
+		return
+	}
+	if strings.TrimSpace(e.Text) != "" {
+		v.problemf(e, "text is not allowed in it")
+	}
+	if ct.content == nil {
+		if len(e.Children) > 0 {
+			v.problemf(e, "element %s is not allowed in it", e.Children[0].Name())
+		}
+		return
+	}
+
+	v.want = map[int][]qname{}
+	furthest := 0
+	var decls []*elementDecl
+	matched := false
+	for _, m := range v.match(ct.content, e.Children, 0) {
+		if m.end > furthest {
+			furthest = m.end
+		}
+		if m.end == len(e.Children) {
+			decls, matched = m.decls, true
+			break
+		}
+	}
+	if !matched {
+		var names []string
+		seen := map[string]bool{}
+		for _, q := range v.want[furthest] {
+			if name := display(q, e); !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		switch {
+		case furthest < len(e.Children) && len(names) > 0:
+			v.problemf(e.Children[furthest], "not expected here, expected %s", strings.Join(names, " or "))
+		case furthest < len(e.Children):
+			v.problemf(e.Children[furthest], "not expected here")
+		default:
+			v.problemf(e, "missing %s", strings.Join(names, " or "))
+		}
+		return
+	}
+	for i, c := range e.Children {
+		v.element(c, decls[i])
+	}
+}
+
+// match is a way to match a particle: the position after it and the
+// declarations of the elements it matched
+type match struct {
+	end   int
+	decls []*elementDecl
+}
+
+// match returns the ways p can match children from position i on, each end
+// position once
+func (v *validator) match(p *particle, children []*Element, i int) []match {
+	var results []match
+	states := []match{{end: i}}
+	if p.min == 0 {
+		results = append(results, states[0])
+	}
+	for n := 1; p.max < 0 || n <= p.max; n++ {
+		var next []match
+		for _, s := range states {
+			for _, m := range v.matchOnce(p, children, s.end) {
+				if m.end == s.end {
+					// Matching nothing fills any occurrences still required,
+					// and repeating it won't get any further
+					results = append(results, s)
+					continue
+				}
+				next = append(next, match{end: m.end, decls: append(append([]*elementDecl{}, s.decls...), m.decls...)})
+			}
+		}
+		next = distinct(next)
+		if n >= p.min {
+			results = append(results, next...)
+		}
+		if len(next) == 0 {
+			break
+		}
+		states = next
+	}
+	return distinct(results)
+}
+
+func (v *validator) matchOnce(p *particle, children []*Element, i int) []match {
+	switch p.kind {
+	case partElement:
+		if i < len(children) && children[i].Space == p.elem.name.space && children[i].Local == p.elem.name.local {
+			return []match{{end: i + 1, decls: []*elementDecl{p.elem}}}
+		}
+		v.want[i] = append(v.want[i], p.elem.name)
+		return nil
+	case partSequence:
+		states := []match{{end: i}}
+		for _, item := range p.items {
+			var next []match
+			for _, s := range states {
+				for _, m := range v.match(item, children, s.end) {
+					next = append(next, match{end: m.end, decls: append(append([]*elementDecl{}, s.decls...), m.decls...)})
+				}
+			}
+			states = distinct(next)
+			if len(states) == 0 {
+				return nil
+			}
+		}
+		return states
+	default:
+		var results []match
+		for _, item := range p.items {
+			results = append(results, v.match(item, children, i)...)
+		}
+		return distinct(results)
+	}
+}
+
+// distinct keeps the first match for each end position
+func distinct(matches []match) []match {
+	seen := map[int]bool{}
+	var out []match
+	for _, m := range matches {
+		if !seen[m.end] {
+			seen[m.end] = true
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func (v *validator) attributes(e *Element, decls []*attrDecl) {
+	for _, a := range e.Attrs {
+		if a.Space == xsiNS {
+			continue
+		}
+		var decl *attrDecl
+		for _, d := range decls {
+			if a.Space == "" && a.Local == d.name {
+				decl = d
+			}
+		}
+		if decl == nil {
+			v.problemf(e, "attribute %s is not allowed", a.Local)
+			continue
+		}
+		if problem := checkValue(a.Value, decl.typ); problem != "" {
+			v.problemf(e, "attribute %s: %s", a.Local, problem)
+		}
+	}
+	for _, d := range decls {
+		if _, ok := e.Attr(d.name); d.required && !ok {
+			v.problemf(e, "missing attribute %s", d.name)
+		}
+	}
+}
+
+func (v *validator) value(e *Element, value string, st *simpleType) {
+	if problem := checkValue(value, st); problem != "" {
+		v.problemf(e, "%s", problem)
+	}
+}
+
+// checkValue checks a value against a simple type and its bases. It returns
+// what is wrong, or "" when the value is valid.
+func checkValue(value string, st *simpleType) string {
+	if st.builtin == "" {
+		if problem := checkValue(value, st.base); problem != "" {
+			return problem
+		}
+	}
+	builtin := st
+	for builtin.builtin == "" {
+		builtin = builtin.base
+	}
+	switch builtin.builtin {
+	case "string":
+	case "normalizedString":
+		value = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(value)
+	default:
+		value = strings.Join(strings.Fields(value), " ")
+	}
+
+	if st.builtin != "" {
+		if re := builtins[st.builtin]; re != nil && !re.MatchString(value) {
+			return fmt.Sprintf("'%s' is not a valid %s", value, st.builtin)
+		}
+		return ""
+	}
+
+	if len(st.enums) > 0 {
+		found := false
+		for _, e := range st.enums {
+			found = found || e == value
+		}
+		if !found {
+			return fmt.Sprintf("'%s' is not one of %s", value, strings.Join(st.enums, ", "))
+		}
+	}
+	for _, re := range st.patterns {
+		if !re.MatchString(value) {
+			return fmt.Sprintf("'%s' does not match the pattern %s", value, strings.TrimSuffix(strings.TrimPrefix(re.String(), "^(?:"), ")$"))
+		}
+	}
+	n := len([]rune(value))
+	switch {
+	case st.length >= 0 && n != st.length:
+		return fmt.Sprintf("'%s' must be %d characters long", value, st.length)
+	case st.minLen >= 0 && n < st.minLen:
+		return fmt.Sprintf("'%s' must be at least %d characters long", value, st.minLen)
+	case st.maxLen >= 0 && n > st.maxLen:
+		return fmt.Sprintf("'%s' must be at most %d characters long", value, st.maxLen)
+	}
+	if st.fraction >= 0 {
+		if _, frac, ok := strings.Cut(value, "."); ok && len(strings.TrimRight(frac, "0")) > st.fraction {
+			return fmt.Sprintf("'%s' has more than %d decimals", value, st.fraction)
+		}
+	}
+	return ""
+}
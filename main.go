@@ -29,10 +29,42 @@ func main() {
 		err = cmd.RunInit()
 	case "invoice":
 		err = cmd.RunInvoice(os.Args[2:])
+	case "quote":
+		err = cmd.RunQuote(os.Args[2:])
+	case "customer":
+		err = cmd.RunCustomer(os.Args[2:])
+	case "product":
+		err = cmd.RunProduct(os.Args[2:])
+	case "expense":
+		err = cmd.RunExpense(os.Args[2:])
 	case "list":
 		err = cmd.RunList(os.Args[2:])
 	case "delete":
 		err = cmd.RunDelete(os.Args[2:])
+	case "credit":
+		err = cmd.RunCredit(os.Args[2:])
+	case "edit":
+		err = cmd.RunEdit(os.Args[2:])
+	case "render":
+		err = cmd.RunRender(os.Args[2:])
+	case "mark-sent":
+		err = cmd.RunMarkSent(os.Args[2:])
+	case "mark-paid":
+		err = cmd.RunMarkPaid(os.Args[2:])
+	case "void":
+		err = cmd.RunVoid(os.Args[2:])
+	case "payment":
+		err = cmd.RunPayment(os.Args[2:])
+	case "export":
+		err = cmd.RunExport(os.Args[2:])
+	case "send":
+		err = cmd.RunSend(os.Args[2:])
+	case "remind":
+		err = cmd.RunRemind(os.Args[2:])
+	case "late-fees":
+		err = cmd.RunLateFees(os.Args[2:])
+	case "recurring":
+		err = cmd.RunRecurring(os.Args[2:])
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", os.Args[1])
 		printUsage()
@@ -51,7 +83,22 @@ func printUsage() {
 	fmt.Println("Commands:")
 	fmt.Println("  init                              Initialize ~/.simplebill/ directory")
 	fmt.Println("  invoice <customer> <product:qty>  Generate an invoice")
+	fmt.Println("  quote <customer> <product:qty>    Create a quote, or accept one as an invoice")
+	fmt.Println("  customer add|edit|show|remove     Manage customers in customers.yml")
+	fmt.Println("  product add|edit|show|import      Manage products in products.yml")
+	fmt.Println("  expense add|list|remove           Record costs to bill on the next invoice")
 	fmt.Println("  list [type]                       List data (default: invoices)")
-	fmt.Println("  delete <invoice-number>           Delete an invoice")
+	fmt.Println("  delete <invoice-number>           Delete a draft invoice")
+	fmt.Println("  credit <invoice-number>           Issue a credit note for an invoice")
+	fmt.Println("  edit <invoice-number>             Edit a draft invoice in $EDITOR")
+	fmt.Println("  render <invoice-number>|--all     Rebuild PDFs from saved invoices")
+	fmt.Println("  mark-sent <invoice-number>        Mark an invoice as sent")
+	fmt.Println("  mark-paid <invoice-number>        Mark an invoice as paid")
+	fmt.Println("  void <invoice-number>             Void an invoice")
+	fmt.Println("  payment add|list                  Record and list payments")
+	fmt.Println("  export ubl|xrechnung <number>     Export an invoice as e-invoice XML")
+	fmt.Println("  send <invoice-number>             Email an invoice to the customer")
+	fmt.Println("  remind [--dry-run]                Send payment reminders for overdue invoices")
+	fmt.Println("  late-fees <invoice-number>        Work out and bill interest on a late invoice")
+	fmt.Println("  recurring run|list                Create invoices from recurring schedules")
 }
-